@@ -2,15 +2,44 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/app"
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file")
+	printConfig := flag.Bool("print-config", false, "print effective config with secrets redacted and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [migrate up | down [steps] | status]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *printConfig {
+		os.Exit(runPrintConfig(*configPath))
+	}
+
 	ctx := context.Background()
 
-	a, err := app.New(ctx)
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Panicf("failed to load config: %s", err)
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(ctx, cfg, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatalf("migrate: %s", err)
+		}
+
+		return
+	}
+
+	a, err := app.New(ctx, cfg)
 	if err != nil {
 		log.Panicf("failed to init app: %s", err)
 	}
@@ -22,3 +51,26 @@ func main() {
 		log.Panicf("failed to run app: %s", err)
 	}
 }
+
+func runPrintConfig(path string) int {
+	cfg, err := config.Read(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	if err := cfg.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	return 0
+}
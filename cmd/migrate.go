@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/app"
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+)
+
+var errMigrateUsage = errors.New("usage: app migrate up | down [steps] | status")
+
+func runMigrate(ctx context.Context, cfg *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	m, closeDB, err := app.NewMigrator(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errMigrateUsage
+			}
+		}
+
+		return m.Down(ctx, steps)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(out, "%04d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+
+		return nil
+	default:
+		return errMigrateUsage
+	}
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/service"
+)
+
+const defaultListLimit = 100
+
+var (
+	errUsage      = errors.New("invalid arguments, run with -h for usage")
+	errAborted    = errors.New("aborted")
+	errUnbalanced = errors.New("balances do not add up with the ledger")
+)
+
+type cli struct {
+	admin service.Admin
+	users service.UserManager
+
+	in  *bufio.Reader
+	out *printer
+	// prompts receives confirmation prompts, so that they do not mix with
+	// the output.
+	prompts io.Writer
+
+	dryRun bool
+	yes    bool
+}
+
+func (c *cli) run(ctx context.Context, args []string) error {
+	cmd, args := args[0], args[1:]
+
+	switch cmd {
+	case "users":
+		return c.listUsers(ctx, args)
+	case "user":
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		return c.showUser(ctx, args[0])
+	case "create-user":
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		return c.createUser(ctx, args[0])
+	case "mint", "burn":
+		if len(args) != 3 {
+			return errUsage
+		}
+
+		amount, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errUsage
+		}
+
+		return c.moveCoins(ctx, args[0], amount, args[2], cmd == "burn")
+	case "transfers":
+		return c.listTransfers(ctx, args)
+	case "reverse":
+		return c.reverseTransfer(ctx, args)
+	case "deactivate", "activate":
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		return c.setActive(ctx, args[0], cmd == "activate")
+	case "items":
+		if len(args) != 0 {
+			return errUsage
+		}
+
+		return c.listItems(ctx)
+	case "create-item":
+		if len(args) != 2 {
+			return errUsage
+		}
+
+		price, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errUsage
+		}
+
+		return c.createItem(ctx, args[0], price)
+	case "stock":
+		return c.setStock(ctx, args)
+	case "variants":
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		return c.listVariants(ctx, args[0])
+	case "create-variant":
+		return c.createVariant(ctx, args)
+	case "reconcile":
+		if len(args) != 0 {
+			return errUsage
+		}
+
+		return c.reconcile(ctx)
+	case "limits":
+		switch len(args) {
+		case 1:
+			return c.showTransferLimits(ctx, args[0])
+		case 4:
+			override, err := parseTransferLimits(args[1:])
+			if err != nil {
+				return errUsage
+			}
+
+			return c.setTransferLimits(ctx, args[0], override)
+		default:
+			return errUsage
+		}
+	default:
+		return fmt.Errorf("unknown command %q, run with -h for usage", cmd)
+	}
+}
+
+func (c *cli) listUsers(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("users", flag.ContinueOnError)
+	after := flags.String("after", "", "list users after this username")
+	limit := flags.Int("limit", defaultListLimit, "maximum number of users")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	users, err := c.admin.ListUsers(ctx, *after, *limit)
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(newUserList(users))
+}
+
+func (c *cli) showUser(ctx context.Context, username string) error {
+	user, err := c.admin.GetUser(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	info, err := c.users.Info(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(newUserDetails(user, info.Inventory))
+}
+
+// createUser registers a user, such as an admin, who cannot sign up by
+// logging in. The password is read from the first line of the input, so that
+// it does not show up in the shell history.
+func (c *cli) createUser(ctx context.Context, username string) error {
+	fmt.Fprint(c.prompts, "Password: ")
+
+	password, err := c.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read password: %w", err)
+	}
+
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return fmt.Errorf("%w: empty password", errUsage)
+	}
+
+	if c.dryRun {
+		fmt.Fprintln(c.prompts, "Dry run, nothing was changed.")
+
+		return nil
+	}
+
+	user, err := c.users.Create(ctx, username, password, "")
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(newUserList([]model.User{*user}))
+}
+
+func (c *cli) moveCoins(ctx context.Context, username string, amount int, reason string, burn bool) error {
+	move, action, delta := c.admin.Mint, "Mint", amount
+	if burn {
+		move, action, delta = c.admin.Burn, "Burn", -amount
+	}
+
+	return c.change(func(dryRun bool) (string, view, error) {
+		user, err := move(ctx, username, amount, reason, dryRun)
+		if err != nil {
+			return "", nil, err
+		}
+
+		prompt := fmt.Sprintf("%s %d coins for %s (%s), balance %d -> %d?",
+			action, amount, username, reason, user.Balance-delta, user.Balance)
+
+		return prompt, newUserList([]model.User{*user}), nil
+	})
+}
+
+func (c *cli) listTransfers(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("transfers", flag.ContinueOnError)
+	limit := flags.Int("limit", defaultListLimit, "maximum number of transfers")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	transfers, err := c.admin.ListUserTransfers(ctx, flags.Arg(0), *limit)
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(newTransferList(transfers))
+}
+
+func (c *cli) reverseTransfer(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reverse", flag.ContinueOnError)
+	partial := flags.Bool("partial", false, "reverse what the receiver has if they spent some of the coins")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		return errUsage
+	}
+
+	id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil {
+		return errUsage
+	}
+
+	reason := flags.Arg(1)
+
+	return c.change(func(dryRun bool) (string, view, error) {
+		transfer, err := c.admin.ReverseTransfer(ctx, id, reason, *partial, dryRun)
+		if err != nil {
+			return "", nil, err
+		}
+
+		prompt := fmt.Sprintf("Reverse transfer %d from %s to %s (%s), reversed %d of %d coins?",
+			id, transfer.FromUser, transfer.ToUser, reason, transfer.Reversed, transfer.Amount)
+
+		return prompt, newTransferList([]model.Transfer{*transfer}), nil
+	})
+}
+
+func (c *cli) setActive(ctx context.Context, username string, active bool) error {
+	return c.change(func(dryRun bool) (string, view, error) {
+		var (
+			user *model.User
+			err  error
+		)
+
+		if active {
+			user, err = c.admin.ActivateUser(ctx, username, dryRun)
+		} else {
+			user, err = c.admin.DeactivateUser(ctx, username, dryRun)
+		}
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		action := "Deactivate"
+		if active {
+			action = "Activate"
+		}
+
+		return fmt.Sprintf("%s %s?", action, username), newUserList([]model.User{*user}), nil
+	})
+}
+
+func (c *cli) listItems(ctx context.Context) error {
+	items, err := c.admin.ListItems(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(newItemList(items))
+}
+
+func (c *cli) createItem(ctx context.Context, name string, price int) error {
+	return c.change(func(dryRun bool) (string, view, error) {
+		item, err := c.admin.CreateItem(ctx, name, price, dryRun)
+		if err != nil {
+			return "", nil, err
+		}
+
+		prompt := fmt.Sprintf("Create item %s priced at %d coins?", name, price)
+
+		return prompt, newItemList([]model.Item{*item}), nil
+	})
+}
+
+// setStock sets the stock of an item, or of its variant if -size or -color
+// is given.
+func (c *cli) setStock(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("stock", flag.ContinueOnError)
+	size := flags.String("size", "", "size of the variant")
+	color := flags.String("color", "", "color of the variant")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		return errUsage
+	}
+
+	name := flags.Arg(0)
+
+	stock, err := parseStock(flags.Arg(1))
+	if err != nil {
+		return errUsage
+	}
+
+	attrs := model.VariantAttributes{Size: *size, Color: *color}
+	if attrs.IsZero() {
+		return c.setItemStock(ctx, name, stock)
+	}
+
+	return c.change(func(dryRun bool) (string, view, error) {
+		variant, err := c.admin.SetVariantStock(ctx, name, attrs, stock, dryRun)
+		if err != nil {
+			return "", nil, err
+		}
+
+		prompt := fmt.Sprintf("Set the stock of %s (%s) to %s?", name, attrs, formatStock(stock))
+
+		return prompt, newVariantList([]model.Variant{*variant}), nil
+	})
+}
+
+func (c *cli) setItemStock(ctx context.Context, name string, stock *int) error {
+	return c.change(func(dryRun bool) (string, view, error) {
+		item, err := c.admin.SetItemStock(ctx, name, stock, dryRun)
+		if err != nil {
+			return "", nil, err
+		}
+
+		prompt := fmt.Sprintf("Set the stock of %s to %s?", name, formatStock(stock))
+
+		return prompt, newItemList([]model.Item{*item}), nil
+	})
+}
+
+func (c *cli) listVariants(ctx context.Context, name string) error {
+	variants, err := c.admin.ListVariants(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(newVariantList(variants))
+}
+
+func (c *cli) createVariant(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("create-variant", flag.ContinueOnError)
+	size := flags.String("size", "", "size of the variant")
+	color := flags.String("color", "", "color of the variant")
+	delta := flags.Int("delta", 0, "coins added to the price of the item")
+	stockArg := flags.String("stock", "-", "number of items left, - never runs out")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	stock, err := parseStock(*stockArg)
+	if err != nil {
+		return errUsage
+	}
+
+	name := flags.Arg(0)
+	variant := model.Variant{
+		VariantAttributes: model.VariantAttributes{Size: *size, Color: *color},
+		PriceDelta:        *delta,
+		Stock:             stock,
+	}
+
+	return c.change(func(dryRun bool) (string, view, error) {
+		created, err := c.admin.CreateVariant(ctx, name, variant, dryRun)
+		if err != nil {
+			return "", nil, err
+		}
+
+		prompt := fmt.Sprintf("Add variant %s of %s priced %+d coins?", variant.VariantAttributes, name, *delta)
+
+		return prompt, newVariantList([]model.Variant{*created}), nil
+	})
+}
+
+// reconcile prints the reconciliation report and fails if the books do not
+// add up, so that it can run as a periodic check.
+func (c *cli) reconcile(ctx context.Context) error {
+	result, err := c.admin.Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+
+	balanced := result.Balanced()
+
+	if err := c.out.print(reconciliationView{Balanced: balanced, Reconciliation: result}); err != nil {
+		return err
+	}
+
+	if !balanced {
+		return errUnbalanced
+	}
+
+	return nil
+}
+
+func (c *cli) showTransferLimits(ctx context.Context, username string) error {
+	limits, err := c.admin.TransferLimits(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(transferLimitsView{limits})
+}
+
+func (c *cli) setTransferLimits(ctx context.Context, username string, override *model.TransferLimitsOverride) error {
+	return c.change(func(dryRun bool) (string, view, error) {
+		limits, err := c.admin.SetTransferLimits(ctx, username, override, dryRun)
+		if err != nil {
+			return "", nil, err
+		}
+
+		prompt := fmt.Sprintf("Override the transfer limits of %s?", username)
+		if limits.Override == nil {
+			prompt = fmt.Sprintf("Restore the global transfer limits of %s?", username)
+		}
+
+		return prompt, transferLimitsView{limits}, nil
+	})
+}
+
+// parseTransferLimits parses the maximum amount, daily amount and daily
+// count of an override, "-" keeps the global limit.
+func parseTransferLimits(args []string) (*model.TransferLimitsOverride, error) {
+	values := make([]*int, len(args))
+
+	for i, arg := range args {
+		if arg == "-" {
+			continue
+		}
+
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("parse limit %q: %w", arg, err)
+		}
+
+		values[i] = &v
+	}
+
+	return &model.TransferLimitsOverride{MaxAmount: values[0], DailyAmount: values[1], DailyCount: values[2]}, nil
+}
+
+// change runs apply as a dry run first, which validates the change and
+// describes it. With -dry-run it prints the outcome and stops there,
+// otherwise it asks for confirmation, unless -yes is set, and applies the
+// change.
+func (c *cli) change(apply func(dryRun bool) (string, view, error)) error {
+	prompt, preview, err := apply(true)
+	if err != nil {
+		return err
+	}
+
+	if c.dryRun {
+		fmt.Fprintln(c.prompts, "Dry run, nothing was changed.")
+
+		return c.out.print(preview)
+	}
+
+	if !c.yes {
+		ok, err := c.confirm(prompt)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return errAborted
+		}
+	}
+
+	_, result, err := apply(false)
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(result)
+}
+
+func (c *cli) confirm(prompt string) (bool, error) {
+	fmt.Fprintf(c.prompts, "%s [y/N] ", prompt)
+
+	answer, err := c.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("read answer: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// parseStock parses a number of items left, "-" means the stock never runs
+// out.
+func parseStock(s string) (*int, error) {
+	if s == "-" {
+		return nil, nil //nolint:nilnil // nil stock never runs out
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
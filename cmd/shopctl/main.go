@@ -0,0 +1,104 @@
+// Command shopctl runs operator tasks, such as minting coins or deactivating
+// users, against the storage configured for the shop.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/app"
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/di"
+)
+
+const usage = `usage: %s [flags] <command> [args]
+
+commands:
+  users [-after username] [-limit n]  list users and their balances
+  user <username>                     show the balance and inventory of a user
+  create-user <username>              register a user, such as an admin, with the password
+                                      read from the input
+  mint <username> <amount> <reason>   credit coins to a user from the system account
+  burn <username> <amount> <reason>   debit coins from a user to the system account
+  transfers [-limit n] <username>     list the transfers a user sent or received, newest first
+  reverse [-partial] <id> <reason>    move the coins of a transfer back to the sender,
+                                      -partial reverses what the receiver has left
+  deactivate <username>               stop a user from logging in, buying and transferring
+  activate <username>                 revert deactivate
+  items                               list the item catalog
+  create-item <name> <price>          add an item to the catalog
+  stock [-size s] [-color c] <name> <n|->
+                                      set the number of items, or of a variant, left,
+                                      - never runs out
+  variants <name>                     list the variants of an item
+  create-variant [-size s] [-color c] [-delta n] [-stock n] <name>
+                                      add a variant to an item, priced delta coins more
+  reconcile                           check that the balances add up with the ledger
+  limits <username> [<max> <daily-amount> <daily-count>]
+                                      show or override the transfer limits of a user,
+                                      0 removes a limit and - keeps the global one
+
+flags:
+`
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file")
+	dryRun := flag.Bool("dry-run", false, "show what a change would do without applying it")
+	yes := flag.Bool("yes", false, "apply changes without asking for confirmation")
+	output := flag.String("output", formatTable, "output format, table or json")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || (*output != formatTable && *output != formatJSON) {
+		flag.Usage()
+
+		return 2
+	}
+
+	ctx := context.Background()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load config: %s\n", err)
+
+		return 1
+	}
+
+	repo, closeRepo, err := app.NewRepository(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+	defer closeRepo()
+
+	container := di.New(cfg, repo)
+
+	c := &cli{
+		admin:   container.Admin(),
+		users:   container.Users(),
+		in:      bufio.NewReader(os.Stdin),
+		out:     &printer{w: os.Stdout, format: *output},
+		prompts: os.Stderr,
+		dryRun:  *dryRun,
+		yes:     *yes,
+	}
+
+	if err := c.run(ctx, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	return 0
+}
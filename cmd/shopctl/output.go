@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// view is a command result, encoded as is in JSON or printed as tables.
+type view interface {
+	tables() []table
+}
+
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func (p *printer) print(v view) error {
+	if p.format == formatJSON {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	}
+
+	for i, t := range v.tables() {
+		if i > 0 {
+			fmt.Fprintln(p.w)
+		}
+
+		tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type userView struct {
+	Username      string     `json:"username"`
+	Balance       int        `json:"balance"`
+	Active        bool       `json:"active"`
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty"`
+}
+
+func newUserView(user *model.User) userView {
+	return userView{
+		Username:      user.Username,
+		Balance:       user.Balance,
+		Active:        user.Active(),
+		DeactivatedAt: user.DeactivatedAt,
+	}
+}
+
+func (u userView) row() []string {
+	deactivatedAt := "-"
+	if u.DeactivatedAt != nil {
+		deactivatedAt = u.DeactivatedAt.Format(time.RFC3339)
+	}
+
+	return []string{u.Username, strconv.Itoa(u.Balance), strconv.FormatBool(u.Active), deactivatedAt}
+}
+
+var userHeader = []string{"USERNAME", "BALANCE", "ACTIVE", "DEACTIVATED AT"}
+
+type userList []userView
+
+func newUserList(users []model.User) userList {
+	list := make(userList, 0, len(users))
+	for i := range users {
+		list = append(list, newUserView(&users[i]))
+	}
+
+	return list
+}
+
+func (l userList) tables() []table {
+	t := table{header: userHeader}
+	for _, u := range l {
+		t.rows = append(t.rows, u.row())
+	}
+
+	return []table{t}
+}
+
+type inventoryView struct {
+	Type     string `json:"type"`
+	Size     string `json:"size,omitempty"`
+	Color    string `json:"color,omitempty"`
+	Quantity int    `json:"quantity"`
+}
+
+type userDetails struct {
+	userView
+	Inventory []inventoryView `json:"inventory"`
+}
+
+func newUserDetails(user *model.User, inventory []model.Inventory) userDetails {
+	d := userDetails{
+		userView:  newUserView(user),
+		Inventory: make([]inventoryView, 0, len(inventory)),
+	}
+
+	for _, inv := range inventory {
+		d.Inventory = append(d.Inventory, inventoryView{
+			Type:     inv.Type,
+			Size:     inv.Size,
+			Color:    inv.Color,
+			Quantity: inv.Quantity,
+		})
+	}
+
+	return d
+}
+
+func (d userDetails) tables() []table {
+	inventory := table{header: []string{"ITEM", "VARIANT", "QUANTITY"}}
+	for _, inv := range d.Inventory {
+		variant := model.VariantAttributes{Size: inv.Size, Color: inv.Color}
+		inventory.rows = append(inventory.rows, []string{inv.Type, variant.String(), strconv.Itoa(inv.Quantity)})
+	}
+
+	return []table{{header: userHeader, rows: [][]string{d.row()}}, inventory}
+}
+
+type itemView struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+	Stock *int   `json:"stock"`
+}
+
+type itemList []itemView
+
+func newItemList(items []model.Item) itemList {
+	list := make(itemList, 0, len(items))
+	for _, item := range items {
+		list = append(list, itemView{Name: item.Name, Price: item.Price, Stock: item.Stock})
+	}
+
+	return list
+}
+
+func (l itemList) tables() []table {
+	t := table{header: []string{"NAME", "PRICE", "STOCK"}}
+	for _, item := range l {
+		t.rows = append(t.rows, []string{item.Name, strconv.Itoa(item.Price), formatStock(item.Stock)})
+	}
+
+	return []table{t}
+}
+
+type variantView struct {
+	Size       string `json:"size,omitempty"`
+	Color      string `json:"color,omitempty"`
+	PriceDelta int    `json:"priceDelta"`
+	Stock      *int   `json:"stock"`
+}
+
+type variantList []variantView
+
+func newVariantList(variants []model.Variant) variantList {
+	list := make(variantList, 0, len(variants))
+	for _, v := range variants {
+		list = append(list, variantView{Size: v.Size, Color: v.Color, PriceDelta: v.PriceDelta, Stock: v.Stock})
+	}
+
+	return list
+}
+
+func (l variantList) tables() []table {
+	t := table{header: []string{"SIZE", "COLOR", "PRICE DELTA", "STOCK"}}
+	for _, v := range l {
+		t.rows = append(t.rows, []string{v.Size, v.Color, strconv.Itoa(v.PriceDelta), formatStock(v.Stock)})
+	}
+
+	return []table{t}
+}
+
+type transferView struct {
+	ID        int64     `json:"id"`
+	FromUser  string    `json:"fromUser"`
+	ToUser    string    `json:"toUser"`
+	Amount    int       `json:"amount"`
+	Memo      string    `json:"memo,omitempty"`
+	Reversed  int       `json:"reversed"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type transferList []transferView
+
+func newTransferList(transfers []model.Transfer) transferList {
+	list := make(transferList, 0, len(transfers))
+	for _, t := range transfers {
+		list = append(list, transferView{
+			ID:        t.ID,
+			FromUser:  t.FromUser,
+			ToUser:    t.ToUser,
+			Amount:    t.Amount,
+			Memo:      t.Memo,
+			Reversed:  t.Reversed,
+			CreatedAt: t.CreatedAt,
+		})
+	}
+
+	return list
+}
+
+func (l transferList) tables() []table {
+	t := table{header: []string{"ID", "FROM", "TO", "AMOUNT", "REVERSED", "CREATED AT", "MEMO"}}
+	for _, v := range l {
+		t.rows = append(t.rows, []string{
+			strconv.FormatInt(v.ID, 10),
+			v.FromUser,
+			v.ToUser,
+			strconv.Itoa(v.Amount),
+			strconv.Itoa(v.Reversed),
+			v.CreatedAt.Format(time.RFC3339),
+			v.Memo,
+		})
+	}
+
+	return []table{t}
+}
+
+// formatStock prints nil stock, which never runs out, as "unlimited".
+func formatStock(stock *int) string {
+	if stock == nil {
+		return "unlimited"
+	}
+
+	return strconv.Itoa(*stock)
+}
+
+type reconciliationView struct {
+	Balanced bool `json:"balanced"`
+	*model.Reconciliation
+}
+
+func (r reconciliationView) tables() []table {
+	totals := table{
+		header: []string{"BALANCED", "ISSUED", "BURNED", "BALANCES", "HELD"},
+		rows: [][]string{{
+			strconv.FormatBool(r.Balanced),
+			strconv.FormatInt(r.Issued, 10),
+			strconv.FormatInt(r.Burned, 10),
+			strconv.FormatInt(r.Balances, 10),
+			strconv.FormatInt(r.Held, 10),
+		}},
+	}
+
+	if len(r.Discrepancies) == 0 {
+		return []table{totals}
+	}
+
+	discrepancies := table{header: []string{"USERNAME", "BALANCE", "EXPECTED"}}
+	for _, d := range r.Discrepancies {
+		discrepancies.rows = append(discrepancies.rows, []string{
+			d.Username, strconv.FormatInt(d.Balance, 10), strconv.FormatInt(d.Expected, 10),
+		})
+	}
+
+	return []table{totals, discrepancies}
+}
+
+type transferLimitsView struct {
+	*model.UserTransferLimits
+}
+
+func (v transferLimitsView) tables() []table {
+	limit := func(value int, override *int) string {
+		s := "unlimited"
+		if value > 0 {
+			s = strconv.Itoa(value)
+		}
+
+		if override != nil {
+			s += " (override)"
+		}
+
+		return s
+	}
+
+	var o model.TransferLimitsOverride
+	if v.Override != nil {
+		o = *v.Override
+	}
+
+	return []table{{
+		header: []string{"USERNAME", "MAX AMOUNT", "DAILY AMOUNT", "DAILY COUNT"},
+		rows: [][]string{{
+			v.Username,
+			limit(v.Limits.MaxAmount, o.MaxAmount),
+			limit(v.Limits.DailyAmount, o.DailyAmount),
+			limit(v.Limits.DailyCount, o.DailyCount),
+		}},
+	}}
+}
@@ -2,55 +2,141 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/config"
 	"github.com/esklo/avito-backend-winter-2025/internal/di"
+	"github.com/esklo/avito-backend-winter-2025/internal/grpc"
 	"github.com/esklo/avito-backend-winter-2025/internal/http"
+	"github.com/esklo/avito-backend-winter-2025/internal/migrate"
 	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/esklo/avito-backend-winter-2025/migrations"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var ErrMigrationsUnsupported = errors.New("migrations are not supported by db driver")
+
+// server is a network server that runs until its context is done.
+type server interface {
+	Run(ctx context.Context) error
+}
+
 type App struct {
 	cfg       *config.Config
-	db        *pgxpool.Pool
+	closeRepo func()
 	container *di.Container
 }
 
-func New(ctx context.Context) (*App, error) {
-	cfg, err := config.Load()
+func New(ctx context.Context, cfg *config.Config) (*App, error) {
+	repo, closeRepo, err := NewRepository(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("load config: %w", err)
+		return nil, err
+	}
+
+	return &App{
+		cfg:       cfg,
+		closeRepo: closeRepo,
+		container: di.New(cfg, repo),
+	}, nil
+}
+
+// NewRepository connects to the configured storage, migrating the database
+// if auto migration is on. The returned function closes the connection.
+func NewRepository(ctx context.Context, cfg *config.Config) (repository.Repository, func(), error) {
+	if cfg.DB.Driver == config.DriverMemory {
+		slog.Warn("using in-memory storage, data will be lost on restart")
+
+		return memory.New(), func() {}, nil
 	}
 
 	db, err := initDB(ctx, cfg.DB)
 	if err != nil {
-		return nil, fmt.Errorf("init database: %w", err)
+		return nil, nil, fmt.Errorf("init database: %w", err)
 	}
 
-	repo := repository.New(db)
+	if cfg.DB.AutoMigrate {
+		if err := migrateUp(ctx, db); err != nil {
+			db.Close()
 
-	container := di.New(cfg, repo)
+			return nil, nil, fmt.Errorf("migrate database: %w", err)
+		}
+	}
 
-	return &App{
-		cfg:       cfg,
-		db:        db,
-		container: container,
-	}, nil
+	return repository.New(db), db.Close, nil
 }
 
 func (a *App) Run(ctx context.Context) error {
-	server := http.NewServer(a.container)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go a.container.Relay().Run(ctx)
+	go a.container.Dispatcher().Run(ctx)
+	go a.container.Listener().Run(ctx)
+	go a.container.Scheduler().Run(ctx)
+
+	servers := []server{
+		http.NewServer(a.container),
+		grpc.NewServer(a.container),
+	}
+
+	errs := make(chan error, len(servers))
+
+	for _, srv := range servers {
+		go func() { errs <- srv.Run(ctx) }()
+	}
 
-	return server.Run(ctx)
+	// Either server stopping stops the other one as well.
+	err := <-errs
+
+	cancel()
+
+	for range len(servers) - 1 {
+		err = errors.Join(err, <-errs)
+	}
+
+	return err
 }
 
 func (a *App) Shutdown() error {
-	a.db.Close()
+	a.closeRepo()
 
 	return nil
 }
 
+// NewMigrator connects to the database and returns a migrator for the embedded
+// migrations. The returned function closes the connection.
+func NewMigrator(ctx context.Context, cfg *config.Config) (*migrate.Migrator, func(), error) {
+	if cfg.DB.Driver != config.DriverPostgres {
+		return nil, nil, fmt.Errorf("%w: %s", ErrMigrationsUnsupported, cfg.DB.Driver)
+	}
+
+	db, err := initDB(ctx, cfg.DB)
+	if err != nil {
+		return nil, nil, fmt.Errorf("init database: %w", err)
+	}
+
+	m, err := migrate.New(db, migrations.FS, slog.Default())
+	if err != nil {
+		db.Close()
+
+		return nil, nil, err
+	}
+
+	return m, db.Close, nil
+}
+
+func migrateUp(ctx context.Context, db *pgxpool.Pool) error {
+	m, err := migrate.New(db, migrations.FS, slog.Default())
+	if err != nil {
+		return err
+	}
+
+	return m.Up(ctx)
+}
+
 func initDB(ctx context.Context, cfg config.DBConfig) (*pgxpool.Pool, error) {
 	db, err := pgxpool.New(ctx, cfg.DSN())
 	if err != nil {
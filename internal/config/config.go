@@ -1,42 +1,560 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"time"
 
+	"github.com/esklo/avito-backend-winter-2025/internal/cron"
 	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	minJWTSecretLength = 32
+	redacted           = "[redacted]"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+const (
+	SinkBus     = "bus"
+	SinkFile    = "file"
+	SinkWebhook = "webhook"
 )
 
 type Config struct {
-	App  AppConfig
-	HTTP HTTPConfig
-	DB   DBConfig
+	App       AppConfig       `yaml:"app"`
+	HTTP      HTTPConfig      `yaml:"http"`
+	GRPC      GRPCConfig      `yaml:"grpc"`
+	DB        DBConfig        `yaml:"db"`
+	Events    EventsConfig    `yaml:"events"`
+	Webhooks  WebhooksConfig  `yaml:"webhooks"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+	Allowance AllowanceConfig `yaml:"allowance"`
+	Signup    SignupConfig    `yaml:"signup"`
+	Transfers TransfersConfig `yaml:"transfers"`
+
+	PendingTransfers PendingTransfersConfig `yaml:"pending_transfers"`
+	PaymentRequests  PaymentRequestsConfig  `yaml:"payment_requests"`
+
+	ScheduledTransfers ScheduledTransfersConfig `yaml:"scheduled_transfers"`
 }
 
 type AppConfig struct {
-	JWTSecret []byte `envconfig:"JWT_SECRET"`
+	JWTSecret Secret `envconfig:"JWT_SECRET" yaml:"jwt_secret"`
+
+	// Admins lists the usernames allowed to use the admin API.
+	Admins []string `envconfig:"ADMIN_USERS" yaml:"admins"`
 }
 
 type HTTPConfig struct {
-	Host string `envconfig:"HTTP_HOST"`
-	Port int    `envconfig:"HTTP_PORT"`
+	Host string `envconfig:"HTTP_HOST" yaml:"host"`
+	Port int    `envconfig:"HTTP_PORT" yaml:"port"`
+}
+
+type GRPCConfig struct {
+	Host string `envconfig:"GRPC_HOST" yaml:"host"`
+	Port int    `envconfig:"GRPC_PORT" yaml:"port"`
 }
 
 type DBConfig struct {
-	Host     string `envconfig:"DB_HOST"`
-	Port     int    `envconfig:"DB_PORT"`
-	Name     string `envconfig:"DB_NAME"`
-	User     string `envconfig:"DB_USER"`
-	Password string `envconfig:"DB_PASSWORD"`
+	// Driver selects the storage backend, either postgres or memory. The
+	// in-memory backend loses all data on restart and is meant for tests and
+	// local development.
+	Driver string `envconfig:"DB_DRIVER" yaml:"driver"`
+
+	Host     string `envconfig:"DB_HOST"     yaml:"host"`
+	Port     int    `envconfig:"DB_PORT"     yaml:"port"`
+	Name     string `envconfig:"DB_NAME"     yaml:"name"`
+	User     string `envconfig:"DB_USER"     yaml:"user"`
+	Password Secret `envconfig:"DB_PASSWORD" yaml:"password"`
+
+	// AutoMigrate applies pending migrations on startup.
+	AutoMigrate bool `envconfig:"DB_AUTO_MIGRATE" yaml:"auto_migrate"`
+}
+
+type EventsConfig struct {
+	// Sinks lists where the outbox relay publishes domain events: the
+	// in-process bus, an NDJSON file and/or an HTTP webhook.
+	Sinks        []string      `envconfig:"EVENTS_SINKS"         yaml:"sinks"`
+	FilePath     string        `envconfig:"EVENTS_FILE_PATH"     yaml:"file_path"`
+	WebhookURL   string        `envconfig:"EVENTS_WEBHOOK_URL"   yaml:"webhook_url"`
+	PollInterval time.Duration `envconfig:"EVENTS_POLL_INTERVAL" yaml:"poll_interval"`
+	BatchSize    int           `envconfig:"EVENTS_BATCH_SIZE"    yaml:"batch_size"`
+}
+
+type WebhooksConfig struct {
+	PollInterval time.Duration `envconfig:"WEBHOOKS_POLL_INTERVAL" yaml:"poll_interval"`
+	BatchSize    int           `envconfig:"WEBHOOKS_BATCH_SIZE"    yaml:"batch_size"`
+	Timeout      time.Duration `envconfig:"WEBHOOKS_TIMEOUT"       yaml:"timeout"`
+
+	// MaxAttempts is the number of attempts after which a delivery is marked
+	// dead. The delay between attempts doubles from BaseBackoff up to MaxBackoff.
+	MaxAttempts int           `envconfig:"WEBHOOKS_MAX_ATTEMPTS" yaml:"max_attempts"`
+	BaseBackoff time.Duration `envconfig:"WEBHOOKS_BASE_BACKOFF" yaml:"base_backoff"`
+	MaxBackoff  time.Duration `envconfig:"WEBHOOKS_MAX_BACKOFF"  yaml:"max_backoff"`
+}
+
+type SchedulerConfig struct {
+	// PollInterval is how often every replica checks for due jobs.
+	PollInterval time.Duration `envconfig:"SCHEDULER_POLL_INTERVAL" yaml:"poll_interval"`
+}
+
+type AllowanceConfig struct {
+	// Enabled turns on the allowance, a grant of Amount coins to every active
+	// user at the times of Schedule, a cron expression in UTC.
+	Enabled  bool   `envconfig:"ALLOWANCE_ENABLED"  yaml:"enabled"`
+	Amount   int    `envconfig:"ALLOWANCE_AMOUNT"   yaml:"amount"`
+	Schedule string `envconfig:"ALLOWANCE_SCHEDULE" yaml:"schedule"`
+}
+
+type SignupConfig struct {
+	// WelcomeGrant is the starting balance of a new user, issued by the
+	// system account.
+	WelcomeGrant int `envconfig:"SIGNUP_WELCOME_GRANT" yaml:"welcome_grant"`
+
+	// ReferralBonus is credited to both the referrer and a new user who
+	// registers with their referral code. A referrer is rewarded for at most
+	// ReferralLimit referrals per ReferralWindow, further users still register
+	// but nobody gets the bonus.
+	ReferralBonus  int           `envconfig:"SIGNUP_REFERRAL_BONUS"  yaml:"referral_bonus"`
+	ReferralLimit  int           `envconfig:"SIGNUP_REFERRAL_LIMIT"  yaml:"referral_limit"`
+	ReferralWindow time.Duration `envconfig:"SIGNUP_REFERRAL_WINDOW" yaml:"referral_window"`
+}
+
+type TransfersConfig struct {
+	// MaxAmount bounds a single transfer, DailyAmount and DailyCount the
+	// coins and transfers a user sends per UTC day. Zero turns a limit off.
+	// Admins may override the limits per user.
+	MaxAmount   int `envconfig:"TRANSFERS_MAX_AMOUNT"   yaml:"max_amount"`
+	DailyAmount int `envconfig:"TRANSFERS_DAILY_AMOUNT" yaml:"daily_amount"`
+	DailyCount  int `envconfig:"TRANSFERS_DAILY_COUNT"  yaml:"daily_count"`
 }
 
-func Load() (*Config, error) {
-	var cfg Config
+type PendingTransfersConfig struct {
+	// TTL is how long the receiver has to accept a pending transfer. The
+	// sweeper returns the coins of expired transfers to their senders at the
+	// times of Schedule, a cron expression in UTC.
+	TTL      time.Duration `envconfig:"PENDING_TRANSFERS_TTL"      yaml:"ttl"`
+	Schedule string        `envconfig:"PENDING_TRANSFERS_SCHEDULE" yaml:"schedule"`
+	// HoldAbove and HoldNewReceivers make transfers pending on their own:
+	// those of more than HoldAbove coins, 0 turns it off, and the first
+	// transfer of a sender to a receiver.
+	HoldAbove        int  `envconfig:"PENDING_TRANSFERS_HOLD_ABOVE"         yaml:"hold_above"`
+	HoldNewReceivers bool `envconfig:"PENDING_TRANSFERS_HOLD_NEW_RECEIVERS" yaml:"hold_new_receivers"`
+}
+
+type PaymentRequestsConfig struct {
+	// TTL is how long the payer has to pay a payment request.
+	TTL time.Duration `envconfig:"PAYMENT_REQUESTS_TTL" yaml:"ttl"`
+}
+
+type ScheduledTransfersConfig struct {
+	// Schedule is a cron expression in UTC of the worker that makes due
+	// scheduled transfers. MaxPerUser bounds the active scheduled transfers
+	// of a user.
+	Schedule   string `envconfig:"SCHEDULED_TRANSFERS_SCHEDULE"     yaml:"schedule"`
+	MaxPerUser int    `envconfig:"SCHEDULED_TRANSFERS_MAX_PER_USER" yaml:"max_per_user"`
+}
 
-	if err := envconfig.Process("", &cfg); err != nil {
+// Secret is a sensitive value that is never written out when the config is printed.
+type Secret []byte
+
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = append((*s)[:0], text...)
+
+	return nil
+}
+
+func (s Secret) MarshalYAML() (any, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+
+	return redacted, nil
+}
+
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Host: "0.0.0.0",
+			Port: 8080,
+		},
+		GRPC: GRPCConfig{
+			Host: "0.0.0.0",
+			Port: 9090,
+		},
+		DB: DBConfig{
+			Driver:      DriverPostgres,
+			Port:        5432,
+			AutoMigrate: true,
+		},
+		Events: EventsConfig{
+			Sinks:        []string{SinkBus},
+			PollInterval: time.Second,
+			BatchSize:    100,
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval: time.Second,
+			BatchSize:    50,
+			Timeout:      10 * time.Second,
+			MaxAttempts:  8,
+			BaseBackoff:  5 * time.Second,
+			MaxBackoff:   time.Hour,
+		},
+		Scheduler: SchedulerConfig{
+			PollInterval: time.Minute,
+		},
+		Allowance: AllowanceConfig{
+			Amount:   100,
+			Schedule: "@monthly",
+		},
+		Signup: SignupConfig{
+			WelcomeGrant:   1000,
+			ReferralBonus:  100,
+			ReferralLimit:  10,
+			ReferralWindow: 30 * 24 * time.Hour,
+		},
+		PendingTransfers: PendingTransfersConfig{
+			TTL:      72 * time.Hour,
+			Schedule: "* * * * *",
+		},
+		PaymentRequests: PaymentRequestsConfig{
+			TTL: 7 * 24 * time.Hour,
+		},
+		ScheduledTransfers: ScheduledTransfersConfig{
+			Schedule:   "* * * * *",
+			MaxPerUser: 20,
+		},
+	}
+}
+
+// Load reads the configuration and validates it.
+func Load(path string) (*Config, error) {
+	cfg, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Read builds the configuration from defaults, the optional YAML file at path
+// and environment variables, in that order of precedence.
+func Read(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := envconfig.Process("", cfg); err != nil {
 		return nil, fmt.Errorf("process env vars: %w", err)
 	}
 
-	return &cfg, nil
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decode config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate reports every invalid field at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if len(c.App.JWTSecret) < minJWTSecretLength {
+		errs = append(errs, fmt.Errorf(
+			"app.jwt_secret (JWT_SECRET): must be at least %d bytes long, got %d",
+			minJWTSecretLength, len(c.App.JWTSecret),
+		))
+	}
+
+	errs = append(errs, validatePort("http.port (HTTP_PORT)", c.HTTP.Port))
+	errs = append(errs, validatePort("grpc.port (GRPC_PORT)", c.GRPC.Port))
+
+	if c.GRPC.Port == c.HTTP.Port {
+		errs = append(errs, fmt.Errorf("grpc.port (GRPC_PORT): must differ from http.port, got %d", c.GRPC.Port))
+	}
+
+	switch c.DB.Driver {
+	case DriverPostgres:
+		errs = append(errs, c.DB.validatePostgres()...)
+	case DriverMemory:
+	default:
+		errs = append(errs, fmt.Errorf(
+			"db.driver (DB_DRIVER): must be %q or %q, got %q",
+			DriverPostgres, DriverMemory, c.DB.Driver,
+		))
+	}
+
+	errs = append(errs, c.Events.validate()...)
+	errs = append(errs, c.Webhooks.validate()...)
+
+	if c.Scheduler.PollInterval <= 0 {
+		errs = append(errs, fmt.Errorf(
+			"scheduler.poll_interval (SCHEDULER_POLL_INTERVAL): must be positive, got %s", c.Scheduler.PollInterval,
+		))
+	}
+
+	if c.Allowance.Enabled {
+		errs = append(errs, c.Allowance.validate()...)
+	}
+
+	errs = append(errs, c.Signup.validate()...)
+	errs = append(errs, c.Transfers.validate()...)
+	errs = append(errs, c.PendingTransfers.validate()...)
+	errs = append(errs, c.PaymentRequests.validate()...)
+	errs = append(errs, c.ScheduledTransfers.validate()...)
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	return nil
+}
+
+func (c *DBConfig) validatePostgres() []error {
+	var errs []error
+
+	if c.Host == "" {
+		errs = append(errs, errors.New("db.host (DB_HOST): is required"))
+	}
+
+	errs = append(errs, validatePort("db.port (DB_PORT)", c.Port))
+
+	if c.Name == "" {
+		errs = append(errs, errors.New("db.name (DB_NAME): is required"))
+	}
+
+	if c.User == "" {
+		errs = append(errs, errors.New("db.user (DB_USER): is required"))
+	}
+
+	return errs
+}
+
+func (c *EventsConfig) validate() []error {
+	var errs []error
+
+	for _, sink := range c.Sinks {
+		switch sink {
+		case SinkBus:
+		case SinkFile:
+			if c.FilePath == "" {
+				errs = append(errs, errors.New("events.file_path (EVENTS_FILE_PATH): is required by the file sink"))
+			}
+		case SinkWebhook:
+			if c.WebhookURL == "" {
+				errs = append(errs, errors.New("events.webhook_url (EVENTS_WEBHOOK_URL): is required by the webhook sink"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf(
+				"events.sinks (EVENTS_SINKS): unknown sink %q, must be %q, %q or %q",
+				sink, SinkBus, SinkFile, SinkWebhook,
+			))
+		}
+	}
+
+	if c.PollInterval <= 0 {
+		errs = append(errs, fmt.Errorf("events.poll_interval (EVENTS_POLL_INTERVAL): must be positive, got %s", c.PollInterval))
+	}
+
+	if c.BatchSize < 1 {
+		errs = append(errs, fmt.Errorf("events.batch_size (EVENTS_BATCH_SIZE): must be positive, got %d", c.BatchSize))
+	}
+
+	return errs
+}
+
+func (c *WebhooksConfig) validate() []error {
+	var errs []error
+
+	durations := []struct {
+		field string
+		value time.Duration
+	}{
+		{"webhooks.poll_interval (WEBHOOKS_POLL_INTERVAL)", c.PollInterval},
+		{"webhooks.timeout (WEBHOOKS_TIMEOUT)", c.Timeout},
+		{"webhooks.base_backoff (WEBHOOKS_BASE_BACKOFF)", c.BaseBackoff},
+	}
+
+	for _, d := range durations {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s: must be positive, got %s", d.field, d.value))
+		}
+	}
+
+	if c.MaxBackoff < c.BaseBackoff {
+		errs = append(errs, fmt.Errorf(
+			"webhooks.max_backoff (WEBHOOKS_MAX_BACKOFF): must not be less than base_backoff %s, got %s",
+			c.BaseBackoff, c.MaxBackoff,
+		))
+	}
+
+	if c.BatchSize < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.batch_size (WEBHOOKS_BATCH_SIZE): must be positive, got %d", c.BatchSize))
+	}
+
+	if c.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.max_attempts (WEBHOOKS_MAX_ATTEMPTS): must be positive, got %d", c.MaxAttempts))
+	}
+
+	return errs
+}
+
+func (c *AllowanceConfig) validate() []error {
+	var errs []error
+
+	if c.Amount < 1 {
+		errs = append(errs, fmt.Errorf("allowance.amount (ALLOWANCE_AMOUNT): must be positive, got %d", c.Amount))
+	}
+
+	if _, err := cron.Parse(c.Schedule); err != nil {
+		errs = append(errs, fmt.Errorf("allowance.schedule (ALLOWANCE_SCHEDULE): %w", err))
+	}
+
+	return errs
+}
+
+func (c *SignupConfig) validate() []error {
+	var errs []error
+
+	amounts := []struct {
+		field string
+		value int
+	}{
+		{"signup.welcome_grant (SIGNUP_WELCOME_GRANT)", c.WelcomeGrant},
+		{"signup.referral_bonus (SIGNUP_REFERRAL_BONUS)", c.ReferralBonus},
+		{"signup.referral_limit (SIGNUP_REFERRAL_LIMIT)", c.ReferralLimit},
+	}
+
+	for _, a := range amounts {
+		if a.value < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative, got %d", a.field, a.value))
+		}
+	}
+
+	if c.ReferralWindow <= 0 {
+		errs = append(errs, fmt.Errorf(
+			"signup.referral_window (SIGNUP_REFERRAL_WINDOW): must be positive, got %s", c.ReferralWindow,
+		))
+	}
+
+	return errs
+}
+
+func (c *TransfersConfig) validate() []error {
+	var errs []error
+
+	limits := []struct {
+		field string
+		value int
+	}{
+		{"transfers.max_amount (TRANSFERS_MAX_AMOUNT)", c.MaxAmount},
+		{"transfers.daily_amount (TRANSFERS_DAILY_AMOUNT)", c.DailyAmount},
+		{"transfers.daily_count (TRANSFERS_DAILY_COUNT)", c.DailyCount},
+	}
+
+	for _, l := range limits {
+		if l.value < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative, got %d", l.field, l.value))
+		}
+	}
+
+	return errs
+}
+
+func (c *PendingTransfersConfig) validate() []error {
+	var errs []error
+
+	if c.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("pending_transfers.ttl (PENDING_TRANSFERS_TTL): must be positive, got %s", c.TTL))
+	}
+
+	if _, err := cron.Parse(c.Schedule); err != nil {
+		errs = append(errs, fmt.Errorf("pending_transfers.schedule (PENDING_TRANSFERS_SCHEDULE): %w", err))
+	}
+
+	if c.HoldAbove < 0 {
+		errs = append(errs, fmt.Errorf(
+			"pending_transfers.hold_above (PENDING_TRANSFERS_HOLD_ABOVE): must not be negative, got %d", c.HoldAbove,
+		))
+	}
+
+	return errs
+}
+
+func (c *PaymentRequestsConfig) validate() []error {
+	var errs []error
+
+	if c.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("payment_requests.ttl (PAYMENT_REQUESTS_TTL): must be positive, got %s", c.TTL))
+	}
+
+	return errs
+}
+
+func (c *ScheduledTransfersConfig) validate() []error {
+	var errs []error
+
+	if _, err := cron.Parse(c.Schedule); err != nil {
+		errs = append(errs, fmt.Errorf("scheduled_transfers.schedule (SCHEDULED_TRANSFERS_SCHEDULE): %w", err))
+	}
+
+	if c.MaxPerUser < 1 {
+		errs = append(errs, fmt.Errorf(
+			"scheduled_transfers.max_per_user (SCHEDULED_TRANSFERS_MAX_PER_USER): must be positive, got %d",
+			c.MaxPerUser,
+		))
+	}
+
+	return errs
+}
+
+func validatePort(field string, port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%s: must be between 1 and 65535, got %d", field, port)
+	}
+
+	return nil
+}
+
+// Print writes the effective configuration as YAML with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(c); err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+
+	return enc.Close()
 }
 
 func (c *DBConfig) DSN() string {
@@ -49,3 +567,7 @@ func (c *DBConfig) DSN() string {
 func (c *HTTPConfig) Address() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
+
+func (c *GRPCConfig) Address() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validConfig() *Config {
+	cfg := Default()
+	cfg.App.JWTSecret = Secret("0123456789abcdef0123456789abcdef")
+	cfg.DB.Host = "db"
+	cfg.DB.Name = "shop"
+	cfg.DB.User = "postgres"
+	cfg.DB.Password = Secret("password")
+
+	return cfg
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid config", func(t *testing.T) {
+		t.Parallel()
+		assert.NoError(t, validConfig().Validate())
+	})
+
+	t.Run("reports every invalid field", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.App.JWTSecret = Secret("short")
+		cfg.HTTP.Port = 0
+		cfg.GRPC.Port = 0
+		cfg.DB.Port = 70000
+		cfg.DB.Host = ""
+		cfg.DB.Name = ""
+		cfg.DB.User = ""
+
+		err := cfg.Validate()
+		require.Error(t, err)
+
+		for _, field := range []string{"JWT_SECRET", "HTTP_PORT", "GRPC_PORT", "DB_PORT", "DB_HOST", "DB_NAME", "DB_USER"} {
+			assert.Contains(t, err.Error(), field)
+		}
+	})
+
+	t.Run("memory driver needs no database settings", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.DB = DBConfig{Driver: DriverMemory}
+
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("event sinks", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.Events.Sinks = []string{SinkBus, SinkFile, SinkWebhook, "kafka"}
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "EVENTS_FILE_PATH")
+		assert.Contains(t, err.Error(), "EVENTS_WEBHOOK_URL")
+		assert.Contains(t, err.Error(), `"kafka"`)
+	})
+
+	t.Run("webhooks", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.Webhooks.MaxAttempts = 0
+		cfg.Webhooks.MaxBackoff = time.Second
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "WEBHOOKS_MAX_ATTEMPTS")
+		assert.Contains(t, err.Error(), "WEBHOOKS_MAX_BACKOFF")
+	})
+
+	t.Run("allowance", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.Allowance.Schedule = "every month"
+		cfg.Allowance.Amount = 0
+		assert.NoError(t, cfg.Validate(), "disabled allowance is not validated")
+
+		cfg.Allowance.Enabled = true
+		cfg.Scheduler.PollInterval = 0
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ALLOWANCE_AMOUNT")
+		assert.Contains(t, err.Error(), "ALLOWANCE_SCHEDULE")
+		assert.Contains(t, err.Error(), "SCHEDULER_POLL_INTERVAL")
+	})
+
+	t.Run("signup", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.Signup.WelcomeGrant = 0
+		cfg.Signup.ReferralBonus = 0
+		assert.NoError(t, cfg.Validate(), "grants may be zero")
+
+		cfg.Signup.WelcomeGrant = -1
+		cfg.Signup.ReferralLimit = -1
+		cfg.Signup.ReferralWindow = 0
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "SIGNUP_WELCOME_GRANT")
+		assert.Contains(t, err.Error(), "SIGNUP_REFERRAL_LIMIT")
+		assert.Contains(t, err.Error(), "SIGNUP_REFERRAL_WINDOW")
+		assert.NotContains(t, err.Error(), "SIGNUP_REFERRAL_BONUS")
+	})
+
+	t.Run("transfers", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.Transfers = TransfersConfig{MaxAmount: 500, DailyAmount: 2000, DailyCount: 20}
+		assert.NoError(t, cfg.Validate())
+
+		cfg.Transfers.MaxAmount = -1
+		cfg.Transfers.DailyCount = -1
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TRANSFERS_MAX_AMOUNT")
+		assert.Contains(t, err.Error(), "TRANSFERS_DAILY_COUNT")
+		assert.NotContains(t, err.Error(), "TRANSFERS_DAILY_AMOUNT")
+	})
+
+	t.Run("pending transfers", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.PendingTransfers = PendingTransfersConfig{TTL: 0, Schedule: "every minute", HoldAbove: -1}
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "PENDING_TRANSFERS_TTL")
+		assert.Contains(t, err.Error(), "PENDING_TRANSFERS_SCHEDULE")
+		assert.Contains(t, err.Error(), "PENDING_TRANSFERS_HOLD_ABOVE")
+	})
+
+	t.Run("payment requests", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.PaymentRequests.TTL = -time.Hour
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "PAYMENT_REQUESTS_TTL")
+	})
+
+	t.Run("scheduled transfers", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.ScheduledTransfers = ScheduledTransfersConfig{Schedule: "@never", MaxPerUser: 0}
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "SCHEDULED_TRANSFERS_SCHEDULE")
+		assert.Contains(t, err.Error(), "SCHEDULED_TRANSFERS_MAX_PER_USER")
+	})
+
+	t.Run("unknown driver", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := validConfig()
+		cfg.DB.Driver = "sqlite"
+
+		assert.ErrorContains(t, cfg.Validate(), "DB_DRIVER")
+	})
+}
+
+func TestRead(t *testing.T) { //nolint:paralleltest // uses t.Setenv
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+app:
+  jwt_secret: from-file
+http:
+  port: 9090
+db:
+  host: file-host
+`), 0o600)
+	require.NoError(t, err)
+
+	t.Setenv("DB_HOST", "env-host")
+
+	cfg, err := Read(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, Secret("from-file"), cfg.App.JWTSecret)
+	assert.Equal(t, 9090, cfg.HTTP.Port)
+	assert.Equal(t, "0.0.0.0", cfg.HTTP.Host)
+	assert.Equal(t, "env-host", cfg.DB.Host)
+}
+
+func TestRead_UnknownField(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("htp:\n  port: 1\n"), 0o600))
+
+	_, err := Read(path)
+	assert.Error(t, err)
+}
+
+func TestConfig_Print(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	require.NoError(t, validConfig().Print(&buf))
+
+	assert.NotContains(t, buf.String(), "0123456789abcdef")
+	assert.NotContains(t, buf.String(), "password: password")
+	assert.Contains(t, buf.String(), redacted)
+	assert.Contains(t, buf.String(), "host: db")
+}
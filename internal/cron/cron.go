@@ -0,0 +1,194 @@
+// Package cron parses the five field cron expressions used to schedule
+// background jobs.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchLimit bounds how far ahead Next looks for a matching time, enough to
+// reach February 29 from any date.
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+var ErrInvalid = errors.New("invalid cron expression")
+
+var descriptors = map[string]string{ //nolint:gochecknoglobals
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = [...]field{ //nolint:gochecknoglobals
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Schedule is a parsed cron expression. Every field is a set of allowed
+// values, the bit n is set if n is allowed.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record a "*" day of month or week. As in cron, if
+	// both days are restricted a time matches if either of them does.
+	domStar, dowStar bool
+}
+
+// Parse parses an expression of five space separated fields: minute, hour,
+// day of month, month and day of week (0 or 7 is Sunday). A field is "*" or
+// a comma separated list of values and ranges like "1-5", either optionally
+// followed by a step like "*/15". The descriptors @yearly, @monthly, @weekly,
+// @daily and @hourly are accepted as well.
+func Parse(expr string) (*Schedule, error) {
+	if d, ok := descriptors[strings.TrimSpace(expr)]; ok {
+		expr = d
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("%w %q: want %d fields, got %d", ErrInvalid, expr, len(fields), len(parts))
+	}
+
+	var sets [len(fields)]uint64
+
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalid, expr, err)
+		}
+
+		sets[i] = set
+	}
+
+	s := &Schedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}
+
+	// Sunday may be written as 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	if s.Next(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, fmt.Errorf("%w %q: never matches", ErrInvalid, expr)
+	}
+
+	return s, nil
+}
+
+func parseField(s string, f field) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("%s: invalid step %q", f.name, stepStr)
+			}
+		}
+
+		lo, hi := f.min, f.max
+
+		if rng != "*" {
+			var err error
+			if lo, hi, err = parseRange(rng, f); err != nil {
+				return 0, err
+			}
+
+			// "5/10" means from 5 up to the maximum in steps of 10.
+			if hasStep && !strings.Contains(rng, "-") {
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+
+	return set, nil
+}
+
+func parseRange(s string, f field) (lo, hi int, err error) {
+	loStr, hiStr, isRange := strings.Cut(s, "-")
+
+	if lo, err = strconv.Atoi(loStr); err != nil {
+		return 0, 0, fmt.Errorf("%s: invalid value %q", f.name, loStr)
+	}
+
+	hi = lo
+
+	if isRange {
+		if hi, err = strconv.Atoi(hiStr); err != nil {
+			return 0, 0, fmt.Errorf("%s: invalid value %q", f.name, hiStr)
+		}
+	}
+
+	if lo < f.min || hi > f.max || lo > hi {
+		return 0, 0, fmt.Errorf("%s: %q is out of range %d-%d", f.name, s, f.min, f.max)
+	}
+
+	return lo, hi, nil
+}
+
+// Next returns the first matching time after t, in the location of t, or the
+// zero time if there is none within five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	limit := t.Add(searchLimit)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(s.hour, t.Hour()):
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) matchDay(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
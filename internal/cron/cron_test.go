@@ -0,0 +1,87 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{
+		"* * * * *",
+		"*/15 0-6,18 1 * *",
+		"5/10 * * 1-12/3 1-5",
+		"@monthly",
+		"0 0 29 2 *",
+		"0 12 * * 7",
+	}
+
+	for _, expr := range valid {
+		_, err := Parse(expr)
+		assert.NoError(t, err, expr)
+	}
+
+	invalid := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"* * * * mon",
+		"0 0 30 2 *",
+	}
+
+	for _, expr := range invalid {
+		_, err := Parse(expr)
+		assert.ErrorIs(t, err, ErrInvalid, expr)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	t.Parallel()
+
+	at := func(s string) time.Time {
+		t.Helper()
+
+		v, err := time.Parse(time.DateTime, s)
+		require.NoError(t, err)
+
+		return v
+	}
+
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"* * * * *", "2025-01-01 10:00:30", "2025-01-01 10:01:00"},
+		{"@hourly", "2025-01-01 10:00:00", "2025-01-01 11:00:00"},
+		{"*/15 * * * *", "2025-01-01 10:07:00", "2025-01-01 10:15:00"},
+		{"@monthly", "2025-01-15 08:00:00", "2025-02-01 00:00:00"},
+		{"@monthly", "2025-12-01 00:00:00", "2026-01-01 00:00:00"},
+		{"0 9 * * 1", "2025-01-01 00:00:00", "2025-01-06 09:00:00"},
+		{"0 9 * * 7", "2025-01-01 00:00:00", "2025-01-05 09:00:00"},
+		{"0 0 31 * *", "2025-02-01 00:00:00", "2025-03-31 00:00:00"},
+		{"0 0 29 2 *", "2025-01-01 00:00:00", "2028-02-29 00:00:00"},
+		{"5/20 3 * * *", "2025-01-01 03:30:00", "2025-01-01 03:45:00"},
+		// Restricted day of month and week match if either does.
+		{"0 0 13 * 5", "2025-01-01 00:00:00", "2025-01-03 00:00:00"},
+		{"0 0 13 * 5", "2025-01-10 12:00:00", "2025-01-13 00:00:00"},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		require.NoError(t, err)
+
+		assert.Equal(t, at(tt.want), s.Next(at(tt.from)), "%s after %s", tt.expr, tt.from)
+	}
+}
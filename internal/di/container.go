@@ -6,11 +6,21 @@ import (
 	"github.com/esklo/avito-backend-winter-2025/internal/service"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/cron"
+	"github.com/esklo/avito-backend-winter-2025/internal/events"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
 	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/scheduler"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/admin"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/allowance"
 	"github.com/esklo/avito-backend-winter-2025/internal/service/auth"
 	"github.com/esklo/avito-backend-winter-2025/internal/service/hasher"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/idempotency"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/notification"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/scheduled"
 	"github.com/esklo/avito-backend-winter-2025/internal/service/shop"
 	"github.com/esklo/avito-backend-winter-2025/internal/service/user"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/webhook"
 )
 
 type Container struct {
@@ -19,10 +29,23 @@ type Container struct {
 
 	log *slog.Logger
 
-	hasher *hasher.Argon2
-	auth   *auth.Service
-	users  *user.Service
-	shop   *shop.Service
+	hasher   *hasher.Argon2
+	auth     *auth.Service
+	users    *user.Service
+	shop     *shop.Service
+	webhooks *webhook.Service
+
+	notifications *notification.Service
+	idempotency   *idempotency.Service
+	admin         *admin.Service
+	allowance     *allowance.Service
+
+	scheduledTransfers *scheduled.Service
+
+	bus   *events.Bus
+	relay *events.Relay
+
+	scheduler *scheduler.Scheduler
 }
 
 func New(cfg *config.Config, repo repository.Repository) *Container {
@@ -33,19 +56,108 @@ func New(cfg *config.Config, repo repository.Repository) *Container {
 		hasher: hasher.NewArgon2(),
 	}
 	c.initServices()
+	c.initEvents()
+	c.initScheduler()
 
 	return c
 }
 
 func (c *Container) initServices() {
-	c.users = user.NewService(c.repo, c.hasher)
-	c.auth = auth.NewService(c.repo, c.users, c.hasher, c.cfg.App.JWTSecret)
+	limits := model.TransferLimits{
+		MaxAmount:   c.cfg.Transfers.MaxAmount,
+		DailyAmount: c.cfg.Transfers.DailyAmount,
+		DailyCount:  c.cfg.Transfers.DailyCount,
+	}
+
+	c.users = user.NewService(c.repo, c.hasher, c.cfg.Signup, limits, c.cfg.PendingTransfers, c.cfg.PaymentRequests)
+	c.auth = auth.NewService(c.repo, c.users, c.hasher, c.cfg.App.JWTSecret, c.cfg.App.Admins)
 	c.shop = shop.NewService(c.repo)
+	c.webhooks = webhook.NewService(c.repo, c.cfg.Webhooks, c.log)
+	c.notifications = notification.NewService(c.repo, c.log)
+	c.idempotency = idempotency.NewService(c.repo)
+	c.admin = admin.NewService(c.repo, limits)
+	c.allowance = allowance.NewService(c.repo, c.cfg.Allowance.Amount, c.log)
+	c.scheduledTransfers = scheduled.NewService(c.repo, c.users, c.cfg.ScheduledTransfers, c.log)
+}
+
+func (c *Container) initEvents() {
+	c.bus = events.NewBus()
+	c.bus.Subscribe(c.webhooks.HandleEvent)
+
+	sinks := make(events.MultiSink, 0, len(c.cfg.Events.Sinks))
+
+	for _, name := range c.cfg.Events.Sinks {
+		switch name {
+		case config.SinkBus:
+			sinks = append(sinks, c.bus)
+		case config.SinkFile:
+			sinks = append(sinks, events.NewFileSink(c.cfg.Events.FilePath))
+		case config.SinkWebhook:
+			sinks = append(sinks, events.NewWebhookSink(c.cfg.Events.WebhookURL))
+		}
+	}
+
+	c.relay = events.NewRelay(c.repo, sinks, c.log, c.cfg.Events.PollInterval, c.cfg.Events.BatchSize)
+}
+
+func (c *Container) initScheduler() {
+	c.scheduler = scheduler.New(c.repo, c.log, c.cfg.Scheduler.PollInterval)
+
+	if schedule, err := cron.Parse(c.cfg.PendingTransfers.Schedule); err != nil {
+		c.log.Error("pending transfers do not expire", "error", err)
+	} else {
+		c.scheduler.Add(scheduler.Job{
+			Name:     user.ExpireJobName,
+			Schedule: schedule,
+			Run:      c.users.ExpirePendingTransfers,
+		})
+	}
+
+	if schedule, err := cron.Parse(c.cfg.ScheduledTransfers.Schedule); err != nil {
+		c.log.Error("scheduled transfers are not made", "error", err)
+	} else {
+		c.scheduler.Add(scheduler.Job{
+			Name:     scheduled.JobName,
+			Schedule: schedule,
+			Run:      c.scheduledTransfers.Run,
+		})
+	}
+
+	if !c.cfg.Allowance.Enabled {
+		return
+	}
+
+	schedule, err := cron.Parse(c.cfg.Allowance.Schedule)
+	if err != nil {
+		c.log.Error("allowance is disabled", "error", err)
+
+		return
+	}
+
+	c.scheduler.Add(scheduler.Job{
+		Name:     allowance.JobName,
+		Schedule: schedule,
+		Run:      c.allowance.Run,
+	})
 }
 
-func (c *Container) Config() *config.Config      { return c.cfg }
-func (c *Container) Log() *slog.Logger           { return c.log }
-func (c *Container) Hasher() service.Hasher      { return c.hasher }
-func (c *Container) Auth() service.Authenticator { return c.auth }
-func (c *Container) Users() service.UserManager  { return c.users }
-func (c *Container) Shop() service.Shop          { return c.shop }
+func (c *Container) Config() *config.Config                         { return c.cfg }
+func (c *Container) Log() *slog.Logger                              { return c.log }
+func (c *Container) Hasher() service.Hasher                         { return c.hasher }
+func (c *Container) Auth() service.Authenticator                    { return c.auth }
+func (c *Container) Users() service.UserManager                     { return c.users }
+func (c *Container) Shop() service.Shop                             { return c.shop }
+func (c *Container) Webhooks() service.Webhooks                     { return c.webhooks }
+func (c *Container) Notifications() service.Notifications           { return c.notifications }
+func (c *Container) Idempotency() service.Idempotency               { return c.idempotency }
+func (c *Container) Admin() service.Admin                           { return c.admin }
+func (c *Container) ScheduledTransfers() service.ScheduledTransfers { return c.scheduledTransfers }
+func (c *Container) Events() *events.Bus                            { return c.bus }
+func (c *Container) Relay() *events.Relay                           { return c.relay }
+func (c *Container) Scheduler() *scheduler.Scheduler                { return c.scheduler }
+
+// Dispatcher returns the service that sends webhook deliveries.
+func (c *Container) Dispatcher() *webhook.Service { return c.webhooks }
+
+// Listener returns the service that streams notifications.
+func (c *Container) Listener() *notification.Service { return c.notifications }
@@ -33,6 +33,7 @@ func TestNew(t *testing.T) {
 		assert.NotNil(t, container.Auth())
 		assert.NotNil(t, container.Users())
 		assert.NotNil(t, container.Shop())
+		assert.NotNil(t, container.Admin())
 	})
 }
 
@@ -0,0 +1,44 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+type Handler func(ctx context.Context, event model.Event) error
+
+// Bus is an in-process sink that passes events to subscribed handlers.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler for every published event. A handler error
+// makes the relay publish the whole batch again later.
+func (b *Bus) Subscribe(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *Bus) Publish(ctx context.Context, events []model.Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, event := range events {
+		for _, handler := range b.handlers {
+			if err := handler(ctx, event); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
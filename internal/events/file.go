@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+// FileSink appends events to a file as newline-delimited JSON.
+type FileSink struct {
+	path string
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Publish(_ context.Context, events []model.Event) (err error) {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open events file: %w", err)
+	}
+
+	defer func() {
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("close events file: %w", closeErr)
+		}
+	}()
+
+	enc := json.NewEncoder(f)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return fmt.Errorf("write event %d: %w", event.ID, err)
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync events file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+// relayLockKey is the advisory lock that lets a single replica relay at a
+// time, which keeps the events of a user in order.
+const relayLockKey int64 = 0x73686f705f6f7574
+
+// Relay moves events from the outbox to a sink. An event is marked published
+// only after the sink accepted it, so delivery is at least once.
+type Relay struct {
+	repo      repository.Repository
+	sink      Sink
+	log       *slog.Logger
+	interval  time.Duration
+	batchSize int
+}
+
+func NewRelay(repo repository.Repository, sink Sink, log *slog.Logger, interval time.Duration, batchSize int) *Relay {
+	return &Relay{
+		repo:      repo,
+		sink:      sink,
+		log:       log,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run relays events until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := r.Flush(ctx)
+			if err != nil {
+				r.log.Error("relay events", "error", err)
+			}
+
+			if err != nil || n < r.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes one batch of pending events and returns its size. The
+// batch is published outside of a transaction, so sinks may use the
+// repository.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	release, locked, err := r.repo.TryLock(ctx, relayLockKey)
+	if err != nil || !locked {
+		return 0, err
+	}
+	defer release()
+
+	events, err := r.repo.ListPendingEvents(ctx, nil, r.batchSize)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	if err := r.sink.Publish(ctx, events); err != nil {
+		return 0, fmt.Errorf("publish events: %w", err)
+	}
+
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+
+	if err := r.repo.MarkEventsPublished(ctx, nil, ids); err != nil {
+		return 0, err
+	}
+
+	return len(events), nil
+}
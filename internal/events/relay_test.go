@@ -0,0 +1,167 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errSink = errors.New("sink is down")
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []model.Event
+	err    error
+}
+
+func (s *recordingSink) Publish(_ context.Context, events []model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.events = append(s.events, events...)
+
+	return nil
+}
+
+func newUserWithEvents(t *testing.T, repo *memory.Repository, username string, n int) *model.User {
+	t.Helper()
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateUser(ctx, nil, &model.User{Username: username}))
+
+	user, err := repo.FindUser(ctx, nil, username)
+	require.NoError(t, err)
+
+	err = repo.WithTx(ctx, func(tx repository.DB) error {
+		for range n {
+			event, err := model.NewEvent(model.EventUserRegistered, user.ID, model.UserRegistered{Username: username})
+			require.NoError(t, err)
+			require.NoError(t, repo.AddEvent(ctx, tx, event))
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	return user
+}
+
+func TestRelay_Flush(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("publishes in batches and marks events", func(t *testing.T) {
+		t.Parallel()
+
+		repo := memory.New()
+		sink := &recordingSink{}
+		relay := NewRelay(repo, sink, slog.Default(), time.Second, 2)
+
+		user := newUserWithEvents(t, repo, "user", 3)
+
+		n, err := relay.Flush(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		n, err = relay.Flush(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		n, err = relay.Flush(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		require.Len(t, sink.events, 3)
+
+		for i, event := range sink.events {
+			assert.Equal(t, user.ID, event.UserID)
+			assert.Equal(t, int64(i+1), event.Sequence)
+		}
+	})
+
+	t.Run("keeps events when the sink fails", func(t *testing.T) {
+		t.Parallel()
+
+		repo := memory.New()
+		sink := &recordingSink{err: errSink}
+		relay := NewRelay(repo, sink, slog.Default(), time.Second, 10)
+
+		newUserWithEvents(t, repo, "user", 2)
+
+		_, err := relay.Flush(ctx)
+		require.ErrorIs(t, err, errSink)
+
+		sink.err = nil
+
+		n, err := relay.Flush(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Len(t, sink.events, 2)
+	})
+
+	t.Run("skips when another relay holds the lock", func(t *testing.T) {
+		t.Parallel()
+
+		repo := memory.New()
+		sink := &recordingSink{}
+		relay := NewRelay(repo, sink, slog.Default(), time.Second, 10)
+
+		newUserWithEvents(t, repo, "user", 1)
+
+		release, locked, err := repo.TryLock(ctx, relayLockKey)
+		require.NoError(t, err)
+		require.True(t, locked)
+
+		n, err := relay.Flush(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		release()
+
+		n, err = relay.Flush(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+}
+
+func TestRelay_Run(t *testing.T) {
+	t.Parallel()
+
+	repo := memory.New()
+	bus := NewBus()
+	relay := NewRelay(repo, bus, slog.Default(), 10*time.Millisecond, 10)
+
+	received := make(chan model.Event, 1)
+	bus.Subscribe(func(_ context.Context, event model.Event) error {
+		received <- event
+
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go relay.Run(ctx)
+
+	user := newUserWithEvents(t, repo, "user", 1)
+
+	select {
+	case event := <-received:
+		assert.Equal(t, user.ID, event.UserID)
+	case <-time.After(time.Second):
+		t.Fatal("event was not relayed")
+	}
+}
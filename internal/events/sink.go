@@ -0,0 +1,35 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+// Sink delivers events outside of the service. Events of a user arrive in
+// sequence order, but a batch may be delivered more than once, so consumers
+// should deduplicate by event ID.
+type Sink interface {
+	Publish(ctx context.Context, events []model.Event) error
+}
+
+var (
+	_ Sink = MultiSink(nil)
+	_ Sink = (*Bus)(nil)
+	_ Sink = (*FileSink)(nil)
+	_ Sink = (*WebhookSink)(nil)
+)
+
+// MultiSink publishes to every sink in order and fails if any of them fails.
+type MultiSink []Sink
+
+func (m MultiSink) Publish(ctx context.Context, events []model.Event) error {
+	for i, sink := range m {
+		if err := sink.Publish(ctx, events); err != nil {
+			return fmt.Errorf("sink %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvents() []model.Event {
+	return []model.Event{
+		{ID: 1, Type: model.EventUserRegistered, UserID: 1, Sequence: 1, Payload: json.RawMessage(`{"username":"a"}`)},
+		{ID: 2, Type: model.EventTransferCompleted, UserID: 1, Sequence: 2, Payload: json.RawMessage(`{"amount":1}`)},
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink := NewFileSink(path)
+
+	require.NoError(t, sink.Publish(context.Background(), testEvents()[:1]))
+	require.NoError(t, sink.Publish(context.Background(), testEvents()[1:]))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var got []model.Event
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event model.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+
+		got = append(got, event)
+	}
+
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, testEvents(), got)
+}
+
+func TestWebhookSink(t *testing.T) {
+	t.Parallel()
+
+	t.Run("posts events", func(t *testing.T) {
+		t.Parallel()
+
+		var got []model.Event
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		require.NoError(t, NewWebhookSink(srv.URL).Publish(context.Background(), testEvents()))
+		assert.Equal(t, testEvents(), got)
+	})
+
+	t.Run("fails on error status", func(t *testing.T) {
+		t.Parallel()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer srv.Close()
+
+		err := NewWebhookSink(srv.URL).Publish(context.Background(), testEvents())
+		assert.ErrorIs(t, err, ErrWebhookStatus)
+	})
+}
+
+func TestMultiSink(t *testing.T) {
+	t.Parallel()
+
+	first, second := &recordingSink{}, &recordingSink{err: errSink}
+
+	err := MultiSink{first, second}.Publish(context.Background(), testEvents())
+	assert.ErrorIs(t, err, errSink)
+	assert.Len(t, first.events, 2)
+}
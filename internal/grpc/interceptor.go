@@ -0,0 +1,84 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	shopv1 "github.com/esklo/avito-backend-winter-2025/pkg/api/shop/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type ctxKey string
+
+const ctxUsernameKey ctxKey = "username"
+
+// publicMethods are the methods that can be called without a token.
+var publicMethods = map[string]bool{ //nolint:gochecknoglobals
+	shopv1.AuthService_Login_FullMethodName: true,
+}
+
+// authInterceptor validates the bearer token from the authorization metadata
+// and stores its username in the context.
+func (s *Server) authInterceptor(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	var token string
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		token = strings.TrimPrefix(values[0], "Bearer ")
+	}
+
+	username, err := s.container.Auth().ValidateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(context.WithValue(ctx, ctxUsernameKey, username), req)
+}
+
+// errorInterceptor converts service errors to gRPC statuses and logs the
+// internal ones, which are not shown to the client.
+func (s *Server) errorInterceptor(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		st := toStatus(err)
+		if status.Code(st) == codes.Internal {
+			s.container.Log().Error("grpc handler failed", "method", info.FullMethod, "error", err)
+		}
+
+		return nil, st
+	}
+
+	return resp, nil
+}
+
+func (s *Server) recoverInterceptor(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.container.Log().Error("grpc handler panicked", "method", info.FullMethod, "panic", r)
+			err = toStatus(model.ErrInternalServerError)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+func usernameFromCtx(ctx context.Context) (string, error) {
+	username, ok := ctx.Value(ctxUsernameKey).(string)
+	if !ok || username == "" {
+		return "", model.ErrUnauthorized
+	}
+
+	return username, nil
+}
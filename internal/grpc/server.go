@@ -0,0 +1,80 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/service"
+	shopv1 "github.com/esklo/avito-backend-winter-2025/pkg/api/shop/v1"
+	"google.golang.org/grpc"
+)
+
+type Container interface {
+	Log() *slog.Logger
+	Config() *config.Config
+	Users() service.UserManager
+	Auth() service.Authenticator
+	Shop() service.Shop
+}
+
+// Server exposes the services over gRPC. It is the gRPC counterpart of the
+// HTTP server and reports errors with codes matching the HTTP statuses.
+type Server struct {
+	srv       *grpc.Server
+	container Container
+}
+
+func NewServer(container Container) *Server {
+	s := &Server{container: container}
+
+	s.srv = grpc.NewServer(grpc.ChainUnaryInterceptor(
+		s.recoverInterceptor,
+		s.errorInterceptor,
+		s.authInterceptor,
+	))
+
+	shopv1.RegisterAuthServiceServer(s.srv, &authServer{container: container})
+	shopv1.RegisterUserServiceServer(s.srv, &userServer{container: container})
+	shopv1.RegisterShopServiceServer(s.srv, &shopServer{container: container})
+
+	return s
+}
+
+// Run serves on the configured address until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	addr := s.container.Config().GRPC.Address()
+
+	lis, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	s.container.Log().Info("grpc listening on", "addr", addr)
+
+	return s.Serve(ctx, lis)
+}
+
+// Serve serves on lis until ctx is done, then stops gracefully.
+func (s *Server) Serve(ctx context.Context, lis net.Listener) error {
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.srv.GracefulStop()
+		case <-stopped:
+		}
+	}()
+
+	err := s.srv.Serve(lis)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+
+	return err
+}
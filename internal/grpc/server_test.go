@@ -0,0 +1,285 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/auth"
+	"github.com/esklo/avito-backend-winter-2025/mocks"
+	shopv1 "github.com/esklo/avito-backend-winter-2025/pkg/api/shop/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testSuite struct {
+	users *mocks.MockUserManager
+	auth  *mocks.MockAuthenticator
+	shop  *mocks.MockShop
+
+	authClient shopv1.AuthServiceClient
+	userClient shopv1.UserServiceClient
+	shopClient shopv1.ShopServiceClient
+}
+
+func newTestSuite(t *testing.T) *testSuite {
+	t.Helper()
+
+	ctrl := gomock.NewController(t)
+
+	container := mocks.NewMockContainer(ctrl)
+	users := mocks.NewMockUserManager(ctrl)
+	authenticator := mocks.NewMockAuthenticator(ctrl)
+	shop := mocks.NewMockShop(ctrl)
+
+	container.EXPECT().Log().Return(slog.Default()).AnyTimes()
+	container.EXPECT().Config().Return(config.Default()).AnyTimes()
+	container.EXPECT().Users().Return(users).AnyTimes()
+	container.EXPECT().Auth().Return(authenticator).AnyTimes()
+	container.EXPECT().Shop().Return(shop).AnyTimes()
+
+	lis := bufconn.Listen(1 << 20)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() { done <- NewServer(container).Serve(ctx, lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	return &testSuite{
+		users:      users,
+		auth:       authenticator,
+		shop:       shop,
+		authClient: shopv1.NewAuthServiceClient(conn),
+		userClient: shopv1.NewUserServiceClient(conn),
+		shopClient: shopv1.NewShopServiceClient(conn),
+	}
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func (ts *testSuite) expectToken(token, username string) {
+	ts.auth.EXPECT().ValidateToken(gomock.Any(), token).Return(username, nil)
+}
+
+func TestServer_Login(t *testing.T) {
+	t.Parallel()
+
+	t.Run("does not need a token", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.auth.EXPECT().Login(gomock.Any(), "user", "pass", "").Return("test-token", nil)
+
+		resp, err := ts.authClient.Login(context.Background(), &shopv1.LoginRequest{Username: "user", Password: "pass"})
+		require.NoError(t, err)
+		assert.Equal(t, "test-token", resp.GetToken())
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.auth.EXPECT().Login(gomock.Any(), "user", "wrong", "").Return("", model.ErrUnauthorized)
+
+		_, err := ts.authClient.Login(context.Background(), &shopv1.LoginRequest{Username: "user", Password: "wrong"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestServer_GetInfo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns info of the token owner", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.expectToken("token", "alice")
+		ts.users.EXPECT().Info(gomock.Any(), "alice").Return(&model.Info{
+			Coins: 900,
+			Inventory: []model.Inventory{
+				{Type: "cup", Quantity: 2},
+				{Type: "t-shirt", Quantity: 1},
+				{Type: "t-shirt", Quantity: 3, VariantAttributes: model.VariantAttributes{Size: "L"}},
+			},
+			CoinHistory: &model.CoinHistory{
+				Received: []model.CoinsReceived{{FromUser: "bob", Amount: 10}},
+				Sent:     []model.CoinsSent{{ToUser: "carol", Amount: 70}},
+			},
+		}, nil)
+
+		resp, err := ts.userClient.GetInfo(withToken("token"), &shopv1.GetInfoRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(900), resp.GetCoins())
+		require.Len(t, resp.GetInventory(), 2, "variants are merged into their item")
+		assert.Equal(t, "cup", resp.GetInventory()[0].GetType())
+		assert.Equal(t, int64(2), resp.GetInventory()[0].GetQuantity())
+		assert.Equal(t, "t-shirt", resp.GetInventory()[1].GetType())
+		assert.Equal(t, int64(4), resp.GetInventory()[1].GetQuantity())
+		assert.Equal(t, "bob", resp.GetCoinHistory().GetReceived()[0].GetFromUser())
+		assert.Equal(t, int64(70), resp.GetCoinHistory().GetSent()[0].GetAmount())
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.auth.EXPECT().ValidateToken(gomock.Any(), "").Return("", auth.ErrInvalidToken)
+
+		_, err := ts.userClient.GetInfo(context.Background(), &shopv1.GetInfoRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestServer_SendCoin(t *testing.T) {
+	t.Parallel()
+
+	t.Run("successful transfer", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.expectToken("token", "alice")
+		ts.users.EXPECT().Transfer(gomock.Any(), "alice", "bob", 100, "").Return(nil, nil)
+
+		_, err := ts.userClient.SendCoin(withToken("token"), &shopv1.SendCoinRequest{ToUser: "bob", Amount: 100})
+		assert.NoError(t, err)
+	})
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.expectToken("token", "alice")
+		ts.users.EXPECT().Transfer(gomock.Any(), "alice", "bob", 100, "").Return(nil, model.ErrInsufficientFunds)
+
+		_, err := ts.userClient.SendCoin(withToken("token"), &shopv1.SendCoinRequest{ToUser: "bob", Amount: 100})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, model.ErrInsufficientFunds.Error(), status.Convert(err).Message())
+	})
+}
+
+func TestServer_Shop(t *testing.T) {
+	t.Parallel()
+
+	t.Run("get item", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.expectToken("token", "alice")
+		ts.shop.EXPECT().GetItem(gomock.Any(), "cup").Return(&model.Item{Name: "cup", Price: 20}, nil)
+
+		resp, err := ts.shopClient.GetItem(withToken("token"), &shopv1.GetItemRequest{Name: "cup"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(20), resp.GetItem().GetPrice())
+	})
+
+	t.Run("unknown item", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.expectToken("token", "alice")
+		ts.shop.EXPECT().GetItem(gomock.Any(), "car").Return(nil, sql.ErrNoRows)
+
+		_, err := ts.shopClient.GetItem(withToken("token"), &shopv1.GetItemRequest{Name: "car"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("buy item", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.expectToken("token", "alice")
+		ts.shop.EXPECT().BuyItem(gomock.Any(), "cup", model.VariantAttributes{}, "alice").Return(nil)
+
+		_, err := ts.shopClient.BuyItem(withToken("token"), &shopv1.BuyItemRequest{Name: "cup"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("item with variants", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.expectToken("token", "alice")
+		ts.shop.EXPECT().BuyItem(gomock.Any(), "t-shirt", model.VariantAttributes{}, "alice").
+			Return(fmt.Errorf("%w of %q", model.ErrVariantRequired, "t-shirt"))
+
+		_, err := ts.shopClient.BuyItem(withToken("token"), &shopv1.BuyItemRequest{Name: "t-shirt"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, status.Convert(err).Message(), "only be bought over the HTTP API")
+	})
+
+	t.Run("handler panic", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.expectToken("token", "alice")
+		ts.shop.EXPECT().BuyItem(gomock.Any(), "cup", model.VariantAttributes{}, "alice").
+			DoAndReturn(func(context.Context, string, model.VariantAttributes, string) error {
+				panic("boom")
+			})
+
+		_, err := ts.shopClient.BuyItem(withToken("token"), &shopv1.BuyItemRequest{Name: "cup"})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+}
+
+func TestToStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{model.ErrBadRequest, codes.InvalidArgument},
+		{model.ErrInsufficientFunds, codes.InvalidArgument},
+		{model.ErrUnauthorized, codes.Unauthenticated},
+		{model.ErrForbidden, codes.PermissionDenied},
+		{model.ErrNotFound, codes.NotFound},
+		{model.ErrConflict, codes.Aborted},
+		{errors.New("unexpected"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.code, status.Code(toStatus(tt.err)))
+		})
+	}
+
+	t.Run("internal message", func(t *testing.T) {
+		t.Parallel()
+
+		err := toStatus(fmt.Errorf("select users: %w", errors.New("connection refused")))
+		assert.Equal(t, model.ErrInternalServerError.Error(), status.Convert(err).Message())
+
+		err = toStatus(fmt.Errorf("%w: receiver is deactivated", model.ErrBadRequest))
+		assert.Equal(t, "bad request: receiver is deactivated", status.Convert(err).Message())
+	})
+}
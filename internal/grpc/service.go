@@ -0,0 +1,166 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	shopv1 "github.com/esklo/avito-backend-winter-2025/pkg/api/shop/v1"
+)
+
+type authServer struct {
+	shopv1.UnimplementedAuthServiceServer
+
+	container Container
+}
+
+// Login registers new users without a referral code, referral codes are only
+// accepted by the HTTP API.
+func (s *authServer) Login(ctx context.Context, req *shopv1.LoginRequest) (*shopv1.LoginResponse, error) {
+	token, err := s.container.Auth().Login(ctx, req.GetUsername(), req.GetPassword(), "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &shopv1.LoginResponse{Token: token}, nil
+}
+
+type userServer struct {
+	shopv1.UnimplementedUserServiceServer
+
+	container Container
+}
+
+func (s *userServer) GetInfo(ctx context.Context, _ *shopv1.GetInfoRequest) (*shopv1.GetInfoResponse, error) {
+	username, err := usernameFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.container.Users().Info(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return infoToProto(info), nil
+}
+
+// SendCoin transfers coins without a memo, memos are only accepted by the
+// HTTP API. A transfer held until the receiver accepts it succeeds like any
+// other, it is listed with the pending transfers of the HTTP API.
+func (s *userServer) SendCoin(ctx context.Context, req *shopv1.SendCoinRequest) (*shopv1.SendCoinResponse, error) {
+	username, err := usernameFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := toInt(req.GetAmount())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.container.Users().Transfer(ctx, username, req.GetToUser(), amount, ""); err != nil {
+		return nil, err
+	}
+
+	return &shopv1.SendCoinResponse{}, nil
+}
+
+type shopServer struct {
+	shopv1.UnimplementedShopServiceServer
+
+	container Container
+}
+
+func (s *shopServer) GetItem(ctx context.Context, req *shopv1.GetItemRequest) (*shopv1.GetItemResponse, error) {
+	item, err := s.container.Shop().GetItem(ctx, req.GetName())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &shopv1.GetItemResponse{
+		Item: &shopv1.Item{Name: item.Name, Price: int64(item.Price)},
+	}, nil
+}
+
+func (s *shopServer) BuyItem(ctx context.Context, req *shopv1.BuyItemRequest) (*shopv1.BuyItemResponse, error) {
+	username, err := usernameFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// BuyItemRequest has no variant, so items with variants cannot be bought
+	// over gRPC.
+	err = s.container.Shop().BuyItem(ctx, req.GetName(), model.VariantAttributes{}, username)
+	if errors.Is(err, model.ErrVariantRequired) {
+		return nil, fmt.Errorf("%w: %q has variants, which can only be bought over the HTTP API",
+			model.ErrBadRequest, req.GetName())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &shopv1.BuyItemResponse{}, nil
+}
+
+// infoToProto converts the info of a user. InventoryItem has no variant, so
+// the variants of an item are merged into one entry of the item.
+func infoToProto(info *model.Info) *shopv1.GetInfoResponse {
+	resp := &shopv1.GetInfoResponse{
+		Coins:       int64(info.Coins),
+		CoinHistory: &shopv1.CoinHistory{},
+	}
+
+	inventory := make(map[string]*shopv1.InventoryItem, len(info.Inventory))
+
+	for _, item := range info.Inventory {
+		if merged, ok := inventory[item.Type]; ok {
+			merged.Quantity += int64(item.Quantity)
+
+			continue
+		}
+
+		inventory[item.Type] = &shopv1.InventoryItem{
+			Type:     item.Type,
+			Quantity: int64(item.Quantity),
+		}
+		resp.Inventory = append(resp.Inventory, inventory[item.Type])
+	}
+
+	if info.CoinHistory == nil {
+		return resp
+	}
+
+	for _, r := range info.CoinHistory.Received {
+		resp.CoinHistory.Received = append(resp.CoinHistory.Received, &shopv1.CoinsReceived{
+			FromUser: r.FromUser,
+			Amount:   int64(r.Amount),
+		})
+	}
+
+	for _, sent := range info.CoinHistory.Sent {
+		resp.CoinHistory.Sent = append(resp.CoinHistory.Sent, &shopv1.CoinsSent{
+			ToUser: sent.ToUser,
+			Amount: int64(sent.Amount),
+		})
+	}
+
+	return resp
+}
+
+// toInt narrows a protobuf int64 to the int used by the services.
+func toInt(v int64) (int, error) {
+	n := int(v)
+	if int64(n) != v {
+		return 0, model.ErrBadRequest
+	}
+
+	return n, nil
+}
@@ -0,0 +1,34 @@
+package grpc
+
+import (
+	"net/http"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus reports err with the code matching its HTTP status, so both APIs
+// classify errors the same way. Errors that are not of the domain are
+// reported as internal without their message, the caller logs them.
+func toStatus(err error) error {
+	var code codes.Code
+
+	switch render.StatusCode(err) {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.Aborted
+	default:
+		return status.Error(codes.Internal, model.ErrInternalServerError.Error())
+	}
+
+	return status.Error(code, err.Error())
+}
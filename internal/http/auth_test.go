@@ -0,0 +1,92 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/di"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStackSuite returns a suite backed by the real services and an
+// in-memory repository, with admin as the only admin, for the checks that
+// span authentication and storage.
+func newStackSuite(t *testing.T) (*testSuite, *di.Container) {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.App.JWTSecret = []byte("stack-test-secret-stack-test-secret")
+	cfg.App.Admins = []string{"admin"}
+	cfg.DB.Driver = config.DriverMemory
+
+	container := di.New(cfg, memory.New())
+
+	return &testSuite{server: NewServer(container)}, container
+}
+
+func (ts *testSuite) login(t *testing.T, username string) (string, int) {
+	t.Helper()
+
+	w := ts.do(http.MethodPost, "/api/auth", "", `{"username": "`+username+`", "password": "password"}`)
+	if w.Code != http.StatusOK {
+		return "", w.Code
+	}
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+
+	return resp.Token, w.Code
+}
+
+func TestServer_DeactivatedToken(t *testing.T) {
+	t.Parallel()
+	ts, container := newStackSuite(t)
+
+	token, code := ts.login(t, "alice")
+	require.Equal(t, http.StatusOK, code)
+
+	w := ts.do(http.MethodGet, "/api/info", token, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	_, err := container.Admin().DeactivateUser(context.Background(), "alice", false)
+	require.NoError(t, err)
+
+	w = ts.do(http.MethodGet, "/api/info", token, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestServer_AdminSignup(t *testing.T) {
+	t.Parallel()
+
+	t.Run("new admin is refused", func(t *testing.T) {
+		t.Parallel()
+		ts, _ := newStackSuite(t)
+
+		_, code := ts.login(t, "admin")
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		w := ts.do(http.MethodGet, "/api/admin/reconciliation", "", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("created admin logs in", func(t *testing.T) {
+		t.Parallel()
+		ts, container := newStackSuite(t)
+
+		_, err := container.Users().Create(context.Background(), "admin", "password", "")
+		require.NoError(t, err)
+
+		token, code := ts.login(t, "admin")
+		require.Equal(t, http.StatusOK, code)
+
+		w := ts.do(http.MethodGet, "/api/admin/reconciliation", token, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
@@ -6,31 +6,23 @@ import (
 
 	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
 )
 
-type loginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-
-type loginResponse struct {
-	Token string `json:"token"`
-}
-
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
-	var req loginRequest
+	var req client.AuthRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		render.Error(w, model.ErrBadRequest)
 
 		return
 	}
 
-	token, err := h.container.Auth().Login(r.Context(), req.Username, req.Password)
+	token, err := h.container.Auth().Login(r.Context(), req.Username, req.Password, req.ReferralCode)
 	if err != nil {
 		render.Error(w, err)
 
 		return
 	}
 
-	render.Success(w, loginResponse{Token: token})
+	render.Success(w, client.AuthResponse{Token: token})
 }
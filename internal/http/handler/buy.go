@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
 )
 
 func (h *Handler) Buy(w http.ResponseWriter, r *http.Request) {
@@ -14,7 +16,7 @@ func (h *Handler) Buy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.container.Shop().BuyItem(r.Context(), r.PathValue("name"), username)
+	err = h.container.Shop().BuyItem(r.Context(), r.PathValue("name"), variantFromQuery(r), username)
 	if err != nil {
 		render.Error(w, err)
 
@@ -23,3 +25,32 @@ func (h *Handler) Buy(w http.ResponseWriter, r *http.Request) {
 
 	render.Success(w, nil)
 }
+
+// variantFromQuery returns the variant chosen with the size and color query
+// parameters.
+func variantFromQuery(r *http.Request) model.VariantAttributes {
+	query := r.URL.Query()
+
+	return model.VariantAttributes{Size: query.Get("size"), Color: query.Get("color")}
+}
+
+func (h *Handler) Variants(w http.ResponseWriter, r *http.Request) {
+	item, variants, err := h.container.Shop().Variants(r.Context(), r.PathValue("name"))
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	resp := make([]client.Variant, 0, len(variants))
+	for _, v := range variants {
+		resp = append(resp, client.Variant{
+			Size:  v.Size,
+			Color: v.Color,
+			Price: item.Price + v.PriceDelta,
+			Stock: v.Stock,
+		})
+	}
+
+	render.Success(w, resp)
+}
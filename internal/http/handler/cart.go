@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
+)
+
+type cartFunc func(ctx context.Context, username string) (*model.Cart, error)
+
+func (h *Handler) Cart(w http.ResponseWriter, r *http.Request) {
+	h.cart(w, r, h.container.Shop().Cart)
+}
+
+func (h *Handler) AddToCart(w http.ResponseWriter, r *http.Request) {
+	var req client.AddToCartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+
+	h.cart(w, r, func(ctx context.Context, username string) (*model.Cart, error) {
+		variant := model.VariantAttributes{Size: req.Size, Color: req.Color}
+
+		return h.container.Shop().AddToCart(ctx, username, req.Item, variant, req.Quantity)
+	})
+}
+
+func (h *Handler) RemoveFromCart(w http.ResponseWriter, r *http.Request) {
+	h.cart(w, r, func(ctx context.Context, username string) (*model.Cart, error) {
+		return h.container.Shop().RemoveFromCart(ctx, username, r.PathValue("name"), variantFromQuery(r))
+	})
+}
+
+func (h *Handler) Checkout(w http.ResponseWriter, r *http.Request) {
+	h.cart(w, r, h.container.Shop().Checkout)
+}
+
+// cart responds with the cart returned by fn for the user.
+func (h *Handler) cart(w http.ResponseWriter, r *http.Request, fn cartFunc) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	cart, err := fn(r.Context(), username)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	resp := client.CartResponse{Items: make([]client.CartItem, 0, len(cart.Items)), Total: cart.Total()}
+	for i := range cart.Items {
+		line := &cart.Items[i]
+		resp.Items = append(resp.Items, client.CartItem{
+			Item:     line.Item,
+			Size:     line.Size,
+			Color:    line.Color,
+			Quantity: line.Quantity,
+			Price:    line.Price,
+			Total:    line.Total(),
+			Stock:    line.Stock,
+		})
+	}
+
+	render.Success(w, resp)
+}
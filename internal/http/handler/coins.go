@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+type moveCoinsRequest struct {
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+type balanceResponse struct {
+	Username string `json:"username"`
+	Balance  int    `json:"balance"`
+}
+
+type moveCoinsFunc func(
+	ctx context.Context, username string, amount int, reason string, dryRun bool,
+) (*model.User, error)
+
+func (h *Handler) MintCoins(w http.ResponseWriter, r *http.Request) {
+	h.moveCoins(w, r, h.container.Admin().Mint)
+}
+
+func (h *Handler) BurnCoins(w http.ResponseWriter, r *http.Request) {
+	h.moveCoins(w, r, h.container.Admin().Burn)
+}
+
+func (h *Handler) moveCoins(w http.ResponseWriter, r *http.Request, move moveCoinsFunc) {
+	var req moveCoinsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	user, err := move(r.Context(), r.PathValue("username"), req.Amount, req.Reason, false)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, balanceResponse{Username: user.Username, Balance: user.Balance})
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+// keepAliveInterval keeps idle streams from being closed by proxies.
+const keepAliveInterval = 15 * time.Second
+
+// Events streams the notifications of the user as Server-Sent Events. A
+// reconnecting client gets everything after the Last-Event-ID it sends.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	var lastEventID int64
+
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		lastEventID, err = strconv.ParseInt(header, 10, 64)
+		if err != nil || lastEventID < 0 {
+			render.Error(w, model.ErrBadRequest)
+
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+
+	notifications, err := h.container.Notifications().Subscribe(r.Context(), username, lastEventID)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case n, ok := <-notifications:
+			if !ok {
+				return
+			}
+
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", n.ID, n.Type, n.Payload)
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
@@ -10,6 +10,10 @@ type Container interface {
 	Users() service.UserManager
 	Auth() service.Authenticator
 	Shop() service.Shop
+	Webhooks() service.Webhooks
+	Notifications() service.Notifications
+	Admin() service.Admin
+	ScheduledTransfers() service.ScheduledTransfers
 }
 type Handler struct {
 	container Container
@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 
 	"github.com/esklo/avito-backend-winter-2025/mocks"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
@@ -22,6 +26,10 @@ type testSuite struct {
 	shop      *mocks.MockShop
 	users     *mocks.MockUserManager
 	auth      *mocks.MockAuthenticator
+	webhooks  *mocks.MockWebhooks
+	notifier  *mocks.MockNotifications
+	admin     *mocks.MockAdmin
+	scheduled *mocks.MockScheduledTransfers
 }
 
 func newTestSuite(t *testing.T) *testSuite {
@@ -31,16 +39,28 @@ func newTestSuite(t *testing.T) *testSuite {
 	shop := mocks.NewMockShop(ctrl)
 	users := mocks.NewMockUserManager(ctrl)
 	auth := mocks.NewMockAuthenticator(ctrl)
+	webhooks := mocks.NewMockWebhooks(ctrl)
+	notifier := mocks.NewMockNotifications(ctrl)
+	admin := mocks.NewMockAdmin(ctrl)
+	scheduled := mocks.NewMockScheduledTransfers(ctrl)
 
 	container.EXPECT().Shop().Return(shop).AnyTimes()
 	container.EXPECT().Users().Return(users).AnyTimes()
 	container.EXPECT().Auth().Return(auth).AnyTimes()
+	container.EXPECT().Webhooks().Return(webhooks).AnyTimes()
+	container.EXPECT().Notifications().Return(notifier).AnyTimes()
+	container.EXPECT().Admin().Return(admin).AnyTimes()
+	container.EXPECT().ScheduledTransfers().Return(scheduled).AnyTimes()
 
 	return &testSuite{
 		container: container,
 		shop:      shop,
 		users:     users,
 		auth:      auth,
+		webhooks:  webhooks,
+		notifier:  notifier,
+		admin:     admin,
+		scheduled: scheduled,
 		handler:   New(container),
 	}
 }
@@ -52,13 +72,13 @@ func TestHandler_Login(t *testing.T) {
 		t.Parallel()
 		ts := newTestSuite(t)
 
-		req := loginRequest{
+		req := client.AuthRequest{
 			Username: "user",
 			Password: "pass",
 		}
 
 		ts.auth.EXPECT().
-			Login(gomock.Any(), req.Username, req.Password).
+			Login(gomock.Any(), req.Username, req.Password, "").
 			Return("test-token", nil)
 
 		w := httptest.NewRecorder()
@@ -70,7 +90,7 @@ func TestHandler_Login(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
-		var resp loginResponse
+		var resp client.AuthResponse
 		err = json.NewDecoder(w.Body).Decode(&resp)
 		require.NoError(t, err)
 		assert.Equal(t, "test-token", resp.Token)
@@ -85,7 +105,7 @@ func TestHandler_Buy(t *testing.T) {
 		ts := newTestSuite(t)
 
 		ts.shop.EXPECT().
-			BuyItem(gomock.Any(), "hoody", "test-user").
+			BuyItem(gomock.Any(), "hoody", model.VariantAttributes{}, "test-user").
 			Return(nil)
 
 		w := httptest.NewRecorder()
@@ -99,6 +119,24 @@ func TestHandler_Buy(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 
+	t.Run("variant", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.shop.EXPECT().
+			BuyItem(gomock.Any(), "hoody", model.VariantAttributes{Size: "L", Color: "pink"}, "test-user").
+			Return(nil)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/buy/hoody?size=L&color=pink", nil)
+		r.SetPathValue("name", "hoody")
+		r = r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "test-user"))
+
+		ts.handler.Buy(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
 	t.Run("unauthorized", func(t *testing.T) {
 		t.Parallel()
 		ts := newTestSuite(t)
@@ -112,6 +150,30 @@ func TestHandler_Buy(t *testing.T) {
 	})
 }
 
+func TestHandler_Variants(t *testing.T) {
+	t.Parallel()
+	ts := newTestSuite(t)
+
+	stock := 2
+	ts.shop.EXPECT().
+		Variants(gomock.Any(), "hoody").
+		Return(&model.Item{ID: 1, Name: "hoody", Price: 300}, []model.Variant{
+			{ID: 1, ItemID: 1, VariantAttributes: model.VariantAttributes{Size: "M"}},
+			{ID: 2, ItemID: 1, VariantAttributes: model.VariantAttributes{Size: "XL"}, PriceDelta: 20, Stock: &stock},
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/items/hoody/variants", nil)
+	r.SetPathValue("name", "hoody")
+	ts.handler.Variants(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp []client.Variant
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, []client.Variant{{Size: "M", Price: 300}, {Size: "XL", Price: 320, Stock: &stock}}, resp)
+}
+
 func TestHandler_Transfer(t *testing.T) {
 	t.Parallel()
 
@@ -119,14 +181,15 @@ func TestHandler_Transfer(t *testing.T) {
 		t.Parallel()
 		ts := newTestSuite(t)
 
-		req := transferRequest{
+		req := client.SendCoinRequest{
 			ToUser: "receiver",
 			Amount: 100,
+			Memo:   "thanks",
 		}
 
 		ts.users.EXPECT().
-			Transfer(gomock.Any(), "sender", req.ToUser, req.Amount).
-			Return(nil)
+			Transfer(gomock.Any(), "sender", req.ToUser, req.Amount, req.Memo).
+			Return(nil, nil)
 
 		w := httptest.NewRecorder()
 		data, err := json.Marshal(req)
@@ -141,6 +204,28 @@ func TestHandler_Transfer(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 
+	t.Run("held transfer", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.users.EXPECT().
+			Transfer(gomock.Any(), "sender", "receiver", 500, "").
+			Return(&model.PendingTransfer{ID: 7, FromUser: "sender", ToUser: "receiver", Amount: 500}, nil)
+
+		r := httptest.NewRequest(http.MethodPost, "/api/sendCoin", strings.NewReader(`{"toUser": "receiver", "amount": 500}`))
+		r = r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "sender"))
+		w := httptest.NewRecorder()
+
+		ts.handler.Transfer(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp client.PendingTransfer
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, int64(7), resp.ID)
+		assert.Equal(t, 500, resp.Amount)
+	})
+
 	t.Run("unauthorized", func(t *testing.T) {
 		t.Parallel()
 		ts := newTestSuite(t)
@@ -154,6 +239,246 @@ func TestHandler_Transfer(t *testing.T) {
 	})
 }
 
+func TestHandler_TransferBatch(t *testing.T) {
+	t.Parallel()
+
+	body := `{"transfers": [{"toUser": "bob", "amount": 10}, {"toUser": "carol", "amount": 20, "memo": "hi"}]}`
+	transfers := []model.BatchTransfer{
+		{ToUser: "bob", Amount: 10},
+		{ToUser: "carol", Amount: 20, Memo: "hi"},
+	}
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/sendCoin/batch", strings.NewReader(body))
+
+		return r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "sender"))
+	}
+
+	t.Run("applied", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		report := model.NewBatchTransferReport(transfers, model.BatchTransferApplied)
+		ts.users.EXPECT().
+			TransferBatch(gomock.Any(), "sender", transfers).
+			Return(report, nil)
+
+		w := httptest.NewRecorder()
+		ts.handler.TransferBatch(w, newRequest())
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"total": 30, "results": [
+			{"toUser": "bob", "amount": 10, "status": "applied"},
+			{"toUser": "carol", "amount": 20, "status": "applied"}
+		]}`, w.Body.String())
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		report := model.NewBatchTransferReport(transfers, model.BatchTransferSkipped)
+		report.Fail(1, fmt.Errorf("%w: receiver not found", model.ErrBadRequest))
+
+		ts.users.EXPECT().
+			TransferBatch(gomock.Any(), "sender", transfers).
+			Return(report, fmt.Errorf("%w: receiver not found", model.ErrBadRequest))
+
+		w := httptest.NewRecorder()
+		ts.handler.TransferBatch(w, newRequest())
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"errors": "bad request: receiver not found", "total": 30, "results": [
+			{"toUser": "bob", "amount": 10, "status": "skipped"},
+			{"toUser": "carol", "amount": 20, "status": "failed", "error": "bad request: receiver not found"}
+		]}`, w.Body.String())
+	})
+
+	t.Run("deactivated sender", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.users.EXPECT().
+			TransferBatch(gomock.Any(), "sender", transfers).
+			Return(nil, model.ErrUserDeactivated)
+
+		w := httptest.NewRecorder()
+		ts.handler.TransferBatch(w, newRequest())
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.NotContains(t, w.Body.String(), "results")
+	})
+}
+
+func TestHandler_AcceptTransfer(t *testing.T) {
+	t.Parallel()
+
+	newRequest := func(id string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/pending-transfers/"+id+"/accept", nil)
+		r.SetPathValue("id", id)
+
+		return r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "receiver"))
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.users.EXPECT().
+			AcceptTransfer(gomock.Any(), "receiver", int64(7)).
+			Return(nil)
+
+		w := httptest.NewRecorder()
+		ts.handler.AcceptTransfer(w, newRequest("7"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("already declined", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.users.EXPECT().
+			AcceptTransfer(gomock.Any(), "receiver", int64(7)).
+			Return(fmt.Errorf("%w: transfer is already declined", model.ErrConflict))
+
+		w := httptest.NewRecorder()
+		ts.handler.AcceptTransfer(w, newRequest("7"))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		w := httptest.NewRecorder()
+		ts.handler.AcceptTransfer(w, newRequest("abc"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_RequestPayment(t *testing.T) {
+	t.Parallel()
+
+	newRequest := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/payment-requests", strings.NewReader(body))
+
+		return r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "requester"))
+	}
+
+	t.Run("requested", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		expiresAt := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+		ts.users.EXPECT().
+			RequestPayment(gomock.Any(), "requester", "payer", 30, "lunch").
+			Return(&model.PaymentRequest{
+				ID:        3,
+				Requester: "requester",
+				Payer:     "payer",
+				Amount:    30,
+				Memo:      "lunch",
+				CreatedAt: expiresAt.Add(-time.Hour),
+				ExpiresAt: expiresAt,
+			}, nil)
+
+		w := httptest.NewRecorder()
+		ts.handler.RequestPayment(w, newRequest(`{"fromUser": "payer", "amount": 30, "memo": "lunch"}`))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{
+			"id": 3,
+			"requester": "requester",
+			"payer": "payer",
+			"amount": 30,
+			"memo": "lunch",
+			"createdAt": "2025-01-31T23:00:00Z",
+			"expiresAt": "2025-02-01T00:00:00Z"
+		}`, w.Body.String())
+	})
+
+	t.Run("unknown payer", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.users.EXPECT().
+			RequestPayment(gomock.Any(), "requester", "nobody", 30, "").
+			Return(nil, model.ErrBadRequest)
+
+		w := httptest.NewRecorder()
+		ts.handler.RequestPayment(w, newRequest(`{"fromUser": "nobody", "amount": 30}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_ScheduleTransfer(t *testing.T) {
+	t.Parallel()
+
+	newRequest := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/scheduled-transfers", strings.NewReader(body))
+
+		return r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "sender"))
+	}
+
+	t.Run("scheduled", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		next := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+		ts.scheduled.EXPECT().
+			Create(gomock.Any(), "sender", &model.ScheduledTransfer{ToUser: "charity", Amount: 50, Schedule: "@monthly"}).
+			Return(&model.ScheduledTransfer{
+				ID:        4,
+				ToUser:    "charity",
+				Amount:    50,
+				Schedule:  "@monthly",
+				Status:    model.ScheduledTransferActive,
+				NextRunAt: next,
+				CreatedAt: next.Add(-time.Hour),
+			}, nil)
+
+		w := httptest.NewRecorder()
+		ts.handler.ScheduleTransfer(w, newRequest(`{"toUser": "charity", "amount": 50, "schedule": "@monthly"}`))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{
+			"id": 4,
+			"toUser": "charity",
+			"amount": 50,
+			"schedule": "@monthly",
+			"status": "active",
+			"nextRunAt": "2025-03-01T00:00:00Z",
+			"createdAt": "2025-02-28T23:00:00Z"
+		}`, w.Body.String())
+	})
+
+	t.Run("run time", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		runAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		ts.scheduled.EXPECT().
+			Create(gomock.Any(), "sender", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, transfer *model.ScheduledTransfer) (*model.ScheduledTransfer, error) {
+				assert.True(t, runAt.Equal(transfer.NextRunAt))
+
+				return nil, fmt.Errorf("%w: at most 20 active scheduled transfers", model.ErrLimitExceeded)
+			})
+
+		w := httptest.NewRecorder()
+		ts.handler.ScheduleTransfer(w, newRequest(`{"toUser": "bob", "amount": 5, "runAt": "2025-03-01T12:00:00Z"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
 func TestHandler_Info(t *testing.T) {
 	t.Parallel()
 
@@ -188,3 +513,392 @@ func TestHandler_Info(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
 }
+
+func TestHandler_Referral(t *testing.T) {
+	t.Parallel()
+	ts := newTestSuite(t)
+
+	ts.users.EXPECT().
+		Referral(gomock.Any(), "sender").
+		Return(&model.ReferralInfo{Code: "ABCDEFGH", Bonus: 100, Rewarded: 1, Remaining: 9}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/referral", nil)
+	r = r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "sender"))
+
+	ts.handler.Referral(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp client.ReferralResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, client.ReferralResponse{Code: "ABCDEFGH", Bonus: 100, Rewarded: 1, Remaining: 9}, resp)
+}
+
+func TestHandler_CreateWebhook(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns webhook with secret", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		req := createWebhookRequest{
+			URL:    "http://example.com/hook",
+			Events: []model.EventType{model.EventTransferCompleted},
+		}
+
+		ts.webhooks.EXPECT().
+			Create(gomock.Any(), req.URL, req.Events).
+			Return(&model.Webhook{ID: 1, URL: req.URL, Secret: "secret", EventTypes: req.Events}, nil)
+
+		w := httptest.NewRecorder()
+		data, err := json.Marshal(req)
+		require.NoError(t, err)
+
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/webhooks", bytes.NewReader(data))
+		ts.handler.CreateWebhook(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp model.Webhook
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, "secret", resp.Secret)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/webhooks", bytes.NewReader([]byte("{")))
+		ts.handler.CreateWebhook(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_RetryWebhookDelivery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("retries delivery", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.webhooks.EXPECT().
+			RetryDelivery(gomock.Any(), int64(7)).
+			Return(&model.WebhookDelivery{ID: 7, Status: model.DeliveryPending}, nil)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/webhooks/deliveries/7/retry", nil)
+		r.SetPathValue("id", "7")
+		ts.handler.RetryWebhookDelivery(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/webhooks/deliveries/x/retry", nil)
+		r.SetPathValue("id", "x")
+		ts.handler.RetryWebhookDelivery(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_MintCoins(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns new balance", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.admin.EXPECT().
+			Mint(gomock.Any(), "alice", 100, "bug bounty", false).
+			Return(&model.User{Username: "alice", Balance: 1100}, nil)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/users/alice/mint",
+			bytes.NewReader([]byte(`{"amount": 100, "reason": "bug bounty"}`)))
+		r.SetPathValue("username", "alice")
+		ts.handler.MintCoins(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp balanceResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, balanceResponse{Username: "alice", Balance: 1100}, resp)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/users/alice/mint", bytes.NewReader([]byte("{")))
+		r.SetPathValue("username", "alice")
+		ts.handler.MintCoins(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_BurnCoins(t *testing.T) {
+	t.Parallel()
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.admin.EXPECT().
+			Burn(gomock.Any(), "alice", 5000, "refund", false).
+			Return(nil, model.ErrInsufficientFunds)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/users/alice/burn",
+			bytes.NewReader([]byte(`{"amount": 5000, "reason": "refund"}`)))
+		r.SetPathValue("username", "alice")
+		ts.handler.BurnCoins(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_ReverseTransfer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns reversed transfer", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.admin.EXPECT().
+			ReverseTransfer(gomock.Any(), int64(7), "sent by mistake", true, false).
+			Return(&model.Transfer{ID: 7, FromUser: "alice", ToUser: "bob", Amount: 300, Reversed: 100}, nil)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/transfers/7/reverse",
+			bytes.NewReader([]byte(`{"reason": "sent by mistake", "partial": true}`)))
+		r.SetPathValue("id", "7")
+		ts.handler.ReverseTransfer(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp transferResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, transferResponse{ID: 7, FromUser: "alice", ToUser: "bob", Amount: 300, Reversed: 100}, resp)
+	})
+
+	t.Run("receiver spent the coins", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.admin.EXPECT().
+			ReverseTransfer(gomock.Any(), int64(7), "sent by mistake", false, false).
+			Return(nil, model.ErrInsufficientFunds)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/transfers/7/reverse",
+			bytes.NewReader([]byte(`{"reason": "sent by mistake"}`)))
+		r.SetPathValue("id", "7")
+		ts.handler.ReverseTransfer(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/transfers/x/reverse",
+			bytes.NewReader([]byte(`{"reason": "sent by mistake"}`)))
+		r.SetPathValue("id", "x")
+		ts.handler.ReverseTransfer(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_SetTransferLimits(t *testing.T) {
+	t.Parallel()
+	ts := newTestSuite(t)
+
+	limit := 100
+	override := &model.TransferLimitsOverride{MaxAmount: &limit}
+
+	ts.admin.EXPECT().
+		SetTransferLimits(gomock.Any(), "alice", override, false).
+		Return(&model.UserTransferLimits{
+			Username: "alice",
+			Limits:   model.TransferLimits{MaxAmount: 100, DailyCount: 10},
+			Override: override,
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/api/admin/users/alice/transfer-limits",
+		bytes.NewReader([]byte(`{"maxAmount": 100, "dailyCount": null}`)))
+	r.SetPathValue("username", "alice")
+	ts.handler.SetTransferLimits(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"username": "alice",
+		"limits": {"maxAmount": 100, "dailyAmount": 0, "dailyCount": 10},
+		"override": {"maxAmount": 100, "dailyAmount": null, "dailyCount": null}
+	}`, w.Body.String())
+}
+
+func TestHandler_Reconcile(t *testing.T) {
+	t.Parallel()
+	ts := newTestSuite(t)
+
+	ts.admin.EXPECT().
+		Reconcile(gomock.Any()).
+		Return(&model.Reconciliation{
+			Issued:        1000,
+			Balances:      1005,
+			Discrepancies: []model.Discrepancy{{Username: "alice", Balance: 1005, Expected: 1000}},
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/admin/reconciliation", nil)
+	ts.handler.Reconcile(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"balanced": false,
+		"issued": 1000,
+		"burned": 0,
+		"balances": 1005,
+		"held": 0,
+		"discrepancies": [{"username": "alice", "balance": 1005, "expected": 1000}]
+	}`, w.Body.String())
+}
+
+func TestHandler_Events(t *testing.T) {
+	t.Parallel()
+
+	t.Run("streams notifications", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		notifications := make(chan model.Notification, 2)
+		notifications <- model.Notification{
+			ID:      8,
+			Type:    model.NotificationCoinsReceived,
+			Payload: json.RawMessage(`{"fromUser":"bob","amount":5}`),
+		}
+		notifications <- model.Notification{
+			ID:      9,
+			Type:    model.NotificationBalanceChanged,
+			Payload: json.RawMessage(`{"balance":1005}`),
+		}
+		close(notifications)
+
+		ts.notifier.EXPECT().
+			Subscribe(gomock.Any(), "alice", int64(7)).
+			Return(notifications, nil)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+		r.Header.Set("Last-Event-ID", "7")
+		r = r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "alice"))
+
+		ts.handler.Events(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+		assert.Equal(t, "id: 8\nevent: coinsReceived\ndata: {\"fromUser\":\"bob\",\"amount\":5}\n\n"+
+			"id: 9\nevent: balanceChanged\ndata: {\"balance\":1005}\n\n", w.Body.String())
+	})
+
+	t.Run("invalid last event id", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+		r.Header.Set("Last-Event-ID", "abc")
+		r = r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "alice"))
+
+		ts.handler.Events(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_AddToCart(t *testing.T) {
+	t.Parallel()
+	ts := newTestSuite(t)
+
+	ts.shop.EXPECT().
+		AddToCart(gomock.Any(), "alice", "cup", model.VariantAttributes{}, 1).
+		Return(&model.Cart{Items: []model.CartItem{{ItemID: 1, Item: "cup", Quantity: 2, Price: 20}}}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/cart/items", bytes.NewReader([]byte(`{"item": "cup"}`)))
+	r = r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "alice"))
+	ts.handler.AddToCart(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp client.CartResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, client.CartResponse{
+		Items: []client.CartItem{{Item: "cup", Quantity: 2, Price: 20, Total: 40}},
+		Total: 40,
+	}, resp)
+}
+
+func TestHandler_Checkout(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns bought lines", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		stock := 3
+		ts.shop.EXPECT().
+			Checkout(gomock.Any(), "alice").
+			Return(&model.Cart{Items: []model.CartItem{
+				{ItemID: 1, Item: "cup", Quantity: 2, Price: 20},
+				{ItemID: 2, Item: "pen", Quantity: 1, Price: 10, Stock: &stock},
+			}}, nil)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/checkout", nil)
+		r = r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "alice"))
+		ts.handler.Checkout(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp client.CartResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, client.CartResponse{
+			Items: []client.CartItem{
+				{Item: "cup", Quantity: 2, Price: 20, Total: 40},
+				{Item: "pen", Quantity: 1, Price: 10, Total: 10, Stock: &stock},
+			},
+			Total: 50,
+		}, resp)
+	})
+
+	t.Run("item out of stock", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.shop.EXPECT().
+			Checkout(gomock.Any(), "alice").
+			Return(nil, fmt.Errorf("%w: cup", model.ErrOutOfStock))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/checkout", nil)
+		r = r.WithContext(context.WithValue(r.Context(), CtxUsernameKey, "alice"))
+		ts.handler.Checkout(w, r)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
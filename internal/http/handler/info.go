@@ -6,6 +6,7 @@ import (
 
 	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
 )
 
 func (h *Handler) Info(w http.ResponseWriter, r *http.Request) {
@@ -23,7 +24,51 @@ func (h *Handler) Info(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	render.Success(w, info)
+	render.Success(w, newInfoResponse(info))
+}
+
+func newInfoResponse(info *model.Info) client.InfoResponse {
+	resp := client.InfoResponse{
+		Coins:     info.Coins,
+		Inventory: make([]client.Item, 0, len(info.Inventory)),
+		CoinHistory: client.CoinHistory{
+			Received: []client.ReceivedCoins{},
+			Sent:     []client.SentCoins{},
+		},
+	}
+
+	for _, item := range info.Inventory {
+		resp.Inventory = append(resp.Inventory, client.Item{
+			Type:     item.Type,
+			Size:     item.Size,
+			Color:    item.Color,
+			Quantity: item.Quantity,
+		})
+	}
+
+	if info.CoinHistory == nil {
+		return resp
+	}
+
+	for _, r := range info.CoinHistory.Received {
+		resp.CoinHistory.Received = append(resp.CoinHistory.Received, client.ReceivedCoins{
+			FromUser: r.FromUser,
+			Amount:   r.Amount,
+			Memo:     r.Memo,
+			Reversal: r.Reversal,
+		})
+	}
+
+	for _, s := range info.CoinHistory.Sent {
+		resp.CoinHistory.Sent = append(resp.CoinHistory.Sent, client.SentCoins{
+			ToUser:   s.ToUser,
+			Amount:   s.Amount,
+			Memo:     s.Memo,
+			Reversal: s.Reversal,
+		})
+	}
+
+	return resp
 }
 
 func usernameFromCtx(ctx context.Context) (string, error) {
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+func (h *Handler) TransferLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := h.container.Admin().TransferLimits(r.Context(), r.PathValue("username"))
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, limits)
+}
+
+func (h *Handler) SetTransferLimits(w http.ResponseWriter, r *http.Request) {
+	var req model.TransferLimitsOverride
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	limits, err := h.container.Admin().SetTransferLimits(r.Context(), r.PathValue("username"), &req, false)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, limits)
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
+)
+
+func (h *Handler) RequestPayment(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	var req client.RequestPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	request, err := h.container.Users().RequestPayment(r.Context(), username, req.FromUser, req.Amount, req.Memo)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, paymentRequest(request))
+}
+
+func (h *Handler) PaymentRequests(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	requests, err := h.container.Users().PaymentRequests(r.Context(), username)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	resp := client.PaymentRequestsResponse{
+		Incoming: make([]client.PaymentRequest, 0, len(requests.Incoming)),
+		Outgoing: make([]client.PaymentRequest, 0, len(requests.Outgoing)),
+	}
+
+	for i := range requests.Incoming {
+		resp.Incoming = append(resp.Incoming, paymentRequest(&requests.Incoming[i]))
+	}
+
+	for i := range requests.Outgoing {
+		resp.Outgoing = append(resp.Outgoing, paymentRequest(&requests.Outgoing[i]))
+	}
+
+	render.Success(w, resp)
+}
+
+func (h *Handler) PayRequest(w http.ResponseWriter, r *http.Request) {
+	h.resolveTransfer(w, r, h.container.Users().PayRequest)
+}
+
+func (h *Handler) DeclineRequest(w http.ResponseWriter, r *http.Request) {
+	h.resolveTransfer(w, r, h.container.Users().DeclineRequest)
+}
+
+func paymentRequest(p *model.PaymentRequest) client.PaymentRequest {
+	return client.PaymentRequest{
+		ID:        p.ID,
+		Requester: p.Requester,
+		Payer:     p.Payer,
+		Amount:    p.Amount,
+		Memo:      p.Memo,
+		CreatedAt: p.CreatedAt,
+		ExpiresAt: p.ExpiresAt,
+	}
+}
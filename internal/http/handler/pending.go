@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
+)
+
+type resolveTransferFunc func(ctx context.Context, username string, id int64) error
+
+func (h *Handler) HoldTransfer(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	var req client.SendCoinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	transfer, err := h.container.Users().HoldTransfer(r.Context(), username, req.ToUser, req.Amount, req.Memo)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, pendingTransfer(transfer))
+}
+
+func (h *Handler) PendingTransfers(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	transfers, err := h.container.Users().PendingTransfers(r.Context(), username)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	resp := client.PendingTransfersResponse{
+		Incoming: make([]client.PendingTransfer, 0, len(transfers.Incoming)),
+		Outgoing: make([]client.PendingTransfer, 0, len(transfers.Outgoing)),
+	}
+
+	for i := range transfers.Incoming {
+		resp.Incoming = append(resp.Incoming, pendingTransfer(&transfers.Incoming[i]))
+	}
+
+	for i := range transfers.Outgoing {
+		resp.Outgoing = append(resp.Outgoing, pendingTransfer(&transfers.Outgoing[i]))
+	}
+
+	render.Success(w, resp)
+}
+
+func (h *Handler) AcceptTransfer(w http.ResponseWriter, r *http.Request) {
+	h.resolveTransfer(w, r, h.container.Users().AcceptTransfer)
+}
+
+func (h *Handler) DeclineTransfer(w http.ResponseWriter, r *http.Request) {
+	h.resolveTransfer(w, r, h.container.Users().DeclineTransfer)
+}
+
+func (h *Handler) resolveTransfer(w http.ResponseWriter, r *http.Request, resolve resolveTransferFunc) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	if err := resolve(r.Context(), username, id); err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, nil)
+}
+
+func pendingTransfer(t *model.PendingTransfer) client.PendingTransfer {
+	return client.PendingTransfer{
+		ID:        t.ID,
+		FromUser:  t.FromUser,
+		ToUser:    t.ToUser,
+		Amount:    t.Amount,
+		Memo:      t.Memo,
+		CreatedAt: t.CreatedAt,
+		ExpiresAt: t.ExpiresAt,
+	}
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+type reconciliationResponse struct {
+	Balanced bool `json:"balanced"`
+	*model.Reconciliation
+}
+
+func (h *Handler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	result, err := h.container.Admin().Reconcile(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, reconciliationResponse{Balanced: result.Balanced(), Reconciliation: result})
+}
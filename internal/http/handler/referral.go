@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
+)
+
+func (h *Handler) Referral(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	info, err := h.container.Users().Referral(r.Context(), username)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, client.ReferralResponse{
+		Code:      info.Code,
+		Bonus:     info.Bonus,
+		Rewarded:  info.Rewarded,
+		Remaining: info.Remaining,
+	})
+}
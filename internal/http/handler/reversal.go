@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+// userTransfersLimit bounds the transfers listed for an admin.
+const userTransfersLimit = 100
+
+type reverseTransferRequest struct {
+	Reason  string `json:"reason"`
+	Partial bool   `json:"partial"`
+}
+
+type transferResponse struct {
+	ID        int64     `json:"id"`
+	FromUser  string    `json:"fromUser"`
+	ToUser    string    `json:"toUser"`
+	Amount    int       `json:"amount"`
+	Memo      string    `json:"memo,omitempty"`
+	Reversed  int       `json:"reversed"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (h *Handler) UserTransfers(w http.ResponseWriter, r *http.Request) {
+	transfers, err := h.container.Admin().ListUserTransfers(r.Context(), r.PathValue("username"), userTransfersLimit)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	resp := make([]transferResponse, 0, len(transfers))
+	for i := range transfers {
+		resp = append(resp, newTransferResponse(&transfers[i]))
+	}
+
+	render.Success(w, resp)
+}
+
+func (h *Handler) ReverseTransfer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	var req reverseTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	transfer, err := h.container.Admin().ReverseTransfer(r.Context(), id, req.Reason, req.Partial, false)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, newTransferResponse(transfer))
+}
+
+func newTransferResponse(t *model.Transfer) transferResponse {
+	return transferResponse{
+		ID:        t.ID,
+		FromUser:  t.FromUser,
+		ToUser:    t.ToUser,
+		Amount:    t.Amount,
+		Memo:      t.Memo,
+		Reversed:  t.Reversed,
+		CreatedAt: t.CreatedAt,
+	}
+}
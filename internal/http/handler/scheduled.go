@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
+)
+
+func (h *Handler) ScheduleTransfer(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	var req client.ScheduleTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	transfer := &model.ScheduledTransfer{
+		ToUser:   req.ToUser,
+		Amount:   req.Amount,
+		Memo:     req.Memo,
+		Schedule: req.Schedule,
+	}
+	if req.RunAt != nil {
+		transfer.NextRunAt = *req.RunAt
+	}
+
+	transfer, err = h.container.ScheduledTransfers().Create(r.Context(), username, transfer)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, scheduledTransfer(transfer))
+}
+
+func (h *Handler) ScheduledTransfers(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	transfers, err := h.container.ScheduledTransfers().List(r.Context(), username)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	resp := make([]client.ScheduledTransfer, 0, len(transfers))
+	for i := range transfers {
+		resp = append(resp, scheduledTransfer(&transfers[i]))
+	}
+
+	render.Success(w, resp)
+}
+
+func (h *Handler) ScheduledTransferRuns(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	runs, err := h.container.ScheduledTransfers().Runs(r.Context(), username, id)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	resp := make([]client.ScheduledTransferRun, 0, len(runs))
+	for _, run := range runs {
+		resp = append(resp, client.ScheduledTransferRun{
+			ScheduledAt: run.ScheduledAt,
+			Status:      string(run.Status),
+			Error:       run.Error,
+			CreatedAt:   run.CreatedAt,
+		})
+	}
+
+	render.Success(w, resp)
+}
+
+func (h *Handler) CancelScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	h.resolveTransfer(w, r, h.container.ScheduledTransfers().Cancel)
+}
+
+func scheduledTransfer(t *model.ScheduledTransfer) client.ScheduledTransfer {
+	transfer := client.ScheduledTransfer{
+		ID:        t.ID,
+		ToUser:    t.ToUser,
+		Amount:    t.Amount,
+		Memo:      t.Memo,
+		Schedule:  t.Schedule,
+		Status:    string(t.Status),
+		CreatedAt: t.CreatedAt,
+	}
+
+	if t.Status == model.ScheduledTransferActive {
+		next := t.NextRunAt
+		transfer.NextRunAt = &next
+	}
+
+	return transfer
+}
@@ -6,13 +6,9 @@ import (
 
 	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
 )
 
-type transferRequest struct {
-	ToUser string `json:"toUser"`
-	Amount int    `json:"amount"`
-}
-
 func (h *Handler) Transfer(w http.ResponseWriter, r *http.Request) {
 	username, err := usernameFromCtx(r.Context())
 	if err != nil {
@@ -21,19 +17,78 @@ func (h *Handler) Transfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req transferRequest
+	var req client.SendCoinRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		render.Error(w, model.ErrBadRequest)
 
 		return
 	}
 
-	err = h.container.Users().Transfer(r.Context(), username, req.ToUser, req.Amount)
+	pending, err := h.container.Users().Transfer(r.Context(), username, req.ToUser, req.Amount, req.Memo)
 	if err != nil {
 		render.Error(w, err)
 
 		return
 	}
 
+	if pending != nil {
+		render.Success(w, pendingTransfer(pending))
+
+		return
+	}
+
 	render.Success(w, nil)
 }
+
+func (h *Handler) TransferBatch(w http.ResponseWriter, r *http.Request) {
+	username, err := usernameFromCtx(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	var req client.SendCoinBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	transfers := make([]model.BatchTransfer, len(req.Transfers))
+	for i, t := range req.Transfers {
+		transfers[i] = model.BatchTransfer{ToUser: t.ToUser, Amount: t.Amount, Memo: t.Memo}
+	}
+
+	report, err := h.container.Users().TransferBatch(r.Context(), username, transfers)
+	if err != nil {
+		if report != nil {
+			render.ErrorWith(w, err, map[string]any{
+				"total":   report.Total,
+				"results": batchResults(report),
+			})
+
+			return
+		}
+
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, client.SendCoinBatchResponse{Total: report.Total, Results: batchResults(report)})
+}
+
+func batchResults(report *model.BatchTransferReport) []client.SendCoinResult {
+	results := make([]client.SendCoinResult, len(report.Results))
+	for i, res := range report.Results {
+		results[i] = client.SendCoinResult{
+			ToUser: res.ToUser,
+			Amount: res.Amount,
+			Status: string(res.Status),
+			Error:  res.Error,
+		}
+	}
+
+	return results
+}
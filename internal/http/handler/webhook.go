@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+type createWebhookRequest struct {
+	URL    string            `json:"url"`
+	Events []model.EventType `json:"events"`
+}
+
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	webhook, err := h.container.Webhooks().Create(r.Context(), req.URL, req.Events)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, webhook)
+}
+
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.container.Webhooks().List(r.Context())
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, webhooks)
+}
+
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	if err := h.container.Webhooks().Delete(r.Context(), id); err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, nil)
+}
+
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	deliveries, err := h.container.Webhooks().ListDeliveries(r.Context(), id)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, deliveries)
+}
+
+func (h *Handler) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		render.Error(w, model.ErrBadRequest)
+
+		return
+	}
+
+	delivery, err := h.container.Webhooks().RetryDelivery(r.Context(), id)
+	if err != nil {
+		render.Error(w, err)
+
+		return
+	}
+
+	render.Success(w, delivery)
+}
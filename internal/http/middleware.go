@@ -1,8 +1,14 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/http/handler"
@@ -32,6 +38,127 @@ func (s *Server) withAuth(next http.HandlerFunc) http.HandlerFunc {
 	})
 }
 
+// withAdmin lets through only the users listed in the app.admins setting.
+func (s *Server) withAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return s.withAuth(func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(handler.CtxUsernameKey).(string)
+		if !slices.Contains(s.container.Config().App.Admins, username) {
+			render.Error(w, model.ErrForbidden)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withValidation rejects requests that do not match the spec of the route
+// registered under pattern.
+func (s *Server) withValidation(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.spec.Validate(pattern, r); err != nil {
+			render.Error(w, err)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// withIdempotency runs a request sent with an Idempotency-Key header once and
+// answers its retries with the stored response. Server errors are not stored,
+// so such requests can be retried with the same key.
+func (s *Server) withIdempotency(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keyName := r.Header.Get("Idempotency-Key")
+		if keyName == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			render.Error(w, model.ErrBadRequest)
+
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		_, _ = fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.Path)
+		_, _ = hash.Write(body)
+
+		username, _ := r.Context().Value(handler.CtxUsernameKey).(string)
+		idempotency := s.container.Idempotency()
+
+		key, err := idempotency.Begin(r.Context(), username, keyName, hex.EncodeToString(hash.Sum(nil)))
+		if err != nil {
+			render.Error(w, err)
+
+			return
+		}
+
+		if key.Completed() {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(key.StatusCode)
+			_, _ = w.Write(key.Response)
+
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		ctx := context.WithoutCancel(r.Context())
+		succeeded := false
+
+		// Runs on server errors and panics alike.
+		defer func() {
+			if succeeded {
+				return
+			}
+
+			if err := idempotency.Abort(ctx, key); err != nil {
+				s.container.Log().Error("abort idempotency key", "error", err)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		// A key that fails to complete stays reserved, retries are refused
+		// rather than run twice.
+		succeeded = true
+
+		if err := idempotency.Complete(ctx, key, rec.status, rec.body.Bytes()); err != nil {
+			s.container.Log().Error("complete idempotency key", "error", err)
+		}
+	}
+}
+
+// responseRecorder passes the response through and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+
+	return r.ResponseWriter.Write(b)
+}
+
 func (s *Server) withRecover(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -46,8 +173,8 @@ func (s *Server) withRecover(next http.HandlerFunc) http.HandlerFunc {
 func (s *Server) withCORS(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID, Idempotency-Key")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,32 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	swaggerFiles "github.com/swaggo/files"
+)
+
+// swaggerInitializer points the bundled Swagger UI at /openapi.json instead
+// of the demo petstore spec.
+//
+//go:embed swagger-initializer.js
+var swaggerInitializer []byte
+
+// Docs serves the Swagger UI. It expects the mount prefix to be stripped.
+func Docs() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(swaggerFiles.HTTP))
+	mux.HandleFunc("/swagger-initializer.js", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		_, _ = w.Write(swaggerInitializer)
+	})
+
+	return mux
+}
+
+// ServeJSON writes the specification as JSON.
+func (s *Spec) ServeJSON(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(s.json)
+}
@@ -0,0 +1,166 @@
+// Package openapi holds the OpenAPI specification of the HTTP API and
+// validates incoming requests against it.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+// Spec is the parsed specification with its operations indexed by the
+// http.ServeMux pattern they are served under, e.g. "POST /api/sendCoin".
+type Spec struct {
+	json    []byte
+	routes  map[string]*routers.Route
+	options *openapi3filter.Options
+}
+
+// Load parses and validates the embedded specification.
+func Load() (*Spec, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(specYAML)
+	if err != nil {
+		return nil, fmt.Errorf("load openapi spec: %w", err)
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("validate openapi spec: %w", err)
+	}
+
+	data, err := doc.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("marshal openapi spec: %w", err)
+	}
+
+	s := &Spec{
+		json:   data,
+		routes: make(map[string]*routers.Route),
+		options: &openapi3filter.Options{
+			// Tokens are checked by the auth middleware.
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}
+	s.options.WithCustomSchemaErrorFunc(schemaErrorMessage)
+
+	for path, item := range doc.Paths.Map() {
+		for method, operation := range item.Operations() {
+			s.routes[method+" "+path] = &routers.Route{
+				Spec:      doc,
+				Path:      path,
+				PathItem:  item,
+				Method:    method,
+				Operation: operation,
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// MustLoad is like Load but panics on error. The spec is embedded, so an
+// error means a broken build rather than a runtime condition.
+func MustLoad() *Spec {
+	s, err := Load()
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// Routes returns the sorted patterns of all operations in the spec.
+func (s *Spec) Routes() []string {
+	routes := make([]string, 0, len(s.routes))
+	for pattern := range s.routes {
+		routes = append(routes, pattern)
+	}
+
+	slices.Sort(routes)
+
+	return routes
+}
+
+// Validate checks the parameters and body of r against the operation served
+// under pattern. Requests to patterns missing from the spec are not checked.
+func (s *Spec) Validate(pattern string, r *http.Request) error {
+	route, ok := s.routes[pattern]
+	if !ok {
+		return nil
+	}
+
+	// Handlers decode the body as JSON whatever the header says, so clients
+	// omitting it keep working.
+	if r.ContentLength != 0 && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	pathParams := make(map[string]string)
+
+	for _, params := range []openapi3.Parameters{route.PathItem.Parameters, route.Operation.Parameters} {
+		for _, param := range params {
+			if param.Value.In == openapi3.ParameterInPath {
+				pathParams[param.Value.Name] = r.PathValue(param.Value.Name)
+			}
+		}
+	}
+
+	err := openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    s.options,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s", model.ErrBadRequest, errorMessage(err))
+	}
+
+	return nil
+}
+
+func errorMessage(err error) string {
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		return err.Error()
+	}
+
+	var schemaErr *openapi3.SchemaError
+
+	switch {
+	case reqErr.Parameter != nil:
+		return fmt.Sprintf("%s %s: %s", reqErr.Parameter.In, reqErr.Parameter.Name, innerMessage(reqErr))
+	case errors.As(reqErr.Err, &schemaErr):
+		return "body: " + schemaErrorMessage(schemaErr)
+	case reqErr.RequestBody != nil:
+		return "body: " + innerMessage(reqErr)
+	default:
+		return reqErr.Error()
+	}
+}
+
+func innerMessage(err *openapi3filter.RequestError) string {
+	if err.Err != nil {
+		return err.Err.Error()
+	}
+
+	return err.Reason
+}
+
+func schemaErrorMessage(err *openapi3.SchemaError) string {
+	if path := err.JSONPointer(); len(path) > 0 {
+		return strings.Join(path, ".") + ": " + err.Reason
+	}
+
+	return err.Reason
+}
@@ -13,11 +13,21 @@ func Success(w http.ResponseWriter, data any) {
 }
 
 func Error(w http.ResponseWriter, err error) {
-	code := getStatusCode(err)
+	ErrorWith(w, err, nil)
+}
 
-	render(w, code, map[string]any{
+// ErrorWith reports err like Error, adding fields to the response, e.g. the
+// results of a batch that was rejected.
+func ErrorWith(w http.ResponseWriter, err error, fields map[string]any) {
+	resp := map[string]any{
 		"errors": err.Error(),
-	})
+	}
+
+	for k, v := range fields {
+		resp[k] = v
+	}
+
+	render(w, StatusCode(err), resp)
 }
 
 func render(w http.ResponseWriter, code int, resp any) {
@@ -29,14 +39,21 @@ func render(w http.ResponseWriter, code int, resp any) {
 	}
 }
 
-func getStatusCode(err error) int {
+// StatusCode returns the HTTP status that err is reported with.
+func StatusCode(err error) int {
 	switch {
-	case errors.Is(err, model.ErrBadRequest):
+	case errors.Is(err, model.ErrBadRequest),
+		errors.Is(err, model.ErrInsufficientFunds),
+		errors.Is(err, model.ErrLimitExceeded):
 		return http.StatusBadRequest
 	case errors.Is(err, model.ErrUnauthorized):
 		return http.StatusUnauthorized
+	case errors.Is(err, model.ErrForbidden):
+		return http.StatusForbidden
 	case errors.Is(err, model.ErrNotFound):
 		return http.StatusNotFound
+	case errors.Is(err, model.ErrConflict):
+		return http.StatusConflict
 	default:
 		return http.StatusInternalServerError
 	}
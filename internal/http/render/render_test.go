@@ -113,7 +113,17 @@ func TestError(t *testing.T) {
 	}
 }
 
-func TestGetStatusCode(t *testing.T) {
+func TestErrorWith(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+
+	ErrorWith(w, model.ErrInsufficientFunds, map[string]any{"total": 30})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"errors":"insufficient funds","total":30}`, w.Body.String())
+}
+
+func TestStatusCode(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
@@ -136,6 +146,26 @@ func TestGetStatusCode(t *testing.T) {
 			err:          model.ErrNotFound,
 			expectedCode: http.StatusNotFound,
 		},
+		{
+			name:         "forbidden error",
+			err:          model.ErrForbidden,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "conflict error",
+			err:          model.ErrConflict,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "insufficient funds error",
+			err:          model.ErrInsufficientFunds,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "limit exceeded error",
+			err:          model.ErrLimitExceeded,
+			expectedCode: http.StatusBadRequest,
+		},
 		{
 			name:         "wrapped error",
 			err:          errors.Join(model.ErrBadRequest, errors.New("context")),
@@ -151,7 +181,7 @@ func TestGetStatusCode(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			code := getStatusCode(tt.err)
+			code := StatusCode(tt.err)
 			assert.Equal(t, tt.expectedCode, code)
 		})
 	}
@@ -2,7 +2,9 @@ package http
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/esklo/avito-backend-winter-2025/internal/service"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/http/handler"
+	"github.com/esklo/avito-backend-winter-2025/internal/http/openapi"
 )
 
 //go:generate mockgen -destination=../../mocks/mock_server_container.go -package=mocks github.com/esklo/avito-backend-winter-2025/internal/http Container
@@ -20,18 +23,29 @@ type Container interface {
 	Users() service.UserManager
 	Auth() service.Authenticator
 	Shop() service.Shop
+	Webhooks() service.Webhooks
+	Notifications() service.Notifications
+	Idempotency() service.Idempotency
+	Admin() service.Admin
+	ScheduledTransfers() service.ScheduledTransfers
 }
 
 type Server struct {
 	srv       *http.Server
 	router    *http.ServeMux
 	container Container
+	spec      *openapi.Spec
+
+	// routes lists the patterns of the API routes, which must all be
+	// described in the spec.
+	routes []string
 }
 
 func NewServer(container Container) *Server {
 	s := &Server{
 		router:    http.NewServeMux(),
 		container: container,
+		spec:      openapi.MustLoad(),
 	}
 
 	s.setupRoutes()
@@ -48,9 +62,31 @@ func NewServer(container Container) *Server {
 func (s *Server) Run(ctx context.Context) error {
 	s.container.Log().Info("listening on", "addr", s.srv.Addr)
 
-	defer func() { _ = s.Shutdown(ctx) }()
+	// Requests inherit ctx, so that event streams end on shutdown.
+	s.srv.BaseContext = func(net.Listener) context.Context { return ctx }
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.Shutdown(context.WithoutCancel(ctx))
+		case <-stopped:
+		}
+	}()
+
+	err := s.srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
 
-	return s.srv.ListenAndServe()
+	return err
+}
+
+// Handler returns the router, e.g. to serve it with httptest.
+func (s *Server) Handler() http.Handler {
+	return s.srv.Handler
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
@@ -60,8 +96,54 @@ func (s *Server) Shutdown(ctx context.Context) error {
 func (s *Server) setupRoutes() {
 	h := handler.New(s.container)
 
-	s.router.Handle("POST /api/auth", s.withMiddlewares(h.Login))
-	s.router.Handle("GET /api/info", s.withAuth(h.Info))
-	s.router.Handle("GET /api/buy/{name}", s.withAuth(h.Buy))
-	s.router.Handle("POST /api/sendCoin", s.withAuth(h.Transfer))
+	s.handle("POST /api/auth", s.withMiddlewares, h.Login)
+	s.handle("GET /api/info", s.withAuth, h.Info)
+	s.handle("GET /api/buy/{name}", s.withAuth, s.withIdempotency(h.Buy))
+	s.handle("GET /api/items/{name}/variants", s.withAuth, h.Variants)
+	s.handle("GET /api/cart", s.withAuth, h.Cart)
+	s.handle("POST /api/cart/items", s.withAuth, s.withIdempotency(h.AddToCart))
+	s.handle("DELETE /api/cart/items/{name}", s.withAuth, s.withIdempotency(h.RemoveFromCart))
+	s.handle("POST /api/checkout", s.withAuth, s.withIdempotency(h.Checkout))
+	s.handle("POST /api/sendCoin", s.withAuth, s.withIdempotency(h.Transfer))
+	s.handle("POST /api/sendCoin/batch", s.withAuth, s.withIdempotency(h.TransferBatch))
+	s.handle("POST /api/pending-transfers", s.withAuth, s.withIdempotency(h.HoldTransfer))
+	s.handle("GET /api/pending-transfers", s.withAuth, h.PendingTransfers)
+	s.handle("POST /api/pending-transfers/{id}/accept", s.withAuth, s.withIdempotency(h.AcceptTransfer))
+	s.handle("POST /api/pending-transfers/{id}/decline", s.withAuth, s.withIdempotency(h.DeclineTransfer))
+	s.handle("POST /api/payment-requests", s.withAuth, s.withIdempotency(h.RequestPayment))
+	s.handle("GET /api/payment-requests", s.withAuth, h.PaymentRequests)
+	s.handle("POST /api/payment-requests/{id}/pay", s.withAuth, s.withIdempotency(h.PayRequest))
+	s.handle("POST /api/payment-requests/{id}/decline", s.withAuth, s.withIdempotency(h.DeclineRequest))
+	s.handle("POST /api/scheduled-transfers", s.withAuth, s.withIdempotency(h.ScheduleTransfer))
+	s.handle("GET /api/scheduled-transfers", s.withAuth, h.ScheduledTransfers)
+	s.handle("GET /api/scheduled-transfers/{id}/runs", s.withAuth, h.ScheduledTransferRuns)
+	s.handle("POST /api/scheduled-transfers/{id}/cancel", s.withAuth, s.withIdempotency(h.CancelScheduledTransfer))
+	s.handle("GET /api/events", s.withAuth, h.Events)
+	s.handle("GET /api/referral", s.withAuth, h.Referral)
+
+	s.handle("POST /api/admin/webhooks", s.withAdmin, h.CreateWebhook)
+	s.handle("GET /api/admin/webhooks", s.withAdmin, h.ListWebhooks)
+	s.handle("DELETE /api/admin/webhooks/{id}", s.withAdmin, h.DeleteWebhook)
+	s.handle("GET /api/admin/webhooks/{id}/deliveries", s.withAdmin, h.ListWebhookDeliveries)
+	s.handle("POST /api/admin/webhooks/deliveries/{id}/retry", s.withAdmin, h.RetryWebhookDelivery)
+	s.handle("POST /api/admin/users/{username}/mint", s.withAdmin, s.withIdempotency(h.MintCoins))
+	s.handle("POST /api/admin/users/{username}/burn", s.withAdmin, s.withIdempotency(h.BurnCoins))
+	s.handle("GET /api/admin/users/{username}/transfer-limits", s.withAdmin, h.TransferLimits)
+	s.handle("PUT /api/admin/users/{username}/transfer-limits", s.withAdmin, h.SetTransferLimits)
+	s.handle("GET /api/admin/users/{username}/transfers", s.withAdmin, h.UserTransfers)
+	s.handle("POST /api/admin/transfers/{id}/reverse", s.withAdmin, s.withIdempotency(h.ReverseTransfer))
+	s.handle("GET /api/admin/reconciliation", s.withAdmin, h.Reconcile)
+
+	// The routes match their methods only, so preflight requests of every
+	// route are answered here, by withCORS.
+	s.router.Handle("OPTIONS /", s.withMiddlewares(func(http.ResponseWriter, *http.Request) {}))
+	s.router.Handle("GET /openapi.json", s.withMiddlewares(s.spec.ServeJSON))
+	s.router.Handle("GET /docs/", http.StripPrefix("/docs", openapi.Docs()))
+}
+
+// handle registers an API route. Requests that pass the middleware are
+// validated against the spec before they reach h.
+func (s *Server) handle(pattern string, middleware func(http.HandlerFunc) http.HandlerFunc, h http.HandlerFunc) {
+	s.routes = append(s.routes, pattern)
+	s.router.Handle(pattern, middleware(s.withValidation(pattern, h)))
 }
@@ -0,0 +1,340 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type testSuite struct {
+	server      *Server
+	users       *mocks.MockUserManager
+	shop        *mocks.MockShop
+	admin       *mocks.MockAdmin
+	idempotency *mocks.MockIdempotency
+}
+
+func newTestSuite(t *testing.T) *testSuite {
+	ctrl := gomock.NewController(t)
+
+	container := mocks.NewMockContainer(ctrl)
+	users := mocks.NewMockUserManager(ctrl)
+	shop := mocks.NewMockShop(ctrl)
+	auth := mocks.NewMockAuthenticator(ctrl)
+	admin := mocks.NewMockAdmin(ctrl)
+	idempotency := mocks.NewMockIdempotency(ctrl)
+
+	cfg := config.Default()
+	cfg.App.Admins = []string{"admin"}
+
+	container.EXPECT().Config().Return(cfg).AnyTimes()
+	container.EXPECT().Users().Return(users).AnyTimes()
+	container.EXPECT().Shop().Return(shop).AnyTimes()
+	container.EXPECT().Auth().Return(auth).AnyTimes()
+	container.EXPECT().Admin().Return(admin).AnyTimes()
+	container.EXPECT().Idempotency().Return(idempotency).AnyTimes()
+	container.EXPECT().Log().Return(slog.Default()).AnyTimes()
+
+	auth.EXPECT().
+		ValidateToken(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, token string) (string, error) {
+			return token, nil
+		}).
+		AnyTimes()
+
+	return &testSuite{
+		server:      NewServer(container),
+		users:       users,
+		shop:        shop,
+		admin:       admin,
+		idempotency: idempotency,
+	}
+}
+
+func (ts *testSuite) do(method, target, token, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	ts.server.router.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestServer_RoutesMatchSpec(t *testing.T) {
+	t.Parallel()
+	ts := newTestSuite(t)
+
+	routes := slices.Clone(ts.server.routes)
+	slices.Sort(routes)
+
+	assert.Equal(t, ts.server.spec.Routes(), routes)
+}
+
+func TestServer_Validation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		token   string
+		body    string
+		message string
+	}{
+		{
+			name:    "unknown field",
+			method:  http.MethodPost,
+			target:  "/api/sendCoin",
+			body:    `{"toUser": "bob", "amount": 10, "comment": "hi"}`,
+			message: `property "comment" is unsupported`,
+		},
+		{
+			name:    "wrong type",
+			method:  http.MethodPost,
+			target:  "/api/sendCoin",
+			body:    `{"toUser": "bob", "amount": "10"}`,
+			message: "amount: value must be an integer",
+		},
+		{
+			name:    "memo too long",
+			method:  http.MethodPost,
+			target:  "/api/sendCoin",
+			body:    `{"toUser": "bob", "amount": 10, "memo": "` + strings.Repeat("a", 201) + `"}`,
+			message: "memo: maximum string length is 200",
+		},
+		{
+			name:    "empty batch",
+			method:  http.MethodPost,
+			target:  "/api/sendCoin/batch",
+			body:    `{"transfers": []}`,
+			message: "minimum number of items is 1",
+		},
+		{
+			name:    "missing property",
+			method:  http.MethodPost,
+			target:  "/api/auth",
+			body:    `{"username": "alice"}`,
+			message: `property "password" is missing`,
+		},
+		{
+			name:    "malformed body",
+			method:  http.MethodPost,
+			target:  "/api/sendCoin",
+			body:    `{`,
+			message: "body:",
+		},
+		{
+			name:    "missing reason",
+			method:  http.MethodPost,
+			target:  "/api/admin/users/alice/mint",
+			token:   "admin",
+			body:    `{"amount": 10}`,
+			message: `property "reason" is missing`,
+		},
+		{
+			name:    "negative limit",
+			method:  http.MethodPut,
+			target:  "/api/admin/users/alice/transfer-limits",
+			token:   "admin",
+			body:    `{"maxAmount": -1}`,
+			message: "maxAmount: number must be at least 0",
+		},
+		{
+			name:    "invalid path parameter",
+			method:  http.MethodDelete,
+			target:  "/api/admin/webhooks/abc",
+			token:   "admin",
+			message: "path id:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ts := newTestSuite(t)
+
+			token := tt.token
+			if token == "" {
+				token = "alice"
+			}
+
+			w := ts.do(tt.method, tt.target, token, tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var resp struct {
+				Errors string `json:"errors"`
+			}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Contains(t, resp.Errors, tt.message)
+		})
+	}
+
+	t.Run("valid request reaches handler", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.users.EXPECT().
+			Transfer(gomock.Any(), "alice", "bob", 10, "").
+			Return(nil, nil)
+
+		w := ts.do(http.MethodPost, "/api/sendCoin", "alice", `{"toUser": "bob", "amount": 10}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("null limits reach handler", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.admin.EXPECT().
+			SetTransferLimits(gomock.Any(), "alice", &model.TransferLimitsOverride{}, false).
+			Return(&model.UserTransferLimits{Username: "alice"}, nil)
+
+		w := ts.do(http.MethodPut, "/api/admin/users/alice/transfer-limits", "admin", `{"maxAmount": null}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("authentication comes first", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		w := ts.do(http.MethodDelete, "/api/admin/webhooks/abc", "alice", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestServer_Idempotency(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stores response", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		key := &model.IdempotencyKey{Username: "alice", Key: "key"}
+
+		ts.idempotency.EXPECT().Begin(gomock.Any(), "alice", "key", gomock.Any()).Return(key, nil)
+		ts.shop.EXPECT().BuyItem(gomock.Any(), "cup", model.VariantAttributes{}, "alice").Return(nil)
+		ts.idempotency.EXPECT().Complete(gomock.Any(), key, http.StatusOK, []byte("null\n")).Return(nil)
+
+		w := ts.do(http.MethodGet, "/api/buy/cup", "alice", "", "Idempotency-Key", "key")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("replays stored response", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		key := &model.IdempotencyKey{
+			StatusCode: http.StatusBadRequest,
+			Response:   []byte(`{"errors":"insufficient funds"}`),
+		}
+
+		ts.idempotency.EXPECT().Begin(gomock.Any(), "alice", "key", gomock.Any()).Return(key, nil)
+
+		w := ts.do(http.MethodGet, "/api/buy/cup", "alice", "", "Idempotency-Key", "key")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+		assert.JSONEq(t, string(key.Response), w.Body.String())
+	})
+
+	t.Run("server error releases key", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		key := &model.IdempotencyKey{Username: "alice", Key: "key"}
+
+		ts.idempotency.EXPECT().Begin(gomock.Any(), "alice", "key", gomock.Any()).Return(key, nil)
+		ts.users.EXPECT().Transfer(gomock.Any(), "alice", "bob", 10, "").Return(nil, errors.New("connection lost"))
+		ts.idempotency.EXPECT().Abort(gomock.Any(), key).Return(nil)
+
+		body := `{"toUser": "bob", "amount": 10}`
+		w := ts.do(http.MethodPost, "/api/sendCoin", "alice", body, "Idempotency-Key", "key")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("panic releases key", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		key := &model.IdempotencyKey{Username: "alice", Key: "key"}
+
+		ts.idempotency.EXPECT().Begin(gomock.Any(), "alice", "key", gomock.Any()).Return(key, nil)
+		ts.shop.EXPECT().BuyItem(gomock.Any(), "cup", model.VariantAttributes{}, "alice").
+			Do(func(context.Context, string, model.VariantAttributes, string) {
+				panic("boom")
+			})
+		ts.idempotency.EXPECT().Abort(gomock.Any(), key).Return(nil)
+
+		w := ts.do(http.MethodGet, "/api/buy/cup", "alice", "", "Idempotency-Key", "key")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestServer_Docs(t *testing.T) {
+	t.Parallel()
+	ts := newTestSuite(t)
+
+	w := ts.do(http.MethodGet, "/openapi.json", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var spec struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&spec))
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+	assert.Contains(t, spec.Paths, "/api/sendCoin")
+
+	w = ts.do(http.MethodGet, "/docs/", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "swagger-ui")
+
+	w = ts.do(http.MethodGet, "/docs/swagger-initializer.js", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/openapi.json")
+}
+
+func TestServer_CORS(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+	}{
+		{"get", http.MethodGet, "/api/info"},
+		{"post", http.MethodPost, "/api/sendCoin"},
+		{"put", http.MethodPut, "/api/admin/users/alice/transfer-limits"},
+		{"delete", http.MethodDelete, "/api/admin/webhooks/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ts := newTestSuite(t)
+
+			w := ts.do(http.MethodOptions, tt.target, "", "",
+				"Origin", "https://example.com", "Access-Control-Request-Method", tt.method)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), tt.method)
+		})
+	}
+}
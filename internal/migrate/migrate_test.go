@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/esklo/avito-backend-winter-2025/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("orders by version and pairs scripts", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"0002_second.up.sql":   {Data: []byte("up 2")},
+			"0001_first.up.sql":    {Data: []byte("up 1")},
+			"0001_first.down.sql":  {Data: []byte("down 1")},
+			"README.md":            {Data: []byte("ignored")},
+			"0003_third.down.sql~": {Data: []byte("ignored")},
+		}
+
+		got, err := Parse(fsys)
+		require.NoError(t, err)
+
+		assert.Equal(t, []Migration{
+			{Version: 1, Name: "first", Up: "up 1", Down: "down 1"},
+			{Version: 2, Name: "second", Up: "up 2"},
+		}, got)
+	})
+
+	t.Run("missing up script", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := Parse(fstest.MapFS{"0001_first.down.sql": {Data: []byte("down")}})
+		assert.ErrorIs(t, err, ErrInvalidMigration)
+	})
+
+	t.Run("conflicting names", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := Parse(fstest.MapFS{
+			"0001_first.up.sql":   {Data: []byte("up")},
+			"0001_other.down.sql": {Data: []byte("down")},
+		})
+		assert.ErrorIs(t, err, ErrInvalidMigration)
+	})
+
+	t.Run("embedded migrations", func(t *testing.T) {
+		t.Parallel()
+
+		embedded, err := Parse(migrations.FS)
+		require.NoError(t, err)
+		require.NotEmpty(t, embedded)
+
+		for _, m := range embedded {
+			assert.NotEmpty(t, m.Down, "migration %d has no down script", m.Version)
+		}
+	})
+}
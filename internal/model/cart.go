@@ -0,0 +1,56 @@
+package model
+
+const (
+	// MaxCartLines is the maximum number of different items in a cart.
+	MaxCartLines = 50
+	// MaxCartQuantity is the maximum quantity of an item in a cart.
+	MaxCartQuantity = 100
+)
+
+// CartItem is a line of the cart of a user. Price and Stock are those of the
+// item, or of its variant if one is chosen, at the time the cart is read, so
+// a cart is always priced anew. VariantID is zero if no variant is chosen.
+type CartItem struct {
+	ItemID    int
+	VariantID int
+	Item      string
+	VariantAttributes
+	Quantity int
+	Price    int
+	Stock    *int
+}
+
+// Name returns the item with its variant, e.g. "t-shirt (L, black)".
+func (c *CartItem) Name() string {
+	if c.VariantID == 0 {
+		return c.Item
+	}
+
+	return c.Item + " (" + c.VariantAttributes.String() + ")"
+}
+
+// Total returns the price of the line.
+func (c *CartItem) Total() int {
+	return c.Price * c.Quantity
+}
+
+// Available reports whether enough items are left for the line.
+func (c *CartItem) Available() bool {
+	return c.Stock == nil || *c.Stock >= c.Quantity
+}
+
+// Cart lists the items a user is going to buy, ordered by name.
+type Cart struct {
+	Items []CartItem
+}
+
+// Total returns the price of the cart.
+func (c *Cart) Total() int {
+	total := 0
+
+	for i := range c.Items {
+		total += c.Items[i].Total()
+	}
+
+	return total
+}
@@ -5,7 +5,10 @@ import "errors"
 var (
 	ErrBadRequest          = errors.New("bad request")
 	ErrUnauthorized        = errors.New("unauthorized")
+	ErrForbidden           = errors.New("forbidden")
 	ErrInternalServerError = errors.New("internal server error")
 	ErrInsufficientFunds   = errors.New("insufficient funds")
 	ErrNotFound            = errors.New("not found")
+	ErrConflict            = errors.New("conflict")
+	ErrLimitExceeded       = errors.New("limit_exceeded")
 )
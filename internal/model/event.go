@@ -0,0 +1,73 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventUserRegistered    EventType = "UserRegistered"
+	EventTransferCompleted EventType = "TransferCompleted"
+	EventItemPurchased     EventType = "ItemPurchased"
+)
+
+// Valid reports whether t is a known event type.
+func (t EventType) Valid() bool {
+	switch t {
+	case EventUserRegistered, EventTransferCompleted, EventItemPurchased:
+		return true
+	default:
+		return false
+	}
+}
+
+// Event is a domain event stored in the outbox. Sequence orders the events of
+// a single user and has no gaps. An event that concerns two users, a
+// transfer, is stored once and is also in the stream of ReceiverID at
+// ReceiverSequence, which counts on from the same numbers.
+type Event struct {
+	ID               int64           `json:"id"`
+	Type             EventType       `json:"type"`
+	UserID           int             `json:"userId"`
+	Sequence         int64           `json:"sequence"`
+	ReceiverID       int             `json:"receiverId,omitempty"`
+	ReceiverSequence int64           `json:"receiverSequence,omitempty"`
+	Payload          json.RawMessage `json:"payload"`
+	CreatedAt        time.Time       `json:"createdAt"`
+}
+
+type UserRegistered struct {
+	Username string `json:"username"`
+}
+
+type TransferCompleted struct {
+	FromUser string `json:"fromUser"`
+	ToUser   string `json:"toUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+}
+
+type ItemPurchased struct {
+	Username string `json:"username"`
+	Item     string `json:"item"`
+	VariantAttributes
+	Quantity int `json:"quantity"`
+	Price    int `json:"price"`
+}
+
+// NewEvent builds an event of the user with payload encoded as JSON.
+func NewEvent(eventType EventType, userID int, payload any) (*Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s payload: %w", eventType, err)
+	}
+
+	return &Event{
+		Type:    eventType,
+		UserID:  userID,
+		Payload: data,
+	}, nil
+}
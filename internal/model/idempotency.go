@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// IdempotencyKey remembers the response to a request sent with an
+// Idempotency-Key header, so that a retry gets the same response instead of
+// running the request again.
+type IdempotencyKey struct {
+	Username string
+	Key      string
+	// RequestHash identifies the request, a key may not be reused for
+	// another one.
+	RequestHash string
+	// StatusCode is zero while the first request is in progress.
+	StatusCode int
+	Response   []byte
+	CreatedAt  time.Time
+}
+
+// Completed reports whether the response has been stored.
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
@@ -1,18 +1,29 @@
 package model
 
+// Inventory sums the items of a type the user bought. Each variant of an
+// item is listed on its own.
 type Inventory struct {
-	Type     string `json:"type"`
-	Quantity int    `json:"quantity"`
+	Type string `json:"type"`
+	VariantAttributes
+	Quantity int `json:"quantity"`
 }
 
+// CoinsReceived sums the coins received from a user. A transfer with a memo
+// is listed on its own, and so is a reversal of a transfer the user sent.
 type CoinsReceived struct {
 	FromUser string `json:"fromUser"`
 	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+	Reversal bool   `json:"reversal,omitempty"`
 }
 
+// CoinsSent sums the coins sent to a user. A transfer with a memo is listed
+// on its own, and so is a reversal of a transfer the user received.
 type CoinsSent struct {
-	ToUser string `json:"toUser"`
-	Amount int    `json:"amount"`
+	ToUser   string `json:"toUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+	Reversal bool   `json:"reversal,omitempty"`
 }
 type CoinHistory struct {
 	Received []CoinsReceived `json:"received"`
@@ -1,7 +1,19 @@
 package model
 
+import "fmt"
+
+// ErrOutOfStock means there are fewer items left than requested.
+var ErrOutOfStock = fmt.Errorf("%w: out of stock", ErrConflict)
+
 type Item struct {
 	ID    int
 	Name  string
 	Price int
+	// Stock is the number of items left, nil means the item never runs out.
+	Stock *int
+}
+
+// Available reports whether quantity items are left.
+func (i *Item) Available(quantity int) bool {
+	return i.Stock == nil || *i.Stock >= quantity
 }
@@ -0,0 +1,80 @@
+package model
+
+import "time"
+
+// SystemAccount is the treasury of the shop. It is the counterparty of every
+// coin movement that is not a transfer between users: it issues the starting
+// balances, allowances and minted coins, and receives the coins spent on
+// items and burned. The ledger entries are its journal.
+const SystemAccount = "system"
+
+type LedgerEntryKind string
+
+const (
+	LedgerEntrySignup    LedgerEntryKind = "signup"
+	LedgerEntryOpening   LedgerEntryKind = "opening"
+	LedgerEntryPurchase  LedgerEntryKind = "purchase"
+	LedgerEntryReferral  LedgerEntryKind = "referral"
+	LedgerEntryAllowance LedgerEntryKind = "allowance"
+	LedgerEntryMint      LedgerEntryKind = "mint"
+	LedgerEntryBurn      LedgerEntryKind = "burn"
+	// LedgerEntryReversal entries come in pairs that move the coins of a
+	// transfer back from the receiver to the sender, so they add up to zero.
+	LedgerEntryReversal LedgerEntryKind = "reversal"
+)
+
+// InCoinHistory reports whether entries of the kind show up in the coin
+// history as transfers with the system account. Starting balances and
+// purchases do not, the inventory shows the latter. Reversals are shown as
+// transfers with the other user of the reversed transfer.
+func (k LedgerEntryKind) InCoinHistory() bool {
+	switch k {
+	case LedgerEntrySignup, LedgerEntryOpening, LedgerEntryPurchase, LedgerEntryReversal:
+		return false
+	default:
+		return true
+	}
+}
+
+// LedgerEntry moves coins between the system account and a user, a positive
+// amount credits the user. An optional Reference identifies what the entry is
+// for, e.g. the allowance period, and a user gets at most one entry of a kind
+// for it. Reason explains entries made by an admin. TransferID links a
+// reversal to the reversed transfer.
+type LedgerEntry struct {
+	ID         int64
+	UserID     int
+	Kind       LedgerEntryKind
+	Amount     int
+	Reference  string
+	Reason     string
+	TransferID int64
+	CreatedAt  time.Time
+}
+
+// Reconciliation checks the balances of the users against the ledger. The
+// coins users hold, including those held by pending transfers, must add up to
+// the coins the system account issued minus the coins it received back, which
+// do not include reversals as they move coins between users, and
+// the balance of every user must equal their ledger entries plus the coins
+// they received minus the coins they sent or hold in pending transfers.
+type Reconciliation struct {
+	Issued   int64 `json:"issued"`
+	Burned   int64 `json:"burned"`
+	Balances int64 `json:"balances"`
+	Held     int64 `json:"held"`
+	// Discrepancies lists users whose balance does not match their entries
+	// and transfers, up to a limit.
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+type Discrepancy struct {
+	Username string `json:"username"`
+	Balance  int64  `json:"balance"`
+	Expected int64  `json:"expected"`
+}
+
+// Balanced reports whether the books add up.
+func (r *Reconciliation) Balanced() bool {
+	return r.Balances+r.Held == r.Issued-r.Burned && len(r.Discrepancies) == 0
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// TransferLimits bound the coins a user sends to other users. The daily
+// limits count the transfers of a UTC day. Zero means no limit.
+type TransferLimits struct {
+	MaxAmount   int `json:"maxAmount"`
+	DailyAmount int `json:"dailyAmount"`
+	DailyCount  int `json:"dailyCount"`
+}
+
+// TransferLimitsOverride replaces the global limits for a single user. Nil
+// fields keep the global limit, zero removes it.
+type TransferLimitsOverride struct {
+	MaxAmount   *int `json:"maxAmount"`
+	DailyAmount *int `json:"dailyAmount"`
+	DailyCount  *int `json:"dailyCount"`
+}
+
+// Empty reports whether the override keeps all global limits.
+func (o *TransferLimitsOverride) Empty() bool {
+	return o == nil || o.MaxAmount == nil && o.DailyAmount == nil && o.DailyCount == nil
+}
+
+// With returns the limits with the fields set by the override replaced.
+func (l TransferLimits) With(o *TransferLimitsOverride) TransferLimits {
+	if o == nil {
+		return l
+	}
+
+	if o.MaxAmount != nil {
+		l.MaxAmount = *o.MaxAmount
+	}
+
+	if o.DailyAmount != nil {
+		l.DailyAmount = *o.DailyAmount
+	}
+
+	if o.DailyCount != nil {
+		l.DailyCount = *o.DailyCount
+	}
+
+	return l
+}
+
+// Check returns ErrLimitExceeded if a transfer of amount coins on top of
+// the usage of the day breaks any of the limits.
+func (l TransferLimits) Check(usage TransferUsage, amount int) error {
+	switch {
+	case l.MaxAmount > 0 && amount > l.MaxAmount:
+		return fmt.Errorf("%w: at most %d coins per transfer", ErrLimitExceeded, l.MaxAmount)
+	case l.DailyAmount > 0 && usage.Amount+amount > l.DailyAmount:
+		return fmt.Errorf("%w: %d of %d coins per day already sent", ErrLimitExceeded, usage.Amount, l.DailyAmount)
+	case l.DailyCount > 0 && usage.Count >= l.DailyCount:
+		return fmt.Errorf("%w: at most %d transfers per day", ErrLimitExceeded, l.DailyCount)
+	default:
+		return nil
+	}
+}
+
+// UserTransferLimits are the limits of a user: the global ones with the
+// override of the user, if any.
+type UserTransferLimits struct {
+	Username string                  `json:"username"`
+	Limits   TransferLimits          `json:"limits"`
+	Override *TransferLimitsOverride `json:"override"`
+}
+
+// TransferUsage sums the transfers a user sent on a day.
+type TransferUsage struct {
+	Amount int
+	Count  int
+}
+
+// TransferDay returns the day whose transfers the daily limits count at t.
+func TransferDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type NotificationType string
+
+const (
+	NotificationBalanceChanged NotificationType = "balanceChanged"
+	NotificationCoinsReceived  NotificationType = "coinsReceived"
+	NotificationItemPurchased  NotificationType = "itemPurchased"
+	// NotificationTransferPending tells the receiver that a transfer waits
+	// for them to accept it.
+	NotificationTransferPending NotificationType = "transferPending"
+	// NotificationPaymentRequested tells the payer that a user requests coins
+	// from them, NotificationPaymentDeclined tells the requester that the
+	// payer declined.
+	NotificationPaymentRequested NotificationType = "paymentRequested"
+	NotificationPaymentDeclined  NotificationType = "paymentDeclined"
+)
+
+// Notification is a message for a single user, streamed to their open
+// connections. IDs grow with every notification, so a client can resume
+// after the last one it has seen.
+type Notification struct {
+	ID        int64            `json:"id"`
+	UserID    int              `json:"-"`
+	Type      NotificationType `json:"type"`
+	Payload   json.RawMessage  `json:"payload"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+type BalanceChanged struct {
+	Balance int `json:"balance"`
+}
+
+// NewNotification builds a notification of the user with payload encoded as
+// JSON.
+func NewNotification(notificationType NotificationType, userID int, payload any) (*Notification, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s payload: %w", notificationType, err)
+	}
+
+	return &Notification{
+		UserID:  userID,
+		Type:    notificationType,
+		Payload: data,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+type PaymentRequestStatus string
+
+const (
+	PaymentRequestPending  PaymentRequestStatus = "pending"
+	PaymentRequestPaid     PaymentRequestStatus = "paid"
+	PaymentRequestDeclined PaymentRequestStatus = "declined"
+)
+
+// PaymentRequest asks the payer to send coins to the requester. Nothing is
+// held, the payer makes an ordinary transfer when paying the request. A
+// request that is not resolved by ExpiresAt can no longer be paid.
+type PaymentRequest struct {
+	ID          int64
+	RequesterID int
+	PayerID     int
+	// Requester and Payer are the usernames of the users.
+	Requester  string
+	Payer      string
+	Amount     int
+	Memo       string
+	Status     PaymentRequestStatus
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	ResolvedAt *time.Time
+}
+
+// Open reports whether the request can still be paid or declined at t.
+func (r *PaymentRequest) Open(t time.Time) bool {
+	return r.Status == PaymentRequestPending && r.ExpiresAt.After(t)
+}
+
+// PaymentRequests lists the requests a user has to pay and those they sent
+// to others.
+type PaymentRequests struct {
+	Incoming []PaymentRequest
+	Outgoing []PaymentRequest
+}
+
+// PaymentRequested is the notification payload for the payer of a request.
+type PaymentRequested struct {
+	ID        int64     `json:"id"`
+	Requester string    `json:"requester"`
+	Amount    int       `json:"amount"`
+	Memo      string    `json:"memo,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PaymentDeclined is the notification payload for the requester of a declined
+// request.
+type PaymentDeclined struct {
+	ID     int64  `json:"id"`
+	Payer  string `json:"payer"`
+	Amount int    `json:"amount"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+type PendingTransferStatus string
+
+const (
+	PendingTransferPending  PendingTransferStatus = "pending"
+	PendingTransferAccepted PendingTransferStatus = "accepted"
+	PendingTransferDeclined PendingTransferStatus = "declined"
+	PendingTransferExpired  PendingTransferStatus = "expired"
+)
+
+// PendingTransfer holds coins of the sender until the receiver accepts the
+// transfer. Coins of a declined or expired transfer go back to the sender.
+type PendingTransfer struct {
+	ID         int64
+	SenderID   int
+	ReceiverID int
+	// FromUser and ToUser are the usernames of the sender and the receiver.
+	FromUser   string
+	ToUser     string
+	Amount     int
+	Memo       string
+	Status     PendingTransferStatus
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	ResolvedAt *time.Time
+}
+
+// PendingTransfers lists the transfers waiting for a user and those they wait
+// for others to accept.
+type PendingTransfers struct {
+	Incoming []PendingTransfer
+	Outgoing []PendingTransfer
+}
+
+// TransferPending is the notification payload for the receiver of a pending
+// transfer.
+type TransferPending struct {
+	ID        int64     `json:"id"`
+	FromUser  string    `json:"fromUser"`
+	Amount    int       `json:"amount"`
+	Memo      string    `json:"memo,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -1,8 +1,12 @@
 package model
 
+// Purchase adds Quantity items to the inventory of the user, Price is paid
+// for all of them. VariantID is zero for items without variants.
 type Purchase struct {
-	ID       int
-	UserID   int
-	ItemID   int
-	Quantity int
+	ID        int
+	UserID    int
+	ItemID    int
+	VariantID int
+	Quantity  int
+	Price     int
 }
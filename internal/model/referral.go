@@ -0,0 +1,32 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrInvalidReferralCode rejects a signup with an unknown referral code or
+// the code of a deactivated user.
+var ErrInvalidReferralCode = fmt.Errorf("%w: invalid referral code", ErrBadRequest)
+
+// Referral records that a user registered with the referral code of another
+// user. Rewarded is false if the referrer had reached the referral limit.
+type Referral struct {
+	ReferrerID int
+	RefereeID  int
+	Rewarded   bool
+	CreatedAt  time.Time
+}
+
+// ReferralInfo is what a user sees about their own referral code.
+type ReferralInfo struct {
+	Code string `json:"code"`
+	// Bonus is credited to the user and to every user who registers with
+	// the code, as long as Remaining is positive.
+	Bonus int `json:"bonus"`
+	// Rewarded is the number of rewarded referrals so far.
+	Rewarded int `json:"rewarded"`
+	// Remaining is the number of referrals that are still rewarded within
+	// the current window.
+	Remaining int `json:"remaining"`
+}
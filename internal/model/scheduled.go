@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+type ScheduledTransferStatus string
+
+const (
+	ScheduledTransferActive ScheduledTransferStatus = "active"
+	// ScheduledTransferCompleted and ScheduledTransferFailed are one-off
+	// transfers after their run.
+	ScheduledTransferCompleted ScheduledTransferStatus = "completed"
+	ScheduledTransferFailed    ScheduledTransferStatus = "failed"
+	ScheduledTransferCancelled ScheduledTransferStatus = "cancelled"
+)
+
+// ScheduledTransfer is a transfer the sender set up to be made later. A
+// transfer with a Schedule, a cron expression in UTC, recurs at its times,
+// one without it runs once at NextRunAt.
+type ScheduledTransfer struct {
+	ID         int64
+	SenderID   int
+	ReceiverID int
+	// FromUser and ToUser are the usernames of the sender and the receiver.
+	FromUser  string
+	ToUser    string
+	Amount    int
+	Memo      string
+	Schedule  string
+	Status    ScheduledTransferStatus
+	NextRunAt time.Time
+	CreatedAt time.Time
+}
+
+// Recurring reports whether the transfer runs on a schedule.
+func (t *ScheduledTransfer) Recurring() bool {
+	return t.Schedule != ""
+}
+
+type ScheduledTransferRunStatus string
+
+const (
+	ScheduledTransferRunSucceeded ScheduledTransferRunStatus = "succeeded"
+	ScheduledTransferRunFailed    ScheduledTransferRunStatus = "failed"
+)
+
+// ScheduledTransferRun records a run of a scheduled transfer, a failed run
+// has the Error of the transfer, e.g. insufficient funds.
+type ScheduledTransferRun struct {
+	ID                  int64
+	ScheduledTransferID int64
+	ScheduledAt         time.Time
+	Status              ScheduledTransferRunStatus
+	Error               string
+	CreatedAt           time.Time
+}
@@ -1,7 +1,89 @@
 package model
 
+import "time"
+
+// Transfer is a single transfer of coins between users.
 type Transfer struct {
-	ID                   int
+	ID                   int64
 	SenderID, ReceiverID int
-	Amount               int
+	// FromUser and ToUser are the usernames of the sender and the receiver,
+	// they are set when the transfer is read.
+	FromUser, ToUser string
+	Amount           int
+	// Memo is the optional message of the sender.
+	Memo string
+	// Reversed is the number of coins an admin moved back to the sender, see
+	// LedgerEntryReversal. It is set when the transfer is read.
+	Reversed  int
+	CreatedAt time.Time
+}
+
+// MaxBatchTransfers is the maximum number of transfers in a batch.
+const MaxBatchTransfers = 100
+
+// BatchTransfer is one of the transfers of a batch sent by a single user.
+type BatchTransfer struct {
+	ToUser string
+	Amount int
+	Memo   string
+}
+
+// BatchTransferStatus is the outcome of a transfer of a batch.
+type BatchTransferStatus string
+
+const (
+	// BatchTransferApplied means the transfer was made.
+	BatchTransferApplied BatchTransferStatus = "applied"
+	// BatchTransferFailed means the transfer is invalid, so the batch was
+	// not applied.
+	BatchTransferFailed BatchTransferStatus = "failed"
+	// BatchTransferSkipped means the transfer is valid but the batch was
+	// not applied because of other transfers or the balance of the sender.
+	BatchTransferSkipped BatchTransferStatus = "skipped"
+)
+
+// BatchTransferResult reports the outcome of a transfer of a batch.
+type BatchTransferResult struct {
+	ToUser string              `json:"toUser"`
+	Amount int                 `json:"amount"`
+	Status BatchTransferStatus `json:"status"`
+	Error  string              `json:"error,omitempty"`
+}
+
+// BatchTransferReport reports the outcome of a batch, results are in the
+// order of the transfers.
+type BatchTransferReport struct {
+	Total   int                   `json:"total"`
+	Results []BatchTransferResult `json:"results"`
+}
+
+// NewBatchTransferReport returns a report where every transfer has status.
+func NewBatchTransferReport(transfers []BatchTransfer, status BatchTransferStatus) *BatchTransferReport {
+	report := &BatchTransferReport{Results: make([]BatchTransferResult, len(transfers))}
+
+	for i, t := range transfers {
+		report.Total += t.Amount
+		report.Results[i] = BatchTransferResult{ToUser: t.ToUser, Amount: t.Amount, Status: status}
+	}
+
+	return report
+}
+
+// Fail marks the i-th transfer as failed with err.
+func (r *BatchTransferReport) Fail(i int, err error) {
+	r.Results[i].Status = BatchTransferFailed
+	r.Results[i].Error = err.Error()
+}
+
+// Failed returns the number of failed transfers.
+func (r *BatchTransferReport) Failed() int {
+	n := 0
+
+	for _, res := range r.Results {
+		if res.Status == BatchTransferFailed {
+			n++
+		}
+	}
+
+	return n
 }
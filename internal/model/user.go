@@ -1,8 +1,26 @@
 package model
 
+import (
+	"fmt"
+	"time"
+)
+
+// ErrUserDeactivated rejects the actions of a deactivated user.
+var ErrUserDeactivated = fmt.Errorf("%w: user is deactivated", ErrForbidden)
+
 type User struct {
 	ID             int
 	Username       string
 	Password, Salt []byte
 	Balance        int
+	// ReferralCode is shared by the user with others, who register with it
+	// to get a bonus for both of them.
+	ReferralCode string
+	// DeactivatedAt is set when an operator deactivates the user, who can
+	// then no longer log in, buy items or transfer coins.
+	DeactivatedAt *time.Time
+}
+
+func (u *User) Active() bool {
+	return u.DeactivatedAt == nil
 }
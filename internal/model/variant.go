@@ -0,0 +1,51 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxVariantAttributeLength is the maximum length of a size or a color.
+const MaxVariantAttributeLength = 50
+
+// ErrVariantRequired is returned for an item with variants bought or added to
+// a cart without one of them.
+var ErrVariantRequired = fmt.Errorf("%w: choose a variant", ErrBadRequest)
+
+// VariantAttributes tell the variants of an item apart. An empty attribute
+// means the variants of the item do not differ in it.
+type VariantAttributes struct {
+	Size  string `json:"size,omitempty"`
+	Color string `json:"color,omitempty"`
+}
+
+// IsZero reports whether no attribute is set, that is no variant is chosen.
+func (a VariantAttributes) IsZero() bool {
+	return a.Size == "" && a.Color == ""
+}
+
+// String returns the attributes that are set, e.g. "L, black".
+func (a VariantAttributes) String() string {
+	var parts []string
+
+	for _, attr := range []string{a.Size, a.Color} {
+		if attr != "" {
+			parts = append(parts, attr)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Variant is a version of an item, such as a size or a color, with its own
+// stock. An item with variants can only be bought as one of them.
+type Variant struct {
+	ID     int
+	ItemID int
+	VariantAttributes
+	// PriceDelta is added to the price of the item, it may be negative.
+	PriceDelta int
+	// Stock is the number of items left, nil means the variant never runs
+	// out. The stock of the item itself is not used.
+	Stock *int
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+type Webhook struct {
+	ID         int         `json:"id"`
+	URL        string      `json:"url"`
+	Secret     string      `json:"secret,omitempty"`
+	EventTypes []EventType `json:"events"`
+	CreatedAt  time.Time   `json:"createdAt"`
+}
+
+// Accepts reports whether the webhook is subscribed to events of eventType.
+func (w *Webhook) Accepts(eventType EventType) bool {
+	for _, t := range w.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead is a delivery that ran out of attempts.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+type WebhookDelivery struct {
+	ID             int64          `json:"id"`
+	WebhookID      int            `json:"webhookId"`
+	EventID        int64          `json:"eventId"`
+	EventType      EventType      `json:"eventType"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"nextAttemptAt"`
+	LastError      string         `json:"lastError,omitempty"`
+	ResponseStatus int            `json:"responseStatus,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	DeliveredAt    *time.Time     `json:"deliveredAt,omitempty"`
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+// AddCartItem adds quantity items to the cart of the user. variantID is zero
+// for items without variants.
+func (r *repo) AddCartItem(ctx context.Context, tx DB, userID, itemID, variantID, quantity int) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO cart_items (user_id, item_id, variant_id, quantity)
+		VALUES ($1, $2, NULLIF($3, 0), $4)
+		ON CONFLICT (user_id, item_id, (coalesce(variant_id, 0)))
+		DO UPDATE SET quantity = cart_items.quantity + $4;
+	`, userID, itemID, variantID, quantity)
+	if err != nil {
+		return fmt.Errorf("insert cart item: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveCartItem removes the item, or its variant, from the cart of the user.
+// It returns sql.ErrNoRows if it is not in the cart.
+func (r *repo) RemoveCartItem(ctx context.Context, tx DB, userID, itemID, variantID int) error {
+	db := r.getExecutor(tx)
+
+	tag, err := db.Exec(ctx, `
+		DELETE FROM cart_items
+		WHERE user_id = $1 AND item_id = $2 AND coalesce(variant_id, 0) = $3;
+	`, userID, itemID, variantID)
+	if err != nil {
+		return fmt.Errorf("delete cart item: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("delete cart item: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// ListCartItems returns the cart of the user with the current price and stock
+// of the items or their variants, ordered by name and then by variant.
+func (r *repo) ListCartItems(ctx context.Context, tx DB, userID int) ([]model.CartItem, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT
+			items.id,
+			coalesce(item_variants.id, 0),
+			items.name,
+			coalesce(item_variants.size, ''),
+			coalesce(item_variants.color, ''),
+			cart_items.quantity,
+			items.price + coalesce(item_variants.price_delta, 0),
+			CASE WHEN item_variants.id IS NULL THEN items.stock ELSE item_variants.stock END
+		FROM cart_items
+		JOIN items ON items.id = cart_items.item_id
+		LEFT JOIN item_variants ON item_variants.id = cart_items.variant_id
+		WHERE cart_items.user_id = $1
+		ORDER BY items.name, item_variants.id NULLS FIRST;
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("select cart items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []model.CartItem
+
+	for rows.Next() {
+		var item model.CartItem
+
+		err := rows.Scan(
+			&item.ItemID,
+			&item.VariantID,
+			&item.Item,
+			&item.Size,
+			&item.Color,
+			&item.Quantity,
+			&item.Price,
+			&item.Stock,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan cart item: %w", err)
+		}
+
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate cart items: %w", err)
+	}
+
+	return items, nil
+}
+
+// ClearCart removes all items from the cart of the user.
+func (r *repo) ClearCart(ctx context.Context, tx DB, userID int) error {
+	db := r.getExecutor(tx)
+
+	if _, err := db.Exec(ctx, `DELETE FROM cart_items WHERE user_id = $1;`, userID); err != nil {
+		return fmt.Errorf("delete cart items: %w", err)
+	}
+
+	return nil
+}
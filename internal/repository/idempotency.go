@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReserveIdempotencyKey stores key as in progress and reports true. If the
+// user already has a key of that name created after notBefore, it fills key
+// with the stored one and reports false. Older keys are replaced.
+func (r *repo) ReserveIdempotencyKey(
+	ctx context.Context, tx DB, key *model.IdempotencyKey, notBefore time.Time,
+) (bool, error) {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		INSERT INTO idempotency_keys (username, key, request_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (username, key) DO UPDATE
+		SET request_hash = excluded.request_hash,
+		    status_code  = 0,
+		    response     = NULL,
+		    created_at   = now()
+		WHERE idempotency_keys.created_at < $4
+		RETURNING created_at;
+	`, key.Username, key.Key, key.RequestHash, notBefore).Scan(&key.CreatedAt)
+	if err == nil {
+		key.StatusCode = 0
+		key.Response = nil
+
+		return true, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("insert idempotency key: %w", err)
+	}
+
+	err = db.QueryRow(ctx, `
+		SELECT request_hash, status_code, response, created_at
+		FROM idempotency_keys
+		WHERE username = $1 AND key = $2;
+	`, key.Username, key.Key).Scan(
+		&key.RequestHash,
+		&key.StatusCode,
+		&key.Response,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("select idempotency key: %w", err)
+	}
+
+	return false, nil
+}
+
+// CompleteIdempotencyKey stores the response of the request made with key.
+func (r *repo) CompleteIdempotencyKey(ctx context.Context, tx DB, key *model.IdempotencyKey) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $3, response = $4
+		WHERE username = $1 AND key = $2;
+	`, key.Username, key.Key, key.StatusCode, key.Response)
+	if err != nil {
+		return fmt.Errorf("update idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteIdempotencyKey releases the key, so that the request can be retried.
+func (r *repo) DeleteIdempotencyKey(ctx context.Context, tx DB, username, key string) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE username = $1 AND key = $2;
+	`, username, key)
+	if err != nil {
+		return fmt.Errorf("delete idempotency key: %w", err)
+	}
+
+	return nil
+}
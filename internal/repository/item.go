@@ -13,13 +13,14 @@ func (r *repo) FindItem(ctx context.Context, tx DB, name string) (*model.Item, e
 	var item model.Item
 
 	err := db.QueryRow(ctx, `
-		SELECT id, name, price
+		SELECT id, name, price, stock
 		FROM items
 		WHERE name = $1;
 	`, name).Scan(
 		&item.ID,
 		&item.Name,
 		&item.Price,
+		&item.Stock,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("select item: %w", err)
@@ -27,3 +28,66 @@ func (r *repo) FindItem(ctx context.Context, tx DB, name string) (*model.Item, e
 
 	return &item, nil
 }
+
+// CreateItem adds the item to the catalog and sets its ID. Item names are
+// unique.
+func (r *repo) CreateItem(ctx context.Context, tx DB, item *model.Item) error {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		INSERT INTO items (name, price, stock)
+		VALUES ($1, $2, $3)
+		RETURNING id;
+	`, item.Name, item.Price, item.Stock).Scan(&item.ID)
+	if err != nil {
+		return fmt.Errorf("insert item: %w", err)
+	}
+
+	return nil
+}
+
+// SetItemStock sets the number of items left, nil means the item never runs
+// out.
+func (r *repo) SetItemStock(ctx context.Context, tx DB, itemID int, stock *int) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `UPDATE items SET stock = $2 WHERE id = $1;`, itemID, stock)
+	if err != nil {
+		return fmt.Errorf("update stock: %w", err)
+	}
+
+	return nil
+}
+
+// ListItems returns the catalog ordered by name.
+func (r *repo) ListItems(ctx context.Context, tx DB) ([]model.Item, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT id, name, price, stock
+		FROM items
+		ORDER BY name;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("select items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []model.Item
+
+	for rows.Next() {
+		var item model.Item
+
+		if err := rows.Scan(&item.ID, &item.Name, &item.Price, &item.Stock); err != nil {
+			return nil, fmt.Errorf("scan item: %w", err)
+		}
+
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate items: %w", err)
+	}
+
+	return items, nil
+}
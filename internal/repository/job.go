@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// FindJobRun returns the scheduled time of the last run of the job, or
+// sql.ErrNoRows if it never ran.
+func (r *repo) FindJobRun(ctx context.Context, tx DB, name string) (time.Time, error) {
+	db := r.getExecutor(tx)
+
+	var at time.Time
+
+	err := db.QueryRow(ctx, `
+		SELECT last_run_at
+		FROM scheduled_jobs
+		WHERE name = $1;
+	`, name).Scan(&at)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("select job run: %w", err)
+	}
+
+	return at, nil
+}
+
+// SaveJobRun records at as the scheduled time of the last run of the job.
+func (r *repo) SaveJobRun(ctx context.Context, tx DB, name string, at time.Time) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO scheduled_jobs (name, last_run_at)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE
+		SET last_run_at = excluded.last_run_at;
+	`, name, at)
+	if err != nil {
+		return fmt.Errorf("upsert job run: %w", err)
+	}
+
+	return nil
+}
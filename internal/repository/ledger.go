@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// AddLedgerEntry stores entry, setting its ID and creation time, and reports
+// true. If entry has a reference and the user already has an entry of the
+// same kind and reference, it stores nothing and reports false. Balances are
+// not changed.
+func (r *repo) AddLedgerEntry(ctx context.Context, tx DB, entry *model.LedgerEntry) (bool, error) {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		INSERT INTO ledger_entries (user_id, kind, amount, reference, reason, transfer_id)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, 0))
+		ON CONFLICT (user_id, kind, reference) DO NOTHING
+		RETURNING id, created_at;
+	`, entry.UserID, entry.Kind, entry.Amount, entry.Reference, entry.Reason, entry.TransferID).
+		Scan(&entry.ID, &entry.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("insert ledger entry: %w", err)
+	}
+
+	return true, nil
+}
+
+// ListLedgerEntries returns the entries of the user, oldest first.
+func (r *repo) ListLedgerEntries(ctx context.Context, tx DB, userID int) ([]model.LedgerEntry, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT id, user_id, kind, amount, COALESCE(reference, ''), reason, COALESCE(transfer_id, 0), created_at
+		FROM ledger_entries
+		WHERE user_id = $1
+		ORDER BY id;
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("select ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []model.LedgerEntry
+
+	for rows.Next() {
+		var entry model.LedgerEntry
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.Kind,
+			&entry.Amount,
+			&entry.Reference,
+			&entry.Reason,
+			&entry.TransferID,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan ledger entry: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ledger entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Reconcile sums the balances and the ledger and returns up to limit users
+// whose balance does not match their ledger entries, transfers and held
+// coins. Reversals move coins between users, so they are left out of the
+// issued and burned totals. It should run in a repeatable read transaction,
+// so that all sums see the same data.
+func (r *repo) Reconcile(ctx context.Context, tx DB, limit int) (*model.Reconciliation, error) {
+	db := r.getExecutor(tx)
+
+	var result model.Reconciliation
+
+	err := db.QueryRow(ctx, `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE amount > 0 AND kind <> $1), 0),
+			COALESCE(-SUM(amount) FILTER (WHERE amount < 0 AND kind <> $1), 0),
+			(SELECT COALESCE(SUM(balance), 0) FROM users),
+			(SELECT COALESCE(SUM(amount), 0) FROM pending_transfers WHERE status = 'pending')
+		FROM ledger_entries;
+	`, model.LedgerEntryReversal).Scan(&result.Issued, &result.Burned, &result.Balances, &result.Held)
+	if err != nil {
+		return nil, fmt.Errorf("select totals: %w", err)
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT username, balance, expected
+		FROM (
+			SELECT
+				users.username,
+				COALESCE(users.balance, 0)::bigint AS balance,
+				COALESCE(ledger.amount, 0)
+					+ COALESCE(received.amount, 0)
+					- COALESCE(sent.amount, 0)
+					- COALESCE(held.amount, 0) AS expected
+			FROM users
+			LEFT JOIN (
+				SELECT user_id, SUM(amount) AS amount FROM ledger_entries GROUP BY user_id
+			) ledger ON ledger.user_id = users.id
+			LEFT JOIN (
+				SELECT receiver_id, SUM(amount) AS amount FROM transfers GROUP BY receiver_id
+			) received ON received.receiver_id = users.id
+			LEFT JOIN (
+				SELECT sender_id, SUM(amount) AS amount FROM transfers GROUP BY sender_id
+			) sent ON sent.sender_id = users.id
+			LEFT JOIN (
+				SELECT sender_id, SUM(amount) AS amount
+				FROM pending_transfers
+				WHERE status = 'pending'
+				GROUP BY sender_id
+			) held ON held.sender_id = users.id
+		) accounts
+		WHERE balance <> expected
+		ORDER BY username
+		LIMIT $1;
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select discrepancies: %w", err)
+	}
+	defer rows.Close()
+
+	result.Discrepancies = make([]model.Discrepancy, 0)
+
+	for rows.Next() {
+		var d model.Discrepancy
+
+		if err := rows.Scan(&d.Username, &d.Balance, &d.Expected); err != nil {
+			return nil, fmt.Errorf("scan discrepancy: %w", err)
+		}
+
+		result.Discrepancies = append(result.Discrepancies, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate discrepancies: %w", err)
+	}
+
+	return &result, nil
+}
+
+// hiddenLedgerEntryKinds are the kinds that are not shown in the coin history,
+// see model.LedgerEntryKind.InCoinHistory.
+func hiddenLedgerEntryKinds() []string {
+	return []string{
+		string(model.LedgerEntrySignup),
+		string(model.LedgerEntryOpening),
+		string(model.LedgerEntryPurchase),
+		string(model.LedgerEntryReversal),
+	}
+}
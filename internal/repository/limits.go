@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+// FindTransferLimits returns the override of the transfer limits of the
+// user, sql.ErrNoRows if there is none.
+func (r *repo) FindTransferLimits(ctx context.Context, tx DB, userID int) (*model.TransferLimitsOverride, error) {
+	db := r.getExecutor(tx)
+
+	var override model.TransferLimitsOverride
+
+	err := db.QueryRow(ctx, `
+		SELECT max_amount, daily_amount, daily_count
+		FROM transfer_limits
+		WHERE user_id = $1;
+	`, userID).Scan(&override.MaxAmount, &override.DailyAmount, &override.DailyCount)
+	if err != nil {
+		return nil, fmt.Errorf("select transfer limits: %w", err)
+	}
+
+	return &override, nil
+}
+
+// SetTransferLimits stores the override of the transfer limits of the user.
+// An empty override is deleted.
+func (r *repo) SetTransferLimits(
+	ctx context.Context, tx DB, userID int, override *model.TransferLimitsOverride,
+) error {
+	db := r.getExecutor(tx)
+
+	if override.Empty() {
+		if _, err := db.Exec(ctx, `DELETE FROM transfer_limits WHERE user_id = $1;`, userID); err != nil {
+			return fmt.Errorf("delete transfer limits: %w", err)
+		}
+
+		return nil
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO transfer_limits (user_id, max_amount, daily_amount, daily_count)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id)
+		DO UPDATE SET max_amount   = excluded.max_amount,
+		              daily_amount = excluded.daily_amount,
+		              daily_count  = excluded.daily_count,
+		              updated_at   = now();
+	`, userID, override.MaxAmount, override.DailyAmount, override.DailyCount)
+	if err != nil {
+		return fmt.Errorf("upsert transfer limits: %w", err)
+	}
+
+	return nil
+}
+
+// FindTransferUsage returns the coins and transfers the user sent on the
+// day, zero if none.
+func (r *repo) FindTransferUsage(ctx context.Context, tx DB, userID int, day time.Time) (model.TransferUsage, error) {
+	db := r.getExecutor(tx)
+
+	var usage model.TransferUsage
+
+	err := db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount), 0), COALESCE(SUM(count), 0)
+		FROM transfer_usage
+		WHERE user_id = $1 AND day = $2;
+	`, userID, day).Scan(&usage.Amount, &usage.Count)
+	if err != nil {
+		return model.TransferUsage{}, fmt.Errorf("select transfer usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// AddTransferUsage counts a transfer of amount coins sent by the user on the
+// day.
+func (r *repo) AddTransferUsage(ctx context.Context, tx DB, userID int, day time.Time, amount int) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO transfer_usage (user_id, day, amount, count)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (user_id, day)
+		DO UPDATE SET amount = transfer_usage.amount + $3,
+		              count  = transfer_usage.count + 1;
+	`, userID, day, amount)
+	if err != nil {
+		return fmt.Errorf("add transfer usage: %w", err)
+	}
+
+	return nil
+}
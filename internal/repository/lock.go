@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// TryLock takes the session-level advisory lock identified by key on a
+// dedicated connection. It reports false without waiting if the lock is held
+// elsewhere, otherwise the lock is kept until release is called.
+func (r *repo) TryLock(ctx context.Context, key int64) (release func(), locked bool, err error) {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("acquire connection: %w", err)
+	}
+
+	err = conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1);`, key).Scan(&locked)
+	if err != nil || !locked {
+		conn.Release()
+
+		if err != nil {
+			return nil, false, fmt.Errorf("try advisory lock: %w", err)
+		}
+
+		return nil, false, nil
+	}
+
+	release = func() {
+		_, _ = conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1);`, key)
+		conn.Release()
+	}
+
+	return release, true, nil
+}
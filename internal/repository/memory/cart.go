@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) AddCartItem(
+	ctx context.Context, db repository.DB, userID, itemID, variantID, quantity int,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		if s.users[userID] == nil || s.items[itemID] == nil {
+			return fmt.Errorf("cart item %d of %d: %w", itemID, userID, sql.ErrNoRows)
+		}
+
+		if variant := s.variants[variantID]; variantID != 0 && (variant == nil || variant.ItemID != itemID) {
+			return fmt.Errorf("variant %d of %d: %w", variantID, itemID, sql.ErrNoRows)
+		}
+
+		s.carts[line{userID, itemID, variantID}] += quantity
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert cart item: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) RemoveCartItem(ctx context.Context, db repository.DB, userID, itemID, variantID int) error {
+	err := r.write(ctx, db, func(s *state) error {
+		key := line{userID, itemID, variantID}
+		if _, ok := s.carts[key]; !ok {
+			return sql.ErrNoRows
+		}
+
+		delete(s.carts, key)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete cart item: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListCartItems(_ context.Context, db repository.DB, userID int) ([]model.CartItem, error) {
+	var items []model.CartItem
+
+	err := r.read(db, func(s *state) error {
+		for _, key := range s.sortedCarts() {
+			if key.userID != userID {
+				continue
+			}
+
+			item := s.items[key.itemID]
+			cartItem := model.CartItem{
+				ItemID:   item.ID,
+				Item:     item.Name,
+				Quantity: s.carts[key],
+				Price:    item.Price,
+				Stock:    copyInt(item.Stock),
+			}
+
+			if variant := s.variants[key.variantID]; variant != nil {
+				cartItem.VariantID = variant.ID
+				cartItem.VariantAttributes = variant.VariantAttributes
+				cartItem.Price += variant.PriceDelta
+				cartItem.Stock = copyInt(variant.Stock)
+			}
+
+			items = append(items, cartItem)
+		}
+
+		slices.SortStableFunc(items, func(a, b model.CartItem) int {
+			return strings.Compare(a.Item, b.Item)
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select cart items: %w", err)
+	}
+
+	return items, nil
+}
+
+func (r *Repository) ClearCart(ctx context.Context, db repository.DB, userID int) error {
+	err := r.write(ctx, db, func(s *state) error {
+		for key := range s.carts {
+			if key.userID == userID {
+				delete(s.carts, key)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete cart items: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) ReserveIdempotencyKey(
+	ctx context.Context, db repository.DB, key *model.IdempotencyKey, notBefore time.Time,
+) (bool, error) {
+	var reserved bool
+
+	err := r.write(ctx, db, func(s *state) error {
+		id := idempotencyKeyID{key.Username, key.Key}
+
+		if stored, ok := s.idempotencyKeys[id]; ok && !stored.CreatedAt.Before(notBefore) {
+			*key = *copyIdempotencyKey(stored)
+
+			return nil
+		}
+
+		key.StatusCode = 0
+		key.Response = nil
+		key.CreatedAt = time.Now()
+
+		s.idempotencyKeys[id] = copyIdempotencyKey(key)
+		reserved = true
+
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("insert idempotency key: %w", err)
+	}
+
+	return reserved, nil
+}
+
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, db repository.DB, key *model.IdempotencyKey) error {
+	err := r.write(ctx, db, func(s *state) error {
+		stored, ok := s.idempotencyKeys[idempotencyKeyID{key.Username, key.Key}]
+		if !ok {
+			return nil
+		}
+
+		stored.StatusCode = key.StatusCode
+		stored.Response = append([]byte(nil), key.Response...)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteIdempotencyKey(ctx context.Context, db repository.DB, username, key string) error {
+	err := r.write(ctx, db, func(s *state) error {
+		delete(s.idempotencyKeys, idempotencyKeyID{username, key})
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete idempotency key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) FindItem(_ context.Context, db repository.DB, name string) (*model.Item, error) {
+	var item model.Item
+
+	err := r.read(db, func(s *state) error {
+		for _, it := range s.items {
+			if it.Name == name {
+				item = *it
+				item.Stock = copyInt(it.Stock)
+
+				return nil
+			}
+		}
+
+		return sql.ErrNoRows
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select item: %w", err)
+	}
+
+	return &item, nil
+}
+
+func (r *Repository) CreateItem(ctx context.Context, db repository.DB, item *model.Item) error {
+	err := r.write(ctx, db, func(s *state) error {
+		for _, it := range s.items {
+			if it.Name == item.Name {
+				return fmt.Errorf("item %q %w", item.Name, ErrAlreadyExist)
+			}
+		}
+
+		s.lastItemID++
+		s.items[s.lastItemID] = &model.Item{
+			ID:    s.lastItemID,
+			Name:  item.Name,
+			Price: item.Price,
+			Stock: copyInt(item.Stock),
+		}
+		item.ID = s.lastItemID
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert item: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) SetItemStock(ctx context.Context, db repository.DB, itemID int, stock *int) error {
+	err := r.write(ctx, db, func(s *state) error {
+		item := s.items[itemID]
+		if item == nil {
+			return fmt.Errorf("item %d: %w", itemID, sql.ErrNoRows)
+		}
+
+		item.Stock = copyInt(stock)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update stock: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListItems(_ context.Context, db repository.DB) ([]model.Item, error) {
+	var items []model.Item
+
+	err := r.read(db, func(s *state) error {
+		for _, it := range s.items {
+			item := *it
+			item.Stock = copyInt(it.Stock)
+			items = append(items, item)
+		}
+
+		slices.SortFunc(items, func(a, b model.Item) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select items: %w", err)
+	}
+
+	return items, nil
+}
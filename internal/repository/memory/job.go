@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) FindJobRun(_ context.Context, db repository.DB, name string) (time.Time, error) {
+	var at time.Time
+
+	err := r.read(db, func(s *state) error {
+		var ok bool
+		if at, ok = s.jobRuns[name]; !ok {
+			return sql.ErrNoRows
+		}
+
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("select job run: %w", err)
+	}
+
+	return at, nil
+}
+
+func (r *Repository) SaveJobRun(ctx context.Context, db repository.DB, name string, at time.Time) error {
+	err := r.write(ctx, db, func(s *state) error {
+		s.jobRuns[name] = at
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("upsert job run: %w", err)
+	}
+
+	return nil
+}
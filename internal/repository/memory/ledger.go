@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) AddLedgerEntry(ctx context.Context, db repository.DB, entry *model.LedgerEntry) (bool, error) {
+	var added bool
+
+	err := r.write(ctx, db, func(s *state) error {
+		if s.users[entry.UserID] == nil {
+			return fmt.Errorf("user %d: %w", entry.UserID, sql.ErrNoRows)
+		}
+
+		if entry.TransferID != 0 && s.findTransfer(entry.TransferID) == nil {
+			return fmt.Errorf("transfer %d: %w", entry.TransferID, sql.ErrNoRows)
+		}
+
+		if entry.Reference != "" {
+			for _, e := range s.ledger {
+				if e.UserID == entry.UserID && e.Kind == entry.Kind && e.Reference == entry.Reference {
+					return nil
+				}
+			}
+		}
+
+		s.addLedgerEntry(entry)
+		added = true
+
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("insert ledger entry: %w", err)
+	}
+
+	return added, nil
+}
+
+func (r *Repository) ListLedgerEntries(_ context.Context, db repository.DB, userID int) ([]model.LedgerEntry, error) {
+	var entries []model.LedgerEntry
+
+	err := r.read(db, func(s *state) error {
+		for _, e := range s.ledger {
+			if e.UserID == userID {
+				entries = append(entries, e)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select ledger entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+func (r *Repository) Reconcile(_ context.Context, db repository.DB, limit int) (*model.Reconciliation, error) {
+	result := &model.Reconciliation{Discrepancies: make([]model.Discrepancy, 0)}
+
+	err := r.read(db, func(s *state) error {
+		expected := make(map[int]int64, len(s.users))
+
+		for _, e := range s.ledger {
+			switch {
+			case e.Kind == model.LedgerEntryReversal:
+			case e.Amount > 0:
+				result.Issued += int64(e.Amount)
+			default:
+				result.Burned -= int64(e.Amount)
+			}
+
+			expected[e.UserID] += int64(e.Amount)
+		}
+
+		for key, amount := range s.transfers {
+			expected[key.a] -= int64(amount)
+			expected[key.b] += int64(amount)
+		}
+
+		for _, t := range s.pendingTransfers {
+			if t.Status == model.PendingTransferPending {
+				result.Held += int64(t.Amount)
+				expected[t.SenderID] -= int64(t.Amount)
+			}
+		}
+
+		for _, username := range slices.Sorted(maps.Keys(s.userIDs)) {
+			user := s.users[s.userIDs[username]]
+			result.Balances += int64(user.Balance)
+
+			if int64(user.Balance) != expected[user.ID] && len(result.Discrepancies) < limit {
+				result.Discrepancies = append(result.Discrepancies, model.Discrepancy{
+					Username: username,
+					Balance:  int64(user.Balance),
+					Expected: expected[user.ID],
+				})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reconcile: %w", err)
+	}
+
+	return result, nil
+}
+
+// addLedgerEntry appends entry, setting its ID and creation time.
+func (s *state) addLedgerEntry(entry *model.LedgerEntry) {
+	s.lastLedgerEntryID++
+	entry.ID = s.lastLedgerEntryID
+	entry.CreatedAt = time.Now()
+
+	s.ledger = append(s.ledger, *entry)
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) FindTransferLimits(
+	_ context.Context, db repository.DB, userID int,
+) (*model.TransferLimitsOverride, error) {
+	var override *model.TransferLimitsOverride
+
+	err := r.read(db, func(s *state) error {
+		o, ok := s.transferLimits[userID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		override = copyTransferLimits(o)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select transfer limits: %w", err)
+	}
+
+	return override, nil
+}
+
+func (r *Repository) SetTransferLimits(
+	ctx context.Context, db repository.DB, userID int, override *model.TransferLimitsOverride,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		if s.users[userID] == nil {
+			return fmt.Errorf("user %d: %w", userID, sql.ErrNoRows)
+		}
+
+		if override.Empty() {
+			delete(s.transferLimits, userID)
+
+			return nil
+		}
+
+		s.transferLimits[userID] = copyTransferLimits(override)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("upsert transfer limits: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) FindTransferUsage(
+	_ context.Context, db repository.DB, userID int, day time.Time,
+) (model.TransferUsage, error) {
+	var usage model.TransferUsage
+
+	err := r.read(db, func(s *state) error {
+		usage = s.transferUsage[transferUsageID{userID, day.Format(time.DateOnly)}]
+
+		return nil
+	})
+	if err != nil {
+		return model.TransferUsage{}, fmt.Errorf("select transfer usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+func (r *Repository) AddTransferUsage(
+	ctx context.Context, db repository.DB, userID int, day time.Time, amount int,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		if s.users[userID] == nil {
+			return fmt.Errorf("user %d: %w", userID, sql.ErrNoRows)
+		}
+
+		id := transferUsageID{userID, day.Format(time.DateOnly)}
+		usage := s.transferUsage[id]
+		usage.Amount += amount
+		usage.Count++
+		s.transferUsage[id] = usage
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add transfer usage: %w", err)
+	}
+
+	return nil
+}
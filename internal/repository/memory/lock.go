@@ -0,0 +1,23 @@
+package memory
+
+import (
+	"context"
+)
+
+func (r *Repository) TryLock(_ context.Context, key int64) (release func(), locked bool, err error) {
+	r.locksMu.Lock()
+	defer r.locksMu.Unlock()
+
+	if r.locks[key] {
+		return nil, false, nil
+	}
+
+	r.locks[key] = true
+
+	return func() {
+		r.locksMu.Lock()
+		defer r.locksMu.Unlock()
+
+		delete(r.locks, key)
+	}, true, nil
+}
@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ repository.Repository = (*Repository)(nil)
+
+var (
+	ErrUnsupported  = errors.New("raw queries are not supported by the in-memory repository")
+	ErrForeignTx    = errors.New("transaction does not belong to the in-memory repository")
+	ErrAlreadyExist = errors.New("already exists")
+)
+
+// Repository keeps all data in memory. Transactions run one at a time on a
+// copy of the data that replaces the committed state only if they succeed.
+// The committed state is never changed in place, so reads outside of a
+// transaction need no lock and, like reads on another connection in
+// Postgres, see the committed state even while a transaction runs.
+type Repository struct {
+	// mu serializes transactions.
+	mu    sync.Mutex
+	state atomic.Pointer[state]
+
+	locksMu sync.Mutex
+	locks   map[int64]bool
+
+	listenersMu sync.Mutex
+	listeners   map[*func(int)]struct{}
+}
+
+// New returns an empty repository with the default item catalog, the same as
+// the one created by the initial migration.
+func New() *Repository {
+	s := newState()
+
+	for _, item := range []struct {
+		name  string
+		price int
+	}{
+		{"t-shirt", 80},
+		{"cup", 20},
+		{"book", 50},
+		{"pen", 10},
+		{"powerbank", 200},
+		{"hoody", 300},
+		{"umbrella", 200},
+		{"socks", 10},
+		{"wallet", 50},
+		{"pink-hoody", 500},
+	} {
+		s.lastItemID++
+		s.items[s.lastItemID] = &model.Item{ID: s.lastItemID, Name: item.name, Price: item.price}
+	}
+
+	r := &Repository{
+		locks:     make(map[int64]bool),
+		listeners: make(map[*func(int)]struct{}),
+	}
+	r.state.Store(s)
+
+	return r
+}
+
+// tx is the handle passed to WithTx callbacks. It satisfies repository.DB only
+// to fit the interface, raw queries always fail.
+type tx struct {
+	state *state
+}
+
+func (*tx) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, ErrUnsupported
+}
+
+func (*tx) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, ErrUnsupported
+}
+
+func (*tx) QueryRow(context.Context, string, ...any) pgx.Row {
+	return errRow{}
+}
+
+type errRow struct{}
+
+func (errRow) Scan(...any) error { return ErrUnsupported }
+
+func (r *Repository) WithTx(_ context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
+	committed, err := r.commit(fn)
+	if err != nil {
+		return err
+	}
+
+	// Like NOTIFY in Postgres, listeners hear about notifications only after
+	// the commit, and may use the repository.
+	r.notify(committed)
+
+	return nil
+}
+
+// commit runs fn on a copy of the state and, if it succeeds, makes the copy
+// current. It returns the notifications added by fn.
+func (r *Repository) commit(fn func(repository.DB) error) ([]model.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	committed := r.state.Load()
+
+	t := &tx{state: committed.clone()}
+	if err := fn(t); err != nil {
+		return nil, err
+	}
+
+	added := t.state.notifications[len(committed.notifications):]
+	r.state.Store(t.state)
+
+	return added, nil
+}
+
+// read runs fn against the transaction state or, outside of a transaction,
+// against the committed state, which fn must not change.
+func (r *Repository) read(db repository.DB, fn func(*state) error) error {
+	if db != nil {
+		t, ok := db.(*tx)
+		if !ok {
+			return ErrForeignTx
+		}
+
+		return fn(t.state)
+	}
+
+	return fn(r.state.Load())
+}
+
+// write runs fn against the transaction state or, outside of a transaction,
+// in a transaction of its own.
+func (r *Repository) write(ctx context.Context, db repository.DB, fn func(*state) error) error {
+	if db == nil {
+		return r.WithTx(ctx, func(db repository.DB) error {
+			return r.write(ctx, db, fn)
+		})
+	}
+
+	t, ok := db.(*tx)
+	if !ok {
+		return ErrForeignTx
+	}
+
+	return fn(t.state)
+}
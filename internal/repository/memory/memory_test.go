@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/repositorytest"
+)
+
+func TestRepository(t *testing.T) {
+	t.Parallel()
+
+	repositorytest.Run(t, New())
+}
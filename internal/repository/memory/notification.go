@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) AddNotifications(
+	ctx context.Context, db repository.DB, notifications ...*model.Notification,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		for _, n := range notifications {
+			if s.users[n.UserID] == nil {
+				return fmt.Errorf("user %d: %w", n.UserID, sql.ErrNoRows)
+			}
+
+			s.lastNotificationID++
+
+			n.ID = s.lastNotificationID
+			n.CreatedAt = time.Now()
+
+			s.notifications = append(s.notifications, *n)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert notification: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListNotifications(
+	_ context.Context, db repository.DB, userID int, afterID int64, limit int,
+) ([]model.Notification, error) {
+	var notifications []model.Notification
+
+	err := r.read(db, func(s *state) error {
+		for _, n := range s.notifications {
+			if len(notifications) == limit {
+				break
+			}
+
+			if n.UserID == userID && n.ID > afterID {
+				notifications = append(notifications, n)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (r *Repository) LastNotificationID(_ context.Context, db repository.DB, userID int) (int64, error) {
+	var id int64
+
+	err := r.read(db, func(s *state) error {
+		for _, n := range s.notifications {
+			if n.UserID == userID {
+				id = n.ID
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("select last notification: %w", err)
+	}
+
+	return id, nil
+}
+
+func (r *Repository) ListenNotifications(ctx context.Context, fn func(userID int)) error {
+	r.listenersMu.Lock()
+	r.listeners[&fn] = struct{}{}
+	r.listenersMu.Unlock()
+
+	<-ctx.Done()
+
+	r.listenersMu.Lock()
+	delete(r.listeners, &fn)
+	r.listenersMu.Unlock()
+
+	return nil
+}
+
+func (r *Repository) notify(notifications []model.Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+
+	r.listenersMu.Lock()
+	defer r.listenersMu.Unlock()
+
+	for fn := range r.listeners {
+		for _, n := range notifications {
+			(*fn)(n.UserID)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) AddEvent(ctx context.Context, db repository.DB, event *model.Event) error {
+	err := r.write(ctx, db, func(s *state) error {
+		if s.users[event.UserID] == nil {
+			return fmt.Errorf("user %d: %w", event.UserID, sql.ErrNoRows)
+		}
+
+		if event.ReceiverID != 0 && s.users[event.ReceiverID] == nil {
+			return fmt.Errorf("receiver %d: %w", event.ReceiverID, sql.ErrNoRows)
+		}
+
+		s.lastEventID++
+		s.sequences[event.UserID]++
+
+		event.ID = s.lastEventID
+		event.Sequence = s.sequences[event.UserID]
+		event.ReceiverSequence = 0
+		event.CreatedAt = time.Now()
+
+		if event.ReceiverID != 0 {
+			s.sequences[event.ReceiverID]++
+			event.ReceiverSequence = s.sequences[event.ReceiverID]
+		}
+
+		s.outbox = append(s.outbox, outboxEntry{event: *event})
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert event: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListPendingEvents(_ context.Context, db repository.DB, limit int) ([]model.Event, error) {
+	var events []model.Event
+
+	err := r.read(db, func(s *state) error {
+		for _, entry := range s.outbox {
+			if len(events) == limit {
+				break
+			}
+
+			if !entry.published {
+				events = append(events, entry.event)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select pending events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (r *Repository) MarkEventsPublished(ctx context.Context, db repository.DB, ids []int64) error {
+	err := r.write(ctx, db, func(s *state) error {
+		published := make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			published[id] = true
+		}
+
+		for i := range s.outbox {
+			if published[s.outbox[i].event.ID] {
+				s.outbox[i].published = true
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("mark events published: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) FindEvent(_ context.Context, db repository.DB, id int64) (*model.Event, error) {
+	var event model.Event
+
+	err := r.read(db, func(s *state) error {
+		for _, entry := range s.outbox {
+			if entry.event.ID == id {
+				event = entry.event
+
+				return nil
+			}
+		}
+
+		return sql.ErrNoRows
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select event: %w", err)
+	}
+
+	return &event, nil
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) CreatePaymentRequest(
+	ctx context.Context, db repository.DB, request *model.PaymentRequest,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		requester, payer := s.users[request.RequesterID], s.users[request.PayerID]
+		if requester == nil || payer == nil {
+			return fmt.Errorf("request %d -> %d: %w", request.RequesterID, request.PayerID, sql.ErrNoRows)
+		}
+
+		s.lastPaymentRequestID++
+		request.ID = s.lastPaymentRequestID
+		request.Status = model.PaymentRequestPending
+		request.CreatedAt = time.Now()
+
+		stored := *request
+		stored.Requester, stored.Payer = requester.Username, payer.Username
+		s.paymentRequests[request.ID] = &stored
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert payment request: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) FindPaymentRequestForUpdate(
+	_ context.Context, db repository.DB, id int64,
+) (*model.PaymentRequest, error) {
+	var request *model.PaymentRequest
+
+	err := r.read(db, func(s *state) error {
+		p, ok := s.paymentRequests[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		request = copyPaymentRequest(p)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select payment request: %w", err)
+	}
+
+	return request, nil
+}
+
+func (r *Repository) ListPaymentRequests(
+	_ context.Context, db repository.DB, userID int, at time.Time,
+) ([]model.PaymentRequest, error) {
+	var requests []model.PaymentRequest
+
+	err := r.read(db, func(s *state) error {
+		for _, id := range slices.Sorted(maps.Keys(s.paymentRequests)) {
+			p := s.paymentRequests[id]
+			if p.Open(at) && (p.RequesterID == userID || p.PayerID == userID) {
+				requests = append(requests, *copyPaymentRequest(p))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select payment requests: %w", err)
+	}
+
+	return requests, nil
+}
+
+func (r *Repository) ResolvePaymentRequest(
+	ctx context.Context, db repository.DB, request *model.PaymentRequest, status model.PaymentRequestStatus,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		p, ok := s.paymentRequests[request.ID]
+		if !ok || p.Status != model.PaymentRequestPending {
+			return sql.ErrNoRows
+		}
+
+		now := time.Now()
+		p.Status = status
+		p.ResolvedAt = &now
+
+		request.Status = status
+		request.ResolvedAt = &now
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("resolve payment request: %w", err)
+	}
+
+	return nil
+}
+
+func copyPaymentRequest(p *model.PaymentRequest) *model.PaymentRequest {
+	c := *p
+	if p.ResolvedAt != nil {
+		at := *p.ResolvedAt
+		c.ResolvedAt = &at
+	}
+
+	return &c
+}
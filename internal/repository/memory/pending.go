@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) CreatePendingTransfer(
+	ctx context.Context, db repository.DB, transfer *model.PendingTransfer,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		sender, receiver := s.users[transfer.SenderID], s.users[transfer.ReceiverID]
+		if sender == nil || receiver == nil {
+			return fmt.Errorf("transfer %d -> %d: %w", transfer.SenderID, transfer.ReceiverID, sql.ErrNoRows)
+		}
+
+		if sender.Balance < transfer.Amount {
+			return model.ErrInsufficientFunds
+		}
+
+		sender.Balance -= transfer.Amount
+
+		s.lastPendingTransferID++
+		transfer.ID = s.lastPendingTransferID
+		transfer.Status = model.PendingTransferPending
+		transfer.CreatedAt = time.Now()
+
+		stored := *transfer
+		stored.FromUser, stored.ToUser = sender.Username, receiver.Username
+		s.pendingTransfers[transfer.ID] = &stored
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert pending transfer: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) FindPendingTransferForUpdate(
+	_ context.Context, db repository.DB, id int64,
+) (*model.PendingTransfer, error) {
+	var transfer *model.PendingTransfer
+
+	err := r.read(db, func(s *state) error {
+		t, ok := s.pendingTransfers[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		transfer = copyPendingTransfer(t)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select pending transfer: %w", err)
+	}
+
+	return transfer, nil
+}
+
+func (r *Repository) ListPendingTransfers(
+	_ context.Context, db repository.DB, userID int,
+) ([]model.PendingTransfer, error) {
+	var transfers []model.PendingTransfer
+
+	err := r.read(db, func(s *state) error {
+		for _, id := range slices.Sorted(maps.Keys(s.pendingTransfers)) {
+			t := s.pendingTransfers[id]
+			if t.Status == model.PendingTransferPending && (t.SenderID == userID || t.ReceiverID == userID) {
+				transfers = append(transfers, *copyPendingTransfer(t))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select pending transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+func (r *Repository) ListExpiredPendingTransfers(
+	_ context.Context, db repository.DB, at time.Time, limit int,
+) ([]model.PendingTransfer, error) {
+	var transfers []model.PendingTransfer
+
+	err := r.read(db, func(s *state) error {
+		for _, id := range slices.Sorted(maps.Keys(s.pendingTransfers)) {
+			t := s.pendingTransfers[id]
+			if t.Status == model.PendingTransferPending && !t.ExpiresAt.After(at) && len(transfers) < limit {
+				transfers = append(transfers, *copyPendingTransfer(t))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select expired pending transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+func (r *Repository) ResolvePendingTransfer(
+	ctx context.Context, db repository.DB, transfer *model.PendingTransfer, status model.PendingTransferStatus,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		t, ok := s.pendingTransfers[transfer.ID]
+		if !ok || t.Status != model.PendingTransferPending {
+			return sql.ErrNoRows
+		}
+
+		now := time.Now()
+		t.Status = status
+		t.ResolvedAt = &now
+		s.users[t.SenderID].Balance += t.Amount
+
+		transfer.Status = status
+		transfer.ResolvedAt = &now
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("resolve pending transfer: %w", err)
+	}
+
+	return nil
+}
+
+func copyPendingTransfer(t *model.PendingTransfer) *model.PendingTransfer {
+	c := *t
+	if t.ResolvedAt != nil {
+		at := *t.ResolvedAt
+		c.ResolvedAt = &at
+	}
+
+	return &c
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) MakePurchase(ctx context.Context, db repository.DB, purchase *model.Purchase) error {
+	err := r.write(ctx, db, func(s *state) error {
+		user, item := s.users[purchase.UserID], s.items[purchase.ItemID]
+		if user == nil || item == nil {
+			return fmt.Errorf("purchase %d by %d: %w", purchase.ItemID, purchase.UserID, sql.ErrNoRows)
+		}
+
+		stock := &item.Stock
+
+		if purchase.VariantID != 0 {
+			variant := s.variants[purchase.VariantID]
+			if variant == nil || variant.ItemID != item.ID {
+				return fmt.Errorf("variant %d of %d: %w", purchase.VariantID, item.ID, sql.ErrNoRows)
+			}
+
+			stock = &variant.Stock
+		}
+
+		if *stock != nil && **stock < purchase.Quantity {
+			return model.ErrOutOfStock
+		}
+
+		if user.Balance < purchase.Price {
+			return model.ErrInsufficientFunds
+		}
+
+		if *stock != nil {
+			left := **stock - purchase.Quantity
+			*stock = &left
+		}
+
+		user.Balance -= purchase.Price
+		s.purchases[line{purchase.UserID, purchase.ItemID, purchase.VariantID}] += purchase.Quantity
+		s.addLedgerEntry(&model.LedgerEntry{
+			UserID: purchase.UserID,
+			Kind:   model.LedgerEntryPurchase,
+			Amount: -purchase.Price,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert purchase: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListInventory(_ context.Context, db repository.DB, userID int) ([]model.Inventory, error) {
+	var inventory []model.Inventory
+
+	err := r.read(db, func(s *state) error {
+		for _, key := range s.sortedPurchases() {
+			if key.userID != userID {
+				continue
+			}
+
+			item := model.Inventory{Type: s.items[key.itemID].Name, Quantity: s.purchases[key]}
+			if variant := s.variants[key.variantID]; variant != nil {
+				item.VariantAttributes = variant.VariantAttributes
+			}
+
+			inventory = append(inventory, item)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select inventory: %w", err)
+	}
+
+	return inventory, nil
+}
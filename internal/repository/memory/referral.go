@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) AddReferral(ctx context.Context, db repository.DB, referral *model.Referral) error {
+	err := r.write(ctx, db, func(s *state) error {
+		if s.users[referral.RefereeID] == nil || s.users[referral.ReferrerID] == nil {
+			return fmt.Errorf("referral of %d by %d: %w", referral.RefereeID, referral.ReferrerID, sql.ErrNoRows)
+		}
+
+		if _, ok := s.referrals[referral.RefereeID]; ok {
+			return fmt.Errorf("referral of %d %w", referral.RefereeID, ErrAlreadyExist)
+		}
+
+		referral.CreatedAt = time.Now()
+		s.referrals[referral.RefereeID] = *referral
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert referral: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) CountRewardedReferrals(
+	_ context.Context, db repository.DB, referrerID int, since time.Time,
+) (int, error) {
+	var count int
+
+	err := r.read(db, func(s *state) error {
+		for _, referral := range s.referrals {
+			if referral.ReferrerID == referrerID && referral.Rewarded && referral.CreatedAt.After(since) {
+				count++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("count referrals: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) CreateScheduledTransfer(
+	ctx context.Context, db repository.DB, transfer *model.ScheduledTransfer,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		sender, receiver := s.users[transfer.SenderID], s.users[transfer.ReceiverID]
+		if sender == nil || receiver == nil {
+			return fmt.Errorf("transfer %d -> %d: %w", transfer.SenderID, transfer.ReceiverID, sql.ErrNoRows)
+		}
+
+		s.lastScheduledTransferID++
+		transfer.ID = s.lastScheduledTransferID
+		transfer.Status = model.ScheduledTransferActive
+		transfer.CreatedAt = time.Now()
+
+		stored := *transfer
+		stored.FromUser, stored.ToUser = sender.Username, receiver.Username
+		s.scheduledTransfers[transfer.ID] = &stored
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert scheduled transfer: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) FindScheduledTransferForUpdate(
+	_ context.Context, db repository.DB, id int64,
+) (*model.ScheduledTransfer, error) {
+	var transfer *model.ScheduledTransfer
+
+	err := r.read(db, func(s *state) error {
+		t, ok := s.scheduledTransfers[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		c := *t
+		transfer = &c
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select scheduled transfer: %w", err)
+	}
+
+	return transfer, nil
+}
+
+func (r *Repository) ListScheduledTransfers(
+	_ context.Context, db repository.DB, senderID int,
+) ([]model.ScheduledTransfer, error) {
+	var transfers []model.ScheduledTransfer
+
+	err := r.read(db, func(s *state) error {
+		for _, id := range slices.Sorted(maps.Keys(s.scheduledTransfers)) {
+			if t := s.scheduledTransfers[id]; t.SenderID == senderID {
+				transfers = append(transfers, *t)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select scheduled transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+func (r *Repository) ListDueScheduledTransfers(
+	_ context.Context, db repository.DB, at time.Time, limit int,
+) ([]model.ScheduledTransfer, error) {
+	var transfers []model.ScheduledTransfer
+
+	err := r.read(db, func(s *state) error {
+		for _, t := range s.scheduledTransfers {
+			if t.Status == model.ScheduledTransferActive && !t.NextRunAt.After(at) {
+				transfers = append(transfers, *t)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select due scheduled transfers: %w", err)
+	}
+
+	slices.SortFunc(transfers, func(a, b model.ScheduledTransfer) int {
+		return cmp.Or(a.NextRunAt.Compare(b.NextRunAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return transfers[:min(limit, len(transfers))], nil
+}
+
+func (r *Repository) UpdateScheduledTransfer(
+	ctx context.Context, db repository.DB, transfer *model.ScheduledTransfer,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		t, ok := s.scheduledTransfers[transfer.ID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		t.Status = transfer.Status
+		t.NextRunAt = transfer.NextRunAt
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update scheduled transfer: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) AddScheduledTransferRun(
+	ctx context.Context, db repository.DB, run *model.ScheduledTransferRun,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		if _, ok := s.scheduledTransfers[run.ScheduledTransferID]; !ok {
+			return fmt.Errorf("scheduled transfer %d: %w", run.ScheduledTransferID, sql.ErrNoRows)
+		}
+
+		s.lastScheduledTransferRunID++
+		run.ID = s.lastScheduledTransferRunID
+		run.CreatedAt = time.Now()
+		s.scheduledTransferRuns = append(s.scheduledTransferRuns, *run)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert scheduled transfer run: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListScheduledTransferRuns(
+	_ context.Context, db repository.DB, transferID int64, limit int,
+) ([]model.ScheduledTransferRun, error) {
+	var runs []model.ScheduledTransferRun
+
+	err := r.read(db, func(s *state) error {
+		for _, run := range slices.Backward(s.scheduledTransferRuns) {
+			if run.ScheduledTransferID == transferID && len(runs) < limit {
+				runs = append(runs, run)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select scheduled transfer runs: %w", err)
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,312 @@
+package memory
+
+import (
+	"cmp"
+	"database/sql"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+type state struct {
+	users   map[int]*model.User
+	userIDs map[string]int
+	items   map[int]*model.Item
+	// variants maps IDs to the variants of all items.
+	variants map[int]*model.Variant
+
+	// purchases maps (user, item, variant) to quantity.
+	purchases map[line]int
+	// carts maps (user, item, variant) to the quantity in the cart.
+	carts map[line]int
+	// transfers maps (sender, receiver) to the total amount sent.
+	transfers map[pair]int
+	// transferHistory lists every transfer.
+	transferHistory []model.Transfer
+
+	outbox []outboxEntry
+	// sequences maps users to the sequence number of their last event.
+	sequences map[int]int64
+
+	webhooks   map[int]*model.Webhook
+	deliveries map[int64]*model.WebhookDelivery
+
+	notifications []model.Notification
+
+	idempotencyKeys map[idempotencyKeyID]*model.IdempotencyKey
+
+	ledger []model.LedgerEntry
+	// referrals maps referees to their referral.
+	referrals map[int]model.Referral
+	// transferLimits maps users to the override of their transfer limits.
+	transferLimits map[int]*model.TransferLimitsOverride
+	transferUsage  map[transferUsageID]model.TransferUsage
+
+	pendingTransfers map[int64]*model.PendingTransfer
+	paymentRequests  map[int64]*model.PaymentRequest
+
+	scheduledTransfers    map[int64]*model.ScheduledTransfer
+	scheduledTransferRuns []model.ScheduledTransferRun
+
+	// jobRuns maps scheduled jobs to the time of their last run.
+	jobRuns map[string]time.Time
+
+	lastUserID, lastItemID, lastVariantID, lastWebhookID               int
+	lastEventID, lastDeliveryID, lastNotificationID, lastLedgerEntryID int64
+	lastTransferID, lastPendingTransferID, lastPaymentRequestID        int64
+	lastScheduledTransferID, lastScheduledTransferRunID                int64
+}
+
+type outboxEntry struct {
+	event     model.Event
+	published bool
+}
+
+func newState() *state {
+	return &state{
+		users:      make(map[int]*model.User),
+		userIDs:    make(map[string]int),
+		items:      make(map[int]*model.Item),
+		variants:   make(map[int]*model.Variant),
+		purchases:  make(map[line]int),
+		carts:      make(map[line]int),
+		transfers:  make(map[pair]int),
+		sequences:  make(map[int]int64),
+		webhooks:   make(map[int]*model.Webhook),
+		deliveries: make(map[int64]*model.WebhookDelivery),
+
+		idempotencyKeys: make(map[idempotencyKeyID]*model.IdempotencyKey),
+		jobRuns:         make(map[string]time.Time),
+		referrals:       make(map[int]model.Referral),
+		transferLimits:  make(map[int]*model.TransferLimitsOverride),
+		transferUsage:   make(map[transferUsageID]model.TransferUsage),
+
+		pendingTransfers: make(map[int64]*model.PendingTransfer),
+		paymentRequests:  make(map[int64]*model.PaymentRequest),
+
+		scheduledTransfers: make(map[int64]*model.ScheduledTransfer),
+	}
+}
+
+// clone returns a copy that can be modified without affecting s.
+func (s *state) clone() *state {
+	c := &state{
+		users:              make(map[int]*model.User, len(s.users)),
+		userIDs:            maps.Clone(s.userIDs),
+		items:              make(map[int]*model.Item, len(s.items)),
+		variants:           make(map[int]*model.Variant, len(s.variants)),
+		purchases:          maps.Clone(s.purchases),
+		carts:              maps.Clone(s.carts),
+		transfers:          maps.Clone(s.transfers),
+		transferHistory:    slices.Clone(s.transferHistory),
+		outbox:             slices.Clone(s.outbox),
+		sequences:          maps.Clone(s.sequences),
+		webhooks:           make(map[int]*model.Webhook, len(s.webhooks)),
+		deliveries:         make(map[int64]*model.WebhookDelivery, len(s.deliveries)),
+		notifications:      slices.Clone(s.notifications),
+		idempotencyKeys:    make(map[idempotencyKeyID]*model.IdempotencyKey, len(s.idempotencyKeys)),
+		ledger:             slices.Clone(s.ledger),
+		jobRuns:            maps.Clone(s.jobRuns),
+		referrals:          maps.Clone(s.referrals),
+		transferLimits:     make(map[int]*model.TransferLimitsOverride, len(s.transferLimits)),
+		transferUsage:      maps.Clone(s.transferUsage),
+		pendingTransfers:   make(map[int64]*model.PendingTransfer, len(s.pendingTransfers)),
+		paymentRequests:    make(map[int64]*model.PaymentRequest, len(s.paymentRequests)),
+		scheduledTransfers: make(map[int64]*model.ScheduledTransfer, len(s.scheduledTransfers)),
+		lastUserID:         s.lastUserID,
+		lastItemID:         s.lastItemID,
+		lastVariantID:      s.lastVariantID,
+		lastWebhookID:      s.lastWebhookID,
+		lastEventID:        s.lastEventID,
+		lastDeliveryID:     s.lastDeliveryID,
+		lastNotificationID: s.lastNotificationID,
+		lastLedgerEntryID:  s.lastLedgerEntryID,
+		lastTransferID:     s.lastTransferID,
+
+		lastPendingTransferID: s.lastPendingTransferID,
+		lastPaymentRequestID:  s.lastPaymentRequestID,
+
+		scheduledTransferRuns:      slices.Clone(s.scheduledTransferRuns),
+		lastScheduledTransferID:    s.lastScheduledTransferID,
+		lastScheduledTransferRunID: s.lastScheduledTransferRunID,
+	}
+
+	for id, w := range s.webhooks {
+		c.webhooks[id] = copyWebhook(w)
+	}
+
+	for id, d := range s.deliveries {
+		c.deliveries[id] = copyDelivery(d)
+	}
+
+	for id, k := range s.idempotencyKeys {
+		c.idempotencyKeys[id] = copyIdempotencyKey(k)
+	}
+
+	for id, u := range s.users {
+		c.users[id] = copyUser(u)
+	}
+
+	for id, o := range s.transferLimits {
+		c.transferLimits[id] = copyTransferLimits(o)
+	}
+
+	for id, t := range s.pendingTransfers {
+		c.pendingTransfers[id] = copyPendingTransfer(t)
+	}
+
+	for id, p := range s.paymentRequests {
+		c.paymentRequests[id] = copyPaymentRequest(p)
+	}
+
+	for id, t := range s.scheduledTransfers {
+		transfer := *t
+		c.scheduledTransfers[id] = &transfer
+	}
+
+	for id, it := range s.items {
+		item := *it
+		item.Stock = copyInt(it.Stock)
+		c.items[id] = &item
+	}
+
+	for id, v := range s.variants {
+		c.variants[id] = copyVariant(v)
+	}
+
+	return c
+}
+
+func (s *state) userByName(username string) (*model.User, error) {
+	id, ok := s.userIDs[username]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return s.users[id], nil
+}
+
+// userByReferralCode scans all users, codes are only looked up on signup.
+func (s *state) userByReferralCode(code string) (*model.User, error) {
+	for _, u := range s.users {
+		if u.ReferralCode != "" && u.ReferralCode == code {
+			return u, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (s *state) sortedPurchases() []line {
+	return sortLines(slices.Collect(maps.Keys(s.purchases)))
+}
+
+func (s *state) sortedCarts() []line {
+	return sortLines(slices.Collect(maps.Keys(s.carts)))
+}
+
+func (s *state) sortedTransfers() []pair {
+	return sortPairs(slices.Collect(maps.Keys(s.transfers)))
+}
+
+func copyUser(u *model.User) *model.User {
+	c := *u
+	if u.DeactivatedAt != nil {
+		at := *u.DeactivatedAt
+		c.DeactivatedAt = &at
+	}
+
+	return &c
+}
+
+func copyWebhook(w *model.Webhook) *model.Webhook {
+	c := *w
+	c.EventTypes = slices.Clone(w.EventTypes)
+
+	return &c
+}
+
+func copyDelivery(d *model.WebhookDelivery) *model.WebhookDelivery {
+	c := *d
+	if d.DeliveredAt != nil {
+		at := *d.DeliveredAt
+		c.DeliveredAt = &at
+	}
+
+	return &c
+}
+
+func copyIdempotencyKey(k *model.IdempotencyKey) *model.IdempotencyKey {
+	c := *k
+	c.Response = slices.Clone(k.Response)
+
+	return &c
+}
+
+func copyTransferLimits(o *model.TransferLimitsOverride) *model.TransferLimitsOverride {
+	return &model.TransferLimitsOverride{
+		MaxAmount:   copyInt(o.MaxAmount),
+		DailyAmount: copyInt(o.DailyAmount),
+		DailyCount:  copyInt(o.DailyCount),
+	}
+}
+
+func copyVariant(v *model.Variant) *model.Variant {
+	c := *v
+	c.Stock = copyInt(v.Stock)
+
+	return &c
+}
+
+func copyInt(v *int) *int {
+	if v == nil {
+		return nil
+	}
+
+	c := *v
+
+	return &c
+}
+
+// idempotencyKeyID is the key of idempotencyKeys, keys are scoped to a user.
+type idempotencyKeyID struct{ username, key string }
+
+// transferUsageID is the key of transferUsage, usage is counted per UTC day.
+type transferUsageID struct {
+	userID int
+	day    string
+}
+
+// pair is a composite key of two IDs.
+type pair struct{ a, b int }
+
+func sortPairs(keys []pair) []pair {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].a != keys[j].a {
+			return keys[i].a < keys[j].a
+		}
+
+		return keys[i].b < keys[j].b
+	})
+
+	return keys
+}
+
+// line is the key of purchases and carts, variantID is zero for items without
+// variants.
+type line struct{ userID, itemID, variantID int }
+
+func sortLines(keys []line) []line {
+	slices.SortFunc(keys, func(a, b line) int {
+		return cmp.Or(
+			cmp.Compare(a.userID, b.userID),
+			cmp.Compare(a.itemID, b.itemID),
+			cmp.Compare(a.variantID, b.variantID),
+		)
+	})
+
+	return keys
+}
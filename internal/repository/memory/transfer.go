@@ -0,0 +1,233 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) MakeTransfer(ctx context.Context, db repository.DB, transfer *model.Transfer) error {
+	err := r.write(ctx, db, func(s *state) error {
+		sender, receiver := s.users[transfer.SenderID], s.users[transfer.ReceiverID]
+		if sender == nil || receiver == nil || sender == receiver {
+			return fmt.Errorf("transfer %d -> %d: %w", transfer.SenderID, transfer.ReceiverID, sql.ErrNoRows)
+		}
+
+		if sender.Balance < transfer.Amount {
+			return model.ErrInsufficientFunds
+		}
+
+		sender.Balance -= transfer.Amount
+		receiver.Balance += transfer.Amount
+		s.transfers[pair{sender.ID, receiver.ID}] += transfer.Amount
+
+		s.lastTransferID++
+		transfer.ID = s.lastTransferID
+		transfer.CreatedAt = time.Now()
+		s.transferHistory = append(s.transferHistory, *transfer)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("make transfer: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) HasTransferred(_ context.Context, db repository.DB, senderID, receiverID int) (bool, error) {
+	var exists bool
+
+	err := r.read(db, func(s *state) error {
+		_, exists = s.transfers[pair{senderID, receiverID}]
+
+		return nil
+	})
+
+	return exists, err
+}
+
+func (r *Repository) FindTransferForUpdate(_ context.Context, db repository.DB, id int64) (*model.Transfer, error) {
+	var transfer *model.Transfer
+
+	err := r.read(db, func(s *state) error {
+		t := s.findTransfer(id)
+		if t == nil {
+			return sql.ErrNoRows
+		}
+
+		transfer = s.readTransfer(*t)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select transfer: %w", err)
+	}
+
+	return transfer, nil
+}
+
+func (r *Repository) ListUserTransfers(
+	_ context.Context, db repository.DB, userID, limit int,
+) ([]model.Transfer, error) {
+	var transfers []model.Transfer
+
+	err := r.read(db, func(s *state) error {
+		for i := len(s.transferHistory) - 1; i >= 0 && len(transfers) < limit; i-- {
+			t := s.transferHistory[i]
+			if t.SenderID == userID || t.ReceiverID == userID {
+				transfers = append(transfers, *s.readTransfer(t))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+func (r *Repository) ListTransactions(_ context.Context, db repository.DB, userID int) (*model.CoinHistory, error) {
+	history := &model.CoinHistory{
+		Received: make([]model.CoinsReceived, 0),
+		Sent:     make([]model.CoinsSent, 0),
+	}
+
+	err := r.read(db, func(s *state) error {
+		// withMemos sums the transfers with a memo, which are listed on
+		// their own.
+		var withMemos map[pair]int
+
+		for _, t := range s.transferHistory {
+			if t.Memo != "" && (t.SenderID == userID || t.ReceiverID == userID) {
+				if withMemos == nil {
+					withMemos = make(map[pair]int)
+				}
+
+				withMemos[pair{t.SenderID, t.ReceiverID}] += t.Amount
+			}
+		}
+
+		for _, key := range s.sortedTransfers() {
+			amount := s.transfers[key] - withMemos[key]
+
+			switch {
+			case amount <= 0:
+			case userID == key.a:
+				history.Sent = append(history.Sent, model.CoinsSent{
+					ToUser: s.users[key.b].Username,
+					Amount: amount,
+				})
+			case userID == key.b:
+				history.Received = append(history.Received, model.CoinsReceived{
+					FromUser: s.users[key.a].Username,
+					Amount:   amount,
+				})
+			}
+		}
+
+		var received, sent int
+
+		for _, e := range s.ledger {
+			switch {
+			case e.UserID != userID || !e.Kind.InCoinHistory():
+			case e.Amount > 0:
+				received += e.Amount
+			case e.Amount < 0:
+				sent -= e.Amount
+			}
+		}
+
+		if received > 0 {
+			history.Received = append(history.Received, model.CoinsReceived{
+				FromUser: model.SystemAccount,
+				Amount:   received,
+			})
+		}
+
+		if sent > 0 {
+			history.Sent = append(history.Sent, model.CoinsSent{
+				ToUser: model.SystemAccount,
+				Amount: sent,
+			})
+		}
+
+		for _, t := range s.transferHistory {
+			switch {
+			case t.Memo == "":
+			case t.SenderID == userID:
+				history.Sent = append(history.Sent, model.CoinsSent{
+					ToUser: s.users[t.ReceiverID].Username,
+					Amount: t.Amount,
+					Memo:   t.Memo,
+				})
+			case t.ReceiverID == userID:
+				history.Received = append(history.Received, model.CoinsReceived{
+					FromUser: s.users[t.SenderID].Username,
+					Amount:   t.Amount,
+					Memo:     t.Memo,
+				})
+			}
+		}
+
+		for _, e := range s.ledger {
+			if e.UserID != userID || e.Kind != model.LedgerEntryReversal {
+				continue
+			}
+
+			t := s.readTransfer(*s.findTransfer(e.TransferID))
+
+			switch {
+			case e.Amount < 0:
+				history.Sent = append(history.Sent, model.CoinsSent{
+					ToUser:   t.FromUser,
+					Amount:   -e.Amount,
+					Reversal: true,
+				})
+			case e.Amount > 0:
+				history.Received = append(history.Received, model.CoinsReceived{
+					FromUser: t.ToUser,
+					Amount:   e.Amount,
+					Reversal: true,
+				})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select transactions: %w", err)
+	}
+
+	return history, nil
+}
+
+func (s *state) findTransfer(id int64) *model.Transfer {
+	for i := range s.transferHistory {
+		if s.transferHistory[i].ID == id {
+			return &s.transferHistory[i]
+		}
+	}
+
+	return nil
+}
+
+// readTransfer returns a copy of t with the usernames and the reversed coins
+// set, as the database returns it.
+func (s *state) readTransfer(t model.Transfer) *model.Transfer {
+	t.FromUser, t.ToUser = s.users[t.SenderID].Username, s.users[t.ReceiverID].Username
+
+	for _, e := range s.ledger {
+		if e.TransferID == t.ID && e.UserID == t.SenderID {
+			t.Reversed += e.Amount
+		}
+	}
+
+	return &t
+}
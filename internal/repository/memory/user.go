@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) FindUser(_ context.Context, db repository.DB, username string) (*model.User, error) {
+	var user *model.User
+
+	err := r.read(db, func(s *state) error {
+		u, err := s.userByName(username)
+		if err != nil {
+			return err
+		}
+
+		user = copyUser(u)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select user: %w", err)
+	}
+
+	return user, nil
+}
+
+// FindUserForUpdate is FindUser, transactions are already serialized.
+func (r *Repository) FindUserForUpdate(ctx context.Context, db repository.DB, username string) (*model.User, error) {
+	return r.FindUser(ctx, db, username)
+}
+
+func (r *Repository) FindUsersForUpdate(
+	_ context.Context, db repository.DB, usernames ...string,
+) (map[string]*model.User, error) {
+	users := make(map[string]*model.User, len(usernames))
+
+	err := r.read(db, func(s *state) error {
+		for _, username := range usernames {
+			if u, err := s.userByName(username); err == nil {
+				users[username] = copyUser(u)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select users for update: %w", err)
+	}
+
+	return users, nil
+}
+
+func (r *Repository) FindUserByReferralCode(_ context.Context, db repository.DB, code string) (*model.User, error) {
+	var user *model.User
+
+	err := r.read(db, func(s *state) error {
+		u, err := s.userByReferralCode(code)
+		if err != nil {
+			return err
+		}
+
+		user = copyUser(u)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select user by referral code: %w", err)
+	}
+
+	return user, nil
+}
+
+func (r *Repository) CreateUser(ctx context.Context, db repository.DB, user *model.User) error {
+	err := r.write(ctx, db, func(s *state) error {
+		if _, ok := s.userIDs[user.Username]; ok {
+			return fmt.Errorf("user %q %w", user.Username, ErrAlreadyExist)
+		}
+
+		if _, err := s.userByReferralCode(user.ReferralCode); err == nil {
+			return fmt.Errorf("referral code %q %w", user.ReferralCode, ErrAlreadyExist)
+		}
+
+		s.lastUserID++
+		s.users[s.lastUserID] = &model.User{
+			ID:           s.lastUserID,
+			Username:     user.Username,
+			Password:     user.Password,
+			Salt:         user.Salt,
+			Balance:      user.Balance,
+			ReferralCode: user.ReferralCode,
+		}
+		s.userIDs[user.Username] = s.lastUserID
+
+		if user.Balance > 0 {
+			s.addLedgerEntry(&model.LedgerEntry{
+				UserID: s.lastUserID,
+				Kind:   model.LedgerEntrySignup,
+				Amount: user.Balance,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert user: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListUsers(_ context.Context, db repository.DB, after string, limit int) ([]model.User, error) {
+	var users []model.User
+
+	err := r.read(db, func(s *state) error {
+		usernames := slices.Sorted(maps.Keys(s.userIDs))
+
+		for _, username := range usernames {
+			if len(users) == limit {
+				break
+			}
+
+			if username > after {
+				users = append(users, *copyUser(s.users[s.userIDs[username]]))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select users: %w", err)
+	}
+
+	return users, nil
+}
+
+func (r *Repository) AddBalance(ctx context.Context, db repository.DB, userID, amount int) error {
+	err := r.write(ctx, db, func(s *state) error {
+		user := s.users[userID]
+		if user == nil {
+			return fmt.Errorf("user %d: %w", userID, sql.ErrNoRows)
+		}
+
+		if user.Balance+amount < 0 {
+			return model.ErrInsufficientFunds
+		}
+
+		user.Balance += amount
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update balance: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) SetUserDeactivatedAt(ctx context.Context, db repository.DB, userID int, at *time.Time) error {
+	err := r.write(ctx, db, func(s *state) error {
+		user := s.users[userID]
+		if user == nil {
+			return fmt.Errorf("user %d: %w", userID, sql.ErrNoRows)
+		}
+
+		user.DeactivatedAt = nil
+		if at != nil {
+			t := *at
+			user.DeactivatedAt = &t
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) CreateVariant(ctx context.Context, db repository.DB, variant *model.Variant) error {
+	err := r.write(ctx, db, func(s *state) error {
+		if s.items[variant.ItemID] == nil {
+			return fmt.Errorf("item %d: %w", variant.ItemID, sql.ErrNoRows)
+		}
+
+		if _, err := s.findVariant(variant.ItemID, variant.VariantAttributes); err == nil {
+			return fmt.Errorf("variant %s of %d %w", variant.VariantAttributes, variant.ItemID, ErrAlreadyExist)
+		}
+
+		s.lastVariantID++
+		variant.ID = s.lastVariantID
+		s.variants[variant.ID] = copyVariant(variant)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert variant: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) FindVariant(
+	_ context.Context, db repository.DB, itemID int, attrs model.VariantAttributes,
+) (*model.Variant, error) {
+	var variant *model.Variant
+
+	err := r.read(db, func(s *state) error {
+		v, err := s.findVariant(itemID, attrs)
+		if err != nil {
+			return err
+		}
+
+		variant = copyVariant(v)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select variant: %w", err)
+	}
+
+	return variant, nil
+}
+
+func (r *Repository) ListVariants(_ context.Context, db repository.DB, itemID int) ([]model.Variant, error) {
+	var variants []model.Variant
+
+	err := r.read(db, func(s *state) error {
+		for _, v := range s.variants {
+			if v.ItemID == itemID {
+				variants = append(variants, *copyVariant(v))
+			}
+		}
+
+		slices.SortFunc(variants, func(a, b model.Variant) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select variants: %w", err)
+	}
+
+	return variants, nil
+}
+
+func (r *Repository) SetVariantStock(ctx context.Context, db repository.DB, variantID int, stock *int) error {
+	err := r.write(ctx, db, func(s *state) error {
+		variant := s.variants[variantID]
+		if variant == nil {
+			return fmt.Errorf("variant %d: %w", variantID, sql.ErrNoRows)
+		}
+
+		variant.Stock = copyInt(stock)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update variant stock: %w", err)
+	}
+
+	return nil
+}
+
+func (s *state) findVariant(itemID int, attrs model.VariantAttributes) (*model.Variant, error) {
+	for _, v := range s.variants {
+		if v.ItemID == itemID && v.VariantAttributes == attrs {
+			return v, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
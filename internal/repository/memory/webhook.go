@@ -0,0 +1,232 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+func (r *Repository) CreateWebhook(ctx context.Context, db repository.DB, webhook *model.Webhook) error {
+	err := r.write(ctx, db, func(s *state) error {
+		s.lastWebhookID++
+
+		webhook.ID = s.lastWebhookID
+		webhook.CreatedAt = time.Now()
+
+		s.webhooks[webhook.ID] = copyWebhook(webhook)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert webhook: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) FindWebhook(_ context.Context, db repository.DB, id int) (*model.Webhook, error) {
+	var webhook *model.Webhook
+
+	err := r.read(db, func(s *state) error {
+		w, ok := s.webhooks[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		webhook = copyWebhook(w)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func (r *Repository) ListWebhooks(_ context.Context, db repository.DB) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+
+	err := r.read(db, func(s *state) error {
+		for _, id := range slices.Sorted(maps.Keys(s.webhooks)) {
+			webhooks = append(webhooks, *copyWebhook(s.webhooks[id]))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+func (r *Repository) DeleteWebhook(ctx context.Context, db repository.DB, id int) error {
+	err := r.write(ctx, db, func(s *state) error {
+		if _, ok := s.webhooks[id]; !ok {
+			return sql.ErrNoRows
+		}
+
+		delete(s.webhooks, id)
+
+		for deliveryID, d := range s.deliveries {
+			if d.WebhookID == id {
+				delete(s.deliveries, deliveryID)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) CreateWebhookDeliveries(ctx context.Context, db repository.DB, event model.Event) error {
+	err := r.write(ctx, db, func(s *state) error {
+		queued := make(map[pair]bool)
+		for _, d := range s.deliveries {
+			queued[pair{d.WebhookID, int(d.EventID)}] = true
+		}
+
+		now := time.Now()
+
+		for _, id := range slices.Sorted(maps.Keys(s.webhooks)) {
+			if !s.webhooks[id].Accepts(event.Type) || queued[pair{id, int(event.ID)}] {
+				continue
+			}
+
+			s.lastDeliveryID++
+			s.deliveries[s.lastDeliveryID] = &model.WebhookDelivery{
+				ID:            s.lastDeliveryID,
+				WebhookID:     id,
+				EventID:       event.ID,
+				EventType:     event.Type,
+				Status:        model.DeliveryPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("insert webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ClaimWebhookDeliveries(
+	ctx context.Context, db repository.DB, limit int, lease time.Duration,
+) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+
+	err := r.write(ctx, db, func(s *state) error {
+		now := time.Now()
+
+		for _, id := range slices.Sorted(maps.Keys(s.deliveries)) {
+			if len(deliveries) == limit {
+				break
+			}
+
+			d := s.deliveries[id]
+			if d.Status != model.DeliveryPending || d.NextAttemptAt.After(now) {
+				continue
+			}
+
+			d.NextAttemptAt = now.Add(lease)
+			deliveries = append(deliveries, *copyDelivery(d))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *Repository) FindWebhookDelivery(
+	_ context.Context, db repository.DB, id int64,
+) (*model.WebhookDelivery, error) {
+	var delivery *model.WebhookDelivery
+
+	err := r.read(db, func(s *state) error {
+		d, ok := s.deliveries[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		delivery = copyDelivery(d)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+func (r *Repository) ListWebhookDeliveries(
+	_ context.Context, db repository.DB, webhookID int, limit int,
+) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+
+	err := r.read(db, func(s *state) error {
+		ids := slices.Sorted(maps.Keys(s.deliveries))
+		slices.Reverse(ids)
+
+		for _, id := range ids {
+			if len(deliveries) == limit {
+				break
+			}
+
+			if d := s.deliveries[id]; d.WebhookID == webhookID {
+				deliveries = append(deliveries, *copyDelivery(d))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *Repository) UpdateWebhookDelivery(
+	ctx context.Context, db repository.DB, delivery *model.WebhookDelivery,
+) error {
+	err := r.write(ctx, db, func(s *state) error {
+		d, ok := s.deliveries[delivery.ID]
+		if !ok {
+			return nil
+		}
+
+		d.Status = delivery.Status
+		d.Attempts = delivery.Attempts
+		d.NextAttemptAt = delivery.NextAttemptAt
+		d.LastError = delivery.LastError
+		d.ResponseStatus = delivery.ResponseStatus
+		d.DeliveredAt = copyDelivery(delivery).DeliveredAt
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update webhook delivery: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+const notificationsChannel = "notifications"
+
+// AddNotifications stores the notifications and fills in their ID and
+// CreatedAt. Listeners are told about them once the transaction commits.
+func (r *repo) AddNotifications(ctx context.Context, tx DB, notifications ...*model.Notification) error {
+	db := r.getExecutor(tx)
+
+	for _, n := range notifications {
+		err := db.QueryRow(ctx, `
+			INSERT INTO notifications (user_id, type, payload)
+			VALUES ($1, $2, $3)
+			RETURNING id, created_at;
+		`, n.UserID, n.Type, n.Payload).Scan(
+			&n.ID,
+			&n.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("insert notification: %w", err)
+		}
+
+		_, err = db.Exec(ctx, `SELECT pg_notify($1, $2);`, notificationsChannel, strconv.Itoa(n.UserID))
+		if err != nil {
+			return fmt.Errorf("notify: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListNotifications returns up to limit notifications of the user that come
+// after afterID, oldest first.
+func (r *repo) ListNotifications(
+	ctx context.Context, tx DB, userID int, afterID int64, limit int,
+) ([]model.Notification, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT id, user_id, type, payload, created_at
+		FROM notifications
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3;
+	`, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []model.Notification
+
+	for rows.Next() {
+		var n model.Notification
+
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Payload, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan notification: %w", err)
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// LastNotificationID returns the ID of the latest notification of the user,
+// or zero if there are none.
+func (r *repo) LastNotificationID(ctx context.Context, tx DB, userID int) (int64, error) {
+	db := r.getExecutor(tx)
+
+	var id int64
+
+	err := db.QueryRow(ctx, `
+		SELECT coalesce(max(id), 0)
+		FROM notifications
+		WHERE user_id = $1;
+	`, userID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("select last notification: %w", err)
+	}
+
+	return id, nil
+}
+
+// ListenNotifications calls fn with the user of every committed notification,
+// from any replica, until ctx is done. It holds a connection of its own.
+func (r *repo) ListenNotifications(ctx context.Context, fn func(userID int)) error {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+
+	// The connection keeps listening, so it is closed instead of being
+	// returned to the pool.
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.WithoutCancel(ctx))
+
+	if _, err := pgConn.Exec(ctx, `LISTEN `+notificationsChannel+`;`); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	for {
+		n, err := pgConn.WaitForNotification(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("wait for notification: %w", err)
+		}
+
+		userID, err := strconv.Atoi(n.Payload)
+		if err != nil {
+			return fmt.Errorf("parse notification payload %q: %w", n.Payload, err)
+		}
+
+		fn(userID)
+	}
+}
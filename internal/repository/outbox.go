@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// AddEvent stores event in the outbox with the next sequence number of its
+// user, and of its receiver if it has one, and fills in its ID, Sequence,
+// ReceiverSequence and CreatedAt.
+func (r *repo) AddEvent(ctx context.Context, tx DB, event *model.Event) error {
+	db := r.getExecutor(tx)
+
+	var err error
+
+	if event.Sequence, err = nextSequence(ctx, db, event.UserID); err != nil {
+		return err
+	}
+
+	event.ReceiverSequence = 0
+	if event.ReceiverID != 0 {
+		if event.ReceiverSequence, err = nextSequence(ctx, db, event.ReceiverID); err != nil {
+			return err
+		}
+	}
+
+	err = db.QueryRow(ctx, `
+		INSERT INTO outbox (event_type, user_id, sequence, receiver_id, receiver_sequence, payload)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0), $6)
+		RETURNING id, created_at;
+	`, event.Type, event.UserID, event.Sequence, event.ReceiverID, event.ReceiverSequence, event.Payload).Scan(
+		&event.ID,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert event: %w", err)
+	}
+
+	return nil
+}
+
+// nextSequence takes the next event sequence number of the user.
+func nextSequence(ctx context.Context, db DB, userID int) (int64, error) {
+	var sequence int64
+
+	err := db.QueryRow(ctx, `
+		INSERT INTO outbox_sequences (user_id, last_sequence)
+		VALUES ($1, 1)
+		ON CONFLICT (user_id)
+		DO UPDATE SET last_sequence = outbox_sequences.last_sequence + 1
+		RETURNING last_sequence;
+	`, userID).Scan(&sequence)
+	if err != nil {
+		return 0, fmt.Errorf("next event sequence: %w", err)
+	}
+
+	return sequence, nil
+}
+
+// ListPendingEvents returns up to limit unpublished events in the order they
+// were added.
+func (r *repo) ListPendingEvents(ctx context.Context, tx DB, limit int) ([]model.Event, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+eventColumns+`
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1;
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select pending events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.Event
+
+	for rows.Next() {
+		var event model.Event
+
+		if err := scanEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate events: %w", err)
+	}
+
+	return events, nil
+}
+
+const eventColumns = `
+	id, event_type, user_id, sequence, COALESCE(receiver_id, 0), COALESCE(receiver_sequence, 0),
+	payload, created_at`
+
+func scanEvent(row pgx.Row, event *model.Event) error {
+	return row.Scan(
+		&event.ID,
+		&event.Type,
+		&event.UserID,
+		&event.Sequence,
+		&event.ReceiverID,
+		&event.ReceiverSequence,
+		&event.Payload,
+		&event.CreatedAt,
+	)
+}
+
+func (r *repo) MarkEventsPublished(ctx context.Context, tx DB, ids []int64) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `
+		UPDATE outbox
+		SET published_at = now()
+		WHERE id = ANY($1);
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("mark events published: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+const paymentRequestColumns = `
+	r.id, r.requester_id, r.payer_id, requester.username, payer.username, r.amount, COALESCE(r.memo, ''),
+	r.status, r.created_at, r.expires_at, r.resolved_at`
+
+const paymentRequestTables = `
+	payment_requests r
+	JOIN users requester ON requester.id = r.requester_id
+	JOIN users payer ON payer.id = r.payer_id`
+
+// CreatePaymentRequest stores a payment request, setting its ID, status and
+// creation time.
+func (r *repo) CreatePaymentRequest(ctx context.Context, tx DB, request *model.PaymentRequest) error {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		INSERT INTO payment_requests (requester_id, payer_id, amount, memo, expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		RETURNING id, status, created_at;
+	`, request.RequesterID, request.PayerID, request.Amount, request.Memo, request.ExpiresAt).
+		Scan(&request.ID, &request.Status, &request.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert payment request: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repo) FindPaymentRequestForUpdate(ctx context.Context, tx DB, id int64) (*model.PaymentRequest, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+paymentRequestColumns+`
+		FROM `+paymentRequestTables+`
+		WHERE r.id = $1
+		FOR UPDATE OF r;
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("select payment request: %w", err)
+	}
+
+	requests, err := scanPaymentRequests(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("select payment request: %w", sql.ErrNoRows)
+	}
+
+	return &requests[0], nil
+}
+
+// ListPaymentRequests returns the requests the user sent or has to pay that
+// are still open at the given time, oldest first.
+func (r *repo) ListPaymentRequests(
+	ctx context.Context, tx DB, userID int, at time.Time,
+) ([]model.PaymentRequest, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+paymentRequestColumns+`
+		FROM `+paymentRequestTables+`
+		WHERE r.status = 'pending' AND r.expires_at > $2 AND (r.requester_id = $1 OR r.payer_id = $1)
+		ORDER BY r.id;
+	`, userID, at)
+	if err != nil {
+		return nil, fmt.Errorf("select payment requests: %w", err)
+	}
+
+	return scanPaymentRequests(rows)
+}
+
+// ResolvePaymentRequest sets the final status of a payment request. It
+// returns sql.ErrNoRows if the request is no longer pending.
+func (r *repo) ResolvePaymentRequest(
+	ctx context.Context, tx DB, request *model.PaymentRequest, status model.PaymentRequestStatus,
+) error {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		UPDATE payment_requests
+		SET status = $2, resolved_at = now()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING resolved_at;
+	`, request.ID, status).Scan(&request.ResolvedAt)
+	if err != nil {
+		return fmt.Errorf("resolve payment request: %w", err)
+	}
+
+	request.Status = status
+
+	return nil
+}
+
+func scanPaymentRequests(rows pgx.Rows) ([]model.PaymentRequest, error) {
+	defer rows.Close()
+
+	var requests []model.PaymentRequest
+
+	for rows.Next() {
+		var p model.PaymentRequest
+
+		err := rows.Scan(
+			&p.ID,
+			&p.RequesterID,
+			&p.PayerID,
+			&p.Requester,
+			&p.Payer,
+			&p.Amount,
+			&p.Memo,
+			&p.Status,
+			&p.CreatedAt,
+			&p.ExpiresAt,
+			&p.ResolvedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan payment request: %w", err)
+		}
+
+		requests = append(requests, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate payment requests: %w", err)
+	}
+
+	return requests, nil
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+const pendingTransferColumns = `
+	p.id, p.sender_id, p.receiver_id, sender.username, receiver.username, p.amount, COALESCE(p.memo, ''),
+	p.status, p.created_at, p.expires_at, p.resolved_at`
+
+const pendingTransferTables = `
+	pending_transfers p
+	JOIN users sender ON sender.id = p.sender_id
+	JOIN users receiver ON receiver.id = p.receiver_id`
+
+// CreatePendingTransfer stores a pending transfer and takes the coins from the
+// sender, setting the ID, status and creation time of the transfer.
+func (r *repo) CreatePendingTransfer(ctx context.Context, tx DB, transfer *model.PendingTransfer) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `UPDATE users SET balance = balance - $2 WHERE id = $1;`, transfer.SenderID, transfer.Amount)
+	if err != nil {
+		return fmt.Errorf("hold coins: %w", translateError(err))
+	}
+
+	err = db.QueryRow(ctx, `
+		INSERT INTO pending_transfers (sender_id, receiver_id, amount, memo, expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		RETURNING id, status, created_at;
+	`, transfer.SenderID, transfer.ReceiverID, transfer.Amount, transfer.Memo, transfer.ExpiresAt).
+		Scan(&transfer.ID, &transfer.Status, &transfer.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert pending transfer: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repo) FindPendingTransferForUpdate(ctx context.Context, tx DB, id int64) (*model.PendingTransfer, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+pendingTransferColumns+`
+		FROM `+pendingTransferTables+`
+		WHERE p.id = $1
+		FOR UPDATE OF p;
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("select pending transfer: %w", err)
+	}
+
+	transfers, err := scanPendingTransfers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transfers) == 0 {
+		return nil, fmt.Errorf("select pending transfer: %w", sql.ErrNoRows)
+	}
+
+	return &transfers[0], nil
+}
+
+// ListPendingTransfers returns the transfers the user sent or received that
+// are still pending, oldest first.
+func (r *repo) ListPendingTransfers(ctx context.Context, tx DB, userID int) ([]model.PendingTransfer, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+pendingTransferColumns+`
+		FROM `+pendingTransferTables+`
+		WHERE p.status = 'pending' AND (p.sender_id = $1 OR p.receiver_id = $1)
+		ORDER BY p.id;
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("select pending transfers: %w", err)
+	}
+
+	return scanPendingTransfers(rows)
+}
+
+// ListExpiredPendingTransfers locks up to limit pending transfers that expired
+// by at, skipping those locked by others.
+func (r *repo) ListExpiredPendingTransfers(
+	ctx context.Context, tx DB, at time.Time, limit int,
+) ([]model.PendingTransfer, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+pendingTransferColumns+`
+		FROM `+pendingTransferTables+`
+		WHERE p.status = 'pending' AND p.expires_at <= $1
+		ORDER BY p.id
+		LIMIT $2
+		FOR UPDATE OF p SKIP LOCKED;
+	`, at, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select expired pending transfers: %w", err)
+	}
+
+	return scanPendingTransfers(rows)
+}
+
+// ResolvePendingTransfer sets the final status of a pending transfer and
+// returns the held coins to the sender, an accepted transfer is then made as
+// usual. It returns sql.ErrNoRows if the transfer is no longer pending.
+func (r *repo) ResolvePendingTransfer(
+	ctx context.Context, tx DB, transfer *model.PendingTransfer, status model.PendingTransferStatus,
+) error {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		UPDATE pending_transfers
+		SET status = $2, resolved_at = now()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING resolved_at;
+	`, transfer.ID, status).Scan(&transfer.ResolvedAt)
+	if err != nil {
+		return fmt.Errorf("resolve pending transfer: %w", err)
+	}
+
+	transfer.Status = status
+
+	_, err = db.Exec(ctx, `UPDATE users SET balance = balance + $2 WHERE id = $1;`, transfer.SenderID, transfer.Amount)
+	if err != nil {
+		return fmt.Errorf("release coins: %w", err)
+	}
+
+	return nil
+}
+
+func scanPendingTransfers(rows pgx.Rows) ([]model.PendingTransfer, error) {
+	defer rows.Close()
+
+	var transfers []model.PendingTransfer
+
+	for rows.Next() {
+		var t model.PendingTransfer
+
+		err := rows.Scan(
+			&t.ID,
+			&t.SenderID,
+			&t.ReceiverID,
+			&t.FromUser,
+			&t.ToUser,
+			&t.Amount,
+			&t.Memo,
+			&t.Status,
+			&t.CreatedAt,
+			&t.ExpiresAt,
+			&t.ResolvedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan pending transfer: %w", err)
+		}
+
+		transfers = append(transfers, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pending transfers: %w", err)
+	}
+
+	return transfers, nil
+}
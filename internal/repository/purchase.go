@@ -7,15 +7,39 @@ import (
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 )
 
-func (r *repo) MakePurchase(ctx context.Context, tx DB, userID, itemID, price int) error {
+// MakePurchase adds the items to the inventory of the user, takes them from
+// the stock of the item, or of its variant if one is bought, and pays their
+// price to the system account. It returns model.ErrOutOfStock if fewer items
+// are left.
+func (r *repo) MakePurchase(ctx context.Context, tx DB, purchase *model.Purchase) error {
 	db := r.getExecutor(tx)
 
+	query := `
+		UPDATE items
+		SET stock = stock - $2
+		WHERE id = $1 AND stock IS NOT NULL;
+	`
+	stockID := purchase.ItemID
+
+	if purchase.VariantID != 0 {
+		query = `
+			UPDATE item_variants
+			SET stock = stock - $2
+			WHERE id = $1 AND stock IS NOT NULL;
+		`
+		stockID = purchase.VariantID
+	}
+
+	if _, err := db.Exec(ctx, query, stockID, purchase.Quantity); err != nil {
+		return fmt.Errorf("update stock: %w", translateError(err))
+	}
+
 	_, err := db.Exec(ctx, `
-		INSERT INTO purchases (user_id, item_id, quantity)
-		VALUES ($1, $2, 1)
-		ON CONFLICT (user_id, item_id)
-		DO UPDATE SET quantity = purchases.quantity + 1;
-	`, userID, itemID)
+		INSERT INTO purchases (user_id, item_id, variant_id, quantity)
+		VALUES ($1, $2, NULLIF($3, 0), $4)
+		ON CONFLICT (user_id, item_id, (coalesce(variant_id, 0)))
+		DO UPDATE SET quantity = purchases.quantity + $4;
+	`, purchase.UserID, purchase.ItemID, purchase.VariantID, purchase.Quantity)
 	if err != nil {
 		return fmt.Errorf("insert purchase: %w", err)
 	}
@@ -24,11 +48,19 @@ func (r *repo) MakePurchase(ctx context.Context, tx DB, userID, itemID, price in
 		UPDATE users 
 		SET balance = balance - $2
 		WHERE id = $1;
-	`, userID, price)
+	`, purchase.UserID, purchase.Price)
 	if err != nil {
 		return fmt.Errorf("update balance: %w", err)
 	}
 
+	_, err = db.Exec(ctx, `
+		INSERT INTO ledger_entries (user_id, kind, amount)
+		VALUES ($1, $2, $3);
+	`, purchase.UserID, model.LedgerEntryPurchase, -purchase.Price)
+	if err != nil {
+		return fmt.Errorf("insert ledger entry: %w", err)
+	}
+
 	return nil
 }
 
@@ -36,10 +68,12 @@ func (r *repo) ListInventory(ctx context.Context, tx DB, userID int) (inventory
 	db := r.getExecutor(tx)
 
 	rows, err := db.Query(ctx, `
-		SELECT items.name, purchases.quantity
+		SELECT items.name, coalesce(item_variants.size, ''), coalesce(item_variants.color, ''), purchases.quantity
 		FROM purchases
 		JOIN items ON items.id = purchases.item_id
-		WHERE purchases.user_id = $1;
+		LEFT JOIN item_variants ON item_variants.id = purchases.variant_id
+		WHERE purchases.user_id = $1
+		ORDER BY purchases.item_id, purchases.variant_id NULLS FIRST;
 	`, userID)
 	if err != nil {
 		return nil, fmt.Errorf("select inventory: %w", err)
@@ -51,6 +85,8 @@ func (r *repo) ListInventory(ctx context.Context, tx DB, userID int) (inventory
 
 		err := rows.Scan(
 			&item.Type,
+			&item.Size,
+			&item.Color,
 			&item.Quantity,
 		)
 		if err != nil {
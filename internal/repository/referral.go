@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+// AddReferral stores referral, setting its creation time. A user is referred
+// at most once.
+func (r *repo) AddReferral(ctx context.Context, tx DB, referral *model.Referral) error {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		INSERT INTO referrals (referee_id, referrer_id, rewarded)
+		VALUES ($1, $2, $3)
+		RETURNING created_at;
+	`, referral.RefereeID, referral.ReferrerID, referral.Rewarded).Scan(&referral.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert referral: %w", err)
+	}
+
+	return nil
+}
+
+// CountRewardedReferrals returns the number of rewarded referrals of the
+// referrer created after since.
+func (r *repo) CountRewardedReferrals(ctx context.Context, tx DB, referrerID int, since time.Time) (int, error) {
+	db := r.getExecutor(tx)
+
+	var count int
+
+	err := db.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM referrals
+		WHERE referrer_id = $1 AND rewarded AND created_at > $2;
+	`, referrerID, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count referrals: %w", err)
+	}
+
+	return count, nil
+}
@@ -2,7 +2,7 @@ package repository
 
 import (
 	"context"
-	"fmt"
+	"time"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 	"github.com/jackc/pgx/v5"
@@ -20,16 +20,103 @@ type DB interface {
 //go:generate mockgen -destination=../../mocks/mock_repository.go -package=mocks github.com/esklo/avito-backend-winter-2025/internal/repository Repository
 type Repository interface {
 	FindUser(ctx context.Context, tx DB, username string) (*model.User, error)
+	FindUserForUpdate(ctx context.Context, tx DB, username string) (*model.User, error)
+	FindUsersForUpdate(ctx context.Context, tx DB, usernames ...string) (map[string]*model.User, error)
+	FindUserByReferralCode(ctx context.Context, tx DB, code string) (*model.User, error)
 	CreateUser(ctx context.Context, tx DB, user *model.User) error
-	MakeTransfer(ctx context.Context, tx DB, senderID, receiverID int, amount int) error
-	MakePurchase(ctx context.Context, tx DB, userID, itemID, price int) error
+	ListUsers(ctx context.Context, tx DB, after string, limit int) ([]model.User, error)
+	AddBalance(ctx context.Context, tx DB, userID, amount int) error
+	SetUserDeactivatedAt(ctx context.Context, tx DB, userID int, at *time.Time) error
+	MakeTransfer(ctx context.Context, tx DB, transfer *model.Transfer) error
+	FindTransferForUpdate(ctx context.Context, tx DB, id int64) (*model.Transfer, error)
+	ListUserTransfers(ctx context.Context, tx DB, userID, limit int) ([]model.Transfer, error)
+	HasTransferred(ctx context.Context, tx DB, senderID, receiverID int) (bool, error)
+	MakePurchase(ctx context.Context, tx DB, purchase *model.Purchase) error
 
 	FindItem(ctx context.Context, tx DB, name string) (*model.Item, error)
+	CreateItem(ctx context.Context, tx DB, item *model.Item) error
+	SetItemStock(ctx context.Context, tx DB, itemID int, stock *int) error
+	ListItems(ctx context.Context, tx DB) ([]model.Item, error)
+	CreateVariant(ctx context.Context, tx DB, variant *model.Variant) error
+	FindVariant(ctx context.Context, tx DB, itemID int, attrs model.VariantAttributes) (*model.Variant, error)
+	ListVariants(ctx context.Context, tx DB, itemID int) ([]model.Variant, error)
+	SetVariantStock(ctx context.Context, tx DB, variantID int, stock *int) error
+
+	AddCartItem(ctx context.Context, tx DB, userID, itemID, variantID, quantity int) error
+	RemoveCartItem(ctx context.Context, tx DB, userID, itemID, variantID int) error
+	ListCartItems(ctx context.Context, tx DB, userID int) ([]model.CartItem, error)
+	ClearCart(ctx context.Context, tx DB, userID int) error
 
 	ListInventory(ctx context.Context, tx DB, userID int) ([]model.Inventory, error)
 	ListTransactions(ctx context.Context, tx DB, userID int) (*model.CoinHistory, error)
 
-	WithTx(ctx context.Context, fn func(DB) error) error
+	AddEvent(ctx context.Context, tx DB, event *model.Event) error
+	ListPendingEvents(ctx context.Context, tx DB, limit int) ([]model.Event, error)
+	MarkEventsPublished(ctx context.Context, tx DB, ids []int64) error
+	FindEvent(ctx context.Context, tx DB, id int64) (*model.Event, error)
+
+	CreateWebhook(ctx context.Context, tx DB, webhook *model.Webhook) error
+	FindWebhook(ctx context.Context, tx DB, id int) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context, tx DB) ([]model.Webhook, error)
+	DeleteWebhook(ctx context.Context, tx DB, id int) error
+	CreateWebhookDeliveries(ctx context.Context, tx DB, event model.Event) error
+	ClaimWebhookDeliveries(ctx context.Context, tx DB, limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	FindWebhookDelivery(ctx context.Context, tx DB, id int64) (*model.WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, tx DB, webhookID int, limit int) ([]model.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, tx DB, delivery *model.WebhookDelivery) error
+
+	AddNotifications(ctx context.Context, tx DB, notifications ...*model.Notification) error
+	ListNotifications(ctx context.Context, tx DB, userID int, afterID int64, limit int) ([]model.Notification, error)
+	LastNotificationID(ctx context.Context, tx DB, userID int) (int64, error)
+	ListenNotifications(ctx context.Context, fn func(userID int)) error
+
+	ReserveIdempotencyKey(ctx context.Context, tx DB, key *model.IdempotencyKey, notBefore time.Time) (bool, error)
+	CompleteIdempotencyKey(ctx context.Context, tx DB, key *model.IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, tx DB, username, key string) error
+
+	AddLedgerEntry(ctx context.Context, tx DB, entry *model.LedgerEntry) (bool, error)
+	ListLedgerEntries(ctx context.Context, tx DB, userID int) ([]model.LedgerEntry, error)
+	Reconcile(ctx context.Context, tx DB, limit int) (*model.Reconciliation, error)
+
+	AddReferral(ctx context.Context, tx DB, referral *model.Referral) error
+	CountRewardedReferrals(ctx context.Context, tx DB, referrerID int, since time.Time) (int, error)
+
+	FindTransferLimits(ctx context.Context, tx DB, userID int) (*model.TransferLimitsOverride, error)
+	SetTransferLimits(ctx context.Context, tx DB, userID int, override *model.TransferLimitsOverride) error
+	FindTransferUsage(ctx context.Context, tx DB, userID int, day time.Time) (model.TransferUsage, error)
+	AddTransferUsage(ctx context.Context, tx DB, userID int, day time.Time, amount int) error
+
+	CreatePendingTransfer(ctx context.Context, tx DB, transfer *model.PendingTransfer) error
+	FindPendingTransferForUpdate(ctx context.Context, tx DB, id int64) (*model.PendingTransfer, error)
+	ListPendingTransfers(ctx context.Context, tx DB, userID int) ([]model.PendingTransfer, error)
+	ListExpiredPendingTransfers(ctx context.Context, tx DB, at time.Time, limit int) ([]model.PendingTransfer, error)
+	ResolvePendingTransfer(
+		ctx context.Context, tx DB, transfer *model.PendingTransfer, status model.PendingTransferStatus,
+	) error
+
+	CreatePaymentRequest(ctx context.Context, tx DB, request *model.PaymentRequest) error
+	FindPaymentRequestForUpdate(ctx context.Context, tx DB, id int64) (*model.PaymentRequest, error)
+	ListPaymentRequests(ctx context.Context, tx DB, userID int, at time.Time) ([]model.PaymentRequest, error)
+	ResolvePaymentRequest(
+		ctx context.Context, tx DB, request *model.PaymentRequest, status model.PaymentRequestStatus,
+	) error
+
+	CreateScheduledTransfer(ctx context.Context, tx DB, transfer *model.ScheduledTransfer) error
+	FindScheduledTransferForUpdate(ctx context.Context, tx DB, id int64) (*model.ScheduledTransfer, error)
+	ListScheduledTransfers(ctx context.Context, tx DB, senderID int) ([]model.ScheduledTransfer, error)
+	ListDueScheduledTransfers(ctx context.Context, tx DB, at time.Time, limit int) ([]model.ScheduledTransfer, error)
+	UpdateScheduledTransfer(ctx context.Context, tx DB, transfer *model.ScheduledTransfer) error
+	AddScheduledTransferRun(ctx context.Context, tx DB, run *model.ScheduledTransferRun) error
+	ListScheduledTransferRuns(
+		ctx context.Context, tx DB, transferID int64, limit int,
+	) ([]model.ScheduledTransferRun, error)
+
+	FindJobRun(ctx context.Context, tx DB, name string) (time.Time, error)
+	SaveJobRun(ctx context.Context, tx DB, name string, at time.Time) error
+
+	TryLock(ctx context.Context, key int64) (release func(), locked bool, err error)
+
+	WithTx(ctx context.Context, fn func(DB) error, opts ...TxOption) error
 }
 
 type repo struct {
@@ -40,27 +127,6 @@ func New(db *pgxpool.Pool) Repository {
 	return &repo{db: db}
 }
 
-func (r *repo) WithTx(ctx context.Context, fn func(DB) error) error {
-	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{
-		IsoLevel: pgx.ReadCommitted,
-	})
-	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
-	}
-
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback(ctx)
-
-			return
-		}
-
-		err = tx.Commit(ctx)
-	}()
-
-	return fn(tx)
-}
-
 func (r *repo) getExecutor(tx DB) DB {
 	if tx != nil {
 		return tx
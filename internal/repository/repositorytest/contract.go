@@ -0,0 +1,1671 @@
+// Package repositorytest holds the behavior every repository.Repository
+// implementation must have, shared by the Postgres and in-memory test suites.
+package repositorytest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const startBalance = 1000
+
+var errTest = errors.New("test error")
+
+// Run checks repo against the contract. The repository must contain the
+// default item catalog and may be shared with other tests, every case uses
+// its own users.
+func Run(t *testing.T, repo repository.Repository) {
+	t.Helper()
+
+	c := &contract{repo: repo}
+
+	t.Run("users", c.testUsers)
+	t.Run("lock users", c.testLockUsers)
+	t.Run("list users", c.testListUsers)
+	t.Run("add balance", c.testAddBalance)
+	t.Run("deactivate users", c.testDeactivateUsers)
+	t.Run("items", c.testItems)
+	t.Run("create items", c.testCreateItems)
+	t.Run("transfer", c.testTransfer)
+	t.Run("transfer memos", c.testTransferMemos)
+	t.Run("transfer reversals", c.testTransferReversals)
+	t.Run("purchase", c.testPurchase)
+	t.Run("item stock", c.testItemStock)
+	t.Run("carts", c.testCarts)
+	t.Run("variants", c.testVariants)
+	t.Run("transaction rollback", c.testRollback)
+	t.Run("read outside of transaction", c.testReadOutsideTx)
+	t.Run("concurrent transfers", c.testConcurrentTransfers)
+	t.Run("outbox", c.testOutbox)
+	t.Run("outbox receivers", c.testOutboxReceivers)
+	t.Run("try lock", c.testTryLock)
+	t.Run("webhooks", c.testWebhooks)
+	t.Run("notifications", c.testNotifications)
+	t.Run("idempotency keys", c.testIdempotencyKeys)
+	t.Run("ledger", c.testLedger)
+	t.Run("ledger reasons", c.testLedgerReasons)
+	t.Run("reconcile", c.testReconcile)
+	t.Run("referrals", c.testReferrals)
+	t.Run("transfer limits", c.testTransferLimits)
+	t.Run("pending transfers", c.testPendingTransfers)
+	t.Run("payment requests", c.testPaymentRequests)
+	t.Run("scheduled transfers", c.testScheduledTransfers)
+	t.Run("job runs", c.testJobRuns)
+}
+
+type contract struct {
+	repo repository.Repository
+	seq  atomic.Int64
+}
+
+func (c *contract) createUser(t *testing.T) *model.User {
+	t.Helper()
+
+	return NewUser(t, c.repo, fmt.Sprintf("contract-%s-%d", t.Name(), c.seq.Add(1)))
+}
+
+func newPurchase(user *model.User, item *model.Item) *model.Purchase {
+	return &model.Purchase{UserID: user.ID, ItemID: item.ID, Quantity: 1, Price: item.Price}
+}
+
+func newTransfer(sender, receiver *model.User, amount int) *model.Transfer {
+	return &model.Transfer{SenderID: sender.ID, ReceiverID: receiver.ID, Amount: amount}
+}
+
+func (c *contract) balance(t *testing.T, username string) int {
+	t.Helper()
+
+	user, err := c.repo.FindUser(context.Background(), nil, username)
+	require.NoError(t, err)
+
+	return user.Balance
+}
+
+func (c *contract) testUsers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+	assert.NotZero(t, user.ID)
+	assert.Equal(t, []byte("hash"), user.Password)
+	assert.Equal(t, []byte("salt"), user.Salt)
+	assert.Equal(t, startBalance, user.Balance)
+
+	err := c.repo.CreateUser(ctx, nil, &model.User{Username: user.Username, Password: []byte("x"), Salt: []byte("y")})
+	assert.Error(t, err, "usernames are unique")
+
+	_, err = c.repo.FindUser(ctx, nil, "contract-missing-user")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func (c *contract) testLockUsers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	first, second := c.createUser(t), c.createUser(t)
+
+	err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+		user, err := c.repo.FindUserForUpdate(ctx, tx, first.Username)
+		require.NoError(t, err)
+		assert.Equal(t, first, user)
+
+		users, err := c.repo.FindUsersForUpdate(ctx, tx, second.Username, "contract-missing-user", first.Username)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]*model.User{
+			first.Username:  first,
+			second.Username: second,
+		}, users)
+
+		_, err = c.repo.FindUserForUpdate(ctx, tx, "contract-missing-user")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func (c *contract) testItems(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	item, err := c.repo.FindItem(ctx, nil, "pink-hoody")
+	require.NoError(t, err)
+	assert.NotZero(t, item.ID)
+	assert.Equal(t, "pink-hoody", item.Name)
+	assert.Equal(t, 500, item.Price)
+
+	_, err = c.repo.FindItem(ctx, nil, "contract-missing-item")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func (c *contract) testListUsers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	first, second := c.createUser(t), c.createUser(t)
+
+	users, err := c.repo.ListUsers(ctx, nil, "", math.MaxInt32)
+	require.NoError(t, err)
+	assert.True(t, slices.IsSortedFunc(users, func(a, b model.User) int {
+		return strings.Compare(a.Username, b.Username)
+	}))
+	assert.Contains(t, users, *first)
+	assert.Contains(t, users, *second)
+
+	users, err = c.repo.ListUsers(ctx, nil, first.Username, 1)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Greater(t, users[0].Username, first.Username, "page starts after the given user")
+}
+
+func (c *contract) testAddBalance(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+
+	require.NoError(t, c.repo.AddBalance(ctx, nil, user.ID, 250))
+	assert.Equal(t, startBalance+250, c.balance(t, user.Username))
+
+	require.NoError(t, c.repo.AddBalance(ctx, nil, user.ID, -50))
+	assert.Equal(t, startBalance+200, c.balance(t, user.Username))
+
+	err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+		return c.repo.AddBalance(ctx, tx, user.ID, -startBalance-201)
+	})
+	require.ErrorIs(t, err, model.ErrInsufficientFunds)
+	assert.Equal(t, startBalance+200, c.balance(t, user.Username))
+
+	err = c.repo.AddBalance(ctx, nil, math.MaxInt32, 1)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func (c *contract) testDeactivateUsers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+	assert.True(t, user.Active())
+
+	at := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, c.repo.SetUserDeactivatedAt(ctx, nil, user.ID, &at))
+
+	found, err := c.repo.FindUser(ctx, nil, user.Username)
+	require.NoError(t, err)
+	require.False(t, found.Active())
+	assert.WithinDuration(t, at, *found.DeactivatedAt, time.Millisecond)
+
+	require.NoError(t, c.repo.SetUserDeactivatedAt(ctx, nil, user.ID, nil))
+
+	found, err = c.repo.FindUser(ctx, nil, user.Username)
+	require.NoError(t, err)
+	assert.True(t, found.Active())
+
+	err = c.repo.SetUserDeactivatedAt(ctx, nil, math.MaxInt32, nil)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func (c *contract) testCreateItems(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	item := &model.Item{Name: fmt.Sprintf("contract-item-%d", c.seq.Add(1)), Price: 42}
+	require.NoError(t, c.repo.CreateItem(ctx, nil, item))
+	assert.NotZero(t, item.ID)
+
+	found, err := c.repo.FindItem(ctx, nil, item.Name)
+	require.NoError(t, err)
+	assert.Equal(t, item, found)
+
+	err = c.repo.CreateItem(ctx, nil, &model.Item{Name: item.Name, Price: 1})
+	assert.Error(t, err, "item names are unique")
+
+	items, err := c.repo.ListItems(ctx, nil)
+	require.NoError(t, err)
+	assert.Contains(t, items, *item)
+	assert.True(t, slices.IsSortedFunc(items, func(a, b model.Item) int {
+		return strings.Compare(a.Name, b.Name)
+	}))
+}
+
+func (c *contract) testTransfer(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	sender, receiver := c.createUser(t), c.createUser(t)
+
+	transferred, err := c.repo.HasTransferred(ctx, nil, sender.ID, receiver.ID)
+	require.NoError(t, err)
+	assert.False(t, transferred)
+
+	for _, amount := range []int{100, 50} {
+		err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+			return c.repo.MakeTransfer(ctx, tx, newTransfer(sender, receiver, amount))
+		})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, startBalance-150, c.balance(t, sender.Username))
+	assert.Equal(t, startBalance+150, c.balance(t, receiver.Username))
+
+	transferred, err = c.repo.HasTransferred(ctx, nil, sender.ID, receiver.ID)
+	require.NoError(t, err)
+	assert.True(t, transferred)
+
+	transferred, err = c.repo.HasTransferred(ctx, nil, receiver.ID, sender.ID)
+	require.NoError(t, err)
+	assert.False(t, transferred, "transfers count one way")
+
+	sent, err := c.repo.ListTransactions(ctx, nil, sender.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CoinsSent{{ToUser: receiver.Username, Amount: 150}}, sent.Sent)
+	assert.Empty(t, sent.Received)
+
+	received, err := c.repo.ListTransactions(ctx, nil, receiver.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CoinsReceived{{FromUser: sender.Username, Amount: 150}}, received.Received)
+	assert.Empty(t, received.Sent)
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		return c.repo.MakeTransfer(ctx, tx, newTransfer(sender, receiver, startBalance))
+	})
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+	assert.Equal(t, startBalance-150, c.balance(t, sender.Username))
+}
+
+func (c *contract) testTransferMemos(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	sender, receiver, other := c.createUser(t), c.createUser(t), c.createUser(t)
+
+	transfers := []*model.Transfer{
+		newTransfer(sender, receiver, 100),
+		{SenderID: sender.ID, ReceiverID: receiver.ID, Amount: 30, Memo: "thanks for the review"},
+		newTransfer(sender, receiver, 50),
+		{SenderID: sender.ID, ReceiverID: other.ID, Amount: 20, Memo: "lunch"},
+	}
+
+	var lastID int64
+
+	for _, transfer := range transfers {
+		err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+			return c.repo.MakeTransfer(ctx, tx, transfer)
+		})
+		require.NoError(t, err)
+		assert.Greater(t, transfer.ID, lastID)
+		assert.False(t, transfer.CreatedAt.IsZero())
+
+		lastID = transfer.ID
+	}
+
+	history, err := c.repo.ListTransactions(ctx, nil, sender.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CoinsSent{
+		{ToUser: receiver.Username, Amount: 150},
+		{ToUser: receiver.Username, Amount: 30, Memo: "thanks for the review"},
+		{ToUser: other.Username, Amount: 20, Memo: "lunch"},
+	}, history.Sent, "transfers with a memo are listed on their own")
+
+	history, err = c.repo.ListTransactions(ctx, nil, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CoinsReceived{{FromUser: sender.Username, Amount: 20, Memo: "lunch"}}, history.Received)
+	assert.Equal(t, startBalance+20, c.balance(t, other.Username))
+}
+
+func (c *contract) testTransferReversals(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	sender, receiver := c.createUser(t), c.createUser(t)
+
+	first, second := newTransfer(sender, receiver, 100), newTransfer(sender, receiver, 50)
+
+	for _, transfer := range []*model.Transfer{first, second} {
+		err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+			return c.repo.MakeTransfer(ctx, tx, transfer)
+		})
+		require.NoError(t, err)
+	}
+
+	err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+		transfer, err := c.repo.FindTransferForUpdate(ctx, tx, first.ID)
+		if err != nil {
+			return err
+		}
+
+		assert.Equal(t, sender.Username, transfer.FromUser)
+		assert.Equal(t, receiver.Username, transfer.ToUser)
+		assert.Zero(t, transfer.Reversed)
+
+		for _, change := range []struct{ userID, amount int }{{receiver.ID, -40}, {sender.ID, 40}} {
+			entry := &model.LedgerEntry{
+				UserID:     change.userID,
+				Kind:       model.LedgerEntryReversal,
+				Amount:     change.amount,
+				Reason:     "sent by mistake",
+				TransferID: first.ID,
+			}
+			if _, err := c.repo.AddLedgerEntry(ctx, tx, entry); err != nil {
+				return err
+			}
+
+			if err := c.repo.AddBalance(ctx, tx, change.userID, change.amount); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	transfer, err := c.repo.FindTransferForUpdate(ctx, nil, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 40, transfer.Reversed)
+
+	_, err = c.repo.FindTransferForUpdate(ctx, nil, second.ID+1000000)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	transfers, err := c.repo.ListUserTransfers(ctx, nil, receiver.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+	assert.Equal(t, second.ID, transfers[0].ID, "newest first")
+	assert.Equal(t, []int{0, 40}, []int{transfers[0].Reversed, transfers[1].Reversed})
+
+	transfers, err = c.repo.ListUserTransfers(ctx, nil, sender.ID, 1)
+	require.NoError(t, err)
+	assert.Len(t, transfers, 1)
+
+	history, err := c.repo.ListTransactions(ctx, nil, sender.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CoinsSent{{ToUser: receiver.Username, Amount: 150}}, history.Sent)
+	assert.Equal(t, []model.CoinsReceived{{FromUser: receiver.Username, Amount: 40, Reversal: true}}, history.Received)
+
+	history, err = c.repo.ListTransactions(ctx, nil, receiver.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CoinsSent{{ToUser: sender.Username, Amount: 40, Reversal: true}}, history.Sent)
+	assert.Equal(t, []model.CoinsReceived{{FromUser: sender.Username, Amount: 150}}, history.Received)
+
+	result, err := c.repo.Reconcile(ctx, nil, 10000)
+	require.NoError(t, err)
+
+	for _, d := range result.Discrepancies {
+		assert.NotEqual(t, sender.Username, d.Username)
+		assert.NotEqual(t, receiver.Username, d.Username)
+	}
+}
+
+func (c *contract) testPurchase(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+
+	item, err := c.repo.FindItem(ctx, nil, "pink-hoody")
+	require.NoError(t, err)
+
+	for range 2 {
+		err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+			return c.repo.MakePurchase(ctx, tx, newPurchase(user, item))
+		})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, startBalance-2*item.Price, c.balance(t, user.Username))
+
+	inventory, err := c.repo.ListInventory(ctx, nil, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Inventory{{Type: item.Name, Quantity: 2}}, inventory)
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		return c.repo.MakePurchase(ctx, tx, newPurchase(user, item))
+	})
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+}
+
+func (c *contract) testItemStock(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+
+	stock := 3
+	item := &model.Item{Name: fmt.Sprintf("contract-stock-%d", c.seq.Add(1)), Price: 10, Stock: &stock}
+	require.NoError(t, c.repo.CreateItem(ctx, nil, item))
+
+	err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+		return c.repo.MakePurchase(ctx, tx, &model.Purchase{UserID: user.ID, ItemID: item.ID, Quantity: 2, Price: 20})
+	})
+	require.NoError(t, err)
+
+	found, err := c.repo.FindItem(ctx, nil, item.Name)
+	require.NoError(t, err)
+	require.NotNil(t, found.Stock)
+	assert.Equal(t, 1, *found.Stock)
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		return c.repo.MakePurchase(ctx, tx, &model.Purchase{UserID: user.ID, ItemID: item.ID, Quantity: 2, Price: 20})
+	})
+	require.ErrorIs(t, err, model.ErrOutOfStock)
+	assert.Equal(t, startBalance-20, c.balance(t, user.Username), "a failed purchase changes nothing")
+
+	require.NoError(t, c.repo.SetItemStock(ctx, nil, item.ID, nil))
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		return c.repo.MakePurchase(ctx, tx, &model.Purchase{UserID: user.ID, ItemID: item.ID, Quantity: 5, Price: 50})
+	})
+	require.NoError(t, err)
+
+	found, err = c.repo.FindItem(ctx, nil, item.Name)
+	require.NoError(t, err)
+	assert.Nil(t, found.Stock, "items without stock never run out")
+
+	inventory, err := c.repo.ListInventory(ctx, nil, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Inventory{{Type: item.Name, Quantity: 7}}, inventory)
+}
+
+func (c *contract) testCarts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user, other := c.createUser(t), c.createUser(t)
+
+	hoody, err := c.repo.FindItem(ctx, nil, "pink-hoody")
+	require.NoError(t, err)
+
+	cup, err := c.repo.FindItem(ctx, nil, "cup")
+	require.NoError(t, err)
+
+	require.NoError(t, c.repo.AddCartItem(ctx, nil, user.ID, hoody.ID, 0, 1))
+	require.NoError(t, c.repo.AddCartItem(ctx, nil, user.ID, cup.ID, 0, 2))
+	require.NoError(t, c.repo.AddCartItem(ctx, nil, user.ID, cup.ID, 0, 1))
+	require.NoError(t, c.repo.AddCartItem(ctx, nil, other.ID, cup.ID, 0, 1))
+
+	items, err := c.repo.ListCartItems(ctx, nil, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CartItem{
+		{ItemID: cup.ID, Item: cup.Name, Quantity: 3, Price: cup.Price},
+		{ItemID: hoody.ID, Item: hoody.Name, Quantity: 1, Price: hoody.Price},
+	}, items, "ordered by name")
+
+	require.NoError(t, c.repo.RemoveCartItem(ctx, nil, user.ID, hoody.ID, 0))
+	require.ErrorIs(t, c.repo.RemoveCartItem(ctx, nil, user.ID, hoody.ID, 0), sql.ErrNoRows)
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		return c.repo.ClearCart(ctx, tx, user.ID)
+	})
+	require.NoError(t, err)
+
+	items, err = c.repo.ListCartItems(ctx, nil, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, items)
+
+	items, err = c.repo.ListCartItems(ctx, nil, other.ID)
+	require.NoError(t, err)
+	assert.Len(t, items, 1, "carts are per user")
+}
+
+func (c *contract) testVariants(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+
+	itemStock, stock := 10, 1
+	item := &model.Item{Name: fmt.Sprintf("contract-variants-%d", c.seq.Add(1)), Price: 50, Stock: &itemStock}
+	require.NoError(t, c.repo.CreateItem(ctx, nil, item))
+
+	large := &model.Variant{
+		ItemID:            item.ID,
+		VariantAttributes: model.VariantAttributes{Size: "L", Color: "black"},
+		PriceDelta:        5,
+		Stock:             &stock,
+	}
+	medium := &model.Variant{ItemID: item.ID, VariantAttributes: model.VariantAttributes{Size: "M"}}
+	require.NoError(t, c.repo.CreateVariant(ctx, nil, large))
+	require.NoError(t, c.repo.CreateVariant(ctx, nil, medium))
+	duplicate := &model.Variant{ItemID: item.ID, VariantAttributes: medium.VariantAttributes}
+	assert.Error(t, c.repo.CreateVariant(ctx, nil, duplicate), "attributes are unique per item")
+
+	variants, err := c.repo.ListVariants(ctx, nil, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Variant{*large, *medium}, variants, "in the order they were added")
+
+	found, err := c.repo.FindVariant(ctx, nil, item.ID, model.VariantAttributes{Size: "L", Color: "black"})
+	require.NoError(t, err)
+	assert.Equal(t, large, found)
+
+	_, err = c.repo.FindVariant(ctx, nil, item.ID, model.VariantAttributes{Size: "L"})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, c.repo.AddCartItem(ctx, nil, user.ID, item.ID, medium.ID, 2))
+	require.NoError(t, c.repo.AddCartItem(ctx, nil, user.ID, item.ID, large.ID, 1))
+
+	items, err := c.repo.ListCartItems(ctx, nil, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CartItem{
+		{
+			ItemID:            item.ID,
+			VariantID:         large.ID,
+			Item:              item.Name,
+			VariantAttributes: large.VariantAttributes,
+			Quantity:          1,
+			Price:             55,
+			Stock:             &stock,
+		},
+		{
+			ItemID:            item.ID,
+			VariantID:         medium.ID,
+			Item:              item.Name,
+			VariantAttributes: medium.VariantAttributes,
+			Quantity:          2,
+			Price:             50,
+		},
+	}, items, "variants are priced and stocked on their own")
+
+	require.ErrorIs(t, c.repo.RemoveCartItem(ctx, nil, user.ID, item.ID, 0), sql.ErrNoRows)
+	require.NoError(t, c.repo.RemoveCartItem(ctx, nil, user.ID, item.ID, medium.ID))
+
+	for _, variantID := range []int{large.ID, medium.ID, medium.ID} {
+		err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+			return c.repo.MakePurchase(ctx, tx, &model.Purchase{
+				UserID:    user.ID,
+				ItemID:    item.ID,
+				VariantID: variantID,
+				Quantity:  1,
+				Price:     50,
+			})
+		})
+		require.NoError(t, err)
+	}
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		return c.repo.MakePurchase(ctx, tx, &model.Purchase{
+			UserID:    user.ID,
+			ItemID:    item.ID,
+			VariantID: large.ID,
+			Quantity:  1,
+		})
+	})
+	require.ErrorIs(t, err, model.ErrOutOfStock)
+
+	found, err = c.repo.FindVariant(ctx, nil, item.ID, large.VariantAttributes)
+	require.NoError(t, err)
+	require.NotNil(t, found.Stock)
+	assert.Equal(t, 0, *found.Stock)
+
+	foundItem, err := c.repo.FindItem(ctx, nil, item.Name)
+	require.NoError(t, err)
+	assert.Equal(t, &itemStock, foundItem.Stock, "variants do not take from the stock of the item")
+
+	require.NoError(t, c.repo.SetVariantStock(ctx, nil, large.ID, nil))
+
+	found, err = c.repo.FindVariant(ctx, nil, item.ID, large.VariantAttributes)
+	require.NoError(t, err)
+	assert.Nil(t, found.Stock)
+
+	inventory, err := c.repo.ListInventory(ctx, nil, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Inventory{
+		{Type: item.Name, VariantAttributes: large.VariantAttributes, Quantity: 1},
+		{Type: item.Name, VariantAttributes: medium.VariantAttributes, Quantity: 2},
+	}, inventory)
+}
+
+func (c *contract) testRollback(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	sender, receiver := c.createUser(t), c.createUser(t)
+
+	err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+		if err := c.repo.MakeTransfer(ctx, tx, newTransfer(sender, receiver, 100)); err != nil {
+			return err
+		}
+
+		return errTest
+	})
+	assert.ErrorIs(t, err, errTest)
+
+	assert.Equal(t, startBalance, c.balance(t, sender.Username))
+	assert.Equal(t, startBalance, c.balance(t, receiver.Username))
+
+	history, err := c.repo.ListTransactions(ctx, nil, sender.ID)
+	require.NoError(t, err)
+	assert.Empty(t, history.Sent)
+}
+
+// testReadOutsideTx checks that a read outside of a running transaction,
+// even from inside it, does not wait for the transaction and sees the
+// committed state.
+func (c *contract) testReadOutsideTx(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.repo.WithTx(ctx, func(tx repository.DB) error {
+			if err := c.repo.AddBalance(ctx, tx, user.ID, 100); err != nil {
+				return err
+			}
+
+			found, err := c.repo.FindUser(ctx, nil, user.Username)
+			if err != nil {
+				return err
+			}
+
+			if found.Balance != startBalance {
+				return fmt.Errorf("read outside of the transaction sees balance %d", found.Balance)
+			}
+
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read outside of the transaction waits for it")
+	}
+
+	assert.Equal(t, startBalance+100, c.balance(t, user.Username))
+}
+
+func (c *contract) testConcurrentTransfers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	const (
+		numUsers     = 4
+		numTransfers = 100
+	)
+
+	users := make([]*model.User, numUsers)
+	for i := range users {
+		users[i] = c.createUser(t)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range numTransfers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			sender, receiver := users[i%numUsers], users[(i+1+i/numUsers)%numUsers]
+			if sender.ID == receiver.ID {
+				return
+			}
+
+			err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+				locked, err := c.repo.FindUsersForUpdate(ctx, tx, sender.Username, receiver.Username)
+				if err != nil {
+					return err
+				}
+
+				if locked[sender.Username].Balance < 300 {
+					return model.ErrInsufficientFunds
+				}
+
+				return c.repo.MakeTransfer(ctx, tx, newTransfer(sender, receiver, 300))
+			})
+			if err != nil {
+				assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	total := 0
+	for _, user := range users {
+		balance := c.balance(t, user.Username)
+		assert.GreaterOrEqual(t, balance, 0)
+
+		total += balance
+	}
+
+	assert.Equal(t, numUsers*startBalance, total)
+}
+
+func (c *contract) addEvent(t *testing.T, tx repository.DB, userID int) *model.Event {
+	t.Helper()
+
+	event, err := model.NewEvent(model.EventUserRegistered, userID, model.UserRegistered{Username: "test"})
+	require.NoError(t, err)
+	require.NoError(t, c.repo.AddEvent(context.Background(), tx, event))
+
+	return event
+}
+
+func (c *contract) pendingEvents(t *testing.T, userIDs ...int) []model.Event {
+	t.Helper()
+
+	all, err := c.repo.ListPendingEvents(context.Background(), nil, 1_000_000)
+	require.NoError(t, err)
+
+	var events []model.Event
+
+	for _, event := range all {
+		if slices.Contains(userIDs, event.UserID) {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+func (c *contract) testOutboxReceivers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	sender, receiver := c.createUser(t), c.createUser(t)
+	registered := c.addEvent(t, nil, receiver.ID)
+
+	event, err := model.NewEvent(model.EventTransferCompleted, sender.ID, model.TransferCompleted{
+		FromUser: sender.Username,
+		ToUser:   receiver.Username,
+		Amount:   10,
+	})
+	require.NoError(t, err)
+
+	event.ReceiverID = receiver.ID
+	require.NoError(t, c.repo.AddEvent(ctx, nil, event))
+	assert.Equal(t, int64(1), event.Sequence)
+	assert.Equal(t, registered.Sequence+1, event.ReceiverSequence, "the receiver's numbers count on")
+
+	next := c.addEvent(t, nil, receiver.ID)
+	assert.Equal(t, event.ReceiverSequence+1, next.Sequence)
+
+	found, err := c.repo.FindEvent(ctx, nil, event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, receiver.ID, found.ReceiverID)
+	assert.Equal(t, event.ReceiverSequence, found.ReceiverSequence)
+
+	pending := c.pendingEvents(t, sender.ID)
+	require.Len(t, pending, 1)
+	assert.Equal(t, event.ReceiverSequence, pending[0].ReceiverSequence)
+	assert.Zero(t, c.pendingEvents(t, receiver.ID)[0].ReceiverID)
+
+	missing := *event
+	missing.ReceiverID = math.MaxInt32
+	require.Error(t, c.repo.AddEvent(ctx, nil, &missing), "the receiver must exist")
+}
+
+func (c *contract) testOutbox(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	first, second := c.createUser(t), c.createUser(t)
+
+	err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+		c.addEvent(t, tx, first.ID)
+
+		return errTest
+	})
+	require.ErrorIs(t, err, errTest)
+
+	var added []*model.Event
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		added = append(added,
+			c.addEvent(t, tx, first.ID),
+			c.addEvent(t, tx, second.ID),
+			c.addEvent(t, tx, first.ID),
+		)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []int64{1, 1, 2}, []int64{added[0].Sequence, added[1].Sequence, added[2].Sequence},
+		"sequences are per user and rolled back events do not use them up")
+	assert.Less(t, added[0].ID, added[1].ID)
+	assert.Less(t, added[1].ID, added[2].ID)
+
+	pending := c.pendingEvents(t, first.ID, second.ID)
+	require.Len(t, pending, 3)
+
+	for i, event := range pending {
+		assert.Equal(t, added[i].ID, event.ID)
+		assert.Equal(t, added[i].Sequence, event.Sequence)
+		assert.Equal(t, model.EventUserRegistered, event.Type)
+		assert.JSONEq(t, string(added[i].Payload), string(event.Payload))
+		assert.False(t, event.CreatedAt.IsZero())
+	}
+
+	err = c.repo.MarkEventsPublished(ctx, nil, []int64{added[0].ID, added[2].ID})
+	require.NoError(t, err)
+
+	pending = c.pendingEvents(t, first.ID, second.ID)
+	require.Len(t, pending, 1)
+	assert.Equal(t, added[1].ID, pending[0].ID)
+}
+
+func (c *contract) testTryLock(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	const key = 0x636f6e7472616374
+
+	release, locked, err := c.repo.TryLock(ctx, key)
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	_, locked, err = c.repo.TryLock(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, locked, "lock is exclusive")
+
+	release()
+
+	release, locked, err = c.repo.TryLock(ctx, key)
+	require.NoError(t, err)
+	require.True(t, locked, "lock can be taken again after release")
+	release()
+}
+
+func (c *contract) testWebhooks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+
+	transfers := &model.Webhook{
+		URL:        "http://example.com/transfers",
+		Secret:     "secret",
+		EventTypes: []model.EventType{model.EventTransferCompleted},
+	}
+	registrations := &model.Webhook{
+		URL:        "http://example.com/registrations",
+		Secret:     "secret",
+		EventTypes: []model.EventType{model.EventUserRegistered, model.EventItemPurchased},
+	}
+
+	for _, w := range []*model.Webhook{transfers, registrations} {
+		require.NoError(t, c.repo.CreateWebhook(ctx, nil, w))
+		assert.NotZero(t, w.ID)
+		assert.False(t, w.CreatedAt.IsZero())
+	}
+
+	found, err := c.repo.FindWebhook(ctx, nil, registrations.ID)
+	require.NoError(t, err)
+	assert.Equal(t, registrations.URL, found.URL)
+	assert.Equal(t, registrations.EventTypes, found.EventTypes)
+
+	webhooks, err := c.repo.ListWebhooks(ctx, nil)
+	require.NoError(t, err)
+	assert.True(t, slices.ContainsFunc(webhooks, func(w model.Webhook) bool { return w.ID == transfers.ID }))
+
+	event := c.addEvent(t, nil, user.ID)
+
+	stored, err := c.repo.FindEvent(ctx, nil, event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, event.Type, stored.Type)
+
+	_, err = c.repo.FindEvent(ctx, nil, -1)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	for range 2 {
+		require.NoError(t, c.repo.CreateWebhookDeliveries(ctx, nil, *event), "queuing is idempotent")
+	}
+
+	deliveries, err := c.repo.ListWebhookDeliveries(ctx, nil, registrations.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+
+	delivery := deliveries[0]
+	assert.Equal(t, event.ID, delivery.EventID)
+	assert.Equal(t, model.DeliveryPending, delivery.Status)
+
+	deliveries, err = c.repo.ListWebhookDeliveries(ctx, nil, transfers.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries, "webhook is not subscribed to the event type")
+
+	claimed := c.claimDeliveries(t, registrations.ID)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, delivery.ID, claimed[0].ID)
+	assert.Empty(t, c.claimDeliveries(t, registrations.ID), "claimed deliveries are leased")
+
+	deliveredAt := time.Now()
+	claimed[0].Status = model.DeliveryDelivered
+	claimed[0].Attempts = 1
+	claimed[0].ResponseStatus = 200
+	claimed[0].DeliveredAt = &deliveredAt
+	require.NoError(t, c.repo.UpdateWebhookDelivery(ctx, nil, &claimed[0]))
+
+	updated, err := c.repo.FindWebhookDelivery(ctx, nil, delivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.DeliveryDelivered, updated.Status)
+	assert.Equal(t, 1, updated.Attempts)
+	assert.Equal(t, 200, updated.ResponseStatus)
+	assert.NotNil(t, updated.DeliveredAt)
+
+	require.NoError(t, c.repo.DeleteWebhook(ctx, nil, registrations.ID))
+
+	_, err = c.repo.FindWebhook(ctx, nil, registrations.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = c.repo.FindWebhookDelivery(ctx, nil, delivery.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "deliveries are deleted with the webhook")
+
+	err = c.repo.DeleteWebhook(ctx, nil, registrations.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, c.repo.DeleteWebhook(ctx, nil, transfers.ID))
+}
+
+// claimDeliveries claims due deliveries of the webhook. Deliveries of other
+// webhooks claimed along the way are released for their own tests.
+func (c *contract) claimDeliveries(t *testing.T, webhookID int) []model.WebhookDelivery {
+	t.Helper()
+
+	all, err := c.repo.ClaimWebhookDeliveries(context.Background(), nil, 1_000_000, time.Hour)
+	require.NoError(t, err)
+
+	var claimed []model.WebhookDelivery
+
+	for _, d := range all {
+		if d.WebhookID == webhookID {
+			claimed = append(claimed, d)
+
+			continue
+		}
+
+		d.NextAttemptAt = time.Now()
+		require.NoError(t, c.repo.UpdateWebhookDelivery(context.Background(), nil, &d))
+	}
+
+	return claimed
+}
+
+func (c *contract) addNotification(t *testing.T, tx repository.DB, userID, balance int) *model.Notification {
+	t.Helper()
+
+	n, err := model.NewNotification(model.NotificationBalanceChanged, userID, model.BalanceChanged{Balance: balance})
+	require.NoError(t, err)
+	require.NoError(t, c.repo.AddNotifications(context.Background(), tx, n))
+
+	return n
+}
+
+func (c *contract) testNotifications(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	first, second := c.createUser(t), c.createUser(t)
+
+	last, err := c.repo.LastNotificationID(ctx, nil, first.ID)
+	require.NoError(t, err)
+	assert.Zero(t, last)
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		c.addNotification(t, tx, first.ID, 1)
+
+		return errTest
+	})
+	require.ErrorIs(t, err, errTest)
+
+	var added []*model.Notification
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		added = append(added,
+			c.addNotification(t, tx, first.ID, 2),
+			c.addNotification(t, tx, second.ID, 3),
+			c.addNotification(t, tx, first.ID, 4),
+		)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	notifications, err := c.repo.ListNotifications(ctx, nil, first.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, notifications, 2, "rolled back notifications are not stored")
+	assert.Equal(t, added[0].ID, notifications[0].ID)
+	assert.Equal(t, added[2].ID, notifications[1].ID)
+	assert.Equal(t, model.NotificationBalanceChanged, notifications[0].Type)
+	assert.JSONEq(t, `{"balance": 2}`, string(notifications[0].Payload))
+	assert.False(t, notifications[0].CreatedAt.IsZero())
+
+	notifications, err = c.repo.ListNotifications(ctx, nil, first.ID, added[0].ID, 10)
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, added[2].ID, notifications[0].ID)
+
+	last, err = c.repo.LastNotificationID(ctx, nil, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, added[2].ID, last)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	heard := make(chan int, 100)
+	done := make(chan error)
+
+	go func() {
+		done <- c.repo.ListenNotifications(ctx, func(userID int) {
+			select {
+			case heard <- userID:
+			default:
+			}
+		})
+	}()
+
+	// The listener may take a moment to start, so keep notifying until it
+	// hears the user.
+	assert.Eventually(t, func() bool {
+		c.addNotification(t, nil, second.ID, 5)
+
+		for {
+			select {
+			case userID := <-heard:
+				if userID == second.ID {
+					return true
+				}
+			case <-time.After(50 * time.Millisecond):
+				return false
+			}
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func (c *contract) testIdempotencyKeys(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	username := c.createUser(t).Username
+	notBefore := time.Now().Add(-time.Hour)
+
+	key := &model.IdempotencyKey{Username: username, Key: "key", RequestHash: "first"}
+
+	reserved, err := c.repo.ReserveIdempotencyKey(ctx, nil, key, notBefore)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	assert.False(t, key.CreatedAt.IsZero())
+
+	retry := &model.IdempotencyKey{Username: username, Key: "key", RequestHash: "second"}
+
+	reserved, err = c.repo.ReserveIdempotencyKey(ctx, nil, retry, notBefore)
+	require.NoError(t, err)
+	require.False(t, reserved, "key is taken")
+	assert.Equal(t, "first", retry.RequestHash, "stored key is returned")
+	assert.False(t, retry.Completed())
+
+	other := &model.IdempotencyKey{Username: c.createUser(t).Username, Key: "key", RequestHash: "other"}
+
+	reserved, err = c.repo.ReserveIdempotencyKey(ctx, nil, other, notBefore)
+	require.NoError(t, err)
+	assert.True(t, reserved, "keys are scoped to the user")
+
+	key.StatusCode = 200
+	key.Response = []byte(`{"ok":true}`)
+	require.NoError(t, c.repo.CompleteIdempotencyKey(ctx, nil, key))
+
+	retry = &model.IdempotencyKey{Username: username, Key: "key"}
+
+	reserved, err = c.repo.ReserveIdempotencyKey(ctx, nil, retry, notBefore)
+	require.NoError(t, err)
+	require.False(t, reserved)
+	assert.Equal(t, 200, retry.StatusCode)
+	assert.Equal(t, key.Response, retry.Response)
+
+	reserved, err = c.repo.ReserveIdempotencyKey(ctx, nil, retry, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, reserved, "expired key is replaced")
+	assert.False(t, retry.Completed())
+
+	require.NoError(t, c.repo.DeleteIdempotencyKey(ctx, nil, username, "key"))
+
+	reserved, err = c.repo.ReserveIdempotencyKey(ctx, nil, key, notBefore)
+	require.NoError(t, err)
+	assert.True(t, reserved, "deleted key can be reserved again")
+}
+
+func (c *contract) testLedger(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+
+	entry := &model.LedgerEntry{UserID: user.ID, Kind: model.LedgerEntryAllowance, Amount: 100, Reference: "2025-01"}
+
+	added, err := c.repo.AddLedgerEntry(ctx, nil, entry)
+	require.NoError(t, err)
+	require.True(t, added)
+	assert.NotZero(t, entry.ID)
+	assert.False(t, entry.CreatedAt.IsZero())
+
+	added, err = c.repo.AddLedgerEntry(ctx, nil, &model.LedgerEntry{
+		UserID: user.ID, Kind: model.LedgerEntryAllowance, Amount: 100, Reference: "2025-01",
+	})
+	require.NoError(t, err)
+	assert.False(t, added, "one entry of a kind per reference")
+
+	next := &model.LedgerEntry{UserID: user.ID, Kind: model.LedgerEntryAllowance, Amount: 50, Reference: "2025-02"}
+
+	added, err = c.repo.AddLedgerEntry(ctx, nil, next)
+	require.NoError(t, err)
+	require.True(t, added)
+
+	entries, err := c.repo.ListLedgerEntries(ctx, nil, user.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, model.LedgerEntrySignup, entries[0].Kind)
+	assert.Equal(t, entry.ID, entries[1].ID)
+	assert.Equal(t, "2025-02", entries[2].Reference)
+	assert.Equal(t, 50, entries[2].Amount)
+
+	assert.Equal(t, startBalance, c.balance(t, user.Username), "balance is not changed")
+
+	entries, err = c.repo.ListLedgerEntries(ctx, nil, c.createUser(t).ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "only the signup entry")
+	assert.Equal(t, model.LedgerEntry{
+		ID:        entries[0].ID,
+		UserID:    entries[0].UserID,
+		Kind:      model.LedgerEntrySignup,
+		Amount:    startBalance,
+		CreatedAt: entries[0].CreatedAt,
+	}, entries[0])
+}
+
+func (c *contract) testLedgerReasons(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+
+	for _, entry := range []*model.LedgerEntry{
+		{UserID: user.ID, Kind: model.LedgerEntryMint, Amount: 30, Reason: "bug bounty"},
+		{UserID: user.ID, Kind: model.LedgerEntryMint, Amount: 20, Reason: "bug bounty"},
+		{UserID: user.ID, Kind: model.LedgerEntryBurn, Amount: -40, Reason: "refund"},
+	} {
+		added, err := c.repo.AddLedgerEntry(ctx, nil, entry)
+		require.NoError(t, err)
+		require.True(t, added, "entries without a reference are never duplicates")
+	}
+
+	entries, err := c.repo.ListLedgerEntries(ctx, nil, user.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, model.LedgerEntryBurn, entries[3].Kind)
+	assert.Equal(t, "refund", entries[3].Reason)
+	assert.Empty(t, entries[3].Reference)
+
+	history, err := c.repo.ListTransactions(ctx, nil, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CoinsReceived{{FromUser: model.SystemAccount, Amount: 50}}, history.Received)
+	assert.Equal(t, []model.CoinsSent{{ToUser: model.SystemAccount, Amount: 40}}, history.Sent)
+}
+
+func (c *contract) testReconcile(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	const limit = 10000
+
+	sender, receiver, skewed := c.createUser(t), c.createUser(t), c.createUser(t)
+
+	item, err := c.repo.FindItem(ctx, nil, "pink-hoody")
+	require.NoError(t, err)
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		if err := c.repo.MakeTransfer(ctx, tx, newTransfer(sender, receiver, 100)); err != nil {
+			return err
+		}
+
+		if err := c.repo.MakePurchase(ctx, tx, newPurchase(sender, item)); err != nil {
+			return err
+		}
+
+		entry := &model.LedgerEntry{UserID: receiver.ID, Kind: model.LedgerEntryMint, Amount: 30, Reason: "bonus"}
+		if _, err := c.repo.AddLedgerEntry(ctx, tx, entry); err != nil {
+			return err
+		}
+
+		if err := c.repo.AddBalance(ctx, tx, receiver.ID, 30); err != nil {
+			return err
+		}
+
+		return c.repo.AddBalance(ctx, tx, skewed.ID, 5)
+	})
+	require.NoError(t, err)
+
+	entries, err := c.repo.ListLedgerEntries(ctx, nil, sender.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, model.LedgerEntryPurchase, entries[1].Kind)
+	assert.Equal(t, -item.Price, entries[1].Amount)
+
+	history, err := c.repo.ListTransactions(ctx, nil, sender.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CoinsSent{{ToUser: receiver.Username, Amount: 100}}, history.Sent,
+		"purchases are not in the coin history")
+	assert.Empty(t, history.Received, "the starting balance is not in the coin history")
+
+	result, err := c.repo.Reconcile(ctx, nil, limit)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, result.Issued, int64(3*startBalance+30))
+	assert.GreaterOrEqual(t, result.Burned, int64(item.Price))
+	assert.Contains(t, result.Discrepancies, model.Discrepancy{
+		Username: skewed.Username,
+		Balance:  startBalance + 5,
+		Expected: startBalance,
+	})
+
+	for _, d := range result.Discrepancies {
+		assert.NotEqual(t, sender.Username, d.Username)
+		assert.NotEqual(t, receiver.Username, d.Username)
+	}
+
+	result, err = c.repo.Reconcile(ctx, nil, 0)
+	require.NoError(t, err)
+	assert.Empty(t, result.Discrepancies)
+}
+
+func (c *contract) testReferrals(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	referrer := c.createUser(t)
+
+	found, err := c.repo.FindUserByReferralCode(ctx, nil, referrer.ReferralCode)
+	require.NoError(t, err)
+	assert.Equal(t, referrer.ID, found.ID)
+
+	_, err = c.repo.FindUserByReferralCode(ctx, nil, "code-missing")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = c.repo.FindUserByReferralCode(ctx, nil, "")
+	require.ErrorIs(t, err, sql.ErrNoRows, "users without a code are not found by an empty code")
+
+	username := fmt.Sprintf("contract-%s-%d", t.Name(), c.seq.Add(1))
+	user := &model.User{Username: username, Password: []byte("x"), Salt: []byte("y")}
+	require.NoError(t, c.repo.CreateUser(ctx, nil, user))
+
+	referee, err := c.repo.FindUser(ctx, nil, username)
+	require.NoError(t, err)
+	assert.Zero(t, referee.Balance)
+	assert.Empty(t, referee.ReferralCode)
+
+	entries, err := c.repo.ListLedgerEntries(ctx, nil, referee.ID)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "no signup entry without a starting balance")
+
+	before := time.Now().Add(-time.Minute)
+
+	referral := &model.Referral{ReferrerID: referrer.ID, RefereeID: referee.ID, Rewarded: true}
+	require.NoError(t, c.repo.AddReferral(ctx, nil, referral))
+	assert.False(t, referral.CreatedAt.IsZero())
+
+	err = c.repo.AddReferral(ctx, nil, &model.Referral{ReferrerID: c.createUser(t).ID, RefereeID: referee.ID})
+	require.Error(t, err, "a user is referred once")
+
+	require.NoError(t, c.repo.AddReferral(ctx, nil, &model.Referral{
+		ReferrerID: referrer.ID, RefereeID: c.createUser(t).ID, Rewarded: false,
+	}))
+
+	count, err := c.repo.CountRewardedReferrals(ctx, nil, referrer.ID, before)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	count, err = c.repo.CountRewardedReferrals(ctx, nil, referrer.ID, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func (c *contract) testTransferLimits(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := c.createUser(t)
+
+	_, err := c.repo.FindTransferLimits(ctx, nil, user.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	maxAmount, dailyCount := 100, 0
+	override := &model.TransferLimitsOverride{MaxAmount: &maxAmount, DailyCount: &dailyCount}
+	require.NoError(t, c.repo.SetTransferLimits(ctx, nil, user.ID, override))
+
+	found, err := c.repo.FindTransferLimits(ctx, nil, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, override, found)
+
+	dailyAmount := 500
+	override = &model.TransferLimitsOverride{DailyAmount: &dailyAmount}
+	require.NoError(t, c.repo.SetTransferLimits(ctx, nil, user.ID, override))
+
+	found, err = c.repo.FindTransferLimits(ctx, nil, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, override, found, "the override is replaced as a whole")
+
+	require.NoError(t, c.repo.SetTransferLimits(ctx, nil, user.ID, &model.TransferLimitsOverride{}))
+
+	_, err = c.repo.FindTransferLimits(ctx, nil, user.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows, "an empty override is deleted")
+
+	today := model.TransferDay(time.Now())
+	yesterday := today.AddDate(0, 0, -1)
+
+	usage, err := c.repo.FindTransferUsage(ctx, nil, user.ID, today)
+	require.NoError(t, err)
+	assert.Zero(t, usage)
+
+	require.NoError(t, c.repo.WithTx(ctx, func(tx repository.DB) error {
+		if err := c.repo.AddTransferUsage(ctx, tx, user.ID, today, 30); err != nil {
+			return err
+		}
+
+		return c.repo.AddTransferUsage(ctx, tx, user.ID, today, 20)
+	}))
+	require.NoError(t, c.repo.AddTransferUsage(ctx, nil, user.ID, yesterday, 70))
+
+	usage, err = c.repo.FindTransferUsage(ctx, nil, user.ID, today)
+	require.NoError(t, err)
+	assert.Equal(t, model.TransferUsage{Amount: 50, Count: 2}, usage)
+
+	usage, err = c.repo.FindTransferUsage(ctx, nil, user.ID, yesterday)
+	require.NoError(t, err)
+	assert.Equal(t, model.TransferUsage{Amount: 70, Count: 1}, usage)
+}
+
+func (c *contract) testJobRuns(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	name := fmt.Sprintf("contract-job-%d", c.seq.Add(1))
+
+	_, err := c.repo.FindJobRun(ctx, nil, name)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	first := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, c.repo.SaveJobRun(ctx, nil, name, first))
+
+	at, err := c.repo.FindJobRun(ctx, nil, name)
+	require.NoError(t, err)
+	assert.True(t, first.Equal(at))
+
+	second := first.AddDate(0, 1, 0)
+	require.NoError(t, c.repo.SaveJobRun(ctx, nil, name, second))
+
+	at, err = c.repo.FindJobRun(ctx, nil, name)
+	require.NoError(t, err)
+	assert.True(t, second.Equal(at))
+}
+
+func (c *contract) testPendingTransfers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	sender, receiver := c.createUser(t), c.createUser(t)
+
+	now := time.Now()
+	held := &model.PendingTransfer{
+		SenderID:   sender.ID,
+		ReceiverID: receiver.ID,
+		Amount:     30,
+		Memo:       "thanks",
+		ExpiresAt:  now.Add(time.Hour),
+	}
+	expired := &model.PendingTransfer{
+		SenderID:   sender.ID,
+		ReceiverID: receiver.ID,
+		Amount:     20,
+		ExpiresAt:  now.Add(-time.Minute),
+	}
+
+	require.NoError(t, c.repo.CreatePendingTransfer(ctx, nil, held))
+	require.NoError(t, c.repo.CreatePendingTransfer(ctx, nil, expired))
+	assert.NotZero(t, held.ID)
+	assert.Equal(t, model.PendingTransferPending, held.Status)
+	assert.Equal(t, startBalance-50, c.balance(t, sender.Username), "the coins are held")
+	assert.Equal(t, startBalance, c.balance(t, receiver.Username))
+
+	err := c.repo.CreatePendingTransfer(ctx, nil, &model.PendingTransfer{
+		SenderID:   sender.ID,
+		ReceiverID: receiver.ID,
+		Amount:     startBalance,
+		ExpiresAt:  now.Add(time.Hour),
+	})
+	require.ErrorIs(t, err, model.ErrInsufficientFunds)
+
+	for _, user := range []*model.User{sender, receiver} {
+		transfers, err := c.repo.ListPendingTransfers(ctx, nil, user.ID)
+		require.NoError(t, err)
+		require.Len(t, transfers, 2)
+		assert.Equal(t, held.ID, transfers[0].ID)
+		assert.Equal(t, sender.Username, transfers[0].FromUser)
+		assert.Equal(t, receiver.Username, transfers[0].ToUser)
+		assert.Equal(t, "thanks", transfers[0].Memo)
+		assert.WithinDuration(t, held.ExpiresAt, transfers[0].ExpiresAt, time.Millisecond)
+		assert.Empty(t, transfers[1].Memo)
+	}
+
+	result, err := c.repo.Reconcile(ctx, nil, 10000)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, result.Held, int64(50))
+
+	for _, d := range result.Discrepancies {
+		assert.NotEqual(t, sender.Username, d.Username, "held coins are expected")
+	}
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		transfers, err := c.repo.ListExpiredPendingTransfers(ctx, tx, now, 10000)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(transfers))
+		for _, t := range transfers {
+			ids = append(ids, t.ID)
+		}
+
+		assert.Contains(t, ids, expired.ID)
+		assert.NotContains(t, ids, held.ID)
+
+		return c.repo.ResolvePendingTransfer(ctx, tx, expired, model.PendingTransferExpired)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.PendingTransferExpired, expired.Status)
+	assert.NotNil(t, expired.ResolvedAt)
+	assert.Equal(t, startBalance-30, c.balance(t, sender.Username), "expired coins go back")
+
+	err = c.repo.ResolvePendingTransfer(ctx, nil, expired, model.PendingTransferDeclined)
+	require.ErrorIs(t, err, sql.ErrNoRows, "a transfer is resolved once")
+
+	found, err := c.repo.FindPendingTransferForUpdate(ctx, nil, expired.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PendingTransferExpired, found.Status)
+
+	transfers, err := c.repo.ListPendingTransfers(ctx, nil, receiver.ID)
+	require.NoError(t, err)
+	require.Len(t, transfers, 1, "resolved transfers are not listed")
+
+	_, err = c.repo.FindPendingTransferForUpdate(ctx, nil, -1)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func (c *contract) testPaymentRequests(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	requester, payer := c.createUser(t), c.createUser(t)
+
+	now := time.Now()
+	open := &model.PaymentRequest{
+		RequesterID: requester.ID,
+		PayerID:     payer.ID,
+		Amount:      30,
+		Memo:        "lunch",
+		ExpiresAt:   now.Add(time.Hour),
+	}
+	expired := &model.PaymentRequest{
+		RequesterID: requester.ID,
+		PayerID:     payer.ID,
+		Amount:      20,
+		ExpiresAt:   now.Add(-time.Minute),
+	}
+
+	require.NoError(t, c.repo.CreatePaymentRequest(ctx, nil, open))
+	require.NoError(t, c.repo.CreatePaymentRequest(ctx, nil, expired))
+	assert.NotZero(t, open.ID)
+	assert.Equal(t, model.PaymentRequestPending, open.Status)
+	assert.Equal(t, startBalance, c.balance(t, payer.Username), "nothing is held")
+
+	for _, user := range []*model.User{requester, payer} {
+		requests, err := c.repo.ListPaymentRequests(ctx, nil, user.ID, now)
+		require.NoError(t, err)
+		require.Len(t, requests, 1, "expired requests are not listed")
+		assert.Equal(t, open.ID, requests[0].ID)
+		assert.Equal(t, requester.Username, requests[0].Requester)
+		assert.Equal(t, payer.Username, requests[0].Payer)
+		assert.Equal(t, "lunch", requests[0].Memo)
+		assert.WithinDuration(t, open.ExpiresAt, requests[0].ExpiresAt, time.Millisecond)
+	}
+
+	err := c.repo.WithTx(ctx, func(tx repository.DB) error {
+		found, err := c.repo.FindPaymentRequestForUpdate(ctx, tx, open.ID)
+		if err != nil {
+			return err
+		}
+
+		return c.repo.ResolvePaymentRequest(ctx, tx, found, model.PaymentRequestPaid)
+	})
+	require.NoError(t, err)
+
+	err = c.repo.ResolvePaymentRequest(ctx, nil, open, model.PaymentRequestDeclined)
+	require.ErrorIs(t, err, sql.ErrNoRows, "a request is resolved once")
+
+	found, err := c.repo.FindPaymentRequestForUpdate(ctx, nil, open.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentRequestPaid, found.Status)
+	assert.NotNil(t, found.ResolvedAt)
+
+	requests, err := c.repo.ListPaymentRequests(ctx, nil, payer.ID, now)
+	require.NoError(t, err)
+	assert.Empty(t, requests, "resolved requests are not listed")
+
+	_, err = c.repo.FindPaymentRequestForUpdate(ctx, nil, -1)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func (c *contract) testScheduledTransfers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	sender, receiver := c.createUser(t), c.createUser(t)
+
+	now := time.Now()
+	monthly := &model.ScheduledTransfer{
+		SenderID:   sender.ID,
+		ReceiverID: receiver.ID,
+		Amount:     50,
+		Memo:       "charity",
+		Schedule:   "@monthly",
+		NextRunAt:  now.Add(-time.Minute),
+	}
+	later := &model.ScheduledTransfer{
+		SenderID:   sender.ID,
+		ReceiverID: receiver.ID,
+		Amount:     10,
+		NextRunAt:  now.Add(time.Hour),
+	}
+
+	require.NoError(t, c.repo.CreateScheduledTransfer(ctx, nil, monthly))
+	require.NoError(t, c.repo.CreateScheduledTransfer(ctx, nil, later))
+	assert.NotZero(t, monthly.ID)
+	assert.Equal(t, model.ScheduledTransferActive, monthly.Status)
+
+	transfers, err := c.repo.ListScheduledTransfers(ctx, nil, sender.ID)
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+	assert.Equal(t, monthly.ID, transfers[0].ID)
+	assert.Equal(t, sender.Username, transfers[0].FromUser)
+	assert.Equal(t, receiver.Username, transfers[0].ToUser)
+	assert.Equal(t, "charity", transfers[0].Memo)
+	assert.Equal(t, "@monthly", transfers[0].Schedule)
+	assert.False(t, transfers[1].Recurring())
+
+	transfers, err = c.repo.ListScheduledTransfers(ctx, nil, receiver.ID)
+	require.NoError(t, err)
+	assert.Empty(t, transfers, "only the sender's transfers are listed")
+
+	next := now.Add(30 * 24 * time.Hour)
+
+	err = c.repo.WithTx(ctx, func(tx repository.DB) error {
+		due, err := c.repo.ListDueScheduledTransfers(ctx, tx, now, 10000)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(due))
+		for _, t := range due {
+			ids = append(ids, t.ID)
+		}
+
+		assert.Contains(t, ids, monthly.ID)
+		assert.NotContains(t, ids, later.ID)
+
+		monthly.NextRunAt = next
+
+		return c.repo.UpdateScheduledTransfer(ctx, tx, monthly)
+	})
+	require.NoError(t, err)
+
+	found, err := c.repo.FindScheduledTransferForUpdate(ctx, nil, monthly.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, next, found.NextRunAt, time.Millisecond)
+
+	for _, run := range []*model.ScheduledTransferRun{
+		{ScheduledTransferID: monthly.ID, ScheduledAt: now, Status: model.ScheduledTransferRunSucceeded},
+		{ScheduledTransferID: monthly.ID, ScheduledAt: next, Status: model.ScheduledTransferRunFailed, Error: "nope"},
+	} {
+		require.NoError(t, c.repo.AddScheduledTransferRun(ctx, nil, run))
+		assert.NotZero(t, run.ID)
+	}
+
+	runs, err := c.repo.ListScheduledTransferRuns(ctx, nil, monthly.ID, 1)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, model.ScheduledTransferRunFailed, runs[0].Status, "newest first")
+	assert.Equal(t, "nope", runs[0].Error)
+
+	runs, err = c.repo.ListScheduledTransferRuns(ctx, nil, later.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	later.Status = model.ScheduledTransferCancelled
+	require.NoError(t, c.repo.UpdateScheduledTransfer(ctx, nil, later))
+
+	due, err := c.repo.ListDueScheduledTransfers(ctx, nil, now.Add(2*time.Hour), 10000)
+	require.NoError(t, err)
+
+	for _, d := range due {
+		assert.NotEqual(t, later.ID, d.ID, "cancelled transfers are not due")
+	}
+
+	_, err = c.repo.FindScheduledTransferForUpdate(ctx, nil, -1)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
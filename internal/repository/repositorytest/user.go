@@ -0,0 +1,30 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// NewUser creates a user with 1000 coins in repo, for the tests of the
+// services.
+func NewUser(t *testing.T, repo repository.Repository, username string) *model.User {
+	t.Helper()
+
+	err := repo.CreateUser(context.Background(), nil, &model.User{
+		Username:     username,
+		Password:     []byte("hash"),
+		Salt:         []byte("salt"),
+		Balance:      startBalance,
+		ReferralCode: "code-" + username,
+	})
+	require.NoError(t, err)
+
+	user, err := repo.FindUser(context.Background(), nil, username)
+	require.NoError(t, err)
+
+	return user
+}
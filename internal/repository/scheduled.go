@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+const scheduledTransferColumns = `
+	s.id, s.sender_id, s.receiver_id, sender.username, receiver.username, s.amount, COALESCE(s.memo, ''),
+	COALESCE(s.schedule, ''), s.status, s.next_run_at, s.created_at`
+
+const scheduledTransferTables = `
+	scheduled_transfers s
+	JOIN users sender ON sender.id = s.sender_id
+	JOIN users receiver ON receiver.id = s.receiver_id`
+
+// CreateScheduledTransfer stores a scheduled transfer, setting its ID, status
+// and creation time.
+func (r *repo) CreateScheduledTransfer(ctx context.Context, tx DB, transfer *model.ScheduledTransfer) error {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		INSERT INTO scheduled_transfers (sender_id, receiver_id, amount, memo, schedule, next_run_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)
+		RETURNING id, status, created_at;
+	`, transfer.SenderID, transfer.ReceiverID, transfer.Amount, transfer.Memo, transfer.Schedule, transfer.NextRunAt).
+		Scan(&transfer.ID, &transfer.Status, &transfer.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert scheduled transfer: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repo) FindScheduledTransferForUpdate(ctx context.Context, tx DB, id int64) (*model.ScheduledTransfer, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+scheduledTransferColumns+`
+		FROM `+scheduledTransferTables+`
+		WHERE s.id = $1
+		FOR UPDATE OF s;
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("select scheduled transfer: %w", err)
+	}
+
+	transfers, err := scanScheduledTransfers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transfers) == 0 {
+		return nil, fmt.Errorf("select scheduled transfer: %w", sql.ErrNoRows)
+	}
+
+	return &transfers[0], nil
+}
+
+// ListScheduledTransfers returns the scheduled transfers of the sender in
+// every status, oldest first.
+func (r *repo) ListScheduledTransfers(ctx context.Context, tx DB, senderID int) ([]model.ScheduledTransfer, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+scheduledTransferColumns+`
+		FROM `+scheduledTransferTables+`
+		WHERE s.sender_id = $1
+		ORDER BY s.id;
+	`, senderID)
+	if err != nil {
+		return nil, fmt.Errorf("select scheduled transfers: %w", err)
+	}
+
+	return scanScheduledTransfers(rows)
+}
+
+// ListDueScheduledTransfers locks up to limit active transfers due by at,
+// skipping those locked by others.
+func (r *repo) ListDueScheduledTransfers(
+	ctx context.Context, tx DB, at time.Time, limit int,
+) ([]model.ScheduledTransfer, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+scheduledTransferColumns+`
+		FROM `+scheduledTransferTables+`
+		WHERE s.status = 'active' AND s.next_run_at <= $1
+		ORDER BY s.next_run_at, s.id
+		LIMIT $2
+		FOR UPDATE OF s SKIP LOCKED;
+	`, at, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select due scheduled transfers: %w", err)
+	}
+
+	return scanScheduledTransfers(rows)
+}
+
+// UpdateScheduledTransfer saves the status and the next run time of a
+// scheduled transfer.
+func (r *repo) UpdateScheduledTransfer(ctx context.Context, tx DB, transfer *model.ScheduledTransfer) error {
+	db := r.getExecutor(tx)
+
+	tag, err := db.Exec(ctx, `
+		UPDATE scheduled_transfers SET status = $2, next_run_at = $3 WHERE id = $1;
+	`, transfer.ID, transfer.Status, transfer.NextRunAt)
+	if err != nil {
+		return fmt.Errorf("update scheduled transfer: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("update scheduled transfer: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// AddScheduledTransferRun records a run of a scheduled transfer, setting its
+// ID and creation time.
+func (r *repo) AddScheduledTransferRun(ctx context.Context, tx DB, run *model.ScheduledTransferRun) error {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		INSERT INTO scheduled_transfer_runs (scheduled_transfer_id, scheduled_at, status, error)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, created_at;
+	`, run.ScheduledTransferID, run.ScheduledAt, run.Status, run.Error).Scan(&run.ID, &run.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert scheduled transfer run: %w", err)
+	}
+
+	return nil
+}
+
+// ListScheduledTransferRuns returns up to limit latest runs of a scheduled
+// transfer, newest first.
+func (r *repo) ListScheduledTransferRuns(
+	ctx context.Context, tx DB, transferID int64, limit int,
+) ([]model.ScheduledTransferRun, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT id, scheduled_transfer_id, scheduled_at, status, COALESCE(error, ''), created_at
+		FROM scheduled_transfer_runs
+		WHERE scheduled_transfer_id = $1
+		ORDER BY id DESC
+		LIMIT $2;
+	`, transferID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select scheduled transfer runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []model.ScheduledTransferRun
+
+	for rows.Next() {
+		var run model.ScheduledTransferRun
+
+		err := rows.Scan(&run.ID, &run.ScheduledTransferID, &run.ScheduledAt, &run.Status, &run.Error, &run.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan scheduled transfer run: %w", err)
+		}
+
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate scheduled transfer runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+func scanScheduledTransfers(rows pgx.Rows) ([]model.ScheduledTransfer, error) {
+	defer rows.Close()
+
+	var transfers []model.ScheduledTransfer
+
+	for rows.Next() {
+		var t model.ScheduledTransfer
+
+		err := rows.Scan(
+			&t.ID,
+			&t.SenderID,
+			&t.ReceiverID,
+			&t.FromUser,
+			&t.ToUser,
+			&t.Amount,
+			&t.Memo,
+			&t.Schedule,
+			&t.Status,
+			&t.NextRunAt,
+			&t.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan scheduled transfer: %w", err)
+		}
+
+		transfers = append(transfers, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate scheduled transfers: %w", err)
+	}
+
+	return transfers, nil
+}
@@ -2,28 +2,57 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"sort"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/jackc/pgx/v5"
 )
 
-func (r *repo) MakeTransfer(ctx context.Context, tx DB, senderID, receiverID int, amount int) error {
+// transferColumns sums the coins reversed by the entries that credit the
+// sender.
+const transferColumns = `
+	t.id, t.sender_id, t.receiver_id, sender.username, receiver.username, t.amount, COALESCE(t.memo, ''),
+	(
+		SELECT COALESCE(SUM(amount), 0)
+		FROM ledger_entries
+		WHERE transfer_id = t.id AND user_id = t.sender_id
+	),
+	t.created_at`
+
+const transferTables = `
+	transfer_history t
+	JOIN users sender ON sender.id = t.sender_id
+	JOIN users receiver ON receiver.id = t.receiver_id`
+
+// MakeTransfer moves the coins of transfer and records it, setting its ID and
+// creation time.
+func (r *repo) MakeTransfer(ctx context.Context, tx DB, transfer *model.Transfer) error {
 	db := r.getExecutor(tx)
 
-	_, err := db.Exec(ctx, `
+	err := db.QueryRow(ctx, `
+		INSERT INTO transfer_history (sender_id, receiver_id, amount, memo)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, created_at;
+	`, transfer.SenderID, transfer.ReceiverID, transfer.Amount, transfer.Memo).Scan(&transfer.ID, &transfer.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert transfer: %w", err)
+	}
+
+	_, err = db.Exec(ctx, `
 		INSERT INTO transfers (sender_id, receiver_id, amount)
 		VALUES ($1, $2, $3)
 		ON CONFLICT (sender_id, receiver_id)
 		DO UPDATE SET amount = transfers.amount + $3;
-	`, senderID, receiverID, amount)
+	`, transfer.SenderID, transfer.ReceiverID, transfer.Amount)
 	if err != nil {
 		return fmt.Errorf("make transfer: %w", err)
 	}
 
 	changes := []struct{ id, amount int }{
-		{id: senderID, amount: -amount},
-		{id: receiverID, amount: amount},
+		{id: transfer.SenderID, amount: -transfer.Amount},
+		{id: transfer.ReceiverID, amount: transfer.Amount},
 	}
 	sort.Slice(changes, func(i, j int) bool {
 		return changes[i].id < changes[j].id
@@ -42,28 +71,174 @@ func (r *repo) MakeTransfer(ctx context.Context, tx DB, senderID, receiverID int
 	return nil
 }
 
+// FindTransferForUpdate returns the transfer and locks it, so that it is
+// reversed by one admin at a time.
+func (r *repo) FindTransferForUpdate(ctx context.Context, tx DB, id int64) (*model.Transfer, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+transferColumns+`
+		FROM `+transferTables+`
+		WHERE t.id = $1
+		FOR UPDATE OF t;
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("select transfer: %w", err)
+	}
+
+	transfers, err := scanTransfers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transfers) == 0 {
+		return nil, fmt.Errorf("select transfer: %w", sql.ErrNoRows)
+	}
+
+	return &transfers[0], nil
+}
+
+// ListUserTransfers returns up to limit transfers the user sent or received,
+// newest first.
+func (r *repo) ListUserTransfers(ctx context.Context, tx DB, userID, limit int) ([]model.Transfer, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+transferColumns+`
+		FROM `+transferTables+`
+		WHERE t.sender_id = $1 OR t.receiver_id = $1
+		ORDER BY t.id DESC
+		LIMIT $2;
+	`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select transfers: %w", err)
+	}
+
+	return scanTransfers(rows)
+}
+
+// HasTransferred reports whether the sender has ever made a transfer to the
+// receiver.
+func (r *repo) HasTransferred(ctx context.Context, tx DB, senderID, receiverID int) (bool, error) {
+	db := r.getExecutor(tx)
+
+	var exists bool
+
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM transfers WHERE sender_id = $1 AND receiver_id = $2);
+	`, senderID, receiverID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("select transfers: %w", err)
+	}
+
+	return exists, nil
+}
+
+// ListTransactions sums the coins the user sent to and received from every
+// other user. Transfers with a memo are listed on their own, after the sums.
+// Ledger entries of the kinds shown in the coin history count as coins sent
+// to or received from the system account. Reversals are listed on their own
+// at the end, as coins sent to or received from the other user.
 func (r *repo) ListTransactions(ctx context.Context, tx DB, userID int) (*model.CoinHistory, error) {
 	db := r.getExecutor(tx)
 
 	rows, err := db.Query(ctx, `
-		SELECT 
-			'sent' as type,
-			users.username,
-			transfers.amount
-		FROM transfers
-		JOIN users ON users.id = transfers.receiver_id
-		WHERE transfers.sender_id = $1
-		
-		UNION ALL
-		
-		SELECT 
-			'received' as type,
-			users.username,
-			transfers.amount
-		FROM transfers
-		JOIN users ON users.id = transfers.sender_id
-		WHERE transfers.receiver_id = $1;
-	`, userID)
+		WITH memos AS (
+			SELECT sender_id, receiver_id, SUM(amount) AS amount
+			FROM transfer_history
+			WHERE (sender_id = $1 OR receiver_id = $1) AND memo IS NOT NULL
+			GROUP BY sender_id, receiver_id
+		)
+		SELECT type, username, amount, memo, reversal
+		FROM (
+			SELECT
+				'sent' as type,
+				users.username,
+				transfers.amount - COALESCE(memos.amount, 0) AS amount,
+				'' AS memo,
+				false AS reversal,
+				0 AS id
+			FROM transfers
+			JOIN users ON users.id = transfers.receiver_id
+			LEFT JOIN memos USING (sender_id, receiver_id)
+			WHERE transfers.sender_id = $1 AND transfers.amount > COALESCE(memos.amount, 0)
+
+			UNION ALL
+
+			SELECT
+				'received' as type,
+				users.username,
+				transfers.amount - COALESCE(memos.amount, 0),
+				'',
+				false,
+				0
+			FROM transfers
+			JOIN users ON users.id = transfers.sender_id
+			LEFT JOIN memos USING (sender_id, receiver_id)
+			WHERE transfers.receiver_id = $1 AND transfers.amount > COALESCE(memos.amount, 0)
+
+			UNION ALL
+
+			SELECT
+				'received' as type,
+				$2,
+				SUM(amount),
+				'',
+				false,
+				0
+			FROM ledger_entries
+			WHERE user_id = $1 AND amount > 0 AND kind <> ALL($3)
+			HAVING COUNT(*) > 0
+
+			UNION ALL
+
+			SELECT
+				'sent' as type,
+				$2,
+				-SUM(amount),
+				'',
+				false,
+				0
+			FROM ledger_entries
+			WHERE user_id = $1 AND amount < 0 AND kind <> ALL($3)
+			HAVING COUNT(*) > 0
+
+			UNION ALL
+
+			SELECT
+				CASE WHEN transfer_history.sender_id = $1 THEN 'sent' ELSE 'received' END,
+				users.username,
+				transfer_history.amount,
+				transfer_history.memo,
+				false,
+				transfer_history.id
+			FROM transfer_history
+			JOIN users ON users.id = CASE
+				WHEN transfer_history.sender_id = $1 THEN transfer_history.receiver_id
+				ELSE transfer_history.sender_id
+			END
+			WHERE (transfer_history.sender_id = $1 OR transfer_history.receiver_id = $1)
+				AND transfer_history.memo IS NOT NULL
+
+			UNION ALL
+
+			SELECT
+				CASE WHEN ledger_entries.amount < 0 THEN 'sent' ELSE 'received' END,
+				users.username,
+				ABS(ledger_entries.amount),
+				'',
+				true,
+				ledger_entries.id
+			FROM ledger_entries
+			JOIN transfer_history ON transfer_history.id = ledger_entries.transfer_id
+			JOIN users ON users.id = CASE
+				WHEN transfer_history.sender_id = $1 THEN transfer_history.receiver_id
+				ELSE transfer_history.sender_id
+			END
+			WHERE ledger_entries.user_id = $1 AND ledger_entries.kind = $4
+		) AS history
+		ORDER BY reversal, id;
+	`, userID, model.SystemAccount, hiddenLedgerEntryKinds(), model.LedgerEntryReversal)
 	if err != nil {
 		return nil, fmt.Errorf("select transactions: %w", err)
 	}
@@ -76,11 +251,12 @@ func (r *repo) ListTransactions(ctx context.Context, tx DB, userID int) (*model.
 
 	for rows.Next() {
 		var (
-			txType, username string
-			amount           int
+			txType, username, memo string
+			amount                 int
+			reversal               bool
 		)
 
-		if err := rows.Scan(&txType, &username, &amount); err != nil {
+		if err := rows.Scan(&txType, &username, &amount, &memo, &reversal); err != nil {
 			return nil, fmt.Errorf("scan transaction: %w", err)
 		}
 
@@ -89,11 +265,15 @@ func (r *repo) ListTransactions(ctx context.Context, tx DB, userID int) (*model.
 			history.Received = append(history.Received, model.CoinsReceived{
 				FromUser: username,
 				Amount:   amount,
+				Memo:     memo,
+				Reversal: reversal,
 			})
 		case "sent":
 			history.Sent = append(history.Sent, model.CoinsSent{
-				ToUser: username,
-				Amount: amount,
+				ToUser:   username,
+				Amount:   amount,
+				Memo:     memo,
+				Reversal: reversal,
 			})
 		}
 	}
@@ -104,3 +284,36 @@ func (r *repo) ListTransactions(ctx context.Context, tx DB, userID int) (*model.
 
 	return history, nil
 }
+
+func scanTransfers(rows pgx.Rows) ([]model.Transfer, error) {
+	defer rows.Close()
+
+	var transfers []model.Transfer
+
+	for rows.Next() {
+		var t model.Transfer
+
+		err := rows.Scan(
+			&t.ID,
+			&t.SenderID,
+			&t.ReceiverID,
+			&t.FromUser,
+			&t.ToUser,
+			&t.Amount,
+			&t.Memo,
+			&t.Reversed,
+			&t.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan transfer: %w", err)
+		}
+
+		transfers = append(transfers, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate transfers: %w", err)
+	}
+
+	return transfers, nil
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	defaultMaxAttempts = 3
+	retryBaseDelay     = 10 * time.Millisecond
+	retryMaxDelay      = 200 * time.Millisecond
+
+	codeCheckViolation       = "23514"
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+
+	constraintPositiveBalance  = "positive_balance"
+	constraintNonNegativeStock = "non_negative_stock"
+)
+
+type txConfig struct {
+	isoLevel    pgx.TxIsoLevel
+	maxAttempts int
+}
+
+type TxOption func(*txConfig)
+
+// WithIsoLevel sets the transaction isolation level, ReadCommitted by default.
+func WithIsoLevel(level pgx.TxIsoLevel) TxOption {
+	return func(c *txConfig) {
+		c.isoLevel = level
+	}
+}
+
+// WithMaxAttempts bounds how many times a transaction is run when it fails
+// with a serialization failure or a deadlock.
+func WithMaxAttempts(n int) TxOption {
+	return func(c *txConfig) {
+		c.maxAttempts = max(n, 1)
+	}
+}
+
+// WithTx runs fn in a transaction, retrying it from scratch on serialization
+// failures and deadlocks, so fn must not have side effects outside of tx.
+func (r *repo) WithTx(ctx context.Context, fn func(DB) error, opts ...TxOption) error {
+	cfg := txConfig{
+		isoLevel:    pgx.ReadCommitted,
+		maxAttempts: defaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	err := retry(ctx, cfg.maxAttempts, func() error {
+		return r.runTx(ctx, cfg.isoLevel, fn)
+	})
+
+	return translateError(err)
+}
+
+func (r *repo) runTx(ctx context.Context, isoLevel pgx.TxIsoLevel, fn func(DB) error) error {
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel: isoLevel,
+	})
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback(ctx)
+
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func retry(ctx context.Context, maxAttempts int, fn func() error) error {
+	var err error
+
+	for attempt := range maxAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Join(err, ctx.Err())
+			case <-time.After(backoff(attempt)):
+			}
+		}
+
+		err = fn()
+		if !isRetryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// backoff returns a full-jitter exponential delay before the given attempt.
+func backoff(attempt int) time.Duration {
+	limit := min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+
+	return rand.N(limit) + 1
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	return pgErr.Code == codeSerializationFailure || pgErr.Code == codeDeadlockDetected
+}
+
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != codeCheckViolation {
+		return err
+	}
+
+	switch pgErr.ConstraintName {
+	case constraintPositiveBalance:
+		return model.ErrInsufficientFunds
+	case constraintNonNegativeStock:
+		return model.ErrOutOfStock
+	default:
+		return err
+	}
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	serializationErr := fmt.Errorf("update balance: %w", &pgconn.PgError{Code: codeSerializationFailure})
+	deadlockErr := &pgconn.PgError{Code: codeDeadlockDetected}
+
+	t.Run("retries retryable errors until success", func(t *testing.T) {
+		t.Parallel()
+
+		errs := []error{serializationErr, deadlockErr, nil}
+		calls := 0
+
+		err := retry(context.Background(), 3, func() error {
+			calls++
+
+			return errs[calls-1]
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		t.Parallel()
+
+		calls := 0
+
+		err := retry(context.Background(), 3, func() error {
+			calls++
+
+			return serializationErr
+		})
+		assert.ErrorIs(t, err, serializationErr)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		t.Parallel()
+
+		calls := 0
+
+		err := retry(context.Background(), 3, func() error {
+			calls++
+
+			return model.ErrBadRequest
+		})
+		assert.ErrorIs(t, err, model.ErrBadRequest)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("stops when context is done", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+
+		err := retry(ctx, 3, func() error {
+			calls++
+			cancel()
+
+			return deadlockErr
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	for attempt := 1; attempt < 10; attempt++ {
+		delay := backoff(attempt)
+		assert.Positive(t, delay)
+		assert.LessOrEqual(t, delay, retryMaxDelay)
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("positive balance violation", func(t *testing.T) {
+		t.Parallel()
+
+		err := fmt.Errorf("update balance: %w", &pgconn.PgError{
+			Code:           codeCheckViolation,
+			ConstraintName: constraintPositiveBalance,
+		})
+		assert.ErrorIs(t, translateError(err), model.ErrInsufficientFunds)
+	})
+
+	t.Run("non-negative stock violation", func(t *testing.T) {
+		t.Parallel()
+
+		err := fmt.Errorf("update stock: %w", &pgconn.PgError{
+			Code:           codeCheckViolation,
+			ConstraintName: constraintNonNegativeStock,
+		})
+		assert.ErrorIs(t, translateError(err), model.ErrOutOfStock)
+	})
+
+	t.Run("other errors are kept", func(t *testing.T) {
+		t.Parallel()
+
+		err := &pgconn.PgError{Code: codeCheckViolation, ConstraintName: "positive_price"}
+		assert.Equal(t, err, translateError(err))
+		assert.NoError(t, translateError(nil))
+		assert.False(t, errors.Is(translateError(model.ErrNotFound), model.ErrInsufficientFunds))
+	})
+}
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 )
@@ -13,7 +15,7 @@ func (r *repo) FindUser(ctx context.Context, tx DB, username string) (*model.Use
 	var user model.User
 
 	err := db.QueryRow(ctx, `
-		SELECT id, username, password, salt, balance 
+		SELECT id, username, password, salt, balance, COALESCE(referral_code, ''), deactivated_at
 		FROM users 
 		WHERE username = $1;
 	`, username).Scan(
@@ -22,6 +24,8 @@ func (r *repo) FindUser(ctx context.Context, tx DB, username string) (*model.Use
 		&user.Password,
 		&user.Salt,
 		&user.Balance,
+		&user.ReferralCode,
+		&user.DeactivatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("select user: %w", err)
@@ -30,16 +34,211 @@ func (r *repo) FindUser(ctx context.Context, tx DB, username string) (*model.Use
 	return &user, nil
 }
 
+// FindUserForUpdate is FindUser that also locks the user row until tx ends.
+func (r *repo) FindUserForUpdate(ctx context.Context, tx DB, username string) (*model.User, error) {
+	db := r.getExecutor(tx)
+
+	var user model.User
+
+	err := db.QueryRow(ctx, `
+		SELECT id, username, password, salt, balance, COALESCE(referral_code, ''), deactivated_at
+		FROM users
+		WHERE username = $1
+		FOR UPDATE;
+	`, username).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Password,
+		&user.Salt,
+		&user.Balance,
+		&user.ReferralCode,
+		&user.DeactivatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select user for update: %w", err)
+	}
+
+	return &user, nil
+}
+
+// FindUserByReferralCode returns the user with the given referral code.
+func (r *repo) FindUserByReferralCode(ctx context.Context, tx DB, code string) (*model.User, error) {
+	db := r.getExecutor(tx)
+
+	var user model.User
+
+	err := db.QueryRow(ctx, `
+		SELECT id, username, password, salt, balance, COALESCE(referral_code, ''), deactivated_at
+		FROM users
+		WHERE referral_code = $1;
+	`, code).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Password,
+		&user.Salt,
+		&user.Balance,
+		&user.ReferralCode,
+		&user.DeactivatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select user by referral code: %w", err)
+	}
+
+	return &user, nil
+}
+
+// FindUsersForUpdate locks the rows of the given users in ID order, so that
+// concurrent transactions locking overlapping sets of users can not deadlock.
+// Users that do not exist are missing from the result.
+func (r *repo) FindUsersForUpdate(ctx context.Context, tx DB, usernames ...string) (map[string]*model.User, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT id, username, password, salt, balance, COALESCE(referral_code, ''), deactivated_at
+		FROM users
+		WHERE username = ANY($1)
+		ORDER BY id
+		FOR UPDATE;
+	`, usernames)
+	if err != nil {
+		return nil, fmt.Errorf("select users for update: %w", err)
+	}
+	defer rows.Close()
+
+	users := make(map[string]*model.User, len(usernames))
+
+	for rows.Next() {
+		var user model.User
+
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Password,
+			&user.Salt,
+			&user.Balance,
+			&user.ReferralCode,
+			&user.DeactivatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+
+		users[user.Username] = &user
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate users: %w", err)
+	}
+
+	return users, nil
+}
+
+// CreateUser stores the user with the starting balance, which is recorded as
+// a signup entry in the ledger.
 func (r *repo) CreateUser(ctx context.Context, tx DB, user *model.User) error {
 	db := r.getExecutor(tx)
 
 	_, err := db.Exec(ctx, `
-		INSERT INTO users (username, password, salt) 
-		VALUES ($1, $2, $3);
-	`, user.Username, user.Password, user.Salt)
+		WITH created AS (
+			INSERT INTO users (username, password, salt, balance, referral_code) 
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+			RETURNING id, balance
+		)
+		INSERT INTO ledger_entries (user_id, kind, amount)
+		SELECT id, $6, balance
+		FROM created
+		WHERE balance > 0;
+	`, user.Username, user.Password, user.Salt, user.Balance, user.ReferralCode, model.LedgerEntrySignup)
 	if err != nil {
 		return fmt.Errorf("insert user: %w", err)
 	}
 
 	return nil
 }
+
+// ListUsers returns up to limit users ordered by username, starting after the
+// given username.
+func (r *repo) ListUsers(ctx context.Context, tx DB, after string, limit int) ([]model.User, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT id, username, password, salt, balance, COALESCE(referral_code, ''), deactivated_at
+		FROM users
+		WHERE username > $1
+		ORDER BY username
+		LIMIT $2;
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []model.User
+
+	for rows.Next() {
+		var user model.User
+
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Password,
+			&user.Salt,
+			&user.Balance,
+			&user.ReferralCode,
+			&user.DeactivatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate users: %w", err)
+	}
+
+	return users, nil
+}
+
+// AddBalance changes the balance of the user by amount, which may be negative
+// but may not take the balance below zero.
+func (r *repo) AddBalance(ctx context.Context, tx DB, userID, amount int) error {
+	db := r.getExecutor(tx)
+
+	tag, err := db.Exec(ctx, `
+		UPDATE users
+		SET balance = balance + $2
+		WHERE id = $1;
+	`, userID, amount)
+	if err != nil {
+		return fmt.Errorf("update balance: %w", translateError(err))
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("update balance of %d: %w", userID, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// SetUserDeactivatedAt deactivates the user at the given time, or activates
+// them again if it is nil.
+func (r *repo) SetUserDeactivatedAt(ctx context.Context, tx DB, userID int, at *time.Time) error {
+	db := r.getExecutor(tx)
+
+	tag, err := db.Exec(ctx, `
+		UPDATE users
+		SET deactivated_at = $2
+		WHERE id = $1;
+	`, userID, at)
+	if err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("update user %d: %w", userID, sql.ErrNoRows)
+	}
+
+	return nil
+}
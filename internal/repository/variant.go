@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+const variantColumns = `id, item_id, size, color, price_delta, stock`
+
+// CreateVariant adds the variant to its item and sets its ID. The attributes
+// of the variants of an item are unique.
+func (r *repo) CreateVariant(ctx context.Context, tx DB, variant *model.Variant) error {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		INSERT INTO item_variants (item_id, size, color, price_delta, stock)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;
+	`, variant.ItemID, variant.Size, variant.Color, variant.PriceDelta, variant.Stock).Scan(&variant.ID)
+	if err != nil {
+		return fmt.Errorf("insert variant: %w", err)
+	}
+
+	return nil
+}
+
+// FindVariant returns the variant of the item with the attributes.
+func (r *repo) FindVariant(
+	ctx context.Context, tx DB, itemID int, attrs model.VariantAttributes,
+) (*model.Variant, error) {
+	db := r.getExecutor(tx)
+
+	var variant model.Variant
+
+	err := db.QueryRow(ctx, `
+		SELECT `+variantColumns+`
+		FROM item_variants
+		WHERE item_id = $1 AND size = $2 AND color = $3;
+	`, itemID, attrs.Size, attrs.Color).Scan(
+		&variant.ID,
+		&variant.ItemID,
+		&variant.Size,
+		&variant.Color,
+		&variant.PriceDelta,
+		&variant.Stock,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select variant: %w", err)
+	}
+
+	return &variant, nil
+}
+
+// ListVariants returns the variants of the item in the order they were
+// added.
+func (r *repo) ListVariants(ctx context.Context, tx DB, itemID int) ([]model.Variant, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+variantColumns+`
+		FROM item_variants
+		WHERE item_id = $1
+		ORDER BY id;
+	`, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("select variants: %w", err)
+	}
+	defer rows.Close()
+
+	var variants []model.Variant
+
+	for rows.Next() {
+		var variant model.Variant
+
+		err := rows.Scan(
+			&variant.ID,
+			&variant.ItemID,
+			&variant.Size,
+			&variant.Color,
+			&variant.PriceDelta,
+			&variant.Stock,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan variant: %w", err)
+		}
+
+		variants = append(variants, variant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate variants: %w", err)
+	}
+
+	return variants, nil
+}
+
+// SetVariantStock sets the number of items of the variant left, nil means
+// the variant never runs out.
+func (r *repo) SetVariantStock(ctx context.Context, tx DB, variantID int, stock *int) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `UPDATE item_variants SET stock = $2 WHERE id = $1;`, variantID, stock)
+	if err != nil {
+		return fmt.Errorf("update variant stock: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+func (r *repo) CreateWebhook(ctx context.Context, tx DB, webhook *model.Webhook) error {
+	db := r.getExecutor(tx)
+
+	err := db.QueryRow(ctx, `
+		INSERT INTO webhooks (url, secret, event_types)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at;
+	`, webhook.URL, webhook.Secret, eventTypesToStrings(webhook.EventTypes)).Scan(
+		&webhook.ID,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert webhook: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repo) FindWebhook(ctx context.Context, tx DB, id int) (*model.Webhook, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT id, url, secret, event_types, created_at
+		FROM webhooks
+		WHERE id = $1;
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("select webhook: %w", err)
+	}
+
+	webhooks, err := scanWebhooks(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(webhooks) == 0 {
+		return nil, fmt.Errorf("select webhook: %w", sql.ErrNoRows)
+	}
+
+	return &webhooks[0], nil
+}
+
+func (r *repo) ListWebhooks(ctx context.Context, tx DB) ([]model.Webhook, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT id, url, secret, event_types, created_at
+		FROM webhooks
+		ORDER BY id;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("select webhooks: %w", err)
+	}
+
+	return scanWebhooks(rows)
+}
+
+func (r *repo) DeleteWebhook(ctx context.Context, tx DB, id int) error {
+	db := r.getExecutor(tx)
+
+	tag, err := db.Exec(ctx, `
+		DELETE FROM webhooks
+		WHERE id = $1;
+	`, id)
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("delete webhook: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// CreateWebhookDeliveries queues event for every webhook subscribed to its
+// type. Queuing the same event again has no effect.
+func (r *repo) CreateWebhookDeliveries(ctx context.Context, tx DB, event model.Event) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type)
+		SELECT id, $1, $2
+		FROM webhooks
+		WHERE $2 = ANY(event_types)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING;
+	`, event.ID, event.Type)
+	if err != nil {
+		return fmt.Errorf("insert webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due
+// and postpones their next attempt by lease, so that other workers skip them
+// while they are being sent.
+func (r *repo) ClaimWebhookDeliveries(
+	ctx context.Context, tx DB, limit int, lease time.Duration,
+) ([]model.WebhookDelivery, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = now() + $2::interval
+		WHERE id IN (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns+`;
+	`, limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+
+	return scanDeliveries(rows)
+}
+
+func (r *repo) FindWebhookDelivery(ctx context.Context, tx DB, id int64) (*model.WebhookDelivery, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE id = $1;
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("select webhook delivery: %w", err)
+	}
+
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("select webhook delivery: %w", sql.ErrNoRows)
+	}
+
+	return &deliveries[0], nil
+}
+
+// ListWebhookDeliveries returns the latest deliveries of a webhook, newest
+// first.
+func (r *repo) ListWebhookDeliveries(
+	ctx context.Context, tx DB, webhookID int, limit int,
+) ([]model.WebhookDelivery, error) {
+	db := r.getExecutor(tx)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2;
+	`, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select webhook deliveries: %w", err)
+	}
+
+	return scanDeliveries(rows)
+}
+
+func (r *repo) UpdateWebhookDelivery(ctx context.Context, tx DB, delivery *model.WebhookDelivery) error {
+	db := r.getExecutor(tx)
+
+	_, err := db.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2,
+			attempts = $3,
+			next_attempt_at = $4,
+			last_error = $5,
+			response_status = $6,
+			delivered_at = $7
+		WHERE id = $1;
+	`,
+		delivery.ID,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastError,
+		delivery.ResponseStatus,
+		delivery.DeliveredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("update webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repo) FindEvent(ctx context.Context, tx DB, id int64) (*model.Event, error) {
+	db := r.getExecutor(tx)
+
+	var event model.Event
+
+	err := scanEvent(db.QueryRow(ctx, `
+		SELECT `+eventColumns+`
+		FROM outbox
+		WHERE id = $1;
+	`, id), &event)
+	if err != nil {
+		return nil, fmt.Errorf("select event: %w", err)
+	}
+
+	return &event, nil
+}
+
+const deliveryColumns = `
+	id, webhook_id, event_id, event_type, status, attempts, next_attempt_at,
+	last_error, response_status, created_at, delivered_at`
+
+func scanDeliveries(rows pgx.Rows) ([]model.WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+
+	for rows.Next() {
+		var d model.WebhookDelivery
+
+		err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.EventID,
+			&d.EventType,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&d.LastError,
+			&d.ResponseStatus,
+			&d.CreatedAt,
+			&d.DeliveredAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func scanWebhooks(rows pgx.Rows) ([]model.Webhook, error) {
+	defer rows.Close()
+
+	var webhooks []model.Webhook
+
+	for rows.Next() {
+		var (
+			w          model.Webhook
+			eventTypes []string
+		)
+
+		err := rows.Scan(&w.ID, &w.URL, &w.Secret, &eventTypes, &w.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook: %w", err)
+		}
+
+		for _, t := range eventTypes {
+			w.EventTypes = append(w.EventTypes, model.EventType(t))
+		}
+
+		webhooks = append(webhooks, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+func eventTypesToStrings(types []model.EventType) []string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = string(t)
+	}
+
+	return s
+}
@@ -0,0 +1,130 @@
+// Package scheduler runs background jobs on cron schedules. Every replica runs
+// a scheduler, an advisory lock per job makes sure only one of them runs it.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/cron"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+// Job is work due at the times of a schedule, in UTC.
+type Job struct {
+	Name     string
+	Schedule *cron.Schedule
+	// Run does the work due at the given scheduled time. The same time is
+	// passed again if Run fails or the replica stops before the run is
+	// recorded, so Run must do the work for a time at most once.
+	Run func(ctx context.Context, at time.Time) error
+}
+
+type Scheduler struct {
+	repo     repository.Repository
+	log      *slog.Logger
+	interval time.Duration
+	jobs     []Job
+}
+
+// New returns a scheduler that checks for due jobs every interval.
+func New(repo repository.Repository, log *slog.Logger, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		repo:     repo,
+		log:      log,
+		interval: interval,
+	}
+}
+
+// Add registers job, it must be called before Run.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Run runs due jobs until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	if len(s.jobs) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.Tick(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick runs the jobs due at now.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) {
+	for _, job := range s.jobs {
+		if err := s.runJob(ctx, job, now.UTC()); err != nil {
+			s.log.Error("run scheduled job", "job", job.Name, "error", err)
+		}
+	}
+}
+
+// runJob runs job for the latest scheduled time since its last run. Earlier
+// missed times are skipped, e.g. after all replicas were down for a while. A
+// job that never ran is only recorded, so it first runs at its next time
+// rather than right after being added.
+func (s *Scheduler) runJob(ctx context.Context, job Job, now time.Time) error {
+	release, locked, err := s.repo.TryLock(ctx, lockKey(job.Name))
+	if err != nil || !locked {
+		return err
+	}
+	defer release()
+
+	last, err := s.repo.FindJobRun(ctx, nil, job.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.repo.SaveJobRun(ctx, nil, job.Name, now)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var due time.Time
+
+	skipped := -1
+
+	for next := job.Schedule.Next(last.UTC()); !next.IsZero() && !next.After(now); next = job.Schedule.Next(next) {
+		due = next
+		skipped++
+	}
+
+	if due.IsZero() {
+		return nil
+	}
+
+	if skipped > 0 {
+		s.log.Warn("skipped missed runs of scheduled job", "job", job.Name, "skipped", skipped)
+	}
+
+	s.log.Info("running scheduled job", "job", job.Name, "at", due)
+
+	if err := job.Run(ctx, due); err != nil {
+		return fmt.Errorf("run for %s: %w", due.Format(time.RFC3339), err)
+	}
+
+	return s.repo.SaveJobRun(ctx, nil, job.Name, due)
+}
+
+// lockKey derives the advisory lock of a job from its name.
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("scheduler:" + name))
+
+	return int64(h.Sum64()) //nolint:gosec // any 64 bits make a key
+}
@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/cron"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errJob = errors.New("job failed")
+
+type recorder struct {
+	runs []time.Time
+	err  error
+}
+
+func (r *recorder) run(_ context.Context, at time.Time) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.runs = append(r.runs, at)
+
+	return nil
+}
+
+func newJob(t *testing.T, rec *recorder) Job {
+	t.Helper()
+
+	schedule, err := cron.Parse("@daily")
+	require.NoError(t, err)
+
+	return Job{Name: "test", Schedule: schedule, Run: rec.run}
+}
+
+func day(d int, hour int) time.Time {
+	return time.Date(2025, time.January, d, hour, 0, 0, 0, time.UTC)
+}
+
+func TestScheduler_Tick(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("runs due jobs once", func(t *testing.T) {
+		t.Parallel()
+
+		rec := &recorder{}
+		s := New(memory.New(), slog.Default(), time.Minute)
+		s.Add(newJob(t, rec))
+
+		s.Tick(ctx, day(1, 12))
+		assert.Empty(t, rec.runs, "first tick only records the job")
+
+		s.Tick(ctx, day(1, 18))
+		assert.Empty(t, rec.runs)
+
+		s.Tick(ctx, day(2, 0))
+		s.Tick(ctx, day(2, 1))
+		assert.Equal(t, []time.Time{day(2, 0)}, rec.runs)
+	})
+
+	t.Run("skips missed runs", func(t *testing.T) {
+		t.Parallel()
+
+		rec := &recorder{}
+		s := New(memory.New(), slog.Default(), time.Minute)
+		s.Add(newJob(t, rec))
+
+		s.Tick(ctx, day(1, 12))
+		s.Tick(ctx, day(5, 12))
+		assert.Equal(t, []time.Time{day(5, 0)}, rec.runs)
+	})
+
+	t.Run("retries failed runs", func(t *testing.T) {
+		t.Parallel()
+
+		rec := &recorder{err: errJob}
+		s := New(memory.New(), slog.Default(), time.Minute)
+		s.Add(newJob(t, rec))
+
+		s.Tick(ctx, day(1, 12))
+		s.Tick(ctx, day(2, 1))
+		assert.Empty(t, rec.runs)
+
+		rec.err = nil
+		s.Tick(ctx, day(2, 2))
+		assert.Equal(t, []time.Time{day(2, 0)}, rec.runs)
+	})
+
+	t.Run("one replica runs a job", func(t *testing.T) {
+		t.Parallel()
+
+		repo := memory.New()
+		rec := &recorder{}
+		s := New(repo, slog.Default(), time.Minute)
+		s.Add(newJob(t, rec))
+
+		s.Tick(ctx, day(1, 12))
+
+		release, locked, err := repo.TryLock(ctx, lockKey("test"))
+		require.NoError(t, err)
+		require.True(t, locked)
+
+		s.Tick(ctx, day(2, 1))
+		assert.Empty(t, rec.runs, "job is locked by another replica")
+
+		release()
+
+		s.Tick(ctx, day(2, 1))
+		assert.Equal(t, []time.Time{day(2, 0)}, rec.runs)
+	})
+}
@@ -0,0 +1,622 @@
+// Package admin runs the operator tasks of shopctl and the admin API.
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/service"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ service.Admin = (*Service)(nil)
+
+const (
+	// MaxReasonLength is the maximum length of the reason of a mint or burn.
+	MaxReasonLength = 500
+	// maxDiscrepancies bounds the users listed by Reconcile.
+	maxDiscrepancies = 100
+)
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+type Service struct {
+	repo repository.Repository
+	// limits are the global transfer limits.
+	limits model.TransferLimits
+}
+
+func NewService(repo repository.Repository, limits model.TransferLimits) *Service {
+	return &Service{repo: repo, limits: limits}
+}
+
+func (s *Service) GetUser(ctx context.Context, username string) (*model.User, error) {
+	user, err := s.repo.FindUser(ctx, nil, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: user %q", model.ErrNotFound, username)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *Service) ListUsers(ctx context.Context, after string, limit int) ([]model.User, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive", model.ErrBadRequest)
+	}
+
+	return s.repo.ListUsers(ctx, nil, after, limit)
+}
+
+// Mint credits amount coins from the system account to the user. The reason
+// is recorded with the ledger entry.
+func (s *Service) Mint(
+	ctx context.Context, username string, amount int, reason string, dryRun bool,
+) (*model.User, error) {
+	return s.moveCoins(ctx, username, model.LedgerEntryMint, amount, reason, dryRun)
+}
+
+// Burn debits amount coins from the user to the system account, the balance
+// may not go below zero. The reason is recorded with the ledger entry.
+func (s *Service) Burn(
+	ctx context.Context, username string, amount int, reason string, dryRun bool,
+) (*model.User, error) {
+	return s.moveCoins(ctx, username, model.LedgerEntryBurn, amount, reason, dryRun)
+}
+
+// moveCoins records a ledger entry of the given kind and changes the balance
+// by amount, which is subtracted for burns. The user is locked the same way
+// Transfer locks its users.
+func (s *Service) moveCoins(
+	ctx context.Context, username string, kind model.LedgerEntryKind, amount int, reason string, dryRun bool,
+) (*model.User, error) {
+	reason = strings.TrimSpace(reason)
+
+	switch {
+	case amount <= 0:
+		return nil, fmt.Errorf("%w: amount must be positive", model.ErrBadRequest)
+	case reason == "":
+		return nil, fmt.Errorf("%w: reason is required", model.ErrBadRequest)
+	case utf8.RuneCountInString(reason) > MaxReasonLength:
+		return nil, fmt.Errorf("%w: reason is longer than %d characters", model.ErrBadRequest, MaxReasonLength)
+	}
+
+	if kind == model.LedgerEntryBurn {
+		amount = -amount
+	}
+
+	var user *model.User
+
+	err := s.withTx(ctx, dryRun, func(tx repository.DB) (err error) {
+		if user, err = s.lockUser(ctx, tx, username); err != nil {
+			return err
+		}
+
+		if user.Balance+amount < 0 {
+			return fmt.Errorf("%w: need %d coins, has %d", model.ErrInsufficientFunds, -amount, user.Balance)
+		}
+
+		entry := &model.LedgerEntry{UserID: user.ID, Kind: kind, Amount: amount, Reason: reason}
+		if _, err := s.repo.AddLedgerEntry(ctx, tx, entry); err != nil {
+			return err
+		}
+
+		if err := s.repo.AddBalance(ctx, tx, user.ID, amount); err != nil {
+			return err
+		}
+
+		notifications, err := moveNotifications(user, model.SystemAccount, amount)
+		if err != nil {
+			return err
+		}
+
+		user.Balance += amount
+
+		return s.repo.AddNotifications(ctx, tx, notifications...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// moveNotifications tell the user about the new balance and about coins
+// received from the given account. The user holds the balance from before the
+// change.
+func moveNotifications(user *model.User, from string, amount int) ([]*model.Notification, error) {
+	balance, err := model.NewNotification(model.NotificationBalanceChanged, user.ID, model.BalanceChanged{
+		Balance: user.Balance + amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if amount < 0 {
+		return []*model.Notification{balance}, nil
+	}
+
+	received, err := model.NewNotification(model.NotificationCoinsReceived, user.ID, model.CoinsReceived{
+		FromUser: from,
+		Amount:   amount,
+		Reversal: from != model.SystemAccount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []*model.Notification{received, balance}, nil
+}
+
+// ListUserTransfers returns up to limit transfers the user sent or received,
+// newest first, with the coins reversed so far.
+func (s *Service) ListUserTransfers(ctx context.Context, username string, limit int) ([]model.Transfer, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive", model.ErrBadRequest)
+	}
+
+	user, err := s.GetUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListUserTransfers(ctx, nil, user.ID, limit)
+}
+
+// ReverseTransfer moves the coins of a transfer that were not reversed yet
+// back from the receiver to the sender, recording a pair of reversal ledger
+// entries linked to the transfer. Balances may not go below zero: if the
+// receiver has already spent some of the coins, the reversal fails unless
+// partial is set, in which case it moves back what the receiver has and the
+// rest can be reversed later.
+func (s *Service) ReverseTransfer(
+	ctx context.Context, id int64, reason string, partial, dryRun bool,
+) (*model.Transfer, error) {
+	reason = strings.TrimSpace(reason)
+
+	switch {
+	case reason == "":
+		return nil, fmt.Errorf("%w: reason is required", model.ErrBadRequest)
+	case utf8.RuneCountInString(reason) > MaxReasonLength:
+		return nil, fmt.Errorf("%w: reason is longer than %d characters", model.ErrBadRequest, MaxReasonLength)
+	}
+
+	var transfer *model.Transfer
+
+	err := s.withTx(ctx, dryRun, func(tx repository.DB) (err error) {
+		transfer, err = s.repo.FindTransferForUpdate(ctx, tx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: transfer %d", model.ErrNotFound, id)
+		}
+
+		if err != nil {
+			return fmt.Errorf("find transfer: %w", err)
+		}
+
+		amount := transfer.Amount - transfer.Reversed
+		if amount == 0 {
+			return fmt.Errorf("%w: transfer %d is already reversed", model.ErrConflict, id)
+		}
+
+		users, err := s.repo.FindUsersForUpdate(ctx, tx, transfer.FromUser, transfer.ToUser)
+		if err != nil {
+			return fmt.Errorf("lock users: %w", err)
+		}
+
+		sender, receiver := users[transfer.FromUser], users[transfer.ToUser]
+
+		if receiver.Balance < amount {
+			if !partial || receiver.Balance == 0 {
+				return fmt.Errorf("%w: receiver has %d of %d coins", model.ErrInsufficientFunds, receiver.Balance, amount)
+			}
+
+			amount = receiver.Balance
+		}
+
+		if err := s.reverse(ctx, tx, transfer, sender, receiver, amount, reason); err != nil {
+			return err
+		}
+
+		transfer.Reversed += amount
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// reverse moves amount coins of transfer from the locked receiver back to the
+// locked sender and tells both about it.
+func (s *Service) reverse(
+	ctx context.Context, tx repository.DB, transfer *model.Transfer, sender, receiver *model.User,
+	amount int, reason string,
+) error {
+	var notifications []*model.Notification
+
+	changes := []struct {
+		user   *model.User
+		from   string
+		amount int
+	}{
+		{user: receiver, from: sender.Username, amount: -amount},
+		{user: sender, from: receiver.Username, amount: amount},
+	}
+
+	for _, change := range changes {
+		entry := &model.LedgerEntry{
+			UserID:     change.user.ID,
+			Kind:       model.LedgerEntryReversal,
+			Amount:     change.amount,
+			Reason:     reason,
+			TransferID: transfer.ID,
+		}
+		if _, err := s.repo.AddLedgerEntry(ctx, tx, entry); err != nil {
+			return err
+		}
+
+		if err := s.repo.AddBalance(ctx, tx, change.user.ID, change.amount); err != nil {
+			return err
+		}
+
+		n, err := moveNotifications(change.user, change.from, change.amount)
+		if err != nil {
+			return err
+		}
+
+		notifications = append(notifications, n...)
+	}
+
+	return s.repo.AddNotifications(ctx, tx, notifications...)
+}
+
+// DeactivateUser stops the user from logging in, buying items and
+// transferring coins. Deactivating a deactivated user changes nothing.
+func (s *Service) DeactivateUser(ctx context.Context, username string, dryRun bool) (*model.User, error) {
+	now := time.Now()
+
+	return s.setDeactivatedAt(ctx, username, &now, dryRun)
+}
+
+// ActivateUser reverts DeactivateUser.
+func (s *Service) ActivateUser(ctx context.Context, username string, dryRun bool) (*model.User, error) {
+	return s.setDeactivatedAt(ctx, username, nil, dryRun)
+}
+
+func (s *Service) setDeactivatedAt(
+	ctx context.Context, username string, at *time.Time, dryRun bool,
+) (*model.User, error) {
+	var user *model.User
+
+	err := s.withTx(ctx, dryRun, func(tx repository.DB) (err error) {
+		if user, err = s.lockUser(ctx, tx, username); err != nil {
+			return err
+		}
+
+		if user.Active() == (at == nil) {
+			return nil
+		}
+
+		if err := s.repo.SetUserDeactivatedAt(ctx, tx, user.ID, at); err != nil {
+			return err
+		}
+
+		user.DeactivatedAt = at
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *Service) ListItems(ctx context.Context) ([]model.Item, error) {
+	return s.repo.ListItems(ctx, nil)
+}
+
+func (s *Service) CreateItem(ctx context.Context, name string, price int, dryRun bool) (*model.Item, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", model.ErrBadRequest)
+	}
+
+	if price <= 0 {
+		return nil, fmt.Errorf("%w: price must be positive", model.ErrBadRequest)
+	}
+
+	item := &model.Item{Name: name, Price: price}
+
+	err := s.withTx(ctx, dryRun, func(tx repository.DB) error {
+		_, err := s.repo.FindItem(ctx, tx, name)
+		if err == nil {
+			return fmt.Errorf("%w: item %q already exists", model.ErrConflict, name)
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("find item: %w", err)
+		}
+
+		return s.repo.CreateItem(ctx, tx, item)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// SetItemStock sets the number of items left, nil means the item never runs
+// out. Items with variants are stocked per variant.
+func (s *Service) SetItemStock(ctx context.Context, name string, stock *int, dryRun bool) (*model.Item, error) {
+	if stock != nil && *stock < 0 {
+		return nil, fmt.Errorf("%w: stock must not be negative", model.ErrBadRequest)
+	}
+
+	var item *model.Item
+
+	err := s.withTx(ctx, dryRun, func(tx repository.DB) (err error) {
+		item, err = s.findItem(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		variants, err := s.repo.ListVariants(ctx, tx, item.ID)
+		if err != nil {
+			return err
+		}
+
+		if len(variants) > 0 {
+			return fmt.Errorf("%w: %q has variants, set the stock of a variant", model.ErrBadRequest, name)
+		}
+
+		item.Stock = stock
+
+		return s.repo.SetItemStock(ctx, tx, item.ID, stock)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// ListVariants returns the variants of the item in the order they were
+// added.
+func (s *Service) ListVariants(ctx context.Context, name string) ([]model.Variant, error) {
+	item, err := s.findItem(ctx, nil, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListVariants(ctx, nil, item.ID)
+}
+
+// CreateVariant adds a variant to the item. Once an item has variants, it
+// can only be bought as one of them.
+func (s *Service) CreateVariant(
+	ctx context.Context, name string, variant model.Variant, dryRun bool,
+) (*model.Variant, error) {
+	if err := validateVariantAttributes(variant.VariantAttributes); err != nil {
+		return nil, err
+	}
+
+	if variant.Stock != nil && *variant.Stock < 0 {
+		return nil, fmt.Errorf("%w: stock must not be negative", model.ErrBadRequest)
+	}
+
+	err := s.withTx(ctx, dryRun, func(tx repository.DB) error {
+		item, err := s.findItem(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		if item.Price+variant.PriceDelta <= 0 {
+			return fmt.Errorf("%w: price must be positive", model.ErrBadRequest)
+		}
+
+		_, err = s.repo.FindVariant(ctx, tx, item.ID, variant.VariantAttributes)
+		if err == nil {
+			return fmt.Errorf("%w: %q already has variant %s", model.ErrConflict, name, variant.VariantAttributes)
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("find variant: %w", err)
+		}
+
+		variant.ItemID = item.ID
+
+		return s.repo.CreateVariant(ctx, tx, &variant)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &variant, nil
+}
+
+// SetVariantStock sets the number of items of the variant left, nil means
+// the variant never runs out.
+func (s *Service) SetVariantStock(
+	ctx context.Context, name string, attrs model.VariantAttributes, stock *int, dryRun bool,
+) (*model.Variant, error) {
+	if stock != nil && *stock < 0 {
+		return nil, fmt.Errorf("%w: stock must not be negative", model.ErrBadRequest)
+	}
+
+	var variant *model.Variant
+
+	err := s.withTx(ctx, dryRun, func(tx repository.DB) error {
+		item, err := s.findItem(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		variant, err = s.repo.FindVariant(ctx, tx, item.ID, attrs)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %q has no variant %s", model.ErrNotFound, name, attrs)
+		}
+
+		if err != nil {
+			return fmt.Errorf("find variant: %w", err)
+		}
+
+		variant.Stock = stock
+
+		return s.repo.SetVariantStock(ctx, tx, variant.ID, stock)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return variant, nil
+}
+
+func (s *Service) findItem(ctx context.Context, tx repository.DB, name string) (*model.Item, error) {
+	item, err := s.repo.FindItem(ctx, tx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: item %q", model.ErrNotFound, name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("find item: %w", err)
+	}
+
+	return item, nil
+}
+
+func validateVariantAttributes(attrs model.VariantAttributes) error {
+	if attrs.IsZero() {
+		return fmt.Errorf("%w: size or color is required", model.ErrBadRequest)
+	}
+
+	for _, attr := range []string{attrs.Size, attrs.Color} {
+		if utf8.RuneCountInString(attr) > model.MaxVariantAttributeLength {
+			return fmt.Errorf("%w: size and color are limited to %d characters",
+				model.ErrBadRequest, model.MaxVariantAttributeLength)
+		}
+	}
+
+	return nil
+}
+
+// TransferLimits returns the transfer limits of the user.
+func (s *Service) TransferLimits(ctx context.Context, username string) (*model.UserTransferLimits, error) {
+	user, err := s.GetUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	override, err := s.repo.FindTransferLimits(ctx, nil, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return s.userTransferLimits(user, override), nil
+}
+
+// SetTransferLimits replaces the override of the transfer limits of the
+// user. An empty override restores the global limits.
+func (s *Service) SetTransferLimits(
+	ctx context.Context, username string, override *model.TransferLimitsOverride, dryRun bool,
+) (*model.UserTransferLimits, error) {
+	for _, v := range []*int{override.MaxAmount, override.DailyAmount, override.DailyCount} {
+		if v != nil && *v < 0 {
+			return nil, fmt.Errorf("%w: limits must not be negative", model.ErrBadRequest)
+		}
+	}
+
+	var user *model.User
+
+	err := s.withTx(ctx, dryRun, func(tx repository.DB) (err error) {
+		if user, err = s.lockUser(ctx, tx, username); err != nil {
+			return err
+		}
+
+		return s.repo.SetTransferLimits(ctx, tx, user.ID, override)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if override.Empty() {
+		override = nil
+	}
+
+	return s.userTransferLimits(user, override), nil
+}
+
+func (s *Service) userTransferLimits(
+	user *model.User, override *model.TransferLimitsOverride,
+) *model.UserTransferLimits {
+	return &model.UserTransferLimits{
+		Username: user.Username,
+		Limits:   s.limits.With(override),
+		Override: override,
+	}
+}
+
+// Reconcile checks that the balances of the users add up with the ledger of
+// the system account. All sums are taken from the same snapshot.
+func (s *Service) Reconcile(ctx context.Context) (*model.Reconciliation, error) {
+	var result *model.Reconciliation
+
+	err := s.repo.WithTx(ctx, func(tx repository.DB) (err error) {
+		result, err = s.repo.Reconcile(ctx, tx, maxDiscrepancies)
+
+		return err
+	}, repository.WithIsoLevel(pgx.RepeatableRead))
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *Service) lockUser(ctx context.Context, tx repository.DB, username string) (*model.User, error) {
+	users, err := s.repo.FindUsersForUpdate(ctx, tx, username)
+	if err != nil {
+		return nil, fmt.Errorf("lock user: %w", err)
+	}
+
+	user, ok := users[username]
+	if !ok {
+		return nil, fmt.Errorf("%w: user %q", model.ErrNotFound, username)
+	}
+
+	return user, nil
+}
+
+// withTx runs fn in a transaction that is rolled back after a dry run, so the
+// caller sees what fn would have done without changing anything.
+func (s *Service) withTx(ctx context.Context, dryRun bool, fn func(repository.DB) error) error {
+	err := s.repo.WithTx(ctx, func(tx repository.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+	if dryRun && errors.Is(err, errDryRun) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,539 @@
+package admin
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/repositorytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Mint(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("validation cases", func(t *testing.T) {
+		t.Parallel()
+		s := NewService(memory.New(), model.TransferLimits{})
+
+		_, err := s.Mint(ctx, "alice", 0, "bonus", false)
+		require.ErrorIs(t, err, model.ErrBadRequest)
+
+		_, err = s.Mint(ctx, "alice", 10, " ", false)
+		require.ErrorIs(t, err, model.ErrBadRequest)
+
+		_, err = s.Mint(ctx, "alice", 10, strings.Repeat("x", MaxReasonLength+1), false)
+		require.ErrorIs(t, err, model.ErrBadRequest)
+
+		_, err = s.Mint(ctx, "alice", 10, "bonus", false)
+		assert.ErrorIs(t, err, model.ErrNotFound)
+	})
+
+	t.Run("mints coins", func(t *testing.T) {
+		t.Parallel()
+		repo := memory.New()
+		s := NewService(repo, model.TransferLimits{})
+		alice := repositorytest.NewUser(t, repo, "alice")
+
+		user, err := s.Mint(ctx, "alice", 250, " bug bounty ", false)
+		require.NoError(t, err)
+		assert.Equal(t, 1250, user.Balance)
+
+		stored, err := s.GetUser(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 1250, stored.Balance)
+
+		entries, err := repo.ListLedgerEntries(ctx, nil, alice.ID)
+		require.NoError(t, err)
+		require.Len(t, entries, 2, "signup and mint")
+		assert.Equal(t, model.LedgerEntryMint, entries[1].Kind)
+		assert.Equal(t, 250, entries[1].Amount)
+		assert.Equal(t, "bug bounty", entries[1].Reason)
+
+		history, err := repo.ListTransactions(ctx, nil, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []model.CoinsReceived{{FromUser: model.SystemAccount, Amount: 250}}, history.Received)
+
+		notifications, err := repo.ListNotifications(ctx, nil, alice.ID, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, notifications, 2)
+		assert.Equal(t, model.NotificationCoinsReceived, notifications[0].Type)
+		assert.Equal(t, model.NotificationBalanceChanged, notifications[1].Type)
+	})
+
+	t.Run("dry run changes nothing", func(t *testing.T) {
+		t.Parallel()
+		repo := memory.New()
+		s := NewService(repo, model.TransferLimits{})
+		alice := repositorytest.NewUser(t, repo, "alice")
+
+		user, err := s.Mint(ctx, "alice", 250, "bonus", true)
+		require.NoError(t, err)
+		assert.Equal(t, 1250, user.Balance, "result shows the change")
+
+		stored, err := s.GetUser(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 1000, stored.Balance)
+
+		entries, err := repo.ListLedgerEntries(ctx, nil, alice.ID)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "only the signup entry")
+
+		notifications, err := repo.ListNotifications(ctx, nil, alice.ID, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, notifications)
+	})
+}
+
+func TestService_Burn(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := memory.New()
+	s := NewService(repo, model.TransferLimits{})
+	alice := repositorytest.NewUser(t, repo, "alice")
+
+	_, err := s.Burn(ctx, "alice", -10, "refund", false)
+	require.ErrorIs(t, err, model.ErrBadRequest)
+
+	user, err := s.Burn(ctx, "alice", 400, "refund", false)
+	require.NoError(t, err)
+	assert.Equal(t, 600, user.Balance)
+
+	_, err = s.Burn(ctx, "alice", 601, "refund", false)
+	require.ErrorIs(t, err, model.ErrInsufficientFunds)
+
+	stored, err := s.GetUser(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 600, stored.Balance)
+
+	entries, err := repo.ListLedgerEntries(ctx, nil, alice.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "signup and burn")
+	assert.Equal(t, model.LedgerEntryBurn, entries[1].Kind)
+	assert.Equal(t, -400, entries[1].Amount)
+
+	history, err := repo.ListTransactions(ctx, nil, alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.CoinsSent{{ToUser: model.SystemAccount, Amount: 400}}, history.Sent)
+
+	notifications, err := repo.ListNotifications(ctx, nil, alice.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, model.NotificationBalanceChanged, notifications[0].Type)
+}
+
+func TestService_ReverseTransfer(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	makeTransfer := func(t *testing.T, repo *memory.Repository, sender, receiver *model.User, amount int) int64 {
+		t.Helper()
+
+		transfer := &model.Transfer{SenderID: sender.ID, ReceiverID: receiver.ID, Amount: amount}
+		require.NoError(t, repo.MakeTransfer(ctx, nil, transfer))
+
+		return transfer.ID
+	}
+
+	t.Run("validation cases", func(t *testing.T) {
+		t.Parallel()
+		s := NewService(memory.New(), model.TransferLimits{})
+
+		_, err := s.ReverseTransfer(ctx, 1, " ", false, false)
+		require.ErrorIs(t, err, model.ErrBadRequest)
+
+		_, err = s.ReverseTransfer(ctx, 1, "sent by mistake", false, false)
+		assert.ErrorIs(t, err, model.ErrNotFound)
+	})
+
+	t.Run("reverses a transfer", func(t *testing.T) {
+		t.Parallel()
+		repo := memory.New()
+		s := NewService(repo, model.TransferLimits{})
+		alice, bob := repositorytest.NewUser(t, repo, "alice"), repositorytest.NewUser(t, repo, "bob")
+		id := makeTransfer(t, repo, alice, bob, 300)
+
+		reversed, err := s.ReverseTransfer(ctx, id, "sent by mistake", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, 300, reversed.Reversed)
+
+		for username, balance := range map[string]int{"alice": 1000, "bob": 1000} {
+			user, err := s.GetUser(ctx, username)
+			require.NoError(t, err)
+			assert.Equal(t, balance, user.Balance, username)
+		}
+
+		entries, err := repo.ListLedgerEntries(ctx, nil, bob.ID)
+		require.NoError(t, err)
+		require.Len(t, entries, 2, "signup and reversal")
+		assert.Equal(t, model.LedgerEntryReversal, entries[1].Kind)
+		assert.Equal(t, -300, entries[1].Amount)
+		assert.Equal(t, id, entries[1].TransferID)
+		assert.Equal(t, "sent by mistake", entries[1].Reason)
+
+		history, err := repo.ListTransactions(ctx, nil, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []model.CoinsReceived{{FromUser: "bob", Amount: 300, Reversal: true}}, history.Received)
+
+		notifications, err := repo.ListNotifications(ctx, nil, alice.ID, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, notifications, 2)
+		assert.Equal(t, model.NotificationCoinsReceived, notifications[0].Type)
+
+		_, err = s.ReverseTransfer(ctx, id, "sent by mistake", false, false)
+		require.ErrorIs(t, err, model.ErrConflict)
+
+		result, err := s.Reconcile(ctx)
+		require.NoError(t, err)
+		assert.True(t, result.Balanced())
+		assert.Equal(t, int64(2000), result.Issued, "reversals are not issued coins")
+	})
+
+	t.Run("spent coins", func(t *testing.T) {
+		t.Parallel()
+		repo := memory.New()
+		s := NewService(repo, model.TransferLimits{})
+		alice, bob, carol := repositorytest.NewUser(t, repo, "alice"), repositorytest.NewUser(t, repo, "bob"), repositorytest.NewUser(t, repo, "carol")
+		id := makeTransfer(t, repo, alice, bob, 300)
+		makeTransfer(t, repo, bob, carol, 1200)
+
+		_, err := s.ReverseTransfer(ctx, id, "sent by mistake", false, false)
+		require.ErrorIs(t, err, model.ErrInsufficientFunds)
+
+		reversed, err := s.ReverseTransfer(ctx, id, "sent by mistake", true, true)
+		require.NoError(t, err)
+		assert.Equal(t, 100, reversed.Reversed, "dry run shows the change")
+
+		reversed, err = s.ReverseTransfer(ctx, id, "sent by mistake", true, false)
+		require.NoError(t, err)
+		assert.Equal(t, 100, reversed.Reversed)
+
+		_, err = s.ReverseTransfer(ctx, id, "sent by mistake", true, false)
+		require.ErrorIs(t, err, model.ErrInsufficientFunds, "the receiver has no coins left")
+
+		makeTransfer(t, repo, carol, bob, 500)
+
+		reversed, err = s.ReverseTransfer(ctx, id, "sent by mistake", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, 300, reversed.Reversed, "the rest is reversed later")
+
+		transfers, err := s.ListUserTransfers(ctx, "bob", 10)
+		require.NoError(t, err)
+		require.Len(t, transfers, 3)
+		assert.Equal(t, []int{0, 0, 300}, []int{transfers[0].Reversed, transfers[1].Reversed, transfers[2].Reversed})
+
+		history, err := repo.ListTransactions(ctx, nil, bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []model.CoinsSent{
+			{ToUser: "carol", Amount: 1200},
+			{ToUser: "alice", Amount: 100, Reversal: true},
+			{ToUser: "alice", Amount: 200, Reversal: true},
+		}, history.Sent)
+
+		bobUser, err := s.GetUser(ctx, "bob")
+		require.NoError(t, err)
+		assert.Equal(t, 300, bobUser.Balance)
+	})
+}
+
+func TestService_DeactivateUser(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := memory.New()
+	s := NewService(repo, model.TransferLimits{})
+	repositorytest.NewUser(t, repo, "alice")
+
+	user, err := s.DeactivateUser(ctx, "alice", true)
+	require.NoError(t, err)
+	assert.False(t, user.Active())
+
+	user, err = s.GetUser(ctx, "alice")
+	require.NoError(t, err)
+	assert.True(t, user.Active(), "dry run changes nothing")
+
+	_, err = s.DeactivateUser(ctx, "alice", false)
+	require.NoError(t, err)
+
+	deactivated, err := s.GetUser(ctx, "alice")
+	require.NoError(t, err)
+	require.False(t, deactivated.Active())
+
+	again, err := s.DeactivateUser(ctx, "alice", false)
+	require.NoError(t, err)
+	assert.Equal(t, deactivated.DeactivatedAt, again.DeactivatedAt, "deactivation time is kept")
+
+	user, err = s.ActivateUser(ctx, "alice", false)
+	require.NoError(t, err)
+	assert.True(t, user.Active())
+
+	_, err = s.DeactivateUser(ctx, "bob", false)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+func TestService_ListUsers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := memory.New()
+	s := NewService(repo, model.TransferLimits{})
+
+	for _, username := range []string{"carol", "alice", "bob"} {
+		repositorytest.NewUser(t, repo, username)
+	}
+
+	users, err := s.ListUsers(ctx, "", 2)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, "alice", users[0].Username)
+	assert.Equal(t, "bob", users[1].Username)
+
+	users, err = s.ListUsers(ctx, "bob", 2)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "carol", users[0].Username)
+
+	_, err = s.ListUsers(ctx, "", 0)
+	assert.ErrorIs(t, err, model.ErrBadRequest)
+}
+
+func TestService_CreateItem(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("validation cases", func(t *testing.T) {
+		t.Parallel()
+		s := NewService(memory.New(), model.TransferLimits{})
+
+		_, err := s.CreateItem(ctx, "", 10, false)
+		require.ErrorIs(t, err, model.ErrBadRequest)
+
+		_, err = s.CreateItem(ctx, "mug", 0, false)
+		require.ErrorIs(t, err, model.ErrBadRequest)
+
+		_, err = s.CreateItem(ctx, "cup", 10, false)
+		assert.ErrorIs(t, err, model.ErrConflict)
+	})
+
+	t.Run("creates item", func(t *testing.T) {
+		t.Parallel()
+		s := NewService(memory.New(), model.TransferLimits{})
+
+		_, err := s.CreateItem(ctx, "mug", 30, true)
+		require.NoError(t, err)
+
+		items, err := s.ListItems(ctx)
+		require.NoError(t, err)
+		assert.NotContains(t, itemNames(items), "mug", "dry run changes nothing")
+
+		item, err := s.CreateItem(ctx, "mug", 30, false)
+		require.NoError(t, err)
+		assert.NotZero(t, item.ID)
+
+		items, err = s.ListItems(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, items, *item)
+	})
+}
+
+func TestService_SetItemStock(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := memory.New()
+	s := NewService(repo, model.TransferLimits{})
+
+	stock := 5
+
+	_, err := s.SetItemStock(ctx, "missing", &stock, false)
+	require.ErrorIs(t, err, model.ErrNotFound)
+
+	negative := -1
+	_, err = s.SetItemStock(ctx, "cup", &negative, false)
+	require.ErrorIs(t, err, model.ErrBadRequest)
+
+	item, err := s.SetItemStock(ctx, "cup", &stock, true)
+	require.NoError(t, err)
+	assert.Equal(t, &stock, item.Stock, "dry run shows the change")
+
+	found, err := repo.FindItem(ctx, nil, "cup")
+	require.NoError(t, err)
+	assert.Nil(t, found.Stock)
+
+	_, err = s.SetItemStock(ctx, "cup", &stock, false)
+	require.NoError(t, err)
+
+	found, err = repo.FindItem(ctx, nil, "cup")
+	require.NoError(t, err)
+	assert.Equal(t, &stock, found.Stock)
+}
+
+func TestService_CreateVariant(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := memory.New()
+	s := NewService(repo, model.TransferLimits{})
+
+	stock := 3
+	large := model.Variant{VariantAttributes: model.VariantAttributes{Size: "L"}, PriceDelta: 10, Stock: &stock}
+
+	_, err := s.CreateVariant(ctx, "missing", large, false)
+	require.ErrorIs(t, err, model.ErrNotFound)
+
+	_, err = s.CreateVariant(ctx, "t-shirt", model.Variant{}, false)
+	require.ErrorIs(t, err, model.ErrBadRequest, "size or color is required")
+
+	_, err = s.CreateVariant(ctx, "t-shirt", model.Variant{
+		VariantAttributes: model.VariantAttributes{Size: "S"},
+		PriceDelta:        -1000,
+	}, false)
+	require.ErrorIs(t, err, model.ErrBadRequest, "the price must stay positive")
+
+	_, err = s.CreateVariant(ctx, "t-shirt", large, true)
+	require.NoError(t, err)
+
+	variants, err := s.ListVariants(ctx, "t-shirt")
+	require.NoError(t, err)
+	assert.Empty(t, variants, "dry run changes nothing")
+
+	variant, err := s.CreateVariant(ctx, "t-shirt", large, false)
+	require.NoError(t, err)
+
+	_, err = s.CreateVariant(ctx, "t-shirt", large, false)
+	require.ErrorIs(t, err, model.ErrConflict)
+
+	variants, err = s.ListVariants(ctx, "t-shirt")
+	require.NoError(t, err)
+	assert.Equal(t, []model.Variant{*variant}, variants)
+
+	_, err = s.SetItemStock(ctx, "t-shirt", &stock, false)
+	require.ErrorIs(t, err, model.ErrBadRequest, "items with variants are stocked per variant")
+
+	_, err = s.SetVariantStock(ctx, "t-shirt", model.VariantAttributes{Size: "M"}, nil, false)
+	require.ErrorIs(t, err, model.ErrNotFound)
+
+	variant, err = s.SetVariantStock(ctx, "t-shirt", large.VariantAttributes, nil, false)
+	require.NoError(t, err)
+	assert.Nil(t, variant.Stock)
+}
+
+func itemNames(items []model.Item) []string {
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+
+	return names
+}
+
+func TestService_Reconcile(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := memory.New()
+	s := NewService(repo, model.TransferLimits{})
+	alice := repositorytest.NewUser(t, repo, "alice")
+	bob := repositorytest.NewUser(t, repo, "bob")
+
+	_, err := s.Mint(ctx, "alice", 300, "bonus", false)
+	require.NoError(t, err)
+
+	_, err = s.Burn(ctx, "bob", 200, "refund", false)
+	require.NoError(t, err)
+
+	item, err := repo.FindItem(ctx, nil, "cup")
+	require.NoError(t, err)
+
+	err = repo.WithTx(ctx, func(tx repository.DB) error {
+		transfer := &model.Transfer{SenderID: alice.ID, ReceiverID: bob.ID, Amount: 50}
+		if err := repo.MakeTransfer(ctx, tx, transfer); err != nil {
+			return err
+		}
+
+		return repo.MakePurchase(ctx, tx, &model.Purchase{UserID: bob.ID, ItemID: item.ID, Quantity: 1, Price: item.Price})
+	})
+	require.NoError(t, err)
+
+	result, err := s.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.Balanced())
+	assert.Equal(t, &model.Reconciliation{
+		Issued:        2300,
+		Burned:        int64(200 + item.Price),
+		Balances:      int64(2100 - item.Price),
+		Discrepancies: []model.Discrepancy{},
+	}, result)
+
+	require.NoError(t, repo.AddBalance(ctx, nil, bob.ID, 7))
+
+	result, err = s.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.False(t, result.Balanced())
+	assert.Equal(t, []model.Discrepancy{{
+		Username: "bob",
+		Balance:  int64(857 - item.Price),
+		Expected: int64(850 - item.Price),
+	}}, result.Discrepancies)
+}
+
+func TestService_SetTransferLimits(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := memory.New()
+	s := NewService(repo, model.TransferLimits{MaxAmount: 100, DailyAmount: 500})
+	repositorytest.NewUser(t, repo, "alice")
+
+	limits, err := s.TransferLimits(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, &model.UserTransferLimits{
+		Username: "alice",
+		Limits:   model.TransferLimits{MaxAmount: 100, DailyAmount: 500},
+	}, limits)
+
+	zero, count := 0, 5
+	override := &model.TransferLimitsOverride{MaxAmount: &zero, DailyCount: &count}
+
+	_, err = s.SetTransferLimits(ctx, "alice", override, true)
+	require.NoError(t, err)
+
+	limits, err = s.TransferLimits(ctx, "alice")
+	require.NoError(t, err)
+	assert.Nil(t, limits.Override, "a dry run changes nothing")
+
+	limits, err = s.SetTransferLimits(ctx, "alice", override, false)
+	require.NoError(t, err)
+
+	want := &model.UserTransferLimits{
+		Username: "alice",
+		Limits:   model.TransferLimits{MaxAmount: 0, DailyAmount: 500, DailyCount: 5},
+		Override: override,
+	}
+	assert.Equal(t, want, limits)
+
+	limits, err = s.TransferLimits(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, want, limits)
+
+	limits, err = s.SetTransferLimits(ctx, "alice", &model.TransferLimitsOverride{}, false)
+	require.NoError(t, err)
+	assert.Nil(t, limits.Override)
+	assert.Equal(t, model.TransferLimits{MaxAmount: 100, DailyAmount: 500}, limits.Limits)
+
+	negative := -1
+	_, err = s.SetTransferLimits(ctx, "alice", &model.TransferLimitsOverride{DailyAmount: &negative}, false)
+	require.ErrorIs(t, err, model.ErrBadRequest)
+
+	_, err = s.TransferLimits(ctx, "bob")
+	require.ErrorIs(t, err, model.ErrNotFound)
+
+	_, err = s.SetTransferLimits(ctx, "bob", override, false)
+	require.ErrorIs(t, err, model.ErrNotFound)
+}
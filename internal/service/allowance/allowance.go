@@ -0,0 +1,164 @@
+// Package allowance grants every active user coins from the system account
+// on a schedule.
+package allowance
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+// JobName is the name of the allowance in the scheduler.
+const JobName = "allowance"
+
+// batchSize is the number of users credited in one transaction.
+const batchSize = 100
+
+type Service struct {
+	repo   repository.Repository
+	amount int
+	log    *slog.Logger
+}
+
+func NewService(repo repository.Repository, amount int, log *slog.Logger) *Service {
+	return &Service{repo: repo, amount: amount, log: log}
+}
+
+// Run grants the allowance of the period starting at the given time, it is
+// the scheduler job.
+func (s *Service) Run(ctx context.Context, period time.Time) error {
+	granted, err := s.Grant(ctx, period)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("granted allowance", "period", period, "users", granted, "amount", s.amount)
+
+	return nil
+}
+
+// Grant credits the allowance of the period starting at the given time to
+// every active user who has not received it yet and returns their number.
+// Users are credited in batches, so an interrupted grant can be repeated.
+func (s *Service) Grant(ctx context.Context, period time.Time) (int, error) {
+	reference := period.UTC().Format(time.RFC3339)
+
+	var granted int
+
+	after := ""
+
+	for {
+		users, err := s.repo.ListUsers(ctx, nil, after, batchSize)
+		if err != nil {
+			return granted, err
+		}
+
+		if len(users) == 0 {
+			return granted, nil
+		}
+
+		after = users[len(users)-1].Username
+
+		usernames := make([]string, 0, len(users))
+
+		for _, user := range users {
+			if user.Active() {
+				usernames = append(usernames, user.Username)
+			}
+		}
+
+		n, err := s.grantBatch(ctx, usernames, reference)
+		if err != nil {
+			return granted, err
+		}
+
+		granted += n
+	}
+}
+
+func (s *Service) grantBatch(ctx context.Context, usernames []string, reference string) (int, error) {
+	if len(usernames) == 0 {
+		return 0, nil
+	}
+
+	var granted int
+
+	err := s.repo.WithTx(ctx, func(tx repository.DB) error {
+		granted = 0
+
+		users, err := s.repo.FindUsersForUpdate(ctx, tx, usernames...)
+		if err != nil {
+			return err
+		}
+
+		notifications := make([]*model.Notification, 0, 2*len(users))
+
+		for _, username := range usernames {
+			user, ok := users[username]
+			if !ok || !user.Active() {
+				continue
+			}
+
+			added, err := s.repo.AddLedgerEntry(ctx, tx, &model.LedgerEntry{
+				UserID:    user.ID,
+				Kind:      model.LedgerEntryAllowance,
+				Amount:    s.amount,
+				Reference: reference,
+			})
+			if err != nil {
+				return err
+			}
+
+			if !added {
+				continue
+			}
+
+			if err := s.repo.AddBalance(ctx, tx, user.ID, s.amount); err != nil {
+				return err
+			}
+
+			received, err := grantNotifications(user, s.amount)
+			if err != nil {
+				return err
+			}
+
+			notifications = append(notifications, received...)
+			granted++
+		}
+
+		if len(notifications) == 0 {
+			return nil
+		}
+
+		return s.repo.AddNotifications(ctx, tx, notifications...)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return granted, nil
+}
+
+// grantNotifications tell the user about the coins from the system account
+// and the new balance. The user holds the balance from before the grant.
+func grantNotifications(user *model.User, amount int) ([]*model.Notification, error) {
+	received, err := model.NewNotification(model.NotificationCoinsReceived, user.ID, model.CoinsReceived{
+		FromUser: model.SystemAccount,
+		Amount:   amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := model.NewNotification(model.NotificationBalanceChanged, user.ID, model.BalanceChanged{
+		Balance: user.Balance + amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []*model.Notification{received, balance}, nil
+}
@@ -0,0 +1,77 @@
+package allowance
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/repositorytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Grant(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := memory.New()
+	s := NewService(repo, 100, slog.Default())
+
+	// More users than fit in a batch.
+	for i := range batchSize + 5 {
+		repositorytest.NewUser(t, repo, fmt.Sprintf("user-%03d", i))
+	}
+
+	inactive := repositorytest.NewUser(t, repo, "inactive")
+	deactivatedAt := time.Now()
+	require.NoError(t, repo.SetUserDeactivatedAt(ctx, nil, inactive.ID, &deactivatedAt))
+
+	january := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	granted, err := s.Grant(ctx, january)
+	require.NoError(t, err)
+	assert.Equal(t, batchSize+5, granted)
+
+	user, err := repo.FindUser(ctx, nil, "user-000")
+	require.NoError(t, err)
+	assert.Equal(t, 1100, user.Balance)
+
+	entries, err := repo.ListLedgerEntries(ctx, nil, user.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "signup and allowance")
+	assert.Equal(t, model.LedgerEntryAllowance, entries[1].Kind)
+	assert.Equal(t, 100, entries[1].Amount)
+	assert.Equal(t, "2025-01-01T00:00:00Z", entries[1].Reference)
+
+	notifications, err := repo.ListNotifications(ctx, nil, user.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, notifications, 2)
+	assert.Equal(t, model.NotificationCoinsReceived, notifications[0].Type)
+	assert.Equal(t, model.NotificationBalanceChanged, notifications[1].Type)
+
+	inactive, err = repo.FindUser(ctx, nil, "inactive")
+	require.NoError(t, err)
+	assert.Equal(t, 1000, inactive.Balance, "inactive users get nothing")
+
+	granted, err = s.Grant(ctx, january)
+	require.NoError(t, err)
+	assert.Zero(t, granted, "a period is granted once")
+
+	late := repositorytest.NewUser(t, repo, "late")
+
+	granted, err = s.Grant(ctx, january)
+	require.NoError(t, err)
+	assert.Equal(t, 1, granted, "repeated grant reaches users it missed")
+
+	late, err = repo.FindUser(ctx, nil, late.Username)
+	require.NoError(t, err)
+	assert.Equal(t, 1100, late.Balance)
+
+	granted, err = s.Grant(ctx, january.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	assert.Equal(t, batchSize+6, granted)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"slices"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 	"github.com/esklo/avito-backend-winter-2025/internal/repository"
@@ -14,7 +16,7 @@ import (
 var _ service.Authenticator = (*Service)(nil)
 
 var (
-	ErrInvalidToken         = errors.New("invalid token")
+	ErrInvalidToken         = fmt.Errorf("%w: invalid token", model.ErrUnauthorized)
 	ErrInvalidSigningMethod = errors.New("unexpected signing method")
 )
 
@@ -23,25 +25,42 @@ type Service struct {
 	users  service.UserManager
 	hasher service.Hasher
 	secret []byte
+	// admins cannot sign up by logging in, their accounts are created with
+	// shopctl, so that nobody else can claim an admin name first.
+	admins []string
 }
 
-func NewService(repo repository.Repository, users service.UserManager, hasher service.Hasher, secret []byte) *Service {
+func NewService(
+	repo repository.Repository, users service.UserManager, hasher service.Hasher, secret []byte, admins []string,
+) *Service {
 	return &Service{
 		repo:   repo,
 		users:  users,
 		hasher: hasher,
 		secret: secret,
+		admins: admins,
 	}
 }
 
-func (s *Service) Login(ctx context.Context, username, password string) (string, error) {
+// Login returns a token of the user, registering them with the referral code,
+// which may be empty, if they do not exist yet. Admins are never registered
+// here.
+func (s *Service) Login(ctx context.Context, username, password, referralCode string) (string, error) {
 	if username == "" || password == "" {
 		return "", model.ErrBadRequest
 	}
 
 	user, err := s.repo.FindUser(ctx, nil, username)
 	if errors.Is(err, sql.ErrNoRows) {
-		user, err = s.users.Create(ctx, username, password)
+		if slices.Contains(s.admins, username) {
+			return "", fmt.Errorf("%w: admin accounts are created with shopctl", model.ErrUnauthorized)
+		}
+
+		user, err = s.users.Create(ctx, username, password, referralCode)
+	}
+
+	if errors.Is(err, model.ErrInvalidReferralCode) {
+		return "", err
 	}
 
 	if err != nil {
@@ -52,10 +71,16 @@ func (s *Service) Login(ctx context.Context, username, password string) (string,
 		return "", model.ErrUnauthorized
 	}
 
+	if !user.Active() {
+		return "", model.ErrUserDeactivated
+	}
+
 	return createToken(newClaims(user.Username), s.secret)
 }
 
-func (s *Service) ValidateToken(_ context.Context, token string) (string, error) {
+// ValidateToken returns the username of the token. It looks the user up, so
+// that the tokens of a deactivated user stop working before they expire.
+func (s *Service) ValidateToken(ctx context.Context, token string) (string, error) {
 	if token == "" {
 		return "", ErrInvalidToken
 	}
@@ -76,5 +101,23 @@ func (s *Service) ValidateToken(_ context.Context, token string) (string, error)
 		return "", ErrInvalidToken
 	}
 
-	return claims.GetSubject()
+	username, err := claims.GetSubject()
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	user, err := s.repo.FindUser(ctx, nil, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidToken
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("find user: %w", err)
+	}
+
+	if !user.Active() {
+		return "", model.ErrUserDeactivated
+	}
+
+	return username, nil
 }
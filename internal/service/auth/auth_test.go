@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 	"github.com/esklo/avito-backend-winter-2025/mocks"
@@ -29,7 +30,7 @@ func newTestSuite(t *testing.T) *testSuite {
 		repo:   repo,
 		users:  users,
 		hasher: hasher,
-		auth:   NewService(repo, users, hasher, []byte("test-secret")),
+		auth:   NewService(repo, users, hasher, []byte("test-secret"), []string{"admin"}),
 	}
 }
 
@@ -66,7 +67,7 @@ func TestService_Login(t *testing.T) {
 				t.Parallel()
 				ts := newTestSuite(t)
 
-				token, err := ts.auth.Login(ctx, tt.username, tt.password)
+				token, err := ts.auth.Login(ctx, tt.username, tt.password, "")
 				assert.ErrorIs(t, err, tt.expectedError)
 				assert.Empty(t, token)
 			})
@@ -92,11 +93,37 @@ func TestService_Login(t *testing.T) {
 			Verify("password", user.Password, user.Salt).
 			Return(true)
 
-		token, err := ts.auth.Login(ctx, user.Username, "password")
+		token, err := ts.auth.Login(ctx, user.Username, "password", "")
 		assert.NoError(t, err)
 		assert.NotEmpty(t, token)
 	})
 
+	t.Run("deactivated user", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		deactivatedAt := time.Now()
+		user := &model.User{
+			ID:            1,
+			Username:      "user",
+			Password:      []byte("hashed"),
+			Salt:          []byte("salt"),
+			DeactivatedAt: &deactivatedAt,
+		}
+
+		ts.repo.EXPECT().
+			FindUser(gomock.Any(), nil, user.Username).
+			Return(user, nil)
+
+		ts.hasher.EXPECT().
+			Verify("password", user.Password, user.Salt).
+			Return(true)
+
+		token, err := ts.auth.Login(ctx, user.Username, "password", "")
+		assert.ErrorIs(t, err, model.ErrForbidden)
+		assert.Empty(t, token)
+	})
+
 	t.Run("new user registration", func(t *testing.T) {
 		t.Parallel()
 		ts := newTestSuite(t)
@@ -113,17 +140,47 @@ func TestService_Login(t *testing.T) {
 			Return(nil, sql.ErrNoRows)
 
 		ts.users.EXPECT().
-			Create(gomock.Any(), user.Username, "password").
+			Create(gomock.Any(), user.Username, "password", "").
 			Return(user, nil)
 
 		ts.hasher.EXPECT().
 			Verify("password", user.Password, user.Salt).
 			Return(true)
 
-		token, err := ts.auth.Login(ctx, user.Username, "password")
+		token, err := ts.auth.Login(ctx, user.Username, "password", "")
 		assert.NoError(t, err)
 		assert.NotEmpty(t, token)
 	})
+
+	t.Run("admin is not registered", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.repo.EXPECT().
+			FindUser(gomock.Any(), nil, "admin").
+			Return(nil, sql.ErrNoRows)
+
+		token, err := ts.auth.Login(ctx, "admin", "password", "")
+		assert.ErrorIs(t, err, model.ErrUnauthorized)
+		assert.Empty(t, token)
+	})
+
+	t.Run("invalid referral code", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		ts.repo.EXPECT().
+			FindUser(gomock.Any(), nil, "user").
+			Return(nil, sql.ErrNoRows)
+
+		ts.users.EXPECT().
+			Create(gomock.Any(), "user", "password", "WRONG").
+			Return(nil, model.ErrInvalidReferralCode)
+
+		token, err := ts.auth.Login(ctx, "user", "password", "WRONG")
+		assert.ErrorIs(t, err, model.ErrInvalidReferralCode)
+		assert.Empty(t, token)
+	})
 }
 
 func TestService_ValidateToken(t *testing.T) {
@@ -155,8 +212,45 @@ func TestService_ValidateToken(t *testing.T) {
 		token, err := createToken(newClaims("user"), []byte("test-secret"))
 		require.NoError(t, err)
 
+		ts.repo.EXPECT().
+			FindUser(gomock.Any(), nil, "user").
+			Return(&model.User{Username: "user"}, nil)
+
 		username, err := ts.auth.ValidateToken(ctx, token)
 		assert.NoError(t, err)
 		assert.Equal(t, "user", username)
 	})
+
+	t.Run("deleted user", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		token, err := createToken(newClaims("user"), []byte("test-secret"))
+		require.NoError(t, err)
+
+		ts.repo.EXPECT().
+			FindUser(gomock.Any(), nil, "user").
+			Return(nil, sql.ErrNoRows)
+
+		username, err := ts.auth.ValidateToken(ctx, token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+		assert.Empty(t, username)
+	})
+
+	t.Run("deactivated user", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		token, err := createToken(newClaims("user"), []byte("test-secret"))
+		require.NoError(t, err)
+
+		deactivatedAt := time.Now()
+		ts.repo.EXPECT().
+			FindUser(gomock.Any(), nil, "user").
+			Return(&model.User{Username: "user", DeactivatedAt: &deactivatedAt}, nil)
+
+		username, err := ts.auth.ValidateToken(ctx, token)
+		assert.ErrorIs(t, err, model.ErrUserDeactivated)
+		assert.Empty(t, username)
+	})
 }
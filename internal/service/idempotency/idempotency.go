@@ -0,0 +1,81 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/service"
+)
+
+const (
+	// keyTTL is how long a key is remembered, after that it may be reused.
+	keyTTL       = 24 * time.Hour
+	maxKeyLength = 255
+)
+
+var _ service.Idempotency = (*Service)(nil)
+
+// Service makes retried requests safe: the first request with a key runs,
+// later ones with the same key get its stored response.
+type Service struct {
+	repo repository.Repository
+}
+
+func NewService(repo repository.Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Begin reserves key for the request identified by requestHash. A returned
+// key that is not completed belongs to the caller, who must Complete or
+// Abort it; a completed one holds the response to replay.
+func (s *Service) Begin(ctx context.Context, username, key, requestHash string) (*model.IdempotencyKey, error) {
+	if username == "" {
+		return nil, model.ErrUnauthorized
+	}
+
+	if key == "" || len(key) > maxKeyLength {
+		return nil, fmt.Errorf("%w: idempotency key must be 1 to %d bytes long", model.ErrBadRequest, maxKeyLength)
+	}
+
+	stored := &model.IdempotencyKey{
+		Username:    username,
+		Key:         key,
+		RequestHash: requestHash,
+	}
+
+	reserved, err := s.repo.ReserveIdempotencyKey(ctx, nil, stored, time.Now().Add(-keyTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	if reserved {
+		return stored, nil
+	}
+
+	if stored.RequestHash != requestHash {
+		return nil, fmt.Errorf("%w: idempotency key is already used for another request", model.ErrBadRequest)
+	}
+
+	if !stored.Completed() {
+		return nil, fmt.Errorf("%w: request with this idempotency key is in progress", model.ErrConflict)
+	}
+
+	return stored, nil
+}
+
+// Complete stores the response to the request made with key.
+func (s *Service) Complete(ctx context.Context, key *model.IdempotencyKey, statusCode int, response []byte) error {
+	key.StatusCode = statusCode
+	key.Response = response
+
+	return s.repo.CompleteIdempotencyKey(ctx, nil, key)
+}
+
+// Abort releases key without a response, e.g. when the request failed
+// unexpectedly, so that it can be retried.
+func (s *Service) Abort(ctx context.Context, key *model.IdempotencyKey) error {
+	return s.repo.DeleteIdempotencyKey(ctx, nil, key.Username, key.Key)
+}
@@ -0,0 +1,68 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Begin(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("validation cases", func(t *testing.T) {
+		t.Parallel()
+		s := NewService(memory.New())
+
+		_, err := s.Begin(ctx, "", "key", "hash")
+		assert.ErrorIs(t, err, model.ErrUnauthorized)
+
+		_, err = s.Begin(ctx, "alice", "", "hash")
+		assert.ErrorIs(t, err, model.ErrBadRequest)
+
+		_, err = s.Begin(ctx, "alice", strings.Repeat("k", maxKeyLength+1), "hash")
+		assert.ErrorIs(t, err, model.ErrBadRequest)
+	})
+
+	t.Run("replays completed request", func(t *testing.T) {
+		t.Parallel()
+		s := NewService(memory.New())
+
+		key, err := s.Begin(ctx, "alice", "key", "hash")
+		require.NoError(t, err)
+		require.False(t, key.Completed())
+
+		_, err = s.Begin(ctx, "alice", "key", "hash")
+		require.ErrorIs(t, err, model.ErrConflict, "first request is in progress")
+
+		require.NoError(t, s.Complete(ctx, key, http.StatusOK, []byte("{}")))
+
+		stored, err := s.Begin(ctx, "alice", "key", "hash")
+		require.NoError(t, err)
+		assert.True(t, stored.Completed())
+		assert.Equal(t, http.StatusOK, stored.StatusCode)
+		assert.Equal(t, []byte("{}"), stored.Response)
+
+		_, err = s.Begin(ctx, "alice", "key", "other")
+		assert.ErrorIs(t, err, model.ErrBadRequest, "key is bound to the request")
+	})
+
+	t.Run("aborted key can be reused", func(t *testing.T) {
+		t.Parallel()
+		s := NewService(memory.New())
+
+		key, err := s.Begin(ctx, "alice", "key", "hash")
+		require.NoError(t, err)
+		require.NoError(t, s.Abort(ctx, key))
+
+		key, err = s.Begin(ctx, "alice", "key", "hash")
+		require.NoError(t, err)
+		assert.False(t, key.Completed())
+	})
+}
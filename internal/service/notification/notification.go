@@ -0,0 +1,185 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/service"
+)
+
+const (
+	batchSize = 100
+	// resyncInterval bounds the delay of a notification whose wake-up was
+	// lost, e.g. while the listener reconnected.
+	resyncInterval = 30 * time.Second
+	retryDelay     = time.Second
+)
+
+var _ service.Notifications = (*Service)(nil)
+
+// Service streams notifications to subscribers. Notifications are read from
+// the repository, listening only tells which users have new ones, so every
+// replica serves its own subscribers whichever replica wrote them.
+type Service struct {
+	repo repository.Repository
+	log  *slog.Logger
+
+	mu      sync.Mutex
+	wakeups map[int]map[chan struct{}]struct{}
+}
+
+func NewService(repo repository.Repository, log *slog.Logger) *Service {
+	return &Service{
+		repo:    repo,
+		log:     log,
+		wakeups: make(map[int]map[chan struct{}]struct{}),
+	}
+}
+
+// Run listens for new notifications until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	for {
+		err := s.repo.ListenNotifications(ctx, s.wake)
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.log.Error("listen notifications", "error", err)
+
+		// Notifications may have been missed while not listening.
+		s.wakeAll()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func (s *Service) Subscribe(ctx context.Context, username string, lastEventID int64) (<-chan model.Notification, error) {
+	user, err := s.repo.FindUser(ctx, nil, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrUnauthorized
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	wakeup := s.subscribe(user.ID)
+
+	if lastEventID == 0 {
+		lastEventID, err = s.repo.LastNotificationID(ctx, nil, user.ID)
+		if err != nil {
+			s.unsubscribe(user.ID, wakeup)
+
+			return nil, err
+		}
+	}
+
+	out := make(chan model.Notification)
+
+	go func() {
+		defer close(out)
+		defer s.unsubscribe(user.ID, wakeup)
+
+		s.stream(ctx, user.ID, lastEventID, wakeup, out)
+	}()
+
+	return out, nil
+}
+
+func (s *Service) stream(ctx context.Context, userID int, afterID int64, wakeup <-chan struct{}, out chan<- model.Notification) {
+	ticker := time.NewTicker(resyncInterval)
+	defer ticker.Stop()
+
+	for {
+		notifications, err := s.repo.ListNotifications(ctx, nil, userID, afterID, batchSize)
+		if err != nil && ctx.Err() == nil {
+			s.log.Error("list notifications", "user", userID, "error", err)
+		}
+
+		for _, n := range notifications {
+			select {
+			case <-ctx.Done():
+				return
+			case out <- n:
+				afterID = n.ID
+			}
+		}
+
+		if len(notifications) == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) subscribe(userID int) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wakeup := make(chan struct{}, 1)
+
+	if s.wakeups[userID] == nil {
+		s.wakeups[userID] = make(map[chan struct{}]struct{})
+	}
+
+	s.wakeups[userID][wakeup] = struct{}{}
+
+	return wakeup
+}
+
+func (s *Service) unsubscribe(userID int, wakeup chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.wakeups[userID], wakeup)
+
+	if len(s.wakeups[userID]) == 0 {
+		delete(s.wakeups, userID)
+	}
+}
+
+// wake tells the subscribers of the user to check for new notifications. It
+// never blocks.
+func (s *Service) wake(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for wakeup := range s.wakeups[userID] {
+		signal(wakeup)
+	}
+}
+
+func (s *Service) wakeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, wakeups := range s.wakeups {
+		for wakeup := range wakeups {
+			signal(wakeup)
+		}
+	}
+}
+
+func signal(wakeup chan struct{}) {
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
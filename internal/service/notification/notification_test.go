@@ -0,0 +1,103 @@
+package notification
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/repositorytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func notify(t *testing.T, repo *memory.Repository, userID, balance int) *model.Notification {
+	t.Helper()
+
+	n, err := model.NewNotification(model.NotificationBalanceChanged, userID, model.BalanceChanged{Balance: balance})
+	require.NoError(t, err)
+	require.NoError(t, repo.AddNotifications(context.Background(), nil, n))
+
+	return n
+}
+
+func receive(t *testing.T, notifications <-chan model.Notification) model.Notification {
+	t.Helper()
+
+	select {
+	case n, ok := <-notifications:
+		require.True(t, ok, "stream is open")
+
+		return n
+	case <-time.After(time.Second):
+		require.FailNow(t, "no notification received")
+
+		return model.Notification{}
+	}
+}
+
+func TestService_Subscribe(t *testing.T) {
+	t.Parallel()
+
+	t.Run("streams new notifications", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		repo := memory.New()
+		s := NewService(repo, slog.Default())
+
+		go s.Run(ctx)
+
+		user, other := repositorytest.NewUser(t, repo, "alice"), repositorytest.NewUser(t, repo, "bob")
+		notify(t, repo, user.ID, 1)
+
+		notifications, err := s.Subscribe(ctx, user.Username, 0)
+		require.NoError(t, err)
+
+		notify(t, repo, other.ID, 2)
+		sent := notify(t, repo, user.ID, 3)
+
+		n := receive(t, notifications)
+		assert.Equal(t, sent.ID, n.ID, "old and other users' notifications are skipped")
+		assert.JSONEq(t, `{"balance": 3}`, string(n.Payload))
+
+		cancel()
+
+		_, ok := <-notifications
+		assert.False(t, ok, "stream is closed when ctx is done")
+	})
+
+	t.Run("resumes after last event", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		repo := memory.New()
+		s := NewService(repo, slog.Default())
+
+		user := repositorytest.NewUser(t, repo, "alice")
+		first := notify(t, repo, user.ID, 1)
+		second := notify(t, repo, user.ID, 2)
+		third := notify(t, repo, user.ID, 3)
+
+		notifications, err := s.Subscribe(ctx, user.Username, first.ID)
+		require.NoError(t, err)
+
+		assert.Equal(t, second.ID, receive(t, notifications).ID)
+		assert.Equal(t, third.ID, receive(t, notifications).ID)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewService(memory.New(), slog.Default())
+
+		_, err := s.Subscribe(context.Background(), "ghost", 0)
+		assert.ErrorIs(t, err, model.ErrUnauthorized)
+	})
+}
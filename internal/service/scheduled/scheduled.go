@@ -0,0 +1,409 @@
+// Package scheduled makes the transfers users set up to run later, once or on
+// a schedule.
+package scheduled
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/cron"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/service"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/user"
+)
+
+// JobName is the name of the worker in the scheduler.
+const JobName = "scheduled-transfers"
+
+const (
+	// runLogLimit caps the runs returned for a scheduled transfer.
+	runLogLimit = 100
+)
+
+var _ service.ScheduledTransfers = (*Service)(nil)
+
+// Transferer makes a transfer in a transaction of the caller, the user
+// service does.
+type Transferer interface {
+	TransferTx(ctx context.Context, tx repository.DB, from, to string, amount int, memo string) error
+}
+
+// Service keeps the scheduled transfers of users and makes them when they are
+// due through the user service, so they are checked and recorded like any
+// other transfer. Every run is recorded, a failed one with its error.
+type Service struct {
+	repo  repository.Repository
+	users Transferer
+	cfg   config.ScheduledTransfersConfig
+	log   *slog.Logger
+}
+
+func NewService(
+	repo repository.Repository, users Transferer, cfg config.ScheduledTransfersConfig, log *slog.Logger,
+) *Service {
+	return &Service{repo: repo, users: users, cfg: cfg, log: log}
+}
+
+// Create schedules a transfer from the user to transfer.ToUser. A transfer
+// without a Schedule runs once at NextRunAt. A recurring one runs at the
+// times of its schedule, starting at NextRunAt if it is set.
+func (s *Service) Create(
+	ctx context.Context, username string, transfer *model.ScheduledTransfer,
+) (*model.ScheduledTransfer, error) {
+	if username == "" {
+		return nil, model.ErrUnauthorized
+	}
+
+	if transfer.Amount <= 0 || transfer.ToUser == "" || transfer.ToUser == username {
+		return nil, model.ErrBadRequest
+	}
+
+	memo, err := user.SanitizeMemo(transfer.Memo)
+	if err != nil {
+		return nil, err
+	}
+
+	expr := strings.TrimSpace(transfer.Schedule)
+
+	runAt, err := firstRun(expr, transfer.NextRunAt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var created *model.ScheduledTransfer
+
+	err = s.repo.WithTx(ctx, func(tx repository.DB) error {
+		users, err := s.repo.FindUsersForUpdate(ctx, tx, username, transfer.ToUser)
+		if err != nil {
+			return fmt.Errorf("lock users: %w", err)
+		}
+
+		sender, ok := users[username]
+		if !ok {
+			return model.ErrUnauthorized
+		}
+
+		if !sender.Active() {
+			return model.ErrUserDeactivated
+		}
+
+		receiver, ok := users[transfer.ToUser]
+		if !ok {
+			return fmt.Errorf("%w: receiver not found", model.ErrBadRequest)
+		}
+
+		if !receiver.Active() {
+			return fmt.Errorf("%w: receiver is deactivated", model.ErrBadRequest)
+		}
+
+		if err := s.checkActive(ctx, tx, sender.ID); err != nil {
+			return err
+		}
+
+		created = &model.ScheduledTransfer{
+			SenderID:   sender.ID,
+			ReceiverID: receiver.ID,
+			FromUser:   sender.Username,
+			ToUser:     receiver.Username,
+			Amount:     transfer.Amount,
+			Memo:       memo,
+			Schedule:   expr,
+			NextRunAt:  runAt,
+		}
+
+		return s.repo.CreateScheduledTransfer(ctx, tx, created)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// List returns the scheduled transfers of the user in every status.
+func (s *Service) List(ctx context.Context, username string) ([]model.ScheduledTransfer, error) {
+	sender, err := s.repo.FindUser(ctx, nil, username)
+	if err != nil {
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	return s.repo.ListScheduledTransfers(ctx, nil, sender.ID)
+}
+
+// Runs returns the latest runs of a scheduled transfer of the user, newest
+// first.
+func (s *Service) Runs(ctx context.Context, username string, id int64) ([]model.ScheduledTransferRun, error) {
+	transfer, err := s.find(ctx, nil, username, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListScheduledTransferRuns(ctx, nil, transfer.ID, runLogLimit)
+}
+
+// Cancel stops an active scheduled transfer of the user. Its runs are kept.
+func (s *Service) Cancel(ctx context.Context, username string, id int64) error {
+	return s.repo.WithTx(ctx, func(tx repository.DB) error {
+		transfer, err := s.find(ctx, tx, username, id)
+		if err != nil {
+			return err
+		}
+
+		if transfer.Status != model.ScheduledTransferActive {
+			return fmt.Errorf("%w: scheduled transfer is already %s", model.ErrConflict, transfer.Status)
+		}
+
+		transfer.Status = model.ScheduledTransferCancelled
+
+		return s.repo.UpdateScheduledTransfer(ctx, tx, transfer)
+	})
+}
+
+// Run makes the transfers due by at, it is the scheduler job. Each transfer
+// is made in one transaction with its run and its move past at, so it runs at
+// most once for a time and once for all the times it missed, and one cut
+// short by a crash is still due.
+func (s *Service) Run(ctx context.Context, at time.Time) error {
+	var (
+		errs         []error
+		made, failed int
+	)
+
+	for {
+		run, err := s.runNext(ctx, at)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if run == nil {
+			break
+		}
+
+		made++
+
+		if run.Status == model.ScheduledTransferRunFailed {
+			failed++
+		}
+	}
+
+	if made > 0 {
+		s.log.Info("made scheduled transfers", "at", at, "transfers", made, "failed", failed)
+	}
+
+	return errors.Join(errs...)
+}
+
+// runNext makes the next transfer due by at and returns its run, or nil if
+// nothing is due. A transfer whose transaction fails is recorded as failed in
+// a transaction of its own, so that it does not stay due, and if that fails
+// too runNext returns no run, which stops Run until the next time.
+func (s *Service) runNext(ctx context.Context, at time.Time) (*model.ScheduledTransferRun, error) {
+	var (
+		transfer *model.ScheduledTransfer
+		run      *model.ScheduledTransferRun
+	)
+
+	err := s.repo.WithTx(ctx, func(tx repository.DB) error {
+		transfer, run = nil, nil
+
+		due, err := s.repo.ListDueScheduledTransfers(ctx, tx, at, 1)
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		transfer, run = &due[0], newRun(&due[0])
+
+		err = s.users.TransferTx(ctx, tx, transfer.FromUser, transfer.ToUser, transfer.Amount, transfer.Memo)
+		if err != nil && !isRunError(err) {
+			return err
+		}
+
+		if err != nil {
+			s.log.Warn("scheduled transfer failed", "id", transfer.ID, "error", err)
+
+			run.Status = model.ScheduledTransferRunFailed
+			run.Error = err.Error()
+		}
+
+		return s.record(ctx, tx, transfer, run, at)
+	})
+	if err == nil || transfer == nil {
+		return run, err
+	}
+
+	s.log.Error("scheduled transfer failed", "id", transfer.ID, "error", err)
+
+	run, failErr := s.recordFailure(ctx, transfer, at)
+	if failErr != nil {
+		return nil, fmt.Errorf("scheduled transfer %d: %w", transfer.ID, errors.Join(err, failErr))
+	}
+
+	return run, fmt.Errorf("scheduled transfer %d: %w", transfer.ID, err)
+}
+
+// recordFailure records a failed run of a transfer whose transaction failed,
+// unless it is no longer due.
+func (s *Service) recordFailure(
+	ctx context.Context, transfer *model.ScheduledTransfer, at time.Time,
+) (*model.ScheduledTransferRun, error) {
+	run := newRun(transfer)
+	run.Status = model.ScheduledTransferRunFailed
+	run.Error = model.ErrInternalServerError.Error()
+
+	err := s.repo.WithTx(ctx, func(tx repository.DB) error {
+		t, err := s.repo.FindScheduledTransferForUpdate(ctx, tx, transfer.ID)
+		if err != nil {
+			return err
+		}
+
+		if t.Status != model.ScheduledTransferActive || t.NextRunAt.After(at) {
+			return nil
+		}
+
+		return s.record(ctx, tx, t, run, at)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// record adds the run of a transfer and moves the transfer past at.
+func (s *Service) record(
+	ctx context.Context, tx repository.DB, t *model.ScheduledTransfer, run *model.ScheduledTransferRun, at time.Time,
+) error {
+	if err := s.repo.AddScheduledTransferRun(ctx, tx, run); err != nil {
+		return err
+	}
+
+	advance(t, at, run.Status)
+
+	return s.repo.UpdateScheduledTransfer(ctx, tx, t)
+}
+
+func newRun(t *model.ScheduledTransfer) *model.ScheduledTransferRun {
+	return &model.ScheduledTransferRun{
+		ScheduledTransferID: t.ID,
+		ScheduledAt:         t.NextRunAt,
+		Status:              model.ScheduledTransferRunSucceeded,
+	}
+}
+
+// find returns a scheduled transfer of the user.
+func (s *Service) find(
+	ctx context.Context, tx repository.DB, username string, id int64,
+) (*model.ScheduledTransfer, error) {
+	transfer, err := s.repo.FindScheduledTransferForUpdate(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && transfer.FromUser != username {
+		return nil, fmt.Errorf("%w: scheduled transfer %d", model.ErrNotFound, id)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// checkActive checks that the locked sender may schedule another transfer.
+func (s *Service) checkActive(ctx context.Context, tx repository.DB, senderID int) error {
+	transfers, err := s.repo.ListScheduledTransfers(ctx, tx, senderID)
+	if err != nil {
+		return err
+	}
+
+	var active int
+
+	for _, t := range transfers {
+		if t.Status == model.ScheduledTransferActive {
+			active++
+		}
+	}
+
+	if active >= s.cfg.MaxPerUser {
+		return fmt.Errorf("%w: at most %d active scheduled transfers", model.ErrLimitExceeded, s.cfg.MaxPerUser)
+	}
+
+	return nil
+}
+
+// firstRun returns the first time a transfer with the schedule expr runs. A
+// one-off transfer needs a run time, a recurring one starts at runAt if it is
+// set and at the next time of its schedule otherwise.
+func firstRun(expr string, runAt, now time.Time) (time.Time, error) {
+	if expr != "" {
+		schedule, err := cron.Parse(expr)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: schedule: %w", model.ErrBadRequest, err)
+		}
+
+		if runAt.IsZero() {
+			runAt = schedule.Next(now.UTC())
+			if runAt.IsZero() {
+				return time.Time{}, fmt.Errorf("%w: schedule %q never runs", model.ErrBadRequest, expr)
+			}
+		}
+	}
+
+	if runAt.IsZero() {
+		return time.Time{}, fmt.Errorf("%w: either runAt or schedule is required", model.ErrBadRequest)
+	}
+
+	if !runAt.After(now) {
+		return time.Time{}, fmt.Errorf("%w: runAt must be in the future", model.ErrBadRequest)
+	}
+
+	return runAt, nil
+}
+
+// advance moves a transfer that ran past at: a recurring one to the next
+// time of its schedule, a one-off one is completed, or failed if its run
+// failed.
+func advance(t *model.ScheduledTransfer, at time.Time, status model.ScheduledTransferRunStatus) {
+	if !t.Recurring() {
+		t.Status = model.ScheduledTransferCompleted
+		if status == model.ScheduledTransferRunFailed {
+			t.Status = model.ScheduledTransferFailed
+		}
+
+		return
+	}
+
+	schedule, err := cron.Parse(t.Schedule)
+	if err != nil {
+		t.Status = model.ScheduledTransferFailed
+
+		return
+	}
+
+	t.NextRunAt = schedule.Next(at.UTC())
+	if t.NextRunAt.IsZero() {
+		t.Status = model.ScheduledTransferCompleted
+	}
+}
+
+// isRunError reports whether err fails a run with its message shown to the
+// user. Other errors are of the service, they are logged instead.
+func isRunError(err error) bool {
+	for _, target := range []error{
+		model.ErrBadRequest,
+		model.ErrInsufficientFunds,
+		model.ErrLimitExceeded,
+		model.ErrForbidden,
+		model.ErrUnauthorized,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
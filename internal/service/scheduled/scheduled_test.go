@@ -0,0 +1,277 @@
+package scheduled
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/hasher"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestService returns a service with users alice, bob and charity, who
+// have 100 coins each.
+func newTestService(t *testing.T) (*Service, *memory.Repository) {
+	t.Helper()
+
+	repo := memory.New()
+	cfg := config.Default()
+	users := user.NewService(repo, hasher.NewArgon2(), config.SignupConfig{WelcomeGrant: 100}, model.TransferLimits{},
+		cfg.PendingTransfers, cfg.PaymentRequests)
+
+	for _, username := range []string{"alice", "bob", "charity"} {
+		_, err := users.Create(context.Background(), username, "password", "")
+		require.NoError(t, err)
+	}
+
+	return NewService(repo, users, config.ScheduledTransfersConfig{MaxPerUser: 2}, slog.Default()), repo
+}
+
+var errRunInsert = errors.New("insert run")
+
+// failingRunRepository fails to record the next failures runs of scheduled
+// transfers.
+type failingRunRepository struct {
+	*memory.Repository
+	failures int
+}
+
+func (r *failingRunRepository) AddScheduledTransferRun(
+	ctx context.Context, db repository.DB, run *model.ScheduledTransferRun,
+) error {
+	if r.failures > 0 {
+		r.failures--
+
+		return errRunInsert
+	}
+
+	return r.Repository.AddScheduledTransferRun(ctx, db, run)
+}
+
+func balance(t *testing.T, repo *memory.Repository, username string) int {
+	t.Helper()
+
+	u, err := repo.FindUser(context.Background(), nil, username)
+	require.NoError(t, err)
+
+	return u.Balance
+}
+
+func TestService_Create(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s, _ := newTestService(t)
+	runAt := time.Now().Add(time.Hour)
+
+	transfer, err := s.Create(ctx, "alice", &model.ScheduledTransfer{
+		ToUser: "bob", Amount: 10, Memo: "rent\nmay", NextRunAt: runAt,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.ScheduledTransferActive, transfer.Status)
+	assert.Equal(t, "rent may", transfer.Memo)
+	assert.Equal(t, runAt, transfer.NextRunAt)
+
+	monthly, err := s.Create(ctx, "alice", &model.ScheduledTransfer{ToUser: "charity", Amount: 50, Schedule: "@monthly"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, monthly.NextRunAt.Day())
+	assert.True(t, monthly.NextRunAt.After(time.Now()))
+
+	_, err = s.Create(ctx, "alice", &model.ScheduledTransfer{ToUser: "bob", Amount: 10, NextRunAt: runAt})
+	require.ErrorIs(t, err, model.ErrLimitExceeded)
+
+	transfers, err := s.List(ctx, "alice")
+	require.NoError(t, err)
+	assert.Len(t, transfers, 2)
+
+	for name, transfer := range map[string]*model.ScheduledTransfer{
+		"no run time":       {ToUser: "charity", Amount: 10},
+		"run time passed":   {ToUser: "charity", Amount: 10, NextRunAt: time.Now().Add(-time.Minute)},
+		"invalid schedule":  {ToUser: "charity", Amount: 10, Schedule: "every month"},
+		"unknown receiver":  {ToUser: "dave", Amount: 10, NextRunAt: runAt},
+		"to themselves":     {ToUser: "bob", Amount: 10, NextRunAt: runAt},
+		"no amount":         {ToUser: "charity", NextRunAt: runAt},
+		"schedule never on": {ToUser: "charity", Amount: 10, Schedule: "0 0 30 2 *"},
+	} {
+		_, err := s.Create(ctx, "bob", transfer)
+		require.ErrorIs(t, err, model.ErrBadRequest, name)
+	}
+}
+
+func TestService_Run(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("one-off and recurring", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newTestService(t)
+
+		once, err := s.Create(ctx, "alice", &model.ScheduledTransfer{
+			ToUser: "bob", Amount: 30, NextRunAt: time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+
+		monthly, err := s.Create(ctx, "alice", &model.ScheduledTransfer{
+			ToUser: "charity", Amount: 50, Memo: "donation", Schedule: "@monthly",
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, s.Run(ctx, time.Now()))
+		assert.Equal(t, 100, balance(t, repo, "alice"), "nothing is due yet")
+
+		first := monthly.NextRunAt
+		require.NoError(t, s.Run(ctx, first))
+		assert.Equal(t, 20, balance(t, repo, "alice"))
+		assert.Equal(t, 130, balance(t, repo, "bob"))
+		assert.Equal(t, 150, balance(t, repo, "charity"))
+
+		transfers, err := s.List(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, transfers, 2)
+		assert.Equal(t, model.ScheduledTransferCompleted, transfers[0].Status)
+		assert.Equal(t, model.ScheduledTransferActive, transfers[1].Status)
+		assert.Equal(t, first.AddDate(0, 1, 0), transfers[1].NextRunAt.In(first.Location()))
+
+		require.NoError(t, s.Run(ctx, first), "a time runs once")
+		assert.Equal(t, 20, balance(t, repo, "alice"))
+
+		// The second month fails, the transfer keeps recurring.
+		require.NoError(t, s.Run(ctx, transfers[1].NextRunAt))
+		assert.Equal(t, 20, balance(t, repo, "alice"))
+
+		runs, err := s.Runs(ctx, "alice", monthly.ID)
+		require.NoError(t, err)
+		require.Len(t, runs, 2)
+		assert.Equal(t, model.ScheduledTransferRunFailed, runs[0].Status)
+		assert.Equal(t, "insufficient funds", runs[0].Error)
+		assert.Equal(t, model.ScheduledTransferRunSucceeded, runs[1].Status)
+
+		runs, err = s.Runs(ctx, "alice", once.ID)
+		require.NoError(t, err)
+		assert.Len(t, runs, 1)
+
+		_, err = s.Runs(ctx, "bob", monthly.ID)
+		require.ErrorIs(t, err, model.ErrNotFound)
+
+		alice, err := repo.FindUser(ctx, nil, "alice")
+		require.NoError(t, err)
+
+		history, err := repo.ListTransactions(ctx, nil, alice.ID)
+		require.NoError(t, err)
+		assert.Contains(t, history.Sent, model.CoinsSent{ToUser: "charity", Amount: 50, Memo: "donation"})
+	})
+
+	t.Run("failed one-off", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newTestService(t)
+
+		runAt := time.Now().Add(time.Hour)
+
+		transfer, err := s.Create(ctx, "alice", &model.ScheduledTransfer{ToUser: "bob", Amount: 30, NextRunAt: runAt})
+		require.NoError(t, err)
+
+		bob, err := repo.FindUser(ctx, nil, "bob")
+		require.NoError(t, err)
+
+		deactivatedAt := time.Now()
+		require.NoError(t, repo.SetUserDeactivatedAt(ctx, nil, bob.ID, &deactivatedAt))
+
+		require.NoError(t, s.Run(ctx, runAt))
+		assert.Equal(t, 100, balance(t, repo, "alice"))
+
+		transfers, err := s.List(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, model.ScheduledTransferFailed, transfers[0].Status)
+
+		runs, err := s.Runs(ctx, "alice", transfer.ID)
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, "bad request: receiver is deactivated", runs[0].Error)
+	})
+
+	t.Run("failed run insert", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newTestService(t)
+		failing := &failingRunRepository{Repository: repo, failures: 2}
+		s.repo = failing
+
+		runAt := time.Now().Add(time.Hour)
+
+		transfer, err := s.Create(ctx, "alice", &model.ScheduledTransfer{ToUser: "bob", Amount: 30, NextRunAt: runAt})
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.Run(ctx, runAt), errRunInsert)
+		assert.Equal(t, 100, balance(t, repo, "alice"), "the transfer is rolled back with its run")
+
+		transfers, err := s.List(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, model.ScheduledTransferActive, transfers[0].Status, "the transfer is still due")
+
+		require.NoError(t, s.Run(ctx, runAt.Add(time.Minute)))
+		assert.Equal(t, 70, balance(t, repo, "alice"))
+
+		transfers, err = s.List(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, model.ScheduledTransferCompleted, transfers[0].Status)
+
+		runs, err := s.Runs(ctx, "alice", transfer.ID)
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Empty(t, runs[0].Error)
+
+		require.NoError(t, s.Run(ctx, runAt.Add(time.Hour)))
+		assert.Equal(t, 70, balance(t, repo, "alice"), "a completed transfer is not made again")
+	})
+
+	t.Run("failed transaction", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newTestService(t)
+		s.repo = &failingRunRepository{Repository: repo, failures: 1}
+
+		runAt := time.Now().Add(time.Hour)
+
+		transfer, err := s.Create(ctx, "alice", &model.ScheduledTransfer{ToUser: "bob", Amount: 30, NextRunAt: runAt})
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.Run(ctx, runAt), errRunInsert)
+		assert.Equal(t, 100, balance(t, repo, "alice"))
+
+		transfers, err := s.List(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, model.ScheduledTransferFailed, transfers[0].Status, "the failure is recorded on its own")
+
+		runs, err := s.Runs(ctx, "alice", transfer.ID)
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, model.ErrInternalServerError.Error(), runs[0].Error)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newTestService(t)
+
+		monthly, err := s.Create(ctx, "alice", &model.ScheduledTransfer{ToUser: "charity", Amount: 50, Schedule: "@monthly"})
+		require.NoError(t, err)
+
+		_, err = s.Create(ctx, "alice", &model.ScheduledTransfer{ToUser: "bob", Amount: 10, Schedule: "@daily"})
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.Cancel(ctx, "bob", monthly.ID), model.ErrNotFound)
+		require.NoError(t, s.Cancel(ctx, "alice", monthly.ID))
+		require.ErrorIs(t, s.Cancel(ctx, "alice", monthly.ID), model.ErrConflict)
+
+		require.NoError(t, s.Run(ctx, monthly.NextRunAt))
+		assert.Equal(t, 90, balance(t, repo, "alice"), "only the daily transfer runs")
+
+		_, err = s.Create(ctx, "alice", &model.ScheduledTransfer{ToUser: "charity", Amount: 50, Schedule: "@weekly"})
+		require.NoError(t, err, "cancelled transfers do not count towards the limit")
+	})
+}
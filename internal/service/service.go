@@ -6,7 +6,7 @@ import (
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 )
 
-//go:generate mockgen -destination=../../mocks/mock_service.go -package=mocks github.com/esklo/avito-backend-winter-2025/internal/service Hasher,Authenticator,UserManager,Shop
+//go:generate mockgen -destination=../../mocks/mock_service.go -package=mocks github.com/esklo/avito-backend-winter-2025/internal/service Hasher,Authenticator,UserManager,Shop,Webhooks,Notifications,Idempotency,Admin,ScheduledTransfers
 
 type Hasher interface {
 	Hash(password string) (hash []byte, salt []byte, err error)
@@ -14,17 +14,89 @@ type Hasher interface {
 }
 
 type Authenticator interface {
-	Login(ctx context.Context, username, password string) (string, error)
+	Login(ctx context.Context, username, password, referralCode string) (string, error)
 	ValidateToken(ctx context.Context, token string) (string, error)
 }
 
 type UserManager interface {
-	Create(ctx context.Context, username, password string) (*model.User, error)
+	Create(ctx context.Context, username, password, referralCode string) (*model.User, error)
 	Info(ctx context.Context, username string) (*model.Info, error)
-	Transfer(ctx context.Context, from, to string, amount int) error
+	Referral(ctx context.Context, username string) (*model.ReferralInfo, error)
+	Transfer(ctx context.Context, from, to string, amount int, memo string) (*model.PendingTransfer, error)
+	TransferBatch(ctx context.Context, from string, transfers []model.BatchTransfer) (*model.BatchTransferReport, error)
+	HoldTransfer(ctx context.Context, from, to string, amount int, memo string) (*model.PendingTransfer, error)
+	PendingTransfers(ctx context.Context, username string) (*model.PendingTransfers, error)
+	AcceptTransfer(ctx context.Context, username string, id int64) error
+	DeclineTransfer(ctx context.Context, username string, id int64) error
+	RequestPayment(ctx context.Context, requester, payer string, amount int, memo string) (*model.PaymentRequest, error)
+	PaymentRequests(ctx context.Context, username string) (*model.PaymentRequests, error)
+	PayRequest(ctx context.Context, username string, id int64) error
+	DeclineRequest(ctx context.Context, username string, id int64) error
+}
+
+// ScheduledTransfers manages the transfers users set up to be made later,
+// once or on a schedule.
+type ScheduledTransfers interface {
+	Create(ctx context.Context, username string, transfer *model.ScheduledTransfer) (*model.ScheduledTransfer, error)
+	List(ctx context.Context, username string) ([]model.ScheduledTransfer, error)
+	Runs(ctx context.Context, username string, id int64) ([]model.ScheduledTransferRun, error)
+	Cancel(ctx context.Context, username string, id int64) error
 }
 
 type Shop interface {
 	GetItem(ctx context.Context, name string) (*model.Item, error)
-	BuyItem(ctx context.Context, name, username string) error
+	Variants(ctx context.Context, name string) (*model.Item, []model.Variant, error)
+	BuyItem(ctx context.Context, name string, variant model.VariantAttributes, username string) error
+	Cart(ctx context.Context, username string) (*model.Cart, error)
+	AddToCart(
+		ctx context.Context, username, name string, variant model.VariantAttributes, quantity int,
+	) (*model.Cart, error)
+	RemoveFromCart(ctx context.Context, username, name string, variant model.VariantAttributes) (*model.Cart, error)
+	Checkout(ctx context.Context, username string) (*model.Cart, error)
+}
+
+type Webhooks interface {
+	Create(ctx context.Context, url string, eventTypes []model.EventType) (*model.Webhook, error)
+	List(ctx context.Context) ([]model.Webhook, error)
+	Delete(ctx context.Context, id int) error
+	ListDeliveries(ctx context.Context, webhookID int) ([]model.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, id int64) (*model.WebhookDelivery, error)
+}
+
+type Notifications interface {
+	// Subscribe streams the notifications of the user that come after
+	// lastEventID, or only new ones if it is zero, until ctx is done.
+	Subscribe(ctx context.Context, username string, lastEventID int64) (<-chan model.Notification, error)
+}
+
+type Idempotency interface {
+	Begin(ctx context.Context, username, key, requestHash string) (*model.IdempotencyKey, error)
+	Complete(ctx context.Context, key *model.IdempotencyKey, statusCode int, response []byte) error
+	Abort(ctx context.Context, key *model.IdempotencyKey) error
+}
+
+// Admin runs operator tasks. The changes of a dry run are rolled back, the
+// result shows what they would have been.
+type Admin interface {
+	GetUser(ctx context.Context, username string) (*model.User, error)
+	ListUsers(ctx context.Context, after string, limit int) ([]model.User, error)
+	Mint(ctx context.Context, username string, amount int, reason string, dryRun bool) (*model.User, error)
+	Burn(ctx context.Context, username string, amount int, reason string, dryRun bool) (*model.User, error)
+	ListUserTransfers(ctx context.Context, username string, limit int) ([]model.Transfer, error)
+	ReverseTransfer(ctx context.Context, id int64, reason string, partial, dryRun bool) (*model.Transfer, error)
+	DeactivateUser(ctx context.Context, username string, dryRun bool) (*model.User, error)
+	ActivateUser(ctx context.Context, username string, dryRun bool) (*model.User, error)
+	ListItems(ctx context.Context) ([]model.Item, error)
+	CreateItem(ctx context.Context, name string, price int, dryRun bool) (*model.Item, error)
+	SetItemStock(ctx context.Context, name string, stock *int, dryRun bool) (*model.Item, error)
+	ListVariants(ctx context.Context, name string) ([]model.Variant, error)
+	CreateVariant(ctx context.Context, name string, variant model.Variant, dryRun bool) (*model.Variant, error)
+	SetVariantStock(
+		ctx context.Context, name string, attrs model.VariantAttributes, stock *int, dryRun bool,
+	) (*model.Variant, error)
+	Reconcile(ctx context.Context) (*model.Reconciliation, error)
+	TransferLimits(ctx context.Context, username string) (*model.UserTransferLimits, error)
+	SetTransferLimits(
+		ctx context.Context, username string, override *model.TransferLimitsOverride, dryRun bool,
+	) (*model.UserTransferLimits, error)
 }
@@ -0,0 +1,211 @@
+package shop
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+// Cart returns the cart of the user at the current prices.
+func (s *Service) Cart(ctx context.Context, username string) (*model.Cart, error) {
+	user, err := s.repo.FindUser(ctx, nil, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrUnauthorized
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	return s.cart(ctx, nil, user.ID)
+}
+
+// AddToCart adds quantity items, of the variant if the item has variants, to
+// the cart of the user and returns the cart. Stock is not reserved, it is
+// checked at checkout.
+func (s *Service) AddToCart(
+	ctx context.Context, username, name string, variant model.VariantAttributes, quantity int,
+) (*model.Cart, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be positive", model.ErrBadRequest)
+	}
+
+	var cart *model.Cart
+
+	err := s.repo.WithTx(ctx, func(tx repository.DB) error {
+		user, item, err := s.lockCartItem(ctx, tx, username, name)
+		if err != nil {
+			return err
+		}
+
+		line, err := s.line(ctx, tx, item, variant, quantity)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.AddCartItem(ctx, tx, user.ID, item.ID, line.VariantID, quantity); err != nil {
+			return err
+		}
+
+		if cart, err = s.cart(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		if len(cart.Items) > model.MaxCartLines {
+			return fmt.Errorf("%w: a cart holds at most %d items", model.ErrLimitExceeded, model.MaxCartLines)
+		}
+
+		for _, line := range cart.Items {
+			if line.Quantity > model.MaxCartQuantity {
+				return fmt.Errorf("%w: a cart holds at most %d of an item", model.ErrLimitExceeded, model.MaxCartQuantity)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// RemoveFromCart removes the item, or its variant, from the cart of the user
+// and returns the cart.
+func (s *Service) RemoveFromCart(
+	ctx context.Context, username, name string, variant model.VariantAttributes,
+) (*model.Cart, error) {
+	var cart *model.Cart
+
+	err := s.repo.WithTx(ctx, func(tx repository.DB) error {
+		user, item, err := s.lockCartItem(ctx, tx, username, name)
+		if err != nil {
+			return err
+		}
+
+		line := model.CartItem{Item: item.Name}
+
+		if !variant.IsZero() {
+			v, err := s.repo.FindVariant(ctx, tx, item.ID, variant)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: %q has no variant %s", model.ErrNotFound, name, variant)
+			}
+
+			if err != nil {
+				return fmt.Errorf("find variant: %w", err)
+			}
+
+			line.VariantID, line.VariantAttributes = v.ID, v.VariantAttributes
+		}
+
+		err = s.repo.RemoveCartItem(ctx, tx, user.ID, item.ID, line.VariantID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %q is not in the cart", model.ErrNotFound, line.Name())
+		}
+
+		if err != nil {
+			return err
+		}
+
+		cart, err = s.cart(ctx, tx, user.ID)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// Checkout buys everything in the cart of the user at the current prices in
+// a single transaction and empties the cart. Balance and stock are checked
+// for the whole cart, and nothing is bought if any item is out of stock or
+// has got variants since it was added. It returns the cart that was bought.
+func (s *Service) Checkout(ctx context.Context, username string) (*model.Cart, error) {
+	var cart *model.Cart
+
+	err := s.repo.WithTx(ctx, func(tx repository.DB) error {
+		user, err := s.lockBuyer(ctx, tx, username)
+		if err != nil {
+			return err
+		}
+
+		if cart, err = s.cart(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		if len(cart.Items) == 0 {
+			return fmt.Errorf("%w: cart is empty", model.ErrBadRequest)
+		}
+
+		var unavailable []string
+
+		for _, line := range cart.Items {
+			if line.VariantID == 0 {
+				if err := s.checkNoVariants(ctx, tx, line.ItemID, line.Item); err != nil {
+					return err
+				}
+			}
+
+			if !line.Available() {
+				unavailable = append(unavailable, line.Name())
+			}
+		}
+
+		if len(unavailable) > 0 {
+			return fmt.Errorf("%w: %s", model.ErrOutOfStock, strings.Join(unavailable, ", "))
+		}
+
+		if total := cart.Total(); user.Balance < total {
+			return fmt.Errorf("%w: need %d coins, has %d", model.ErrInsufficientFunds, total, user.Balance)
+		}
+
+		if err := s.purchase(ctx, tx, user, cart.Items); err != nil {
+			return err
+		}
+
+		return s.repo.ClearCart(ctx, tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// lockCartItem locks the user, so that the cart is changed by one request at
+// a time, and finds the item.
+func (s *Service) lockCartItem(
+	ctx context.Context, tx repository.DB, username, name string,
+) (*model.User, *model.Item, error) {
+	user, err := s.lockBuyer(ctx, tx, username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	item, err := s.repo.FindItem(ctx, tx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("%w: item %q", model.ErrNotFound, name)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("find item: %w", err)
+	}
+
+	return user, item, nil
+}
+
+func (s *Service) cart(ctx context.Context, tx repository.DB, userID int) (*model.Cart, error) {
+	items, err := s.repo.ListCartItems(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Cart{Items: items}, nil
+}
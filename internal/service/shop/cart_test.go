@@ -0,0 +1,158 @@
+package shop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCartService returns a service with user alice, who has 1000 coins.
+func newCartService(t *testing.T) (*Service, *memory.Repository, *model.User) {
+	t.Helper()
+	ctx := context.Background()
+
+	repo := memory.New()
+
+	err := repo.CreateUser(ctx, nil, &model.User{
+		Username: "alice",
+		Password: []byte("hash"),
+		Salt:     []byte("salt"),
+		Balance:  1000,
+	})
+	require.NoError(t, err)
+
+	user, err := repo.FindUser(ctx, nil, "alice")
+	require.NoError(t, err)
+
+	return NewService(repo), repo, user
+}
+
+func TestService_AddToCart(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s, _, _ := newCartService(t)
+
+	_, err := s.AddToCart(ctx, "alice", "cup", model.VariantAttributes{}, 0)
+	require.ErrorIs(t, err, model.ErrBadRequest)
+
+	_, err = s.AddToCart(ctx, "alice", "missing", model.VariantAttributes{}, 1)
+	require.ErrorIs(t, err, model.ErrNotFound)
+
+	_, err = s.AddToCart(ctx, "alice", "cup", model.VariantAttributes{}, 2)
+	require.NoError(t, err)
+
+	cart, err := s.AddToCart(ctx, "alice", "book", model.VariantAttributes{}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"book", "cup"}, cartItemNames(cart))
+	assert.Equal(t, 2*20+50, cart.Total())
+
+	_, err = s.AddToCart(ctx, "alice", "cup", model.VariantAttributes{}, model.MaxCartQuantity)
+	require.ErrorIs(t, err, model.ErrLimitExceeded)
+
+	cart, err = s.RemoveFromCart(ctx, "alice", "book", model.VariantAttributes{})
+	require.NoError(t, err)
+	assert.Equal(t, []model.CartItem{{ItemID: cart.Items[0].ItemID, Item: "cup", Quantity: 2, Price: 20}}, cart.Items)
+
+	_, err = s.RemoveFromCart(ctx, "alice", "book", model.VariantAttributes{})
+	require.ErrorIs(t, err, model.ErrNotFound)
+
+	cart, err = s.Cart(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 40, cart.Total())
+}
+
+func TestService_Checkout(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("buys the cart", func(t *testing.T) {
+		t.Parallel()
+		s, repo, user := newCartService(t)
+
+		_, err := s.Checkout(ctx, "alice")
+		require.ErrorIs(t, err, model.ErrBadRequest, "the cart is empty")
+
+		_, err = s.AddToCart(ctx, "alice", "cup", model.VariantAttributes{}, 3)
+		require.NoError(t, err)
+
+		_, err = s.AddToCart(ctx, "alice", "hoody", model.VariantAttributes{}, 1)
+		require.NoError(t, err)
+
+		cart, err := s.Checkout(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 3*20+300, cart.Total())
+
+		stored, err := repo.FindUser(ctx, nil, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 1000-360, stored.Balance)
+
+		inventory, err := repo.ListInventory(ctx, nil, user.ID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []model.Inventory{{Type: "cup", Quantity: 3}, {Type: "hoody", Quantity: 1}}, inventory)
+
+		cart, err = s.Cart(ctx, "alice")
+		require.NoError(t, err)
+		assert.Empty(t, cart.Items)
+
+		notifications, err := repo.ListNotifications(ctx, nil, user.ID, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, notifications, 3, "a purchase per line and the balance")
+		assert.JSONEq(t, `{"balance": 640}`, string(notifications[2].Payload))
+	})
+
+	t.Run("fails entirely", func(t *testing.T) {
+		t.Parallel()
+		s, repo, user := newCartService(t)
+
+		cup, err := repo.FindItem(ctx, nil, "cup")
+		require.NoError(t, err)
+
+		stock := 1
+		require.NoError(t, repo.SetItemStock(ctx, nil, cup.ID, &stock))
+
+		_, err = s.AddToCart(ctx, "alice", "book", model.VariantAttributes{}, 1)
+		require.NoError(t, err)
+
+		_, err = s.AddToCart(ctx, "alice", "cup", model.VariantAttributes{}, 2)
+		require.NoError(t, err)
+
+		_, err = s.Checkout(ctx, "alice")
+		require.ErrorIs(t, err, model.ErrOutOfStock)
+		assert.ErrorContains(t, err, "cup")
+
+		_, err = s.RemoveFromCart(ctx, "alice", "cup", model.VariantAttributes{})
+		require.NoError(t, err)
+
+		_, err = s.AddToCart(ctx, "alice", "pink-hoody", model.VariantAttributes{}, 2)
+		require.NoError(t, err)
+
+		_, err = s.Checkout(ctx, "alice")
+		require.ErrorIs(t, err, model.ErrInsufficientFunds)
+
+		stored, err := repo.FindUser(ctx, nil, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 1000, stored.Balance)
+
+		inventory, err := repo.ListInventory(ctx, nil, user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, inventory)
+
+		cart, err := s.Cart(ctx, "alice")
+		require.NoError(t, err)
+		assert.Len(t, cart.Items, 2, "the cart is kept")
+	})
+}
+
+func cartItemNames(cart *model.Cart) []string {
+	names := make([]string, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		names = append(names, item.Item)
+	}
+
+	return names
+}
@@ -2,6 +2,8 @@ package shop
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
@@ -23,7 +25,8 @@ func (s *Service) GetItem(ctx context.Context, name string) (*model.Item, error)
 	return s.repo.FindItem(ctx, nil, name)
 }
 
-func (s *Service) BuyItem(ctx context.Context, name, username string) error {
+// BuyItem buys the item, or its variant if the item has variants.
+func (s *Service) BuyItem(ctx context.Context, name string, variant model.VariantAttributes, username string) error {
 	if username == "" {
 		return model.ErrUnauthorized
 	}
@@ -33,9 +36,9 @@ func (s *Service) BuyItem(ctx context.Context, name, username string) error {
 	}
 
 	return s.repo.WithTx(ctx, func(tx repository.DB) error {
-		user, err := s.repo.FindUser(ctx, tx, username)
+		user, err := s.lockBuyer(ctx, tx, username)
 		if err != nil {
-			return model.ErrUnauthorized
+			return err
 		}
 
 		item, err := s.repo.FindItem(ctx, tx, name)
@@ -43,14 +46,113 @@ func (s *Service) BuyItem(ctx context.Context, name, username string) error {
 			return model.ErrNotFound
 		}
 
-		if user.Balance < item.Price {
+		line, err := s.line(ctx, tx, item, variant, 1)
+		if err != nil {
+			return err
+		}
+
+		if !line.Available() {
+			return fmt.Errorf("%w: %s", model.ErrOutOfStock, line.Name())
+		}
+
+		if user.Balance < line.Price {
 			return fmt.Errorf("%w: need %d coins, has %d",
 				model.ErrInsufficientFunds,
-				item.Price,
+				line.Price,
 				user.Balance,
 			)
 		}
 
-		return s.repo.MakePurchase(ctx, tx, user.ID, item.ID, item.Price)
+		return s.purchase(ctx, tx, user, []model.CartItem{line})
+	})
+}
+
+// lockBuyer locks the user, who must be active to buy items.
+func (s *Service) lockBuyer(ctx context.Context, tx repository.DB, username string) (*model.User, error) {
+	user, err := s.repo.FindUserForUpdate(ctx, tx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrUnauthorized
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("lock user: %w", err)
+	}
+
+	if !user.Active() {
+		return nil, model.ErrUserDeactivated
+	}
+
+	return user, nil
+}
+
+// purchase buys the lines for the locked user, who can afford them, and
+// records an event and a notification for each line.
+func (s *Service) purchase(ctx context.Context, tx repository.DB, user *model.User, lines []model.CartItem) error {
+	purchases := make([]model.ItemPurchased, 0, len(lines))
+
+	for _, line := range lines {
+		err := s.repo.MakePurchase(ctx, tx, &model.Purchase{
+			UserID:    user.ID,
+			ItemID:    line.ItemID,
+			VariantID: line.VariantID,
+			Quantity:  line.Quantity,
+			Price:     line.Total(),
+		})
+		if err != nil {
+			return err
+		}
+
+		purchase := model.ItemPurchased{
+			Username:          user.Username,
+			Item:              line.Item,
+			VariantAttributes: line.VariantAttributes,
+			Quantity:          line.Quantity,
+			Price:             line.Total(),
+		}
+
+		if err := s.addEvent(ctx, tx, model.EventItemPurchased, user.ID, purchase); err != nil {
+			return err
+		}
+
+		purchases = append(purchases, purchase)
+	}
+
+	return s.notifyPurchases(ctx, tx, user, purchases)
+}
+
+// notifyPurchases confirms the purchases to the user and tells them the new
+// balance. The user holds the balance from before the purchases.
+func (s *Service) notifyPurchases(
+	ctx context.Context, tx repository.DB, user *model.User, purchases []model.ItemPurchased,
+) error {
+	notifications := make([]*model.Notification, 0, len(purchases)+1)
+	balance := user.Balance
+
+	for _, purchase := range purchases {
+		purchased, err := model.NewNotification(model.NotificationItemPurchased, user.ID, purchase)
+		if err != nil {
+			return err
+		}
+
+		notifications = append(notifications, purchased)
+		balance -= purchase.Price
+	}
+
+	changed, err := model.NewNotification(model.NotificationBalanceChanged, user.ID, model.BalanceChanged{
+		Balance: balance,
 	})
+	if err != nil {
+		return err
+	}
+
+	return s.repo.AddNotifications(ctx, tx, append(notifications, changed)...)
+}
+
+func (s *Service) addEvent(ctx context.Context, tx repository.DB, eventType model.EventType, userID int, payload any) error {
+	event, err := model.NewEvent(eventType, userID, payload)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.AddEvent(ctx, tx, event)
 }
@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 	"github.com/esklo/avito-backend-winter-2025/internal/repository"
 	"github.com/esklo/avito-backend-winter-2025/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
@@ -94,7 +96,7 @@ func TestService_BuyItem(t *testing.T) {
 			t.Run(tt.name, func(t *testing.T) {
 				t.Parallel()
 				ts := newTestSuite(t)
-				err := ts.shop.BuyItem(ctx, tt.item, tt.username)
+				err := ts.shop.BuyItem(ctx, tt.item, model.VariantAttributes{}, tt.username)
 				assert.ErrorIs(t, err, tt.expectedError)
 			})
 		}
@@ -109,12 +111,12 @@ func TestService_BuyItem(t *testing.T) {
 
 		ts.repo.EXPECT().
 			WithTx(gomock.Any(), gomock.Any()).
-			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error) error {
+			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
 				return fn(nil)
 			})
 
 		ts.repo.EXPECT().
-			FindUser(gomock.Any(), nil, user.Username).
+			FindUserForUpdate(gomock.Any(), nil, user.Username).
 			Return(user, nil)
 
 		ts.repo.EXPECT().
@@ -122,10 +124,30 @@ func TestService_BuyItem(t *testing.T) {
 			Return(item, nil)
 
 		ts.repo.EXPECT().
-			MakePurchase(gomock.Any(), nil, user.ID, item.ID, item.Price).
+			ListVariants(gomock.Any(), nil, item.ID).
+			Return(nil, nil)
+
+		ts.repo.EXPECT().
+			MakePurchase(gomock.Any(), nil, &model.Purchase{UserID: user.ID, ItemID: item.ID, Quantity: 1, Price: item.Price}).
 			Return(nil)
 
-		err := ts.shop.BuyItem(ctx, item.Name, user.Username)
+		ts.repo.EXPECT().
+			AddEvent(gomock.Any(), nil, gomock.Cond(func(e *model.Event) bool {
+				return e.Type == model.EventItemPurchased && e.UserID == user.ID
+			})).
+			Return(nil)
+
+		ts.repo.EXPECT().
+			AddNotifications(gomock.Any(), nil, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ repository.DB, notifications ...*model.Notification) error {
+				require.Len(t, notifications, 2)
+				assert.Equal(t, model.NotificationItemPurchased, notifications[0].Type)
+				assert.JSONEq(t, `{"balance": 500}`, string(notifications[1].Payload))
+
+				return nil
+			})
+
+		err := ts.shop.BuyItem(ctx, item.Name, model.VariantAttributes{}, user.Username)
 		assert.NoError(t, err)
 	})
 
@@ -138,19 +160,44 @@ func TestService_BuyItem(t *testing.T) {
 
 		ts.repo.EXPECT().
 			WithTx(gomock.Any(), gomock.Any()).
-			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error) error {
+			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
 				return fn(nil)
 			})
 
 		ts.repo.EXPECT().
-			FindUser(gomock.Any(), nil, user.Username).
+			FindUserForUpdate(gomock.Any(), nil, user.Username).
 			Return(user, nil)
 
 		ts.repo.EXPECT().
 			FindItem(gomock.Any(), nil, item.Name).
 			Return(item, nil)
 
-		err := ts.shop.BuyItem(ctx, item.Name, user.Username)
+		ts.repo.EXPECT().
+			ListVariants(gomock.Any(), nil, item.ID).
+			Return(nil, nil)
+
+		err := ts.shop.BuyItem(ctx, item.Name, model.VariantAttributes{}, user.Username)
 		assert.ErrorIs(t, err, model.ErrInsufficientFunds)
 	})
+
+	t.Run("deactivated user", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		deactivatedAt := time.Now()
+		user := &model.User{ID: 1, Username: "buyer", Balance: 1000, DeactivatedAt: &deactivatedAt}
+
+		ts.repo.EXPECT().
+			WithTx(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
+				return fn(nil)
+			})
+
+		ts.repo.EXPECT().
+			FindUserForUpdate(gomock.Any(), nil, user.Username).
+			Return(user, nil)
+
+		err := ts.shop.BuyItem(ctx, "cup", model.VariantAttributes{}, user.Username)
+		assert.ErrorIs(t, err, model.ErrForbidden)
+	})
 }
@@ -0,0 +1,79 @@
+package shop
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+// Variants returns the item and its variants, if it has any.
+func (s *Service) Variants(ctx context.Context, name string) (*model.Item, []model.Variant, error) {
+	item, err := s.repo.FindItem(ctx, nil, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("%w: item %q", model.ErrNotFound, name)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("find item: %w", err)
+	}
+
+	variants, err := s.repo.ListVariants(ctx, nil, item.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return item, variants, nil
+}
+
+// line returns a line of quantity items of the chosen variant of the item,
+// priced and stocked as the variant.
+func (s *Service) line(
+	ctx context.Context, tx repository.DB, item *model.Item, attrs model.VariantAttributes, quantity int,
+) (model.CartItem, error) {
+	line := model.CartItem{
+		ItemID:   item.ID,
+		Item:     item.Name,
+		Quantity: quantity,
+		Price:    item.Price,
+		Stock:    item.Stock,
+	}
+
+	if attrs.IsZero() {
+		return line, s.checkNoVariants(ctx, tx, item.ID, item.Name)
+	}
+
+	variant, err := s.repo.FindVariant(ctx, tx, item.ID, attrs)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.CartItem{}, fmt.Errorf("%w: %q has no variant %s", model.ErrNotFound, item.Name, attrs)
+	}
+
+	if err != nil {
+		return model.CartItem{}, fmt.Errorf("find variant: %w", err)
+	}
+
+	line.VariantID = variant.ID
+	line.VariantAttributes = variant.VariantAttributes
+	line.Price += variant.PriceDelta
+	line.Stock = variant.Stock
+
+	return line, nil
+}
+
+// checkNoVariants fails if the item has variants, as it can then only be
+// bought as one of them.
+func (s *Service) checkNoVariants(ctx context.Context, tx repository.DB, itemID int, name string) error {
+	variants, err := s.repo.ListVariants(ctx, tx, itemID)
+	if err != nil {
+		return err
+	}
+
+	if len(variants) > 0 {
+		return fmt.Errorf("%w of %q", model.ErrVariantRequired, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package shop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addVariants adds sizes S and L to the t-shirt, L costs 10 coins more and
+// one is left.
+func addVariants(t *testing.T, repo *memory.Repository) *model.Item {
+	t.Helper()
+	ctx := context.Background()
+
+	item, err := repo.FindItem(ctx, nil, "t-shirt")
+	require.NoError(t, err)
+
+	stock := 1
+	for _, v := range []*model.Variant{
+		{ItemID: item.ID, VariantAttributes: model.VariantAttributes{Size: "S"}},
+		{ItemID: item.ID, VariantAttributes: model.VariantAttributes{Size: "L"}, PriceDelta: 10, Stock: &stock},
+	} {
+		require.NoError(t, repo.CreateVariant(ctx, nil, v))
+	}
+
+	return item
+}
+
+func TestService_BuyItem_Variant(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s, repo, user := newCartService(t)
+	item := addVariants(t, repo)
+	large := model.VariantAttributes{Size: "L"}
+
+	err := s.BuyItem(ctx, "t-shirt", model.VariantAttributes{}, "alice")
+	require.ErrorIs(t, err, model.ErrBadRequest, "a variant must be chosen")
+
+	err = s.BuyItem(ctx, "t-shirt", model.VariantAttributes{Size: "XL"}, "alice")
+	require.ErrorIs(t, err, model.ErrNotFound)
+
+	err = s.BuyItem(ctx, "cup", large, "alice")
+	require.ErrorIs(t, err, model.ErrNotFound, "the cup has no variants")
+
+	require.NoError(t, s.BuyItem(ctx, "t-shirt", large, "alice"))
+	require.ErrorIs(t, s.BuyItem(ctx, "t-shirt", large, "alice"), model.ErrOutOfStock)
+
+	found, err := repo.FindUser(ctx, nil, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 1000-item.Price-10, found.Balance)
+
+	inventory, err := repo.ListInventory(ctx, nil, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Inventory{{Type: "t-shirt", VariantAttributes: large, Quantity: 1}}, inventory)
+}
+
+func TestService_Checkout_Variants(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s, repo, _ := newCartService(t)
+
+	_, err := s.AddToCart(ctx, "alice", "t-shirt", model.VariantAttributes{}, 1)
+	require.NoError(t, err)
+
+	item := addVariants(t, repo)
+
+	_, err = s.AddToCart(ctx, "alice", "t-shirt", model.VariantAttributes{}, 1)
+	require.ErrorIs(t, err, model.ErrBadRequest)
+
+	_, err = s.Checkout(ctx, "alice")
+	require.ErrorIs(t, err, model.ErrBadRequest, "the t-shirt got variants after it was added")
+
+	_, err = s.RemoveFromCart(ctx, "alice", "t-shirt", model.VariantAttributes{})
+	require.NoError(t, err)
+
+	_, err = s.AddToCart(ctx, "alice", "t-shirt", model.VariantAttributes{Size: "S"}, 2)
+	require.NoError(t, err)
+
+	cart, err := s.AddToCart(ctx, "alice", "t-shirt", model.VariantAttributes{Size: "L"}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2*(item.Price+10)+2*item.Price, cart.Total())
+
+	_, err = s.Checkout(ctx, "alice")
+	require.ErrorIs(t, err, model.ErrOutOfStock)
+	assert.ErrorContains(t, err, "t-shirt (L)")
+
+	_, err = s.RemoveFromCart(ctx, "alice", "t-shirt", model.VariantAttributes{Size: "L"})
+	require.NoError(t, err)
+
+	bought, err := s.Checkout(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 2*item.Price, bought.Total())
+}
@@ -0,0 +1,189 @@
+package user
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+// errBatchNotApplied rolls back a batch with failed transfers, the caller
+// reports them instead.
+var errBatchNotApplied = errors.New("batch not applied")
+
+// TransferBatch sends coins from one user to several others in a single
+// transaction. Either every transfer is made or none is: the report tells
+// which transfers failed when the batch is rejected.
+func (s *Service) TransferBatch(
+	ctx context.Context, from string, transfers []model.BatchTransfer,
+) (*model.BatchTransferReport, error) {
+	if from == "" {
+		return nil, model.ErrUnauthorized
+	}
+
+	if len(transfers) == 0 || len(transfers) > model.MaxBatchTransfers {
+		return nil, fmt.Errorf("%w: a batch has 1 to %d transfers", model.ErrBadRequest, model.MaxBatchTransfers)
+	}
+
+	transfers = slices.Clone(transfers)
+
+	report := model.NewBatchTransferReport(transfers, model.BatchTransferSkipped)
+	if err := validateBatch(from, transfers, report); err != nil {
+		return report, batchError(err, report)
+	}
+
+	receivers := make([]string, len(transfers))
+	for i, t := range transfers {
+		receivers[i] = t.ToUser
+	}
+
+	var failed error
+
+	err := s.repo.WithTx(ctx, func(tx repository.DB) error {
+		// All users are locked at once in the order of their IDs, so
+		// concurrent batches can't deadlock.
+		users, err := s.repo.FindUsersForUpdate(ctx, tx, append(receivers, from)...)
+		if err != nil {
+			return fmt.Errorf("lock users: %w", err)
+		}
+
+		sender, ok := users[from]
+		if !ok {
+			return model.ErrUnauthorized
+		}
+
+		if !sender.Active() {
+			return model.ErrUserDeactivated
+		}
+
+		day := model.TransferDay(time.Now())
+
+		limits, usage, err := s.transferLimits(ctx, tx, sender.ID, day)
+		if err != nil {
+			return err
+		}
+
+		for i, t := range transfers {
+			if err := checkReceiver(users[t.ToUser]); err != nil {
+				failed = cmp.Or(failed, err)
+				report.Fail(i, err)
+
+				continue
+			}
+
+			if err := limits.Check(usage, t.Amount); err != nil {
+				failed = cmp.Or(failed, err)
+				report.Fail(i, err)
+
+				continue
+			}
+
+			usage.Amount += t.Amount
+			usage.Count++
+		}
+
+		if failed != nil {
+			failed = batchError(failed, report)
+
+			return errBatchNotApplied
+		}
+
+		if sender.Balance < report.Total {
+			failed = fmt.Errorf("%w: the batch needs %d coins", model.ErrInsufficientFunds, report.Total)
+
+			return errBatchNotApplied
+		}
+
+		for _, t := range transfers {
+			if err := s.repo.AddTransferUsage(ctx, tx, sender.ID, day, t.Amount); err != nil {
+				return err
+			}
+
+			receiver := users[t.ToUser]
+			if err := s.transfer(ctx, tx, sender, receiver, t.Amount, t.Memo); err != nil {
+				return err
+			}
+
+			sender.Balance -= t.Amount
+			receiver.Balance += t.Amount
+		}
+
+		return nil
+	})
+	if errors.Is(err, errBatchNotApplied) {
+		return report, failed
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range report.Results {
+		report.Results[i].Status = model.BatchTransferApplied
+	}
+
+	return report, nil
+}
+
+// validateBatch checks the transfers that don't depend on stored data and
+// sanitizes their memos.
+func validateBatch(from string, transfers []model.BatchTransfer, report *model.BatchTransferReport) error {
+	var failed error
+
+	seen := make(map[string]bool, len(transfers))
+
+	for i := range transfers {
+		t := &transfers[i]
+
+		var err error
+
+		switch {
+		case t.ToUser == "":
+			err = fmt.Errorf("%w: receiver is required", model.ErrBadRequest)
+		case t.ToUser == from:
+			err = fmt.Errorf("%w: can't send coins to yourself", model.ErrBadRequest)
+		case seen[t.ToUser]:
+			err = fmt.Errorf("%w: receiver is listed more than once", model.ErrBadRequest)
+		case t.Amount <= 0:
+			err = fmt.Errorf("%w: amount must be positive", model.ErrBadRequest)
+		default:
+			t.Memo, err = SanitizeMemo(t.Memo)
+		}
+
+		seen[t.ToUser] = true
+
+		if err != nil {
+			failed = cmp.Or(failed, err)
+			report.Fail(i, err)
+		}
+	}
+
+	return failed
+}
+
+func checkReceiver(receiver *model.User) error {
+	if receiver == nil {
+		return fmt.Errorf("%w: receiver not found", model.ErrBadRequest)
+	}
+
+	if !receiver.Active() {
+		return fmt.Errorf("%w: receiver is deactivated", model.ErrBadRequest)
+	}
+
+	return nil
+}
+
+// batchError returns the error a batch is rejected with: the first failed
+// transfer and how many failed in total.
+func batchError(first error, report *model.BatchTransferReport) error {
+	if failed := report.Failed(); failed > 1 {
+		return fmt.Errorf("%w (%d of %d transfers failed)", first, failed, len(report.Results))
+	}
+
+	return first
+}
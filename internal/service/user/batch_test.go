@@ -0,0 +1,157 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_TransferBatch(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("applies every transfer", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, memoryConfig{})
+
+		report, err := s.TransferBatch(ctx, "alice", []model.BatchTransfer{
+			{ToUser: "bob", Amount: 30, Memo: "thanks\nbob"},
+			{ToUser: "carol", Amount: 70},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &model.BatchTransferReport{Total: 100, Results: []model.BatchTransferResult{
+			{ToUser: "bob", Amount: 30, Status: model.BatchTransferApplied},
+			{ToUser: "carol", Amount: 70, Status: model.BatchTransferApplied},
+		}}, report)
+		assert.Equal(t, map[string]int{"alice": 0, "bob": 130, "carol": 170}, balances(t, repo))
+
+		alice, err := repo.FindUser(ctx, nil, "alice")
+		require.NoError(t, err)
+
+		history, err := repo.ListTransactions(ctx, nil, alice.ID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []model.CoinsSent{
+			{ToUser: "bob", Amount: 30, Memo: "thanks bob"},
+			{ToUser: "carol", Amount: 70},
+		}, history.Sent)
+
+		notifications, err := repo.ListNotifications(ctx, nil, alice.ID, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, notifications, 2)
+		assert.JSONEq(t, `{"balance": 70}`, string(notifications[0].Payload))
+		assert.JSONEq(t, `{"balance": 0}`, string(notifications[1].Payload))
+	})
+
+	t.Run("invalid transfers", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, memoryConfig{})
+
+		report, err := s.TransferBatch(ctx, "alice", []model.BatchTransfer{
+			{ToUser: "bob", Amount: 10},
+			{ToUser: "alice", Amount: 10},
+			{ToUser: "bob", Amount: 10},
+			{ToUser: "carol"},
+		})
+		require.ErrorIs(t, err, model.ErrBadRequest)
+		assert.Contains(t, err.Error(), "(3 of 4 transfers failed)")
+
+		statuses := make([]model.BatchTransferStatus, len(report.Results))
+		for i, res := range report.Results {
+			statuses[i] = res.Status
+		}
+
+		assert.Equal(t, []model.BatchTransferStatus{
+			model.BatchTransferSkipped,
+			model.BatchTransferFailed,
+			model.BatchTransferFailed,
+			model.BatchTransferFailed,
+		}, statuses)
+		assert.Equal(t, "bad request: receiver is listed more than once", report.Results[2].Error)
+		assert.Equal(t, map[string]int{"alice": 100, "bob": 100, "carol": 100}, balances(t, repo))
+	})
+
+	t.Run("unknown and deactivated receivers", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, memoryConfig{})
+
+		carol, err := repo.FindUser(ctx, nil, "carol")
+		require.NoError(t, err)
+
+		deactivatedAt := time.Now()
+		require.NoError(t, repo.SetUserDeactivatedAt(ctx, nil, carol.ID, &deactivatedAt))
+
+		report, err := s.TransferBatch(ctx, "alice", []model.BatchTransfer{
+			{ToUser: "bob", Amount: 10},
+			{ToUser: "carol", Amount: 10},
+			{ToUser: "dave", Amount: 10},
+		})
+		require.ErrorIs(t, err, model.ErrBadRequest)
+		assert.Equal(t, "bad request: receiver is deactivated (2 of 3 transfers failed)", err.Error())
+		assert.Equal(t, model.BatchTransferSkipped, report.Results[0].Status)
+		assert.Equal(t, "bad request: receiver not found", report.Results[2].Error)
+		assert.Equal(t, map[string]int{"alice": 100, "bob": 100, "carol": 100}, balances(t, repo))
+	})
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, memoryConfig{})
+
+		report, err := s.TransferBatch(ctx, "alice", []model.BatchTransfer{
+			{ToUser: "bob", Amount: 60},
+			{ToUser: "carol", Amount: 60},
+		})
+		require.ErrorIs(t, err, model.ErrInsufficientFunds)
+		assert.Equal(t, model.NewBatchTransferReport([]model.BatchTransfer{
+			{ToUser: "bob", Amount: 60},
+			{ToUser: "carol", Amount: 60},
+		}, model.BatchTransferSkipped), report)
+		assert.Equal(t, map[string]int{"alice": 100, "bob": 100, "carol": 100}, balances(t, repo))
+	})
+
+	t.Run("daily limit", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, memoryConfig{limits: model.TransferLimits{DailyCount: 2}})
+
+		_, err := s.Transfer(ctx, "alice", "bob", 10, "")
+		require.NoError(t, err)
+
+		report, err := s.TransferBatch(ctx, "alice", []model.BatchTransfer{
+			{ToUser: "bob", Amount: 10},
+			{ToUser: "carol", Amount: 10},
+		})
+		require.ErrorIs(t, err, model.ErrLimitExceeded)
+		assert.Equal(t, model.BatchTransferSkipped, report.Results[0].Status)
+		assert.Equal(t, model.BatchTransferFailed, report.Results[1].Status)
+		assert.Equal(t, map[string]int{"alice": 90, "bob": 110, "carol": 100}, balances(t, repo))
+	})
+
+	t.Run("too many transfers", func(t *testing.T) {
+		t.Parallel()
+		s, _ := newMemoryService(t, memoryConfig{})
+
+		transfers := make([]model.BatchTransfer, model.MaxBatchTransfers+1)
+
+		report, err := s.TransferBatch(ctx, "alice", transfers)
+		require.ErrorIs(t, err, model.ErrBadRequest)
+		assert.Nil(t, report)
+	})
+
+	t.Run("deactivated sender", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, memoryConfig{})
+
+		alice, err := repo.FindUser(ctx, nil, "alice")
+		require.NoError(t, err)
+
+		deactivatedAt := time.Now()
+		require.NoError(t, repo.SetUserDeactivatedAt(ctx, nil, alice.ID, &deactivatedAt))
+
+		report, err := s.TransferBatch(ctx, "alice", []model.BatchTransfer{{ToUser: "bob", Amount: 10}})
+		require.ErrorIs(t, err, model.ErrUserDeactivated)
+		assert.Nil(t, report)
+	})
+}
@@ -0,0 +1,38 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+// MaxMemoLength is the maximum length of a transfer memo in characters.
+const MaxMemoLength = 200
+
+// SanitizeMemo makes the memo safe to show to the receiver: line breaks and
+// other control characters become spaces, invisible formatting characters,
+// such as bidi overrides, and invalid UTF-8 are dropped and runs of spaces
+// are collapsed. The result may not be longer than MaxMemoLength.
+func SanitizeMemo(memo string) (string, error) {
+	memo = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r), unicode.IsSpace(r):
+			return ' '
+		case unicode.Is(unicode.Cf, r):
+			return -1
+		default:
+			return r
+		}
+	}, strings.ToValidUTF8(memo, ""))
+
+	memo = strings.Join(strings.Fields(memo), " ")
+
+	if utf8.RuneCountInString(memo) > MaxMemoLength {
+		return "", fmt.Errorf("%w: memo is longer than %d characters", model.ErrBadRequest, MaxMemoLength)
+	}
+
+	return memo, nil
+}
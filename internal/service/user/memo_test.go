@@ -0,0 +1,57 @@
+package user
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeMemo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		memo string
+		want string
+	}{
+		{"empty", "", ""},
+		{"plain", "thanks for the code review", "thanks for the code review"},
+		{"surrounding spaces", "  thanks  ", "thanks"},
+		{"line breaks", "thanks\r\nfor\tthe review", "thanks for the review"},
+		{"control characters", "thanks\x00\x1b[31m!", "thanks [31m!"},
+		{"bidi override", "thanks\u202egnp.exe", "thanksgnp.exe"},
+		{"zero width space", "tha\u200bnks", "thanks"},
+		{"invalid utf-8", "tha\xffnks\xc3", "thanks"},
+		{"replacement character", "thanks \ufffd", "thanks \ufffd"},
+		{"only spaces", " \n\t ", ""},
+		{"unicode", "спасибо 🎉", "спасибо 🎉"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			memo, err := SanitizeMemo(tt.memo)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, memo)
+		})
+	}
+
+	t.Run("length", func(t *testing.T) {
+		t.Parallel()
+
+		memo, err := SanitizeMemo(strings.Repeat("ё", MaxMemoLength))
+		require.NoError(t, err)
+		assert.Equal(t, MaxMemoLength, len([]rune(memo)))
+
+		_, err = SanitizeMemo(strings.Repeat("ё", MaxMemoLength+1))
+		require.ErrorIs(t, err, model.ErrBadRequest)
+
+		memo, err = SanitizeMemo(strings.Repeat("a ", MaxMemoLength) + strings.Repeat(" ", 10))
+		require.ErrorIs(t, err, model.ErrBadRequest)
+		assert.Empty(t, memo)
+	})
+}
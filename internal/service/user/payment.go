@@ -0,0 +1,185 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+// RequestPayment asks the payer to send amount coins to the requester. The
+// payer has the TTL of payment requests to pay it.
+func (s *Service) RequestPayment(
+	ctx context.Context, requester, payer string, amount int, memo string,
+) (*model.PaymentRequest, error) {
+	if requester == "" {
+		return nil, model.ErrUnauthorized
+	}
+
+	if amount <= 0 || payer == "" || requester == payer {
+		return nil, model.ErrBadRequest
+	}
+
+	memo, err := SanitizeMemo(memo)
+	if err != nil {
+		return nil, err
+	}
+
+	var request *model.PaymentRequest
+
+	err = s.repo.WithTx(ctx, func(tx repository.DB) error {
+		from, to, err := s.lockParties(ctx, tx, requester, payer)
+		if err != nil {
+			return err
+		}
+
+		request = &model.PaymentRequest{
+			RequesterID: from.ID,
+			PayerID:     to.ID,
+			Requester:   from.Username,
+			Payer:       to.Username,
+			Amount:      amount,
+			Memo:        memo,
+			ExpiresAt:   time.Now().Add(s.payments.TTL),
+		}
+		if err := s.repo.CreatePaymentRequest(ctx, tx, request); err != nil {
+			return err
+		}
+
+		notification, err := model.NewNotification(model.NotificationPaymentRequested, to.ID, model.PaymentRequested{
+			ID:        request.ID,
+			Requester: from.Username,
+			Amount:    amount,
+			Memo:      memo,
+			ExpiresAt: request.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.repo.AddNotifications(ctx, tx, notification)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// PaymentRequests lists the open payment requests the user has to pay and
+// those they sent.
+func (s *Service) PaymentRequests(ctx context.Context, username string) (*model.PaymentRequests, error) {
+	user, err := s.repo.FindUser(ctx, nil, username)
+	if err != nil {
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	requests, err := s.repo.ListPaymentRequests(ctx, nil, user.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.PaymentRequests{
+		Incoming: make([]model.PaymentRequest, 0),
+		Outgoing: make([]model.PaymentRequest, 0),
+	}
+
+	for _, r := range requests {
+		if r.PayerID == user.ID {
+			result.Incoming = append(result.Incoming, r)
+		} else {
+			result.Outgoing = append(result.Outgoing, r)
+		}
+	}
+
+	return result, nil
+}
+
+// PayRequest pays a payment request to the user with a transfer to the
+// requester, checked and recorded like any other.
+func (s *Service) PayRequest(ctx context.Context, username string, id int64) error {
+	return s.repo.WithTx(ctx, func(tx repository.DB) error {
+		request, err := s.findPayable(ctx, tx, username, id)
+		if err != nil {
+			return err
+		}
+
+		payer, requester, err := s.lockParties(ctx, tx, request.Payer, request.Requester)
+		if err != nil {
+			return err
+		}
+
+		if err := s.spend(ctx, tx, payer, request.Amount); err != nil {
+			return err
+		}
+
+		if err := s.repo.ResolvePaymentRequest(ctx, tx, request, model.PaymentRequestPaid); err != nil {
+			return err
+		}
+
+		return s.transfer(ctx, tx, payer, requester, request.Amount, request.Memo)
+	})
+}
+
+// DeclineRequest declines a payment request to the user and tells the
+// requester about it.
+func (s *Service) DeclineRequest(ctx context.Context, username string, id int64) error {
+	return s.repo.WithTx(ctx, func(tx repository.DB) error {
+		request, err := s.findPayable(ctx, tx, username, id)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.ResolvePaymentRequest(ctx, tx, request, model.PaymentRequestDeclined); err != nil {
+			return err
+		}
+
+		// The requester is locked before they are notified, so that their
+		// notifications commit in the order of their ids.
+		if _, err := s.repo.FindUsersForUpdate(ctx, tx, request.Requester); err != nil {
+			return fmt.Errorf("lock users: %w", err)
+		}
+
+		notification, err := model.NewNotification(
+			model.NotificationPaymentDeclined, request.RequesterID, model.PaymentDeclined{
+				ID:     request.ID,
+				Payer:  request.Payer,
+				Amount: request.Amount,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		return s.repo.AddNotifications(ctx, tx, notification)
+	})
+}
+
+// findPayable locks a payment request to the user that can still be paid or
+// declined.
+func (s *Service) findPayable(
+	ctx context.Context, tx repository.DB, username string, id int64,
+) (*model.PaymentRequest, error) {
+	request, err := s.repo.FindPaymentRequestForUpdate(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && request.Payer != username {
+		return nil, fmt.Errorf("%w: payment request %d", model.ErrNotFound, id)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Status != model.PaymentRequestPending {
+		return nil, fmt.Errorf("%w: request is already %s", model.ErrConflict, request.Status)
+	}
+
+	if !request.Open(time.Now()) {
+		return nil, fmt.Errorf("%w: request has expired", model.ErrConflict)
+	}
+
+	return request, nil
+}
@@ -0,0 +1,202 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// paymentConfig caps transfers at 50 coins, payment requests expire after
+// ttl.
+func paymentConfig(ttl time.Duration) memoryConfig {
+	return memoryConfig{
+		limits:   model.TransferLimits{MaxAmount: 50},
+		payments: config.PaymentRequestsConfig{TTL: ttl},
+	}
+}
+
+func TestService_RequestPayment(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s, repo := newMemoryService(t, paymentConfig(time.Hour))
+
+	request, err := s.RequestPayment(ctx, "alice", "bob", 30, "for\tlunch")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", request.Requester)
+	assert.Equal(t, "bob", request.Payer)
+	assert.Equal(t, "for lunch", request.Memo)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), request.ExpiresAt, time.Minute)
+	assert.Equal(t, map[string]int{"alice": 100, "bob": 100, "carol": 100}, balances(t, repo))
+
+	bob, err := repo.FindUser(ctx, nil, "bob")
+	require.NoError(t, err)
+
+	notifications, err := repo.ListNotifications(ctx, nil, bob.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, model.NotificationPaymentRequested, notifications[0].Type)
+
+	for username, incoming := range map[string]int{"alice": 0, "bob": 1} {
+		requests, err := s.PaymentRequests(ctx, username)
+		require.NoError(t, err)
+		assert.Len(t, requests.Incoming, incoming)
+		assert.Len(t, requests.Outgoing, 1-incoming)
+	}
+
+	_, err = s.RequestPayment(ctx, "alice", "alice", 10, "")
+	require.ErrorIs(t, err, model.ErrBadRequest)
+
+	_, err = s.RequestPayment(ctx, "alice", "dave", 10, "")
+	require.ErrorIs(t, err, model.ErrBadRequest)
+}
+
+func TestService_PayRequest(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("pay", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, paymentConfig(time.Hour))
+
+		request, err := s.RequestPayment(ctx, "alice", "bob", 30, "thanks")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.PayRequest(ctx, "alice", request.ID), model.ErrNotFound, "only the payer pays")
+		require.NoError(t, s.PayRequest(ctx, "bob", request.ID))
+		assert.Equal(t, map[string]int{"alice": 130, "bob": 70, "carol": 100}, balances(t, repo))
+
+		err = s.PayRequest(ctx, "bob", request.ID)
+		require.ErrorIs(t, err, model.ErrConflict)
+		assert.ErrorContains(t, err, "already paid")
+
+		alice, err := repo.FindUser(ctx, nil, "alice")
+		require.NoError(t, err)
+
+		history, err := repo.ListTransactions(ctx, nil, alice.ID)
+		require.NoError(t, err)
+		assert.Contains(t, history.Received, model.CoinsReceived{FromUser: "bob", Amount: 30, Memo: "thanks"})
+	})
+
+	t.Run("transfer checks", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, paymentConfig(time.Hour))
+
+		request, err := s.RequestPayment(ctx, "alice", "bob", 60, "")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.PayRequest(ctx, "bob", request.ID), model.ErrLimitExceeded)
+
+		for _, amount := range []int{50, 30} {
+			_, err = s.Transfer(ctx, "bob", "carol", amount, "")
+			require.NoError(t, err)
+		}
+
+		request, err = s.RequestPayment(ctx, "alice", "bob", 40, "")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.PayRequest(ctx, "bob", request.ID), model.ErrInsufficientFunds)
+		assert.Equal(t, map[string]int{"alice": 100, "bob": 20, "carol": 180}, balances(t, repo))
+
+		requests, err := s.PaymentRequests(ctx, "bob")
+		require.NoError(t, err)
+		assert.Len(t, requests.Incoming, 2, "failed payments leave the requests open")
+	})
+
+	t.Run("decline", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, paymentConfig(time.Hour))
+
+		request, err := s.RequestPayment(ctx, "alice", "bob", 30, "")
+		require.NoError(t, err)
+
+		require.NoError(t, s.DeclineRequest(ctx, "bob", request.ID))
+		assert.Equal(t, map[string]int{"alice": 100, "bob": 100, "carol": 100}, balances(t, repo))
+
+		alice, err := repo.FindUser(ctx, nil, "alice")
+		require.NoError(t, err)
+
+		notifications, err := repo.ListNotifications(ctx, nil, alice.ID, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, notifications, 1)
+		assert.Equal(t, model.NotificationPaymentDeclined, notifications[0].Type)
+
+		requests, err := s.PaymentRequests(ctx, "alice")
+		require.NoError(t, err)
+		assert.Empty(t, requests.Outgoing)
+
+		require.ErrorIs(t, s.PayRequest(ctx, "bob", request.ID), model.ErrConflict)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		t.Parallel()
+		s, _ := newMemoryService(t, paymentConfig(time.Nanosecond))
+
+		request, err := s.RequestPayment(ctx, "alice", "bob", 30, "")
+		require.NoError(t, err)
+
+		requests, err := s.PaymentRequests(ctx, "bob")
+		require.NoError(t, err)
+		assert.Empty(t, requests.Incoming)
+
+		err = s.PayRequest(ctx, "bob", request.ID)
+		require.ErrorIs(t, err, model.ErrConflict)
+		assert.ErrorContains(t, err, "expired")
+	})
+
+	t.Run("unknown request", func(t *testing.T) {
+		t.Parallel()
+		s, _ := newMemoryService(t, paymentConfig(time.Hour))
+
+		require.ErrorIs(t, s.DeclineRequest(ctx, "bob", 42), model.ErrNotFound)
+	})
+}
+
+func TestService_DeclineRequest(t *testing.T) {
+	t.Parallel()
+	ts := newTestSuite(t)
+
+	request := &model.PaymentRequest{
+		ID:          7,
+		RequesterID: 1,
+		PayerID:     2,
+		Requester:   "requester",
+		Payer:       "payer",
+		Amount:      30,
+		Status:      model.PaymentRequestPending,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	ts.repo.EXPECT().
+		WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
+			return fn(nil)
+		})
+
+	gomock.InOrder(
+		ts.repo.EXPECT().FindPaymentRequestForUpdate(gomock.Any(), nil, request.ID).Return(request, nil),
+		ts.repo.EXPECT().ResolvePaymentRequest(gomock.Any(), nil, request, model.PaymentRequestDeclined).Return(nil),
+		ts.repo.EXPECT().
+			FindUsersForUpdate(gomock.Any(), nil, request.Requester).
+			Return(map[string]*model.User{request.Requester: {ID: request.RequesterID}}, nil),
+		ts.repo.EXPECT().
+			AddNotifications(gomock.Any(), nil, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ repository.DB, notifications ...*model.Notification) error {
+				require.Len(t, notifications, 1)
+				assert.Equal(t, model.NotificationPaymentDeclined, notifications[0].Type)
+				assert.Equal(t, request.RequesterID, notifications[0].UserID)
+				assert.JSONEq(t, `{"id": 7, "payer": "payer", "amount": 30}`, string(notifications[0].Payload))
+
+				return nil
+			}),
+	)
+
+	require.NoError(t, ts.users.DeclineRequest(context.Background(), request.Payer, request.ID))
+}
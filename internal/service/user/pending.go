@@ -0,0 +1,319 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+// ExpireJobName is the name of the scheduled job that returns the coins of
+// expired pending transfers.
+const ExpireJobName = "expire-pending-transfers"
+
+// expireBatchSize bounds the pending transfers expired in one transaction.
+const expireBatchSize = 100
+
+// HoldTransfer takes amount coins from one user and holds them until the
+// receiver accepts the transfer. Unless accepted within the TTL, the coins go
+// back to the sender. The transfer counts towards the limits of the sender
+// when it is created.
+func (s *Service) HoldTransfer(
+	ctx context.Context, from, to string, amount int, memo string,
+) (*model.PendingTransfer, error) {
+	if from == "" {
+		return nil, model.ErrUnauthorized
+	}
+
+	if amount <= 0 || to == "" || from == to {
+		return nil, model.ErrBadRequest
+	}
+
+	memo, err := SanitizeMemo(memo)
+	if err != nil {
+		return nil, err
+	}
+
+	var transfer *model.PendingTransfer
+
+	err = s.repo.WithTx(ctx, func(tx repository.DB) error {
+		sender, receiver, err := s.lockParties(ctx, tx, from, to)
+		if err != nil {
+			return err
+		}
+
+		transfer, err = s.hold(ctx, tx, sender, receiver, amount, memo)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// mustHold reports whether a transfer is held by the config of pending
+// transfers: it is over the amount held or the first of the sender to the
+// receiver.
+func (s *Service) mustHold(
+	ctx context.Context, tx repository.DB, sender, receiver *model.User, amount int,
+) (bool, error) {
+	if s.pending.HoldAbove > 0 && amount > s.pending.HoldAbove {
+		return true, nil
+	}
+
+	if !s.pending.HoldNewReceivers {
+		return false, nil
+	}
+
+	transferred, err := s.repo.HasTransferred(ctx, tx, sender.ID, receiver.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return !transferred, nil
+}
+
+// hold takes the coins of a pending transfer from the locked sender and
+// tells both parties about it.
+func (s *Service) hold(
+	ctx context.Context, tx repository.DB, sender, receiver *model.User, amount int, memo string,
+) (*model.PendingTransfer, error) {
+	if err := s.spend(ctx, tx, sender, amount); err != nil {
+		return nil, err
+	}
+
+	transfer := &model.PendingTransfer{
+		SenderID:   sender.ID,
+		ReceiverID: receiver.ID,
+		FromUser:   sender.Username,
+		ToUser:     receiver.Username,
+		Amount:     amount,
+		Memo:       memo,
+		ExpiresAt:  time.Now().Add(s.pending.TTL),
+	}
+	if err := s.repo.CreatePendingTransfer(ctx, tx, transfer); err != nil {
+		return nil, err
+	}
+
+	held, err := model.NewNotification(model.NotificationBalanceChanged, sender.ID, model.BalanceChanged{
+		Balance: sender.Balance - amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := model.NewNotification(model.NotificationTransferPending, receiver.ID, model.TransferPending{
+		ID:        transfer.ID,
+		FromUser:  sender.Username,
+		Amount:    amount,
+		Memo:      memo,
+		ExpiresAt: transfer.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.AddNotifications(ctx, tx, held, pending); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// PendingTransfers lists the pending transfers the user received and sent.
+func (s *Service) PendingTransfers(ctx context.Context, username string) (*model.PendingTransfers, error) {
+	user, err := s.repo.FindUser(ctx, nil, username)
+	if err != nil {
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	transfers, err := s.repo.ListPendingTransfers(ctx, nil, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.PendingTransfers{
+		Incoming: make([]model.PendingTransfer, 0),
+		Outgoing: make([]model.PendingTransfer, 0),
+	}
+
+	for _, t := range transfers {
+		if t.ReceiverID == user.ID {
+			result.Incoming = append(result.Incoming, t)
+		} else {
+			result.Outgoing = append(result.Outgoing, t)
+		}
+	}
+
+	return result, nil
+}
+
+// AcceptTransfer makes a pending transfer to the user with the held coins.
+func (s *Service) AcceptTransfer(ctx context.Context, username string, id int64) error {
+	return s.repo.WithTx(ctx, func(tx repository.DB) error {
+		transfer, err := s.findIncoming(ctx, tx, username, id)
+		if err != nil {
+			return err
+		}
+
+		users, err := s.repo.FindUsersForUpdate(ctx, tx, transfer.FromUser, transfer.ToUser)
+		if err != nil {
+			return fmt.Errorf("lock users: %w", err)
+		}
+
+		sender, receiver := users[transfer.FromUser], users[transfer.ToUser]
+		if sender == nil || receiver == nil {
+			return fmt.Errorf("lock users: %w", sql.ErrNoRows)
+		}
+
+		if !receiver.Active() {
+			return model.ErrUserDeactivated
+		}
+
+		// The held coins go back to the sender and are sent right away, so the
+		// transfer is recorded like any other.
+		if err := s.repo.ResolvePendingTransfer(ctx, tx, transfer, model.PendingTransferAccepted); err != nil {
+			return err
+		}
+
+		sender.Balance += transfer.Amount
+
+		return s.transfer(ctx, tx, sender, receiver, transfer.Amount, transfer.Memo)
+	})
+}
+
+// DeclineTransfer returns the coins of a pending transfer to the user to its
+// sender.
+func (s *Service) DeclineTransfer(ctx context.Context, username string, id int64) error {
+	return s.repo.WithTx(ctx, func(tx repository.DB) error {
+		transfer, err := s.findIncoming(ctx, tx, username, id)
+		if err != nil {
+			return err
+		}
+
+		senders, err := s.lockSenders(ctx, tx, []model.PendingTransfer{*transfer})
+		if err != nil {
+			return err
+		}
+
+		return s.release(ctx, tx, senders[transfer.FromUser], transfer, model.PendingTransferDeclined)
+	})
+}
+
+// ExpirePendingTransfers returns the coins of the pending transfers that
+// expired by at to their senders. It is the run of the scheduled job.
+func (s *Service) ExpirePendingTransfers(ctx context.Context, at time.Time) error {
+	for {
+		var n int
+
+		err := s.repo.WithTx(ctx, func(tx repository.DB) error {
+			transfers, err := s.repo.ListExpiredPendingTransfers(ctx, tx, at, expireBatchSize)
+			if err != nil {
+				return err
+			}
+
+			n = len(transfers)
+
+			senders, err := s.lockSenders(ctx, tx, transfers)
+			if err != nil {
+				return err
+			}
+
+			for i := range transfers {
+				sender := senders[transfers[i].FromUser]
+				if err := s.release(ctx, tx, sender, &transfers[i], model.PendingTransferExpired); err != nil {
+					return fmt.Errorf("expire pending transfer %d: %w", transfers[i].ID, err)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if n < expireBatchSize {
+			return nil
+		}
+	}
+}
+
+// findIncoming locks a pending transfer to the user that can still be
+// accepted or declined.
+func (s *Service) findIncoming(
+	ctx context.Context, tx repository.DB, username string, id int64,
+) (*model.PendingTransfer, error) {
+	transfer, err := s.repo.FindPendingTransferForUpdate(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && transfer.ToUser != username {
+		return nil, fmt.Errorf("%w: pending transfer %d", model.ErrNotFound, id)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if transfer.Status != model.PendingTransferPending {
+		return nil, fmt.Errorf("%w: transfer is already %s", model.ErrConflict, transfer.Status)
+	}
+
+	if !transfer.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: transfer has expired", model.ErrConflict)
+	}
+
+	return transfer, nil
+}
+
+// lockSenders locks the senders of the pending transfers at once, in ID
+// order like every other transaction, before any coins are released.
+func (s *Service) lockSenders(
+	ctx context.Context, tx repository.DB, transfers []model.PendingTransfer,
+) (map[string]*model.User, error) {
+	usernames := make([]string, 0, len(transfers))
+	for _, t := range transfers {
+		usernames = append(usernames, t.FromUser)
+	}
+
+	senders, err := s.repo.FindUsersForUpdate(ctx, tx, usernames...)
+	if err != nil {
+		return nil, fmt.Errorf("lock senders: %w", err)
+	}
+
+	for _, username := range usernames {
+		if senders[username] == nil {
+			return nil, fmt.Errorf("lock senders: %w", sql.ErrNoRows)
+		}
+	}
+
+	return senders, nil
+}
+
+// release returns the held coins of a pending transfer to its sender, who
+// is locked by lockSenders.
+func (s *Service) release(
+	ctx context.Context,
+	tx repository.DB,
+	sender *model.User,
+	transfer *model.PendingTransfer,
+	status model.PendingTransferStatus,
+) error {
+	if err := s.repo.ResolvePendingTransfer(ctx, tx, transfer, status); err != nil {
+		return err
+	}
+
+	sender.Balance += transfer.Amount
+
+	notification, err := model.NewNotification(model.NotificationBalanceChanged, sender.ID, model.BalanceChanged{
+		Balance: sender.Balance,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.repo.AddNotifications(ctx, tx, notification)
+}
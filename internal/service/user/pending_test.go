@@ -0,0 +1,219 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pendingTTL = time.Hour
+
+var pendingConfig = memoryConfig{pending: config.PendingTransfersConfig{TTL: pendingTTL}}
+
+func TestService_HoldTransfer(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s, repo := newMemoryService(t, pendingConfig)
+
+	transfer, err := s.HoldTransfer(ctx, "alice", "bob", 30, "for\tlunch")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", transfer.FromUser)
+	assert.Equal(t, "bob", transfer.ToUser)
+	assert.Equal(t, "for lunch", transfer.Memo)
+	assert.WithinDuration(t, time.Now().Add(pendingTTL), transfer.ExpiresAt, time.Minute)
+	assert.Equal(t, map[string]int{"alice": 70, "bob": 100, "carol": 100}, balances(t, repo))
+
+	bob, err := repo.FindUser(ctx, nil, "bob")
+	require.NoError(t, err)
+
+	notifications, err := repo.ListNotifications(ctx, nil, bob.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, model.NotificationTransferPending, notifications[0].Type)
+
+	for username, incoming := range map[string]int{"alice": 0, "bob": 1} {
+		transfers, err := s.PendingTransfers(ctx, username)
+		require.NoError(t, err)
+		assert.Len(t, transfers.Incoming, incoming)
+		assert.Len(t, transfers.Outgoing, 1-incoming)
+	}
+
+	result, err := repo.Reconcile(ctx, nil, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), result.Held)
+	assert.True(t, result.Balanced(), "held coins are accounted for")
+
+	_, err = s.HoldTransfer(ctx, "alice", "bob", 100, "")
+	require.ErrorIs(t, err, model.ErrInsufficientFunds)
+
+	_, err = s.HoldTransfer(ctx, "alice", "alice", 10, "")
+	require.ErrorIs(t, err, model.ErrBadRequest)
+}
+
+func TestService_TransferHeld(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("large transfer", func(t *testing.T) {
+		t.Parallel()
+		cfg := pendingConfig
+		cfg.pending.HoldAbove = 50
+		s, repo := newMemoryService(t, cfg)
+
+		pending, err := s.Transfer(ctx, "alice", "bob", 40, "")
+		require.NoError(t, err)
+		assert.Nil(t, pending)
+
+		pending, err = s.Transfer(ctx, "alice", "bob", 51, "rent")
+		require.NoError(t, err)
+		require.NotNil(t, pending)
+		assert.Equal(t, 51, pending.Amount)
+		assert.Equal(t, "rent", pending.Memo)
+		assert.Equal(t, map[string]int{"alice": 9, "bob": 140, "carol": 100}, balances(t, repo))
+
+		require.NoError(t, s.AcceptTransfer(ctx, "bob", pending.ID))
+		assert.Equal(t, map[string]int{"alice": 9, "bob": 191, "carol": 100}, balances(t, repo))
+	})
+
+	t.Run("new receiver", func(t *testing.T) {
+		t.Parallel()
+		cfg := pendingConfig
+		cfg.pending.HoldNewReceivers = true
+		s, repo := newMemoryService(t, cfg)
+
+		pending, err := s.Transfer(ctx, "alice", "bob", 10, "")
+		require.NoError(t, err)
+		require.NotNil(t, pending, "the first transfer to bob is held")
+
+		require.NoError(t, s.DeclineTransfer(ctx, "bob", pending.ID))
+
+		pending, err = s.Transfer(ctx, "alice", "bob", 10, "")
+		require.NoError(t, err)
+		require.NotNil(t, pending, "a declined transfer does not count")
+
+		require.NoError(t, s.AcceptTransfer(ctx, "bob", pending.ID))
+
+		pending, err = s.Transfer(ctx, "alice", "bob", 10, "")
+		require.NoError(t, err)
+		assert.Nil(t, pending, "bob has received a transfer from alice before")
+
+		pending, err = s.Transfer(ctx, "bob", "alice", 10, "")
+		require.NoError(t, err)
+		assert.NotNil(t, pending, "transfers count one way")
+
+		assert.Equal(t, map[string]int{"alice": 80, "bob": 110, "carol": 100}, balances(t, repo))
+	})
+}
+
+func TestService_ResolveTransfer(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("accept", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, pendingConfig)
+
+		transfer, err := s.HoldTransfer(ctx, "alice", "bob", 30, "thanks")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.AcceptTransfer(ctx, "carol", transfer.ID), model.ErrNotFound)
+		require.NoError(t, s.AcceptTransfer(ctx, "bob", transfer.ID))
+		assert.Equal(t, map[string]int{"alice": 70, "bob": 130, "carol": 100}, balances(t, repo))
+
+		err = s.AcceptTransfer(ctx, "bob", transfer.ID)
+		require.ErrorIs(t, err, model.ErrConflict)
+		assert.ErrorContains(t, err, "already accepted")
+
+		bob, err := repo.FindUser(ctx, nil, "bob")
+		require.NoError(t, err)
+
+		history, err := repo.ListTransactions(ctx, nil, bob.ID)
+		require.NoError(t, err)
+		assert.Contains(t, history.Received, model.CoinsReceived{FromUser: "alice", Amount: 30, Memo: "thanks"})
+
+		result, err := repo.Reconcile(ctx, nil, 10)
+		require.NoError(t, err)
+		assert.Zero(t, result.Held)
+		assert.True(t, result.Balanced())
+	})
+
+	t.Run("decline", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, pendingConfig)
+
+		transfer, err := s.HoldTransfer(ctx, "alice", "bob", 30, "")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.DeclineTransfer(ctx, "alice", transfer.ID), model.ErrNotFound,
+			"only the receiver declines")
+		require.NoError(t, s.DeclineTransfer(ctx, "bob", transfer.ID))
+		assert.Equal(t, map[string]int{"alice": 100, "bob": 100, "carol": 100}, balances(t, repo))
+
+		transfers, err := s.PendingTransfers(ctx, "bob")
+		require.NoError(t, err)
+		assert.Empty(t, transfers.Incoming)
+
+		require.ErrorIs(t, s.AcceptTransfer(ctx, "bob", transfer.ID), model.ErrConflict)
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, pendingConfig)
+
+		expired, err := s.HoldTransfer(ctx, "alice", "bob", 30, "")
+		require.NoError(t, err)
+
+		require.NoError(t, s.ExpirePendingTransfers(ctx, time.Now()))
+		assert.Equal(t, 70, balances(t, repo)["alice"], "the transfer has not expired yet")
+
+		later := time.Now().Add(pendingTTL)
+
+		_, err = s.HoldTransfer(ctx, "alice", "carol", 20, "")
+		require.NoError(t, err)
+
+		require.NoError(t, s.ExpirePendingTransfers(ctx, later))
+		assert.Equal(t, map[string]int{"alice": 80, "bob": 100, "carol": 100}, balances(t, repo))
+
+		err = s.AcceptTransfer(ctx, "bob", expired.ID)
+		require.ErrorIs(t, err, model.ErrConflict)
+		assert.ErrorContains(t, err, "already expired")
+	})
+
+	t.Run("expire batch", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newMemoryService(t, pendingConfig)
+
+		for _, hold := range []struct {
+			from, to string
+			amount   int
+		}{{"alice", "bob", 30}, {"bob", "alice", 10}, {"alice", "carol", 20}} {
+			_, err := s.HoldTransfer(ctx, hold.from, hold.to, hold.amount, "")
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, s.ExpirePendingTransfers(ctx, time.Now().Add(2*pendingTTL)))
+		assert.Equal(t, map[string]int{"alice": 100, "bob": 100, "carol": 100}, balances(t, repo))
+
+		alice, err := repo.FindUser(ctx, nil, "alice")
+		require.NoError(t, err)
+
+		notifications, err := repo.ListNotifications(ctx, nil, alice.ID, 0, 10)
+		require.NoError(t, err)
+		require.NotEmpty(t, notifications)
+		assert.JSONEq(t, `{"balance": 100}`, string(notifications[len(notifications)-1].Payload),
+			"the balance adds up over the transfers of one sender")
+	})
+
+	t.Run("unknown transfer", func(t *testing.T) {
+		t.Parallel()
+		s, _ := newMemoryService(t, pendingConfig)
+
+		require.ErrorIs(t, s.AcceptTransfer(ctx, "bob", 42), model.ErrNotFound)
+	})
+}
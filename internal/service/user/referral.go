@@ -0,0 +1,160 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+)
+
+// referralCodeBytes makes codes of 8 base32 characters.
+const referralCodeBytes = 5
+
+// Referral returns the referral code of the user and how many more referrals
+// are rewarded.
+func (s *Service) Referral(ctx context.Context, username string) (*model.ReferralInfo, error) {
+	if username == "" {
+		return nil, model.ErrUnauthorized
+	}
+
+	user, err := s.repo.FindUser(ctx, nil, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrUnauthorized
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	rewarded, err := s.repo.CountRewardedReferrals(ctx, nil, user.ID, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	recent, err := s.repo.CountRewardedReferrals(ctx, nil, user.ID, time.Now().Add(-s.signup.ReferralWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ReferralInfo{
+		Code:      user.ReferralCode,
+		Bonus:     s.signup.ReferralBonus,
+		Rewarded:  rewarded,
+		Remaining: max(s.signup.ReferralLimit-recent, 0),
+	}, nil
+}
+
+// lockReferrer finds the owner of the referral code and locks them the same
+// way Transfer does, so that concurrent signups with the same code count the
+// referrals of the window one after another.
+func (s *Service) lockReferrer(ctx context.Context, tx repository.DB, code string) (*model.User, error) {
+	owner, err := s.repo.FindUserByReferralCode(ctx, tx, code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrInvalidReferralCode
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("find referrer: %w", err)
+	}
+
+	users, err := s.repo.FindUsersForUpdate(ctx, tx, owner.Username)
+	if err != nil {
+		return nil, fmt.Errorf("lock referrer: %w", err)
+	}
+
+	referrer, ok := users[owner.Username]
+	if !ok || !referrer.Active() {
+		return nil, model.ErrInvalidReferralCode
+	}
+
+	return referrer, nil
+}
+
+// refer records the referral of referee and credits the bonus to both users
+// if the referrer has not reached the limit of the window.
+func (s *Service) refer(ctx context.Context, tx repository.DB, referrer, referee *model.User) error {
+	rewarded := false
+
+	if s.signup.ReferralBonus > 0 {
+		since := time.Now().Add(-s.signup.ReferralWindow)
+
+		count, err := s.repo.CountRewardedReferrals(ctx, tx, referrer.ID, since)
+		if err != nil {
+			return err
+		}
+
+		rewarded = count < s.signup.ReferralLimit
+	}
+
+	referral := &model.Referral{ReferrerID: referrer.ID, RefereeID: referee.ID, Rewarded: rewarded}
+	if err := s.repo.AddReferral(ctx, tx, referral); err != nil {
+		return err
+	}
+
+	if !rewarded {
+		return nil
+	}
+
+	bonus := s.signup.ReferralBonus
+
+	var notifications []*model.Notification
+
+	for _, user := range []*model.User{referrer, referee} {
+		entry := &model.LedgerEntry{
+			UserID:    user.ID,
+			Kind:      model.LedgerEntryReferral,
+			Amount:    bonus,
+			Reference: strconv.Itoa(referee.ID),
+		}
+		if _, err := s.repo.AddLedgerEntry(ctx, tx, entry); err != nil {
+			return err
+		}
+
+		if err := s.repo.AddBalance(ctx, tx, user.ID, bonus); err != nil {
+			return err
+		}
+
+		user.Balance += bonus
+
+		received, err := model.NewNotification(model.NotificationCoinsReceived, user.ID, model.CoinsReceived{
+			FromUser: model.SystemAccount,
+			Amount:   bonus,
+		})
+		if err != nil {
+			return err
+		}
+
+		balance, err := model.NewNotification(model.NotificationBalanceChanged, user.ID, model.BalanceChanged{
+			Balance: user.Balance,
+		})
+		if err != nil {
+			return err
+		}
+
+		notifications = append(notifications, received, balance)
+	}
+
+	return s.repo.AddNotifications(ctx, tx, notifications...)
+}
+
+func newReferralCode() (string, error) {
+	b := make([]byte, referralCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate referral code: %w", err)
+	}
+
+	return base32.StdEncoding.EncodeToString(b), nil
+}
+
+// normalizeReferralCode makes codes case insensitive.
+func normalizeReferralCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,124 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/hasher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReferralService(signup config.SignupConfig) (*Service, *memory.Repository) {
+	repo := memory.New()
+	cfg := config.Default()
+	s := NewService(repo, hasher.NewArgon2(), signup, model.TransferLimits{}, cfg.PendingTransfers, cfg.PaymentRequests)
+
+	return s, repo
+}
+
+func TestService_CreateWithReferral(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	signup := config.SignupConfig{WelcomeGrant: 500, ReferralBonus: 50, ReferralLimit: 2, ReferralWindow: time.Hour}
+
+	t.Run("credits both users", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newReferralService(signup)
+
+		referrer, err := s.Create(ctx, "referrer", "password", "")
+		require.NoError(t, err)
+		assert.Equal(t, 500, referrer.Balance)
+		assert.Len(t, referrer.ReferralCode, 8)
+
+		referee, err := s.Create(ctx, "referee", "password", " "+referrer.ReferralCode+" ")
+		require.NoError(t, err)
+		assert.Equal(t, 550, referee.Balance)
+
+		referrer, err = repo.FindUser(ctx, nil, "referrer")
+		require.NoError(t, err)
+		assert.Equal(t, 550, referrer.Balance)
+
+		entries, err := repo.ListLedgerEntries(ctx, nil, referrer.ID)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, model.LedgerEntryReferral, entries[1].Kind)
+		assert.Equal(t, 50, entries[1].Amount)
+
+		info, err := s.Referral(ctx, "referrer")
+		require.NoError(t, err)
+		assert.Equal(t, &model.ReferralInfo{Code: referrer.ReferralCode, Bonus: 50, Rewarded: 1, Remaining: 1}, info)
+	})
+
+	t.Run("limit reached", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newReferralService(signup)
+
+		referrer, err := s.Create(ctx, "referrer", "password", "")
+		require.NoError(t, err)
+
+		for i := range 3 {
+			_, err := s.Create(ctx, fmt.Sprintf("referee-%d", i), "password", referrer.ReferralCode)
+			require.NoError(t, err)
+		}
+
+		last, err := repo.FindUser(ctx, nil, "referee-2")
+		require.NoError(t, err)
+		assert.Equal(t, 500, last.Balance)
+
+		referrer, err = repo.FindUser(ctx, nil, "referrer")
+		require.NoError(t, err)
+		assert.Equal(t, 600, referrer.Balance)
+
+		info, err := s.Referral(ctx, "referrer")
+		require.NoError(t, err)
+		assert.Equal(t, 2, info.Rewarded)
+		assert.Zero(t, info.Remaining)
+	})
+
+	t.Run("unknown code", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newReferralService(signup)
+
+		user, err := s.Create(ctx, "referee", "password", "UNKNOWN")
+		require.ErrorIs(t, err, model.ErrInvalidReferralCode)
+		assert.ErrorIs(t, err, model.ErrBadRequest)
+		assert.Nil(t, user)
+
+		_, err = repo.FindUser(ctx, nil, "referee")
+		assert.Error(t, err)
+	})
+
+	t.Run("deactivated referrer", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newReferralService(signup)
+
+		referrer, err := s.Create(ctx, "referrer", "password", "")
+		require.NoError(t, err)
+
+		deactivatedAt := time.Now()
+		require.NoError(t, repo.SetUserDeactivatedAt(ctx, nil, referrer.ID, &deactivatedAt))
+
+		_, err = s.Create(ctx, "referee", "password", referrer.ReferralCode)
+		assert.ErrorIs(t, err, model.ErrInvalidReferralCode)
+	})
+
+	t.Run("no welcome grant", func(t *testing.T) {
+		t.Parallel()
+		s, repo := newReferralService(config.SignupConfig{ReferralWindow: time.Hour})
+
+		user, err := s.Create(ctx, "user", "password", "")
+		require.NoError(t, err)
+		assert.Zero(t, user.Balance)
+
+		entries, err := repo.ListLedgerEntries(ctx, nil, user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
@@ -2,8 +2,12 @@ package user
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 	"github.com/esklo/avito-backend-winter-2025/internal/repository"
 	"github.com/esklo/avito-backend-winter-2025/internal/service"
@@ -14,19 +18,46 @@ var _ service.UserManager = (*Service)(nil)
 type Service struct {
 	repo            repository.Repository
 	passwordService service.Hasher
+	signup          config.SignupConfig
+	// limits are the global transfer limits, users may have overrides.
+	limits   model.TransferLimits
+	pending  config.PendingTransfersConfig
+	payments config.PaymentRequestsConfig
 }
 
-func NewService(repo repository.Repository, passwordService service.Hasher) *Service {
-	return &Service{repo: repo, passwordService: passwordService}
+func NewService(
+	repo repository.Repository,
+	passwordService service.Hasher,
+	signup config.SignupConfig,
+	limits model.TransferLimits,
+	pending config.PendingTransfersConfig,
+	payments config.PaymentRequestsConfig,
+) *Service {
+	return &Service{
+		repo:            repo,
+		passwordService: passwordService,
+		signup:          signup,
+		limits:          limits,
+		pending:         pending,
+		payments:        payments,
+	}
 }
 
-func (s *Service) Create(ctx context.Context, username, password string) (user *model.User, err error) {
+// Create registers a user with the welcome grant. With the referral code of
+// another user both of them get the referral bonus, unless the referrer has
+// reached the referral limit.
+func (s *Service) Create(ctx context.Context, username, password, referralCode string) (user *model.User, err error) {
 	if username == "" || password == "" {
 		return nil, model.ErrBadRequest
 	}
 
+	if username == model.SystemAccount {
+		return nil, fmt.Errorf("%w: username %q is reserved", model.ErrBadRequest, username)
+	}
+
 	user = &model.User{
 		Username: username,
+		Balance:  s.signup.WelcomeGrant,
 	}
 
 	user.Password, user.Salt, err = s.passwordService.Hash(password)
@@ -34,12 +65,52 @@ func (s *Service) Create(ctx context.Context, username, password string) (user *
 		return nil, model.ErrInternalServerError
 	}
 
-	err = s.repo.CreateUser(ctx, nil, user)
+	if user.ReferralCode, err = newReferralCode(); err != nil {
+		return nil, model.ErrInternalServerError
+	}
+
+	referralCode = normalizeReferralCode(referralCode)
+
+	err = s.repo.WithTx(ctx, func(tx repository.DB) error {
+		var referrer *model.User
+
+		if referralCode != "" {
+			if referrer, err = s.lockReferrer(ctx, tx, referralCode); err != nil {
+				return err
+			}
+		}
+
+		if err := s.repo.CreateUser(ctx, tx, user); err != nil {
+			return err
+		}
+
+		user, err = s.repo.FindUser(ctx, tx, user.Username)
+		if err != nil {
+			return err
+		}
+
+		err = s.addEvent(ctx, tx, model.EventUserRegistered, user.ID, model.UserRegistered{
+			Username: user.Username,
+		})
+		if err != nil {
+			return err
+		}
+
+		if referrer == nil {
+			return nil
+		}
+
+		return s.refer(ctx, tx, referrer, user)
+	})
+	if errors.Is(err, model.ErrInvalidReferralCode) {
+		return nil, err
+	}
+
 	if err != nil {
 		return nil, model.ErrInternalServerError
 	}
 
-	return s.repo.FindUser(ctx, nil, user.Username)
+	return user, nil
 }
 
 func (s *Service) Info(ctx context.Context, username string) (*model.Info, error) {
@@ -80,30 +151,230 @@ func (s *Service) Info(ctx context.Context, username string) (*model.Info, error
 	return info, nil
 }
 
-func (s *Service) Transfer(ctx context.Context, from, to string, amount int) error {
-	if from == "" {
-		return model.ErrUnauthorized
+// Transfer sends amount coins from one user to another. The memo is
+// optional and is sanitized before it is stored. A transfer the config of
+// pending transfers holds, a large one or the first to the receiver, is made
+// pending like with HoldTransfer and returned.
+func (s *Service) Transfer(
+	ctx context.Context, from, to string, amount int, memo string,
+) (*model.PendingTransfer, error) {
+	memo, err := checkTransfer(from, to, amount, memo)
+	if err != nil {
+		return nil, err
 	}
 
-	if amount <= 0 || to == "" || from == to {
-		return model.ErrBadRequest
-	}
+	var pending *model.PendingTransfer
 
-	return s.repo.WithTx(ctx, func(tx repository.DB) error {
-		sender, err := s.repo.FindUser(ctx, tx, from)
+	err = s.repo.WithTx(ctx, func(tx repository.DB) error {
+		pending = nil
+
+		sender, receiver, err := s.lockParties(ctx, tx, from, to)
 		if err != nil {
-			return model.ErrUnauthorized
+			return err
 		}
 
-		if sender.Balance < amount {
-			return model.ErrInsufficientFunds
+		held, err := s.mustHold(ctx, tx, sender, receiver, amount)
+		if err != nil {
+			return err
 		}
 
-		receiver, err := s.repo.FindUser(ctx, tx, to)
-		if err != nil {
-			return model.ErrBadRequest
+		if held {
+			pending, err = s.hold(ctx, tx, sender, receiver, amount, memo)
+
+			return err
+		}
+
+		if err := s.spend(ctx, tx, sender, amount); err != nil {
+			return err
 		}
 
-		return s.repo.MakeTransfer(ctx, tx, sender.ID, receiver.ID, amount)
+		return s.transfer(ctx, tx, sender, receiver, amount, memo)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+// TransferTx is Transfer in the transaction tx, for callers that record
+// something with the transfer, it is made or not together with it.
+func (s *Service) TransferTx(ctx context.Context, tx repository.DB, from, to string, amount int, memo string) error {
+	memo, err := checkTransfer(from, to, amount, memo)
+	if err != nil {
+		return err
+	}
+
+	return s.makeTransfer(ctx, tx, from, to, amount, memo)
+}
+
+// checkTransfer validates a transfer and returns its sanitized memo.
+func checkTransfer(from, to string, amount int, memo string) (string, error) {
+	if from == "" {
+		return "", model.ErrUnauthorized
+	}
+
+	if amount <= 0 || to == "" || from == to {
+		return "", model.ErrBadRequest
+	}
+
+	return SanitizeMemo(memo)
+}
+
+func (s *Service) makeTransfer(ctx context.Context, tx repository.DB, from, to string, amount int, memo string) error {
+	sender, receiver, err := s.lockParties(ctx, tx, from, to)
+	if err != nil {
+		return err
+	}
+
+	if err := s.spend(ctx, tx, sender, amount); err != nil {
+		return err
+	}
+
+	return s.transfer(ctx, tx, sender, receiver, amount, memo)
+}
+
+// lockParties locks the sender and the receiver of a transfer and checks
+// that both may take part in it.
+func (s *Service) lockParties(
+	ctx context.Context, tx repository.DB, from, to string,
+) (sender, receiver *model.User, err error) {
+	users, err := s.repo.FindUsersForUpdate(ctx, tx, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("lock users: %w", err)
+	}
+
+	sender, ok := users[from]
+	if !ok {
+		return nil, nil, model.ErrUnauthorized
+	}
+
+	if !sender.Active() {
+		return nil, nil, model.ErrUserDeactivated
+	}
+
+	receiver, ok = users[to]
+	if !ok {
+		return nil, nil, model.ErrBadRequest
+	}
+
+	if !receiver.Active() {
+		return nil, nil, fmt.Errorf("%w: receiver is deactivated", model.ErrBadRequest)
+	}
+
+	return sender, receiver, nil
+}
+
+// spend checks that the locked sender has amount coins and may send them
+// under their transfer limits, and counts them in the usage of the day.
+func (s *Service) spend(ctx context.Context, tx repository.DB, sender *model.User, amount int) error {
+	if sender.Balance < amount {
+		return model.ErrInsufficientFunds
+	}
+
+	day := model.TransferDay(time.Now())
+
+	limits, usage, err := s.transferLimits(ctx, tx, sender.ID, day)
+	if err != nil {
+		return err
+	}
+
+	if err := limits.Check(usage, amount); err != nil {
+		return err
+	}
+
+	return s.repo.AddTransferUsage(ctx, tx, sender.ID, day, amount)
+}
+
+// transferLimits returns the limits of the sender and their usage on day.
+// The sender is locked, so concurrent transfers are counted one after
+// another.
+func (s *Service) transferLimits(
+	ctx context.Context, tx repository.DB, senderID int, day time.Time,
+) (model.TransferLimits, model.TransferUsage, error) {
+	override, err := s.repo.FindTransferLimits(ctx, tx, senderID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return model.TransferLimits{}, model.TransferUsage{}, err
+	}
+
+	usage, err := s.repo.FindTransferUsage(ctx, tx, senderID, day)
+	if err != nil {
+		return model.TransferLimits{}, model.TransferUsage{}, err
+	}
+
+	return s.limits.With(override), usage, nil
+}
+
+// transfer makes a checked transfer between locked users and records it in
+// the outbox and the notifications.
+func (s *Service) transfer(
+	ctx context.Context, tx repository.DB, sender, receiver *model.User, amount int, memo string,
+) error {
+	transfer := &model.Transfer{SenderID: sender.ID, ReceiverID: receiver.ID, Amount: amount, Memo: memo}
+	if err := s.repo.MakeTransfer(ctx, tx, transfer); err != nil {
+		return err
+	}
+
+	event, err := model.NewEvent(model.EventTransferCompleted, sender.ID, model.TransferCompleted{
+		FromUser: sender.Username,
+		ToUser:   receiver.Username,
+		Amount:   amount,
+		Memo:     memo,
 	})
+	if err != nil {
+		return err
+	}
+
+	// One event is published for the transfer, it is in the stream of the
+	// receiver too.
+	event.ReceiverID = receiver.ID
+
+	if err := s.repo.AddEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	return s.notifyTransfer(ctx, tx, sender, receiver, transfer)
+}
+
+// notifyTransfer tells both users about their new balances and the receiver
+// about the incoming coins. The users hold their balances from before the
+// transfer.
+func (s *Service) notifyTransfer(
+	ctx context.Context, tx repository.DB, sender, receiver *model.User, transfer *model.Transfer,
+) error {
+	amount := transfer.Amount
+
+	sent, err := model.NewNotification(model.NotificationBalanceChanged, sender.ID, model.BalanceChanged{
+		Balance: sender.Balance - amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	received, err := model.NewNotification(model.NotificationCoinsReceived, receiver.ID, model.CoinsReceived{
+		FromUser: sender.Username,
+		Amount:   amount,
+		Memo:     transfer.Memo,
+	})
+	if err != nil {
+		return err
+	}
+
+	balance, err := model.NewNotification(model.NotificationBalanceChanged, receiver.ID, model.BalanceChanged{
+		Balance: receiver.Balance + amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.repo.AddNotifications(ctx, tx, sent, received, balance)
+}
+
+func (s *Service) addEvent(ctx context.Context, tx repository.DB, eventType model.EventType, userID int, payload any) error {
+	event, err := model.NewEvent(eventType, userID, payload)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.AddEvent(ctx, tx, event)
 }
@@ -2,12 +2,19 @@ package user
 
 import (
 	"context"
+	"database/sql"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/hasher"
 	"github.com/esklo/avito-backend-winter-2025/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
@@ -21,14 +28,63 @@ func newTestSuite(t *testing.T) *testSuite {
 	ctrl := gomock.NewController(t)
 	repo := mocks.NewMockRepository(ctrl)
 	hasher := mocks.NewMockHasher(ctrl)
+	cfg := config.Default()
 
 	return &testSuite{
 		repo:   repo,
 		hasher: hasher,
-		users:  NewService(repo, hasher),
+		users:  NewService(repo, hasher, cfg.Signup, model.TransferLimits{}, cfg.PendingTransfers, cfg.PaymentRequests),
 	}
 }
 
+// memoryConfig configures the service newMemoryService returns, the zero
+// values are replaced with the defaults.
+type memoryConfig struct {
+	limits   model.TransferLimits
+	pending  config.PendingTransfersConfig
+	payments config.PaymentRequestsConfig
+}
+
+// newMemoryService returns a service backed by an in-memory repository with
+// users alice, bob and carol, who have 100 coins each.
+func newMemoryService(t *testing.T, cfg memoryConfig) (*Service, *memory.Repository) {
+	t.Helper()
+
+	defaults := config.Default()
+	if cfg.pending == (config.PendingTransfersConfig{}) {
+		cfg.pending = defaults.PendingTransfers
+	}
+
+	if cfg.payments == (config.PaymentRequestsConfig{}) {
+		cfg.payments = defaults.PaymentRequests
+	}
+
+	repo := memory.New()
+	signup := config.SignupConfig{WelcomeGrant: 100}
+	s := NewService(repo, hasher.NewArgon2(), signup, cfg.limits, cfg.pending, cfg.payments)
+
+	for _, username := range []string{"alice", "bob", "carol"} {
+		_, err := s.Create(context.Background(), username, "password", "")
+		require.NoError(t, err)
+	}
+
+	return s, repo
+}
+
+func balances(t *testing.T, repo *memory.Repository) map[string]int {
+	t.Helper()
+
+	users, err := repo.FindUsersForUpdate(context.Background(), nil, "alice", "bob", "carol")
+	require.NoError(t, err)
+
+	balances := make(map[string]int, len(users))
+	for username, u := range users {
+		balances[username] = u.Balance
+	}
+
+	return balances
+}
+
 func TestService_Create(t *testing.T) {
 	t.Parallel()
 
@@ -45,11 +101,12 @@ func TestService_Create(t *testing.T) {
 			{"empty both", "", ""},
 			{"empty password", "user", ""},
 			{"empty username", "", "pass"},
+			{"reserved username", model.SystemAccount, "pass"},
 		}
 
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
-				user, err := ts.users.Create(ctx, tt.username, tt.password)
+				user, err := ts.users.Create(ctx, tt.username, tt.password, "")
 				assert.ErrorIs(t, err, model.ErrBadRequest)
 				assert.Nil(t, user)
 			})
@@ -69,6 +126,12 @@ func TestService_Create(t *testing.T) {
 			Hash(gomock.Any()).
 			Return(expected.Password, expected.Salt, nil)
 
+		ts.repo.EXPECT().
+			WithTx(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
+				return fn(nil)
+			})
+
 		ts.repo.EXPECT().
 			CreateUser(gomock.Any(), nil, gomock.Any()).
 			Return(nil)
@@ -77,7 +140,13 @@ func TestService_Create(t *testing.T) {
 			FindUser(gomock.Any(), nil, expected.Username).
 			Return(expected, nil)
 
-		user, err := ts.users.Create(ctx, expected.Username, "password")
+		ts.repo.EXPECT().
+			AddEvent(gomock.Any(), nil, gomock.Cond(func(e *model.Event) bool {
+				return e.Type == model.EventUserRegistered && e.UserID == expected.ID
+			})).
+			Return(nil)
+
+		user, err := ts.users.Create(ctx, expected.Username, "password", "")
 		assert.NoError(t, err)
 		assert.Equal(t, expected, user)
 	})
@@ -106,7 +175,7 @@ func TestService_Info(t *testing.T) {
 
 		ts.repo.EXPECT().
 			WithTx(gomock.Any(), gomock.Any()).
-			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error) error {
+			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
 				return fn(nil)
 			})
 		ts.repo.EXPECT().FindUser(gomock.Any(), nil, user.Username).Return(user, nil)
@@ -175,7 +244,7 @@ func TestService_Transfer(t *testing.T) {
 			t.Run(tt.name, func(t *testing.T) {
 				t.Parallel()
 				ts := newTestSuite(t)
-				err := ts.users.Transfer(ctx, tt.from, tt.to, tt.amount)
+				_, err := ts.users.Transfer(ctx, tt.from, tt.to, tt.amount, "")
 				assert.ErrorIs(t, err, tt.expectedError)
 			})
 		}
@@ -191,44 +260,317 @@ func TestService_Transfer(t *testing.T) {
 
 		ts.repo.EXPECT().
 			WithTx(gomock.Any(), gomock.Any()).
-			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error) error {
+			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
 				return fn(nil)
 			})
 
 		ts.repo.EXPECT().
-			FindUser(gomock.Any(), nil, sender.Username).
-			Return(sender, nil)
+			FindUsersForUpdate(gomock.Any(), nil, sender.Username, receiver.Username).
+			Return(map[string]*model.User{
+				sender.Username:   sender,
+				receiver.Username: receiver,
+			}, nil)
+
+		ts.repo.EXPECT().
+			FindTransferLimits(gomock.Any(), nil, sender.ID).
+			Return(nil, sql.ErrNoRows)
 
 		ts.repo.EXPECT().
-			FindUser(gomock.Any(), nil, receiver.Username).
-			Return(receiver, nil)
+			FindTransferUsage(gomock.Any(), nil, sender.ID, gomock.Any()).
+			Return(model.TransferUsage{}, nil)
 
 		ts.repo.EXPECT().
-			MakeTransfer(gomock.Any(), nil, sender.ID, receiver.ID, amount).
+			MakeTransfer(gomock.Any(), nil, &model.Transfer{
+				SenderID:   sender.ID,
+				ReceiverID: receiver.ID,
+				Amount:     amount,
+				Memo:       "thanks for the review",
+			}).
 			Return(nil)
 
-		err := ts.users.Transfer(ctx, sender.Username, receiver.Username, amount)
+		ts.repo.EXPECT().
+			AddTransferUsage(gomock.Any(), nil, sender.ID, gomock.Any(), amount).
+			Return(nil)
+
+		ts.repo.EXPECT().
+			AddEvent(gomock.Any(), nil, gomock.Cond(func(e *model.Event) bool {
+				return e.Type == model.EventTransferCompleted && e.UserID == sender.ID && e.ReceiverID == receiver.ID
+			})).
+			DoAndReturn(func(_ context.Context, _ repository.DB, e *model.Event) error {
+				assert.JSONEq(t, `{"fromUser": "sender", "toUser": "receiver", "amount": 100,
+					"memo": "thanks for the review"}`, string(e.Payload))
+
+				return nil
+			})
+
+		ts.repo.EXPECT().
+			AddNotifications(gomock.Any(), nil, gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ repository.DB, notifications ...*model.Notification) error {
+				require.Len(t, notifications, 3)
+				assert.Equal(t, sender.ID, notifications[0].UserID)
+				assert.JSONEq(t, `{"balance": 900}`, string(notifications[0].Payload))
+				assert.Equal(t, model.NotificationCoinsReceived, notifications[1].Type)
+				assert.Equal(t, receiver.ID, notifications[1].UserID)
+				assert.JSONEq(t, `{"fromUser": "sender", "amount": 100, "memo": "thanks for the review"}`,
+					string(notifications[1].Payload))
+				assert.JSONEq(t, `{"balance": 600}`, string(notifications[2].Payload))
+
+				return nil
+			})
+
+		_, err := ts.users.Transfer(ctx, sender.Username, receiver.Username, amount, " thanks for\nthe review ")
 		assert.NoError(t, err)
 	})
 
+	t.Run("memo too long", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		_, err := ts.users.Transfer(ctx, "sender", "receiver", 100, strings.Repeat("a", MaxMemoLength+1))
+		assert.ErrorIs(t, err, model.ErrBadRequest)
+	})
+
 	t.Run("insufficient funds", func(t *testing.T) {
 		t.Parallel()
 		ts := newTestSuite(t)
 
 		sender := &model.User{ID: 1, Username: "sender", Balance: 50}
+		receiver := &model.User{ID: 2, Username: "receiver", Balance: 500}
 		amount := 100
 
 		ts.repo.EXPECT().
 			WithTx(gomock.Any(), gomock.Any()).
-			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error) error {
+			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
 				return fn(nil)
 			})
 
 		ts.repo.EXPECT().
-			FindUser(gomock.Any(), nil, sender.Username).
-			Return(sender, nil)
+			FindUsersForUpdate(gomock.Any(), nil, sender.Username, receiver.Username).
+			Return(map[string]*model.User{
+				sender.Username:   sender,
+				receiver.Username: receiver,
+			}, nil)
 
-		err := ts.users.Transfer(ctx, sender.Username, "receiver", amount)
+		_, err := ts.users.Transfer(ctx, sender.Username, receiver.Username, amount, "")
 		assert.ErrorIs(t, err, model.ErrInsufficientFunds)
 	})
+
+	t.Run("limits", func(t *testing.T) {
+		t.Parallel()
+
+		zero, fifty := 0, 50
+
+		tests := []struct {
+			name     string
+			limits   model.TransferLimits
+			override *model.TransferLimitsOverride
+			usage    model.TransferUsage
+			err      error
+		}{
+			{
+				name:   "max amount",
+				limits: model.TransferLimits{MaxAmount: 99},
+				err:    model.ErrLimitExceeded,
+			},
+			{
+				name:   "daily amount",
+				limits: model.TransferLimits{DailyAmount: 500},
+				usage:  model.TransferUsage{Amount: 401, Count: 1},
+				err:    model.ErrLimitExceeded,
+			},
+			{
+				name:   "daily count",
+				limits: model.TransferLimits{DailyCount: 3},
+				usage:  model.TransferUsage{Amount: 30, Count: 3},
+				err:    model.ErrLimitExceeded,
+			},
+			{
+				name:   "within limits",
+				limits: model.TransferLimits{MaxAmount: 100, DailyAmount: 500, DailyCount: 3},
+				usage:  model.TransferUsage{Amount: 400, Count: 2},
+			},
+			{
+				name:     "override lowers a limit",
+				limits:   model.TransferLimits{MaxAmount: 1000},
+				override: &model.TransferLimitsOverride{MaxAmount: &fifty},
+				err:      model.ErrLimitExceeded,
+			},
+			{
+				name:     "override removes a limit",
+				limits:   model.TransferLimits{MaxAmount: 50, DailyCount: 1},
+				override: &model.TransferLimitsOverride{MaxAmount: &zero, DailyCount: &zero},
+				usage:    model.TransferUsage{Amount: 10, Count: 1},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+				ctrl := gomock.NewController(t)
+				repo := mocks.NewMockRepository(ctrl)
+				cfg := config.Default()
+				users := NewService(
+					repo, mocks.NewMockHasher(ctrl), cfg.Signup, tt.limits, cfg.PendingTransfers, cfg.PaymentRequests,
+				)
+
+				sender := &model.User{ID: 1, Username: "sender", Balance: 1000}
+				receiver := &model.User{ID: 2, Username: "receiver", Balance: 500}
+				amount := 100
+
+				repo.EXPECT().
+					WithTx(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
+						return fn(nil)
+					})
+
+				repo.EXPECT().
+					FindUsersForUpdate(gomock.Any(), nil, sender.Username, receiver.Username).
+					Return(map[string]*model.User{sender.Username: sender, receiver.Username: receiver}, nil)
+
+				override, err := tt.override, error(nil)
+				if override == nil {
+					err = sql.ErrNoRows
+				}
+
+				repo.EXPECT().FindTransferLimits(gomock.Any(), nil, sender.ID).Return(override, err)
+				repo.EXPECT().FindTransferUsage(gomock.Any(), nil, sender.ID, gomock.Any()).Return(tt.usage, nil)
+
+				if tt.err == nil {
+					repo.EXPECT().MakeTransfer(gomock.Any(), nil, gomock.Any()).Return(nil)
+					repo.EXPECT().AddTransferUsage(gomock.Any(), nil, sender.ID, gomock.Any(), amount).Return(nil)
+					repo.EXPECT().AddEvent(gomock.Any(), nil, gomock.Any()).Return(nil)
+					repo.EXPECT().AddNotifications(gomock.Any(), nil, gomock.Any()).Return(nil)
+				}
+
+				_, err = users.Transfer(ctx, sender.Username, receiver.Username, amount, "")
+				if tt.err == nil {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorIs(t, err, tt.err)
+				}
+			})
+		}
+	})
+
+	t.Run("unknown receiver", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestSuite(t)
+
+		sender := &model.User{ID: 1, Username: "sender", Balance: 1000}
+
+		ts.repo.EXPECT().
+			WithTx(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
+				return fn(nil)
+			})
+
+		ts.repo.EXPECT().
+			FindUsersForUpdate(gomock.Any(), nil, sender.Username, "unknown").
+			Return(map[string]*model.User{sender.Username: sender}, nil)
+
+		_, err := ts.users.Transfer(ctx, sender.Username, "unknown", 100, "")
+		assert.ErrorIs(t, err, model.ErrBadRequest)
+	})
+
+	t.Run("deactivated users", func(t *testing.T) {
+		t.Parallel()
+
+		deactivatedAt := time.Now()
+
+		tests := []struct {
+			name     string
+			sender   *model.User
+			receiver *model.User
+			err      error
+		}{
+			{
+				name:     "sender",
+				sender:   &model.User{ID: 1, Username: "sender", Balance: 1000, DeactivatedAt: &deactivatedAt},
+				receiver: &model.User{ID: 2, Username: "receiver"},
+				err:      model.ErrForbidden,
+			},
+			{
+				name:     "receiver",
+				sender:   &model.User{ID: 1, Username: "sender", Balance: 1000},
+				receiver: &model.User{ID: 2, Username: "receiver", DeactivatedAt: &deactivatedAt},
+				err:      model.ErrBadRequest,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+				ts := newTestSuite(t)
+
+				ts.repo.EXPECT().
+					WithTx(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, fn func(repository.DB) error, _ ...repository.TxOption) error {
+						return fn(nil)
+					})
+
+				ts.repo.EXPECT().
+					FindUsersForUpdate(gomock.Any(), nil, tt.sender.Username, tt.receiver.Username).
+					Return(map[string]*model.User{
+						tt.sender.Username:   tt.sender,
+						tt.receiver.Username: tt.receiver,
+					}, nil)
+
+				_, err := ts.users.Transfer(ctx, tt.sender.Username, tt.receiver.Username, 100, "")
+				assert.ErrorIs(t, err, tt.err)
+			})
+		}
+	})
+}
+
+func TestService_TransferEvents(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s, repo := newMemoryService(t, memoryConfig{})
+	_, err := s.Transfer(ctx, "alice", "bob", 30, "lunch")
+	require.NoError(t, err)
+
+	events, err := repo.ListPendingEvents(ctx, nil, 100)
+	require.NoError(t, err)
+
+	// The stream of a user is made of their events and the events they
+	// receive, each at its sequence number in the stream.
+	streams := make(map[int]map[int64]model.Event)
+	transfers := 0
+
+	for _, e := range events {
+		if e.Type == model.EventTransferCompleted {
+			transfers++
+		}
+
+		for userID, sequence := range map[int]int64{e.UserID: e.Sequence, e.ReceiverID: e.ReceiverSequence} {
+			if userID == 0 {
+				continue
+			}
+
+			if streams[userID] == nil {
+				streams[userID] = make(map[int64]model.Event)
+			}
+
+			streams[userID][sequence] = e
+		}
+	}
+
+	assert.Equal(t, 1, transfers, "a transfer is one event")
+
+	for _, username := range []string{"alice", "bob"} {
+		user, err := repo.FindUser(ctx, nil, username)
+		require.NoError(t, err)
+
+		stream := streams[user.ID]
+		require.NotEmpty(t, stream)
+
+		for i := range len(stream) {
+			assert.Contains(t, stream, int64(i+1), "the stream of %s has no gaps", username)
+		}
+
+		last := stream[int64(len(stream))]
+		assert.Equal(t, model.EventTransferCompleted, last.Type, "%s has the transfer in their stream", username)
+		assert.JSONEq(t, `{"fromUser": "alice", "toUser": "bob", "amount": 30, "memo": "lunch"}`, string(last.Payload))
+	}
 }
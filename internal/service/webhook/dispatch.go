@@ -0,0 +1,174 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+// Run sends due deliveries until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := s.Dispatch(ctx)
+			if err != nil {
+				s.log.Error("dispatch webhooks", "error", err)
+			}
+
+			if err != nil || n < s.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch sends one batch of due deliveries concurrently and returns its
+// size. Claimed deliveries are hidden from other replicas for twice the
+// request timeout, so a crashed replica delays them only that long.
+func (s *Service) Dispatch(ctx context.Context) (int, error) {
+	deliveries, err := s.repo.ClaimWebhookDeliveries(ctx, nil, s.cfg.BatchSize, 2*s.cfg.Timeout)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+
+	for _, d := range deliveries {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := s.deliver(ctx, d); err != nil {
+				s.log.Error("deliver webhook", "delivery", d.ID, "error", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+func (s *Service) deliver(ctx context.Context, d model.WebhookDelivery) error {
+	webhook, err := s.repo.FindWebhook(ctx, nil, d.WebhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The webhook was deleted along with its deliveries.
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	event, err := s.repo.FindEvent(ctx, nil, d.EventID)
+	if err != nil {
+		return err
+	}
+
+	status, sendErr := s.send(ctx, webhook, &d, event)
+
+	d.Attempts++
+	d.ResponseStatus = status
+	d.LastError = ""
+
+	switch {
+	case sendErr == nil:
+		now := time.Now()
+		d.Status = model.DeliveryDelivered
+		d.DeliveredAt = &now
+	case d.Attempts >= s.cfg.MaxAttempts:
+		d.Status = model.DeliveryDead
+		d.LastError = sendErr.Error()
+	default:
+		d.NextAttemptAt = time.Now().Add(s.backoff(d.Attempts))
+		d.LastError = sendErr.Error()
+	}
+
+	return s.repo.UpdateWebhookDelivery(ctx, nil, &d)
+}
+
+var errStatus = errors.New("unexpected response status")
+
+// send posts the event and returns the response status, if any.
+func (s *Service) send(
+	ctx context.Context, webhook *model.Webhook, d *model.WebhookDelivery, event *model.Event,
+) (int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(event.Type))
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("post event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%w: %s", errStatus, resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (s *Service) backoff(attempts int) time.Duration {
+	delay := s.cfg.BaseBackoff
+	for range attempts - 1 {
+		delay *= 2
+		if delay >= s.cfg.MaxBackoff {
+			return s.cfg.MaxBackoff
+		}
+	}
+
+	return delay
+}
+
+// Sign returns the signature header value of body: the hex encoded
+// HMAC-SHA256 of the body keyed with the webhook secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/service"
+)
+
+const (
+	secretLength = 32
+	// deliveryLogLimit caps the delivery log returned for a webhook.
+	deliveryLogLimit = 100
+)
+
+var _ service.Webhooks = (*Service)(nil)
+
+// Service manages webhook subscriptions and delivers events to them. Every
+// event accepted by a webhook gets a delivery that is retried with
+// exponential backoff until it succeeds or runs out of attempts.
+type Service struct {
+	repo   repository.Repository
+	cfg    config.WebhooksConfig
+	log    *slog.Logger
+	client *http.Client
+}
+
+func NewService(repo repository.Repository, cfg config.WebhooksConfig, log *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		cfg:    cfg,
+		log:    log,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Create registers a webhook. The returned webhook holds the signing secret,
+// which is not shown again.
+func (s *Service) Create(ctx context.Context, rawURL string, eventTypes []model.EventType) (*model.Webhook, error) {
+	if err := validateURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	if len(eventTypes) == 0 {
+		return nil, fmt.Errorf("%w: no event types", model.ErrBadRequest)
+	}
+
+	for _, t := range eventTypes {
+		if !t.Valid() {
+			return nil, fmt.Errorf("%w: unknown event type %q", model.ErrBadRequest, t)
+		}
+	}
+
+	secret := make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate secret: %w", err)
+	}
+
+	webhook := &model.Webhook{
+		URL:        rawURL,
+		Secret:     hex.EncodeToString(secret),
+		EventTypes: eventTypes,
+	}
+
+	if err := s.repo.CreateWebhook(ctx, nil, webhook); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// List returns the webhooks without their secrets.
+func (s *Service) List(ctx context.Context) ([]model.Webhook, error) {
+	webhooks, err := s.repo.ListWebhooks(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+func (s *Service) Delete(ctx context.Context, id int) error {
+	err := s.repo.DeleteWebhook(ctx, nil, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ErrNotFound
+	}
+
+	return err
+}
+
+// ListDeliveries returns the latest deliveries of the webhook, newest first.
+func (s *Service) ListDeliveries(ctx context.Context, webhookID int) ([]model.WebhookDelivery, error) {
+	_, err := s.repo.FindWebhook(ctx, nil, webhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListWebhookDeliveries(ctx, nil, webhookID, deliveryLogLimit)
+}
+
+// RetryDelivery gives a dead delivery a fresh set of attempts.
+func (s *Service) RetryDelivery(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	var delivery *model.WebhookDelivery
+
+	err := s.repo.WithTx(ctx, func(tx repository.DB) error {
+		var err error
+
+		delivery, err = s.repo.FindWebhookDelivery(ctx, tx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrNotFound
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if delivery.Status != model.DeliveryDead {
+			return fmt.Errorf("%w: delivery is %s", model.ErrBadRequest, delivery.Status)
+		}
+
+		delivery.Status = model.DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = time.Now()
+
+		return s.repo.UpdateWebhookDelivery(ctx, tx, delivery)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// HandleEvent queues the event for the webhooks subscribed to it. It is meant
+// to be subscribed to the event bus.
+func (s *Service) HandleEvent(ctx context.Context, event model.Event) error {
+	return s.repo.CreateWebhookDeliveries(ctx, nil, event)
+}
+
+func validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: webhook url must be an absolute http(s) url", model.ErrBadRequest)
+	}
+
+	return nil
+}
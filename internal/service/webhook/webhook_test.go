@@ -0,0 +1,311 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/hasher"
+	"github.com/esklo/avito-backend-winter-2025/internal/service/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a local stand-in for a webhook endpoint that answers with the
+// queued status codes and then with 200.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+
+	w.WriteHeader(status)
+}
+
+func testConfig() config.WebhooksConfig {
+	return config.WebhooksConfig{
+		PollInterval: time.Millisecond,
+		BatchSize:    10,
+		Timeout:      time.Second,
+		MaxAttempts:  3,
+		BaseBackoff:  time.Millisecond,
+		MaxBackoff:   2 * time.Millisecond,
+	}
+}
+
+func newTestService(t *testing.T, statuses ...int) (*Service, *memory.Repository, *receiver, *model.Webhook) {
+	t.Helper()
+
+	rc := &receiver{statuses: statuses}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	repo := memory.New()
+	require.NoError(t, repo.CreateUser(context.Background(), nil, &model.User{Username: "alice"}))
+
+	s := NewService(repo, testConfig(), slog.Default())
+
+	webhook, err := s.Create(context.Background(), srv.URL, []model.EventType{model.EventTransferCompleted})
+	require.NoError(t, err)
+
+	return s, repo, rc, webhook
+}
+
+func addEvent(t *testing.T, s *Service, repo *memory.Repository) *model.Event {
+	t.Helper()
+	ctx := context.Background()
+
+	user, err := repo.FindUser(ctx, nil, "alice")
+	require.NoError(t, err)
+
+	event, err := model.NewEvent(model.EventTransferCompleted, user.ID, model.TransferCompleted{
+		FromUser: "alice",
+		ToUser:   "bob",
+		Amount:   10,
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.AddEvent(ctx, nil, event))
+	require.NoError(t, s.HandleEvent(ctx, *event))
+
+	return event
+}
+
+// dispatchUntil dispatches due deliveries until the delivery reaches status.
+func dispatchUntil(t *testing.T, s *Service, id int64, status model.DeliveryStatus) *model.WebhookDelivery {
+	t.Helper()
+	ctx := context.Background()
+
+	var delivery *model.WebhookDelivery
+
+	require.Eventually(t, func() bool {
+		_, err := s.Dispatch(ctx)
+		require.NoError(t, err)
+
+		delivery, err = s.repo.FindWebhookDelivery(ctx, nil, id)
+		require.NoError(t, err)
+
+		return delivery.Status == status
+	}, time.Second, time.Millisecond)
+
+	return delivery
+}
+
+func TestService_Create(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s := NewService(memory.New(), testConfig(), slog.Default())
+
+	tests := []struct {
+		name   string
+		url    string
+		events []model.EventType
+	}{
+		{name: "relative url", url: "/hook", events: []model.EventType{model.EventItemPurchased}},
+		{name: "unsupported scheme", url: "ftp://example.com", events: []model.EventType{model.EventItemPurchased}},
+		{name: "no events", url: "http://example.com"},
+		{name: "unknown event", url: "http://example.com", events: []model.EventType{"CoinsMinted"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := s.Create(ctx, tt.url, tt.events)
+			assert.ErrorIs(t, err, model.ErrBadRequest)
+		})
+	}
+
+	t.Run("secret is shown only on create", func(t *testing.T) {
+		t.Parallel()
+
+		webhook, err := s.Create(ctx, "https://example.com/hook", []model.EventType{model.EventItemPurchased})
+		require.NoError(t, err)
+		assert.Len(t, webhook.Secret, 2*secretLength)
+
+		webhooks, err := s.List(ctx)
+		require.NoError(t, err)
+		require.NotEmpty(t, webhooks)
+
+		for _, w := range webhooks {
+			assert.Empty(t, w.Secret)
+		}
+	})
+}
+
+func TestService_Dispatch(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("sends signed event", func(t *testing.T) {
+		t.Parallel()
+
+		s, repo, rc, webhook := newTestService(t)
+		event := addEvent(t, s, repo)
+
+		purchase, err := model.NewEvent(model.EventItemPurchased, event.UserID, model.ItemPurchased{})
+		require.NoError(t, err)
+		require.NoError(t, repo.AddEvent(ctx, nil, purchase))
+		require.NoError(t, s.HandleEvent(ctx, *purchase))
+
+		deliveries, err := s.ListDeliveries(ctx, webhook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1, "webhook is not subscribed to purchases")
+
+		delivery := dispatchUntil(t, s, deliveries[0].ID, model.DeliveryDelivered)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, http.StatusOK, delivery.ResponseStatus)
+		assert.NotNil(t, delivery.DeliveredAt)
+
+		require.Len(t, rc.requests, 1)
+		req := rc.requests[0]
+		assert.Equal(t, string(model.EventTransferCompleted), req.Header.Get(HeaderEvent))
+		assert.Equal(t, Sign(webhook.Secret, rc.bodies[0]), req.Header.Get(HeaderSignature))
+		assert.Contains(t, string(rc.bodies[0]), `"amount":10`)
+		assert.Contains(t, string(rc.bodies[0]), string(event.Payload))
+	})
+
+	t.Run("retries failed deliveries", func(t *testing.T) {
+		t.Parallel()
+
+		s, repo, rc, webhook := newTestService(t, http.StatusInternalServerError)
+		addEvent(t, s, repo)
+
+		n, err := s.Dispatch(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		deliveries, err := s.ListDeliveries(ctx, webhook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, model.DeliveryPending, deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+		assert.NotEmpty(t, deliveries[0].LastError)
+
+		delivery := dispatchUntil(t, s, deliveries[0].ID, model.DeliveryDelivered)
+		assert.Equal(t, 2, delivery.Attempts)
+		assert.Empty(t, delivery.LastError)
+		assert.Len(t, rc.requests, 2)
+	})
+
+	t.Run("dead letters after max attempts and retries on demand", func(t *testing.T) {
+		t.Parallel()
+
+		s, repo, rc, webhook := newTestService(t,
+			http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+		addEvent(t, s, repo)
+
+		deliveries, err := s.ListDeliveries(ctx, webhook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+
+		_, err = s.RetryDelivery(ctx, deliveries[0].ID)
+		require.ErrorIs(t, err, model.ErrBadRequest, "only dead deliveries can be retried")
+
+		delivery := dispatchUntil(t, s, deliveries[0].ID, model.DeliveryDead)
+		assert.Equal(t, testConfig().MaxAttempts, delivery.Attempts)
+		assert.Equal(t, http.StatusBadGateway, delivery.ResponseStatus)
+
+		delivery, err = s.RetryDelivery(ctx, delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, model.DeliveryPending, delivery.Status)
+		assert.Zero(t, delivery.Attempts)
+
+		dispatchUntil(t, s, delivery.ID, model.DeliveryDelivered)
+		assert.Len(t, rc.requests, testConfig().MaxAttempts+1)
+	})
+
+	t.Run("skips deleted webhooks", func(t *testing.T) {
+		t.Parallel()
+
+		s, repo, rc, webhook := newTestService(t)
+		addEvent(t, s, repo)
+
+		require.NoError(t, s.Delete(ctx, webhook.ID))
+
+		n, err := s.Dispatch(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+		assert.Empty(t, rc.requests)
+
+		assert.ErrorIs(t, s.Delete(ctx, webhook.ID), model.ErrNotFound)
+
+		_, err = s.ListDeliveries(ctx, webhook.ID)
+		assert.ErrorIs(t, err, model.ErrNotFound)
+	})
+}
+
+func TestService_HandleEvent(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s, repo, _, webhook := newTestService(t)
+
+	cfg := config.Default()
+	users := user.NewService(repo, hasher.NewArgon2(), config.SignupConfig{WelcomeGrant: 100}, model.TransferLimits{},
+		cfg.PendingTransfers, cfg.PaymentRequests)
+
+	for _, username := range []string{"bob", "carol"} {
+		_, err := users.Create(ctx, username, "password", "")
+		require.NoError(t, err)
+	}
+
+	for _, tr := range []struct{ from, to string }{{"bob", "carol"}, {"carol", "bob"}} {
+		_, err := users.Transfer(ctx, tr.from, tr.to, 10, "")
+		require.NoError(t, err)
+	}
+
+	events, err := repo.ListPendingEvents(ctx, nil, 100)
+	require.NoError(t, err)
+
+	for _, event := range events {
+		require.NoError(t, s.HandleEvent(ctx, event))
+	}
+
+	deliveries, err := repo.ListWebhookDeliveries(ctx, nil, webhook.ID, 100)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 2, "one delivery per transfer")
+}
+
+func TestService_backoff(t *testing.T) {
+	t.Parallel()
+
+	s := NewService(memory.New(), config.WebhooksConfig{
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Second,
+	}, slog.Default())
+
+	for attempts, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		assert.Equal(t, want, s.backoff(attempts), "attempts %d", attempts)
+	}
+}
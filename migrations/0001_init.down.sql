@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS transfers;
+DROP TABLE IF EXISTS purchases;
+DROP TABLE IF EXISTS items;
+DROP TABLE IF EXISTS users;
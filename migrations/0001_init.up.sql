@@ -1,4 +1,4 @@
-CREATE TABLE users
+CREATE TABLE IF NOT EXISTS users
 (
     id       serial primary key,
     username text unique not null,
@@ -8,7 +8,7 @@ CREATE TABLE users
         constraint positive_balance check ( balance >= 0 )
 );
 
-CREATE TABLE items
+CREATE TABLE IF NOT EXISTS items
 (
     id    serial primary key,
     name  text unique not null,
@@ -16,7 +16,7 @@ CREATE TABLE items
         constraint positive_price check (price > 0)
 );
 
-CREATE TABLE purchases
+CREATE TABLE IF NOT EXISTS purchases
 (
     id       serial primary key,
     user_id  integer not null references users (id),
@@ -25,11 +25,11 @@ CREATE TABLE purchases
         constraint positive_quantity check ( quantity >= 0 ),
     unique (user_id, item_id)
 );
-CREATE INDEX idx_purchases_user_item ON purchases (user_id, item_id);
-CREATE INDEX idx_purchases_user_id ON purchases (user_id);
-CREATE INDEX idx_purchases_item_id ON purchases (item_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_user_item ON purchases (user_id, item_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_user_id ON purchases (user_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_item_id ON purchases (item_id);
 
-CREATE TABLE transfers
+CREATE TABLE IF NOT EXISTS transfers
 (
     id          serial primary key,
     sender_id   integer not null references users (id),
@@ -39,10 +39,9 @@ CREATE TABLE transfers
     unique (sender_id, receiver_id),
     check ( sender_id != receiver_id )
 );
-CREATE INDEX idx_transfers_sender_receiver ON transfers (sender_id, receiver_id);
-CREATE INDEX idx_transfers_sender_id ON transfers (sender_id);
-CREATE INDEX idx_transfers_receiver_id ON transfers (receiver_id);
-
+CREATE INDEX IF NOT EXISTS idx_transfers_sender_receiver ON transfers (sender_id, receiver_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_sender_id ON transfers (sender_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_receiver_id ON transfers (receiver_id);
 
 INSERT INTO items (name, price)
 values ('t-shirt', 80),
@@ -54,4 +53,5 @@ values ('t-shirt', 80),
        ('umbrella', 200),
        ('socks', 10),
        ('wallet', 50),
-       ('pink-hoody', 500);
\ No newline at end of file
+       ('pink-hoody', 500)
+ON CONFLICT (name) DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE outbox
+(
+    id           bigserial primary key,
+    event_type   text        not null,
+    user_id      integer     not null references users (id),
+    sequence     bigint      not null,
+    payload      jsonb       not null,
+    created_at   timestamptz not null default now(),
+    published_at timestamptz,
+    unique (user_id, sequence)
+);
+CREATE INDEX idx_outbox_pending ON outbox (id) WHERE published_at IS NULL;
+
+CREATE TABLE outbox_sequences
+(
+    user_id       integer primary key references users (id),
+    last_sequence bigint not null
+);
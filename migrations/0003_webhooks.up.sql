@@ -0,0 +1,27 @@
+CREATE TABLE webhooks
+(
+    id          serial primary key,
+    url         text        not null,
+    secret      text        not null,
+    event_types text[]      not null,
+    created_at  timestamptz not null default now()
+);
+
+CREATE TABLE webhook_deliveries
+(
+    id              bigserial primary key,
+    webhook_id      integer     not null references webhooks (id) on delete cascade,
+    event_id        bigint      not null references outbox (id),
+    event_type      text        not null,
+    status          text        not null default 'pending'
+        constraint valid_status check ( status in ('pending', 'delivered', 'dead') ),
+    attempts        integer     not null default 0,
+    next_attempt_at timestamptz not null default now(),
+    last_error      text        not null default '',
+    response_status integer     not null default 0,
+    created_at      timestamptz not null default now(),
+    delivered_at    timestamptz,
+    unique (webhook_id, event_id)
+);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
@@ -0,0 +1,9 @@
+CREATE TABLE notifications
+(
+    id         bigserial primary key,
+    user_id    integer     not null references users (id),
+    type       text        not null,
+    payload    jsonb       not null,
+    created_at timestamptz not null default now()
+);
+CREATE INDEX idx_notifications_user_id ON notifications (user_id, id);
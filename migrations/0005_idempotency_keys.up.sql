@@ -0,0 +1,10 @@
+CREATE TABLE idempotency_keys
+(
+    username     text        not null,
+    key          text        not null,
+    request_hash text        not null,
+    status_code  integer     not null default 0,
+    response     bytea,
+    created_at   timestamptz not null default now(),
+    primary key (username, key)
+);
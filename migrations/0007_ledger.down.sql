@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS scheduled_jobs;
+DROP TABLE IF EXISTS ledger_entries;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS ledger_entries
+(
+    id         bigserial primary key,
+    user_id    integer     not null references users (id),
+    kind       text        not null,
+    amount     integer     not null,
+    reference  text        not null,
+    created_at timestamptz not null default now(),
+    unique (user_id, kind, reference)
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_jobs
+(
+    name        text primary key,
+    last_run_at timestamptz not null
+);
@@ -0,0 +1,3 @@
+UPDATE ledger_entries SET reference = 'entry-' || id WHERE reference IS NULL;
+ALTER TABLE ledger_entries ALTER COLUMN reference SET NOT NULL;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS reason;
@@ -0,0 +1,2 @@
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS reason text not null default '';
+ALTER TABLE ledger_entries ALTER COLUMN reference DROP NOT NULL;
@@ -0,0 +1,24 @@
+-- Balances from before the ledger covered signups and purchases are recorded
+-- as opening entries, so that every balance is backed by the ledger.
+INSERT INTO ledger_entries (user_id, kind, amount, reference)
+SELECT id, 'opening', opening, 'opening'
+FROM (
+    SELECT
+        users.id,
+        COALESCE(users.balance, 0)
+            - COALESCE(ledger.amount, 0)
+            - COALESCE(received.amount, 0)
+            + COALESCE(sent.amount, 0) AS opening
+    FROM users
+    LEFT JOIN (
+        SELECT user_id, SUM(amount) AS amount FROM ledger_entries GROUP BY user_id
+    ) ledger ON ledger.user_id = users.id
+    LEFT JOIN (
+        SELECT receiver_id, SUM(amount) AS amount FROM transfers GROUP BY receiver_id
+    ) received ON received.receiver_id = users.id
+    LEFT JOIN (
+        SELECT sender_id, SUM(amount) AS amount FROM transfers GROUP BY sender_id
+    ) sent ON sent.sender_id = users.id
+) openings
+WHERE opening <> 0
+ON CONFLICT (user_id, kind, reference) DO NOTHING;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS referrals;
+ALTER TABLE users DROP COLUMN IF EXISTS referral_code;
+ALTER TABLE users ALTER COLUMN balance SET DEFAULT 1000;
@@ -0,0 +1,14 @@
+-- The starting balance is set by the service and recorded in the ledger.
+ALTER TABLE users ALTER COLUMN balance SET DEFAULT 0;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS referral_code text UNIQUE;
+UPDATE users SET referral_code = upper(substr(md5(random()::text || id::text), 1, 8)) WHERE referral_code IS NULL;
+
+CREATE TABLE IF NOT EXISTS referrals
+(
+    referee_id  integer primary key references users (id),
+    referrer_id integer     not null references users (id),
+    rewarded    boolean     not null,
+    created_at  timestamptz not null default now()
+);
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer_id ON referrals (referrer_id, created_at);
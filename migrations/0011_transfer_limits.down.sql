@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transfer_usage;
+DROP TABLE IF EXISTS transfer_limits;
@@ -0,0 +1,19 @@
+-- Overrides of the global transfer limits, a null column keeps the global limit.
+CREATE TABLE IF NOT EXISTS transfer_limits
+(
+    user_id      integer primary key references users (id),
+    max_amount   integer,
+    daily_amount integer,
+    daily_count  integer,
+    updated_at   timestamptz not null default now()
+);
+
+-- Coins and transfers sent per user and UTC day, counted by the daily limits.
+CREATE TABLE IF NOT EXISTS transfer_usage
+(
+    user_id integer not null references users (id),
+    day     date    not null,
+    amount  integer not null,
+    count   integer not null,
+    primary key (user_id, day)
+);
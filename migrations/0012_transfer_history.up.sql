@@ -0,0 +1,13 @@
+-- Every transfer on its own, transfers keeps the totals per pair of users.
+-- Transfers made before this migration are only in the totals.
+CREATE TABLE IF NOT EXISTS transfer_history
+(
+    id          bigserial primary key,
+    sender_id   integer     not null references users (id),
+    receiver_id integer     not null references users (id),
+    amount      integer     not null,
+    memo        text,
+    created_at  timestamptz not null default now()
+);
+CREATE INDEX IF NOT EXISTS idx_transfer_history_sender_id ON transfer_history (sender_id, receiver_id);
+CREATE INDEX IF NOT EXISTS idx_transfer_history_receiver_id ON transfer_history (receiver_id, sender_id);
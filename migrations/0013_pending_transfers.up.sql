@@ -0,0 +1,17 @@
+-- Transfers waiting for the receiver to accept them. The coins are taken from
+-- the sender when the transfer is created and held here until it is resolved.
+CREATE TABLE IF NOT EXISTS pending_transfers
+(
+    id          bigserial primary key,
+    sender_id   integer     not null references users (id),
+    receiver_id integer     not null references users (id),
+    amount      integer     not null check (amount > 0),
+    memo        text,
+    status      text        not null default 'pending',
+    created_at  timestamptz not null default now(),
+    expires_at  timestamptz not null,
+    resolved_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_pending_transfers_sender_id ON pending_transfers (sender_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_pending_transfers_receiver_id ON pending_transfers (receiver_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_pending_transfers_expires_at ON pending_transfers (expires_at) WHERE status = 'pending';
@@ -0,0 +1,16 @@
+-- Requests for coins from one user to another. Nothing is held, the payer
+-- makes an ordinary transfer when paying a request.
+CREATE TABLE IF NOT EXISTS payment_requests
+(
+    id           bigserial primary key,
+    requester_id integer     not null references users (id),
+    payer_id     integer     not null references users (id),
+    amount       integer     not null check (amount > 0),
+    memo         text,
+    status       text        not null default 'pending',
+    created_at   timestamptz not null default now(),
+    expires_at   timestamptz not null,
+    resolved_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_requester_id ON payment_requests (requester_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_payment_requests_payer_id ON payment_requests (payer_id) WHERE status = 'pending';
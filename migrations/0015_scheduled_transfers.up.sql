@@ -0,0 +1,28 @@
+-- Transfers users set up to be made later. A transfer with a schedule, a cron
+-- expression in UTC, recurs, one without it runs once at next_run_at.
+CREATE TABLE IF NOT EXISTS scheduled_transfers
+(
+    id          bigserial primary key,
+    sender_id   integer     not null references users (id),
+    receiver_id integer     not null references users (id),
+    amount      integer     not null check (amount > 0),
+    memo        text,
+    schedule    text,
+    status      text        not null default 'active',
+    next_run_at timestamptz not null,
+    created_at  timestamptz not null default now()
+);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_sender_id ON scheduled_transfers (sender_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_next_run_at ON scheduled_transfers (next_run_at) WHERE status = 'active';
+
+-- Every run of a scheduled transfer, with the error of a failed one.
+CREATE TABLE IF NOT EXISTS scheduled_transfer_runs
+(
+    id                    bigserial primary key,
+    scheduled_transfer_id bigint      not null references scheduled_transfers (id),
+    scheduled_at          timestamptz not null,
+    status                text        not null,
+    error                 text,
+    created_at            timestamptz not null default now()
+);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfer_runs_transfer_id ON scheduled_transfer_runs (scheduled_transfer_id, id);
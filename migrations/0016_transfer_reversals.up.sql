@@ -0,0 +1,4 @@
+-- Reversals are pairs of ledger entries that move the coins of a transfer back
+-- from the receiver to the sender, linked to the reversed transfer.
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS transfer_id bigint references transfer_history (id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transfer_id ON ledger_entries (transfer_id) WHERE transfer_id IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS cart_items;
+ALTER TABLE items DROP COLUMN IF EXISTS stock;
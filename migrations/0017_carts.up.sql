@@ -0,0 +1,12 @@
+-- Items without stock never run out.
+ALTER TABLE items ADD COLUMN IF NOT EXISTS stock integer
+    constraint non_negative_stock check (stock >= 0);
+
+-- Carts hold the items a user is going to buy. Lines are priced at checkout.
+CREATE TABLE IF NOT EXISTS cart_items
+(
+    user_id  integer not null references users (id),
+    item_id  integer not null references items (id),
+    quantity integer not null check (quantity > 0),
+    primary key (user_id, item_id)
+);
@@ -0,0 +1,19 @@
+DELETE FROM cart_items WHERE variant_id IS NOT NULL;
+DROP INDEX IF EXISTS idx_cart_items_user_item_variant;
+ALTER TABLE cart_items DROP COLUMN IF EXISTS variant_id;
+ALTER TABLE cart_items ADD PRIMARY KEY (user_id, item_id);
+
+-- Variants bought are kept as the items themselves.
+INSERT INTO purchases (user_id, item_id, quantity)
+SELECT user_id, item_id, sum(quantity)
+FROM purchases
+WHERE variant_id IS NOT NULL
+GROUP BY user_id, item_id
+ON CONFLICT (user_id, item_id, (coalesce(variant_id, 0)))
+    DO UPDATE SET quantity = purchases.quantity + excluded.quantity;
+DELETE FROM purchases WHERE variant_id IS NOT NULL;
+DROP INDEX IF EXISTS idx_purchases_user_item_variant;
+ALTER TABLE purchases DROP COLUMN IF EXISTS variant_id;
+ALTER TABLE purchases ADD CONSTRAINT purchases_user_id_item_id_key UNIQUE (user_id, item_id);
+
+DROP TABLE IF EXISTS item_variants;
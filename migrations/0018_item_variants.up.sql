@@ -0,0 +1,25 @@
+-- Variants are versions of an item, such as sizes or colors, each with its own
+-- stock. Variants without stock never run out.
+CREATE TABLE IF NOT EXISTS item_variants
+(
+    id          serial primary key,
+    item_id     integer     not null references items (id),
+    size        varchar(50) not null default '',
+    color       varchar(50) not null default '',
+    price_delta integer     not null default 0,
+    stock       integer
+        constraint non_negative_stock check (stock >= 0),
+    unique (item_id, size, color),
+    check (size <> '' or color <> '')
+);
+
+-- Purchases and cart lines of items without variants have no variant_id.
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS variant_id integer references item_variants (id);
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_user_id_item_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_purchases_user_item_variant
+    ON purchases (user_id, item_id, (coalesce(variant_id, 0)));
+
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS variant_id integer references item_variants (id);
+ALTER TABLE cart_items DROP CONSTRAINT IF EXISTS cart_items_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_user_item_variant
+    ON cart_items (user_id, item_id, (coalesce(variant_id, 0)));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_receiver_sequence;
+ALTER TABLE outbox DROP CONSTRAINT IF EXISTS receiver_has_sequence;
+ALTER TABLE outbox DROP COLUMN IF EXISTS receiver_sequence;
+ALTER TABLE outbox DROP COLUMN IF EXISTS receiver_id;
@@ -0,0 +1,7 @@
+-- An event that concerns two users, a transfer, is stored once and placed in
+-- the stream of its receiver too, with the next sequence number of the
+-- receiver.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS receiver_id integer references users (id);
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS receiver_sequence bigint;
+ALTER TABLE outbox ADD CONSTRAINT receiver_has_sequence check ((receiver_id is null) = (receiver_sequence is null));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_receiver_sequence ON outbox (receiver_id, receiver_sequence);
@@ -0,0 +1,418 @@
+// Package client is the Go SDK for the shop HTTP API. It logs in on the first
+// call and again whenever the token is about to expire or is rejected.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Client struct {
+	baseURL    string
+	username   string
+	password   string
+	referral   string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the client used for requests, http.DefaultClient by
+// default.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithReferralCode registers the user with the referral code of another user
+// if the first login creates them.
+func WithReferralCode(code string) Option {
+	return func(c *Client) {
+		c.referral = code
+	}
+}
+
+// New returns a client of the API at baseURL, e.g. "http://localhost:8080",
+// acting as the given user. The user is registered on the first login.
+func New(baseURL, username, password string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		username:   username,
+		password:   password,
+		httpClient: http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+type requestOptions struct {
+	idempotencyKey string
+}
+
+type RequestOption func(*requestOptions)
+
+// WithIdempotencyKey makes the request safe to retry: the server runs it once
+// and answers requests with the same key with the stored response for 24
+// hours. A key may not be reused for a different request.
+func WithIdempotencyKey(key string) RequestOption {
+	return func(o *requestOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// Login gets a new token, replacing the current one.
+func (c *Client) Login(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.login(ctx)
+}
+
+// Info returns the balance, inventory and coin history of the user.
+func (c *Client) Info(ctx context.Context) (*InfoResponse, error) {
+	var resp InfoResponse
+	if err := c.do(ctx, http.MethodGet, "/api/info", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// Referral returns the referral code of the user and the referral bonus.
+func (c *Client) Referral(ctx context.Context) (*ReferralResponse, error) {
+	var resp ReferralResponse
+	if err := c.do(ctx, http.MethodGet, "/api/referral", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// Buy buys one item of the given name.
+func (c *Client) Buy(ctx context.Context, item string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodGet, "/api/buy/"+url.PathEscape(item), nil, nil, opts...)
+}
+
+// BuyVariant buys one item of the given name in the variant with the size and
+// color. Items with variants can only be bought this way.
+func (c *Client) BuyVariant(ctx context.Context, item, size, color string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodGet, "/api/buy/"+url.PathEscape(item)+variantQuery(size, color), nil, nil, opts...)
+}
+
+// Variants lists the variants of an item, it is empty if the item has none.
+func (c *Client) Variants(ctx context.Context, item string) ([]Variant, error) {
+	var resp []Variant
+	if err := c.do(ctx, http.MethodGet, "/api/items/"+url.PathEscape(item)+"/variants", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// SendCoin transfers coins to another user.
+func (c *Client) SendCoin(ctx context.Context, req SendCoinRequest, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/api/sendCoin", req, nil, opts...)
+}
+
+// SendCoinBatch transfers coins to several users at once. Either every
+// transfer is made or none is, a rejected batch is reported with the error
+// of its first failed transfer.
+func (c *Client) SendCoinBatch(
+	ctx context.Context, req SendCoinBatchRequest, opts ...RequestOption,
+) (*SendCoinBatchResponse, error) {
+	var resp SendCoinBatchResponse
+	if err := c.do(ctx, http.MethodPost, "/api/sendCoin/batch", req, &resp, opts...); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// HoldTransfer holds coins for another user until they accept the transfer.
+func (c *Client) HoldTransfer(
+	ctx context.Context, req SendCoinRequest, opts ...RequestOption,
+) (*PendingTransfer, error) {
+	var resp PendingTransfer
+	if err := c.do(ctx, http.MethodPost, "/api/pending-transfers", req, &resp, opts...); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// PendingTransfers lists the pending transfers the user received and sent.
+func (c *Client) PendingTransfers(ctx context.Context) (*PendingTransfersResponse, error) {
+	var resp PendingTransfersResponse
+	if err := c.do(ctx, http.MethodGet, "/api/pending-transfers", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// AcceptTransfer accepts a pending transfer to the user.
+func (c *Client) AcceptTransfer(ctx context.Context, id int64, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/api/pending-transfers/"+strconv.FormatInt(id, 10)+"/accept", nil, nil, opts...)
+}
+
+// DeclineTransfer declines a pending transfer to the user, the coins go back
+// to the sender.
+func (c *Client) DeclineTransfer(ctx context.Context, id int64, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/api/pending-transfers/"+strconv.FormatInt(id, 10)+"/decline", nil, nil, opts...)
+}
+
+// RequestPayment asks another user to pay the user.
+func (c *Client) RequestPayment(
+	ctx context.Context, req RequestPaymentRequest, opts ...RequestOption,
+) (*PaymentRequest, error) {
+	var resp PaymentRequest
+	if err := c.do(ctx, http.MethodPost, "/api/payment-requests", req, &resp, opts...); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// PaymentRequests lists the open payment requests the user has to pay and
+// those they sent.
+func (c *Client) PaymentRequests(ctx context.Context) (*PaymentRequestsResponse, error) {
+	var resp PaymentRequestsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/payment-requests", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// PayRequest pays a payment request to the user with a transfer to the
+// requester.
+func (c *Client) PayRequest(ctx context.Context, id int64, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/api/payment-requests/"+strconv.FormatInt(id, 10)+"/pay", nil, nil, opts...)
+}
+
+// DeclineRequest declines a payment request to the user.
+func (c *Client) DeclineRequest(ctx context.Context, id int64, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/api/payment-requests/"+strconv.FormatInt(id, 10)+"/decline", nil, nil, opts...)
+}
+
+// ScheduleTransfer sets up a transfer to be made later, once or on a
+// schedule.
+func (c *Client) ScheduleTransfer(
+	ctx context.Context, req ScheduleTransferRequest, opts ...RequestOption,
+) (*ScheduledTransfer, error) {
+	var resp ScheduledTransfer
+	if err := c.do(ctx, http.MethodPost, "/api/scheduled-transfers", req, &resp, opts...); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// ScheduledTransfers lists the scheduled transfers of the user.
+func (c *Client) ScheduledTransfers(ctx context.Context) ([]ScheduledTransfer, error) {
+	var resp []ScheduledTransfer
+	if err := c.do(ctx, http.MethodGet, "/api/scheduled-transfers", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ScheduledTransferRuns lists the latest runs of a scheduled transfer, newest
+// first.
+func (c *Client) ScheduledTransferRuns(ctx context.Context, id int64) ([]ScheduledTransferRun, error) {
+	path := "/api/scheduled-transfers/" + strconv.FormatInt(id, 10) + "/runs"
+
+	var resp []ScheduledTransferRun
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// CancelScheduledTransfer stops an active scheduled transfer.
+func (c *Client) CancelScheduledTransfer(ctx context.Context, id int64, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/api/scheduled-transfers/"+strconv.FormatInt(id, 10)+"/cancel", nil, nil, opts...)
+}
+
+// Cart returns the cart of the user.
+func (c *Client) Cart(ctx context.Context) (*CartResponse, error) {
+	var resp CartResponse
+	if err := c.do(ctx, http.MethodGet, "/api/cart", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// AddToCart adds items to the cart of the user and returns the cart.
+func (c *Client) AddToCart(ctx context.Context, req AddToCartRequest, opts ...RequestOption) (*CartResponse, error) {
+	var resp CartResponse
+	if err := c.do(ctx, http.MethodPost, "/api/cart/items", req, &resp, opts...); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// RemoveFromCart removes an item from the cart of the user and returns the
+// cart.
+func (c *Client) RemoveFromCart(ctx context.Context, item string, opts ...RequestOption) (*CartResponse, error) {
+	var resp CartResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/cart/items/"+url.PathEscape(item), nil, &resp, opts...); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// RemoveVariantFromCart removes the variant of an item with the size and
+// color from the cart of the user and returns the cart.
+func (c *Client) RemoveVariantFromCart(
+	ctx context.Context, item, size, color string, opts ...RequestOption,
+) (*CartResponse, error) {
+	var resp CartResponse
+
+	path := "/api/cart/items/" + url.PathEscape(item) + variantQuery(size, color)
+	if err := c.do(ctx, http.MethodDelete, path, nil, &resp, opts...); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// Checkout buys everything in the cart of the user at once and returns what
+// was bought. Nothing is bought if any item is out of stock or the user
+// cannot afford the cart.
+func (c *Client) Checkout(ctx context.Context, opts ...RequestOption) (*CartResponse, error) {
+	var resp CartResponse
+	if err := c.do(ctx, http.MethodPost, "/api/checkout", nil, &resp, opts...); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// variantQuery returns the query string that chooses a variant.
+func variantQuery(size, color string) string {
+	query := url.Values{}
+
+	if size != "" {
+		query.Set("size", size)
+	}
+
+	if color != "" {
+		query.Set("color", color)
+	}
+
+	if len(query) == 0 {
+		return ""
+	}
+
+	return "?" + query.Encode()
+}
+
+// do sends an authenticated request and decodes the response into out. A
+// request rejected as unauthorized is sent once more with a new token.
+func (c *Client) do(ctx context.Context, method, path string, in, out any, opts ...RequestOption) error {
+	var o requestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var body []byte
+
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.send(ctx, method, path, token, body, out, o)
+	if !errors.Is(err, ErrUnauthorized) {
+		return err
+	}
+
+	if token, err = c.refreshToken(ctx, token); err != nil {
+		return err
+	}
+
+	return c.send(ctx, method, path, token, body, out, o)
+}
+
+func (c *Client) send(
+	ctx context.Context, method, path, token string, body []byte, out any, o requestOptions,
+) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if o.idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", o.idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Errors == "" {
+			errResp.Errors = http.StatusText(resp.StatusCode)
+		}
+
+		return newError(resp.StatusCode, errResp.Errors)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,375 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/di"
+	internalhttp "github.com/esklo/avito-backend-winter-2025/internal/http"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/memory"
+	"github.com/esklo/avito-backend-winter-2025/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testServer struct {
+	*httptest.Server
+	repo   *memory.Repository
+	logins atomic.Int32
+	// reject makes the server answer the next authenticated request with
+	// 401, as if the token had expired.
+	reject atomic.Bool
+}
+
+// newTestServer serves the real HTTP API backed by an in-memory repository.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.App.JWTSecret = []byte("client-test-secret-client-test-secret")
+	cfg.DB.Driver = config.DriverMemory
+
+	ts := &testServer{repo: memory.New()}
+	handler := internalhttp.NewServer(di.New(cfg, ts.repo)).Handler()
+
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/auth" {
+			ts.logins.Add(1)
+		} else if ts.reject.CompareAndSwap(true, false) {
+			r.Header.Set("Authorization", "Bearer expired")
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestClient_Info(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ts := newTestServer(t)
+
+	c := client.New(ts.URL, "alice", "password")
+
+	info, err := c.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, info.Coins)
+	assert.Empty(t, info.Inventory)
+	assert.Empty(t, info.CoinHistory.Sent)
+
+	_, err = c.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), ts.logins.Load(), "token is reused")
+}
+
+func TestClient_SendCoin(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ts := newTestServer(t)
+
+	alice := client.New(ts.URL, "alice", "password")
+	bob := client.New(ts.URL, "bob", "password")
+
+	_, err := bob.Login(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, alice.SendCoin(ctx, client.SendCoinRequest{ToUser: "bob", Amount: 100}))
+
+	info, err := bob.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1100, info.Coins)
+	assert.Equal(t, []client.ReceivedCoins{{FromUser: "alice", Amount: 100}}, info.CoinHistory.Received)
+
+	err = alice.SendCoin(ctx, client.SendCoinRequest{ToUser: "bob", Amount: 5000})
+	assert.ErrorIs(t, err, client.ErrInsufficientFunds)
+
+	err = alice.SendCoin(ctx, client.SendCoinRequest{ToUser: "bob", Amount: 0})
+	assert.ErrorIs(t, err, client.ErrBadRequest)
+
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+}
+
+func TestClient_SendCoinBatch(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ts := newTestServer(t)
+
+	alice := client.New(ts.URL, "alice", "password")
+
+	for _, name := range []string{"bob", "carol"} {
+		_, err := client.New(ts.URL, name, "password").Login(ctx)
+		require.NoError(t, err)
+	}
+
+	resp, err := alice.SendCoinBatch(ctx, client.SendCoinBatchRequest{Transfers: []client.SendCoinRequest{
+		{ToUser: "bob", Amount: 100},
+		{ToUser: "carol", Amount: 50, Memo: "thanks"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, 150, resp.Total)
+	assert.Equal(t, []client.SendCoinResult{
+		{ToUser: "bob", Amount: 100, Status: "applied"},
+		{ToUser: "carol", Amount: 50, Status: "applied"},
+	}, resp.Results)
+
+	_, err = alice.SendCoinBatch(ctx, client.SendCoinBatchRequest{Transfers: []client.SendCoinRequest{
+		{ToUser: "bob", Amount: 100},
+		{ToUser: "dave", Amount: 100},
+	}})
+	assert.ErrorIs(t, err, client.ErrBadRequest)
+
+	info, err := alice.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 850, info.Coins, "a rejected batch sends nothing")
+}
+
+func TestClient_PendingTransfers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ts := newTestServer(t)
+
+	alice := client.New(ts.URL, "alice", "password")
+	bob := client.New(ts.URL, "bob", "password")
+
+	_, err := bob.Login(ctx)
+	require.NoError(t, err)
+
+	transfer, err := alice.HoldTransfer(ctx, client.SendCoinRequest{ToUser: "bob", Amount: 100, Memo: "lunch"})
+	require.NoError(t, err)
+	assert.Equal(t, "lunch", transfer.Memo)
+
+	pending, err := bob.PendingTransfers(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending.Incoming, 1)
+	assert.Equal(t, transfer.ID, pending.Incoming[0].ID)
+	assert.Empty(t, pending.Outgoing)
+
+	assert.ErrorIs(t, alice.AcceptTransfer(ctx, transfer.ID), client.ErrNotFound)
+	require.NoError(t, bob.AcceptTransfer(ctx, transfer.ID))
+	assert.ErrorIs(t, bob.DeclineTransfer(ctx, transfer.ID), client.ErrConflict)
+
+	info, err := bob.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1100, info.Coins)
+}
+
+func TestClient_PaymentRequests(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ts := newTestServer(t)
+
+	alice := client.New(ts.URL, "alice", "password")
+	bob := client.New(ts.URL, "bob", "password")
+
+	_, err := bob.Login(ctx)
+	require.NoError(t, err)
+
+	request, err := alice.RequestPayment(ctx, client.RequestPaymentRequest{FromUser: "bob", Amount: 100, Memo: "lunch"})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", request.Requester)
+	assert.Equal(t, "bob", request.Payer)
+
+	requests, err := bob.PaymentRequests(ctx)
+	require.NoError(t, err)
+	require.Len(t, requests.Incoming, 1)
+	assert.Equal(t, request.ID, requests.Incoming[0].ID)
+	assert.Empty(t, requests.Outgoing)
+
+	assert.ErrorIs(t, alice.PayRequest(ctx, request.ID), client.ErrNotFound)
+	require.NoError(t, bob.PayRequest(ctx, request.ID))
+	assert.ErrorIs(t, bob.DeclineRequest(ctx, request.ID), client.ErrConflict)
+
+	info, err := alice.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1100, info.Coins)
+	assert.Contains(t, info.CoinHistory.Received, client.ReceivedCoins{FromUser: "bob", Amount: 100, Memo: "lunch"})
+}
+
+func TestClient_ScheduledTransfers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ts := newTestServer(t)
+
+	alice := client.New(ts.URL, "alice", "password")
+	charity := client.New(ts.URL, "charity", "password")
+
+	_, err := charity.Login(ctx)
+	require.NoError(t, err)
+
+	monthly, err := alice.ScheduleTransfer(ctx, client.ScheduleTransferRequest{
+		ToUser:   "charity",
+		Amount:   50,
+		Schedule: "@monthly",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "active", monthly.Status)
+	require.NotNil(t, monthly.NextRunAt)
+
+	_, err = alice.ScheduleTransfer(ctx, client.ScheduleTransferRequest{ToUser: "charity", Amount: 50})
+	require.ErrorIs(t, err, client.ErrBadRequest, "a one-off transfer needs a run time")
+
+	runs, err := alice.ScheduledTransferRuns(ctx, monthly.ID)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	require.NoError(t, alice.CancelScheduledTransfer(ctx, monthly.ID))
+	assert.ErrorIs(t, alice.CancelScheduledTransfer(ctx, monthly.ID), client.ErrConflict)
+	assert.ErrorIs(t, charity.CancelScheduledTransfer(ctx, monthly.ID), client.ErrNotFound)
+
+	transfers, err := alice.ScheduledTransfers(ctx)
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	assert.Equal(t, "cancelled", transfers[0].Status)
+	assert.Nil(t, transfers[0].NextRunAt)
+}
+
+func TestClient_Buy(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ts := newTestServer(t)
+
+	c := client.New(ts.URL, "alice", "password")
+
+	require.NoError(t, c.Buy(ctx, "cup"))
+
+	info, err := c.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 980, info.Coins)
+	assert.Equal(t, []client.Item{{Type: "cup", Quantity: 1}}, info.Inventory)
+
+	assert.ErrorIs(t, c.Buy(ctx, "yacht"), client.ErrNotFound)
+}
+
+func TestClient_Cart(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ts := newTestServer(t)
+
+	c := client.New(ts.URL, "alice", "password")
+
+	_, err := c.AddToCart(ctx, client.AddToCartRequest{Item: "cup", Quantity: 2})
+	require.NoError(t, err)
+	_, err = c.AddToCart(ctx, client.AddToCartRequest{Item: "pen"})
+	require.NoError(t, err)
+
+	cart, err := c.RemoveFromCart(ctx, "pen")
+	require.NoError(t, err)
+	assert.Equal(t, []client.CartItem{{Item: "cup", Quantity: 2, Price: 20, Total: 40}}, cart.Items)
+
+	_, err = c.RemoveFromCart(ctx, "pen")
+	require.ErrorIs(t, err, client.ErrNotFound)
+
+	bought, err := c.Checkout(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 40, bought.Total)
+
+	cart, err = c.Cart(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, cart.Items)
+
+	info, err := c.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 960, info.Coins)
+	assert.Equal(t, []client.Item{{Type: "cup", Quantity: 2}}, info.Inventory)
+
+	_, err = c.Checkout(ctx)
+	assert.ErrorIs(t, err, client.ErrBadRequest, "the cart is empty")
+}
+
+func TestClient_Variants(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ts := newTestServer(t)
+
+	item, err := ts.repo.FindItem(ctx, nil, "t-shirt")
+	require.NoError(t, err)
+
+	err = ts.repo.CreateVariant(ctx, nil, &model.Variant{
+		ItemID:            item.ID,
+		VariantAttributes: model.VariantAttributes{Size: "L", Color: "black"},
+		PriceDelta:        5,
+	})
+	require.NoError(t, err)
+
+	c := client.New(ts.URL, "alice", "password")
+
+	variants, err := c.Variants(ctx, "t-shirt")
+	require.NoError(t, err)
+	assert.Equal(t, []client.Variant{{Size: "L", Color: "black", Price: item.Price + 5}}, variants)
+
+	require.ErrorIs(t, c.Buy(ctx, "t-shirt"), client.ErrBadRequest, "a variant must be chosen")
+	require.ErrorIs(t, c.BuyVariant(ctx, "t-shirt", "L", ""), client.ErrNotFound)
+	require.NoError(t, c.BuyVariant(ctx, "t-shirt", "L", "black"))
+
+	_, err = c.AddToCart(ctx, client.AddToCartRequest{Item: "t-shirt", Size: "L", Color: "black"})
+	require.NoError(t, err)
+
+	cart, err := c.RemoveVariantFromCart(ctx, "t-shirt", "L", "black")
+	require.NoError(t, err)
+	assert.Empty(t, cart.Items)
+
+	info, err := c.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1000-item.Price-5, info.Coins)
+	assert.Equal(t, []client.Item{{Type: "t-shirt", Size: "L", Color: "black", Quantity: 1}}, info.Inventory)
+}
+
+func TestClient_IdempotencyKey(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ts := newTestServer(t)
+
+	c := client.New(ts.URL, "alice", "password")
+
+	for range 3 {
+		require.NoError(t, c.Buy(ctx, "cup", client.WithIdempotencyKey("order-1")))
+	}
+
+	info, err := c.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 980, info.Coins, "retries are not executed")
+
+	err = c.Buy(ctx, "pen", client.WithIdempotencyKey("order-1"))
+	assert.ErrorIs(t, err, client.ErrBadRequest, "key is bound to the first request")
+}
+
+func TestClient_Token(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("rejected token is replaced", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestServer(t)
+
+		c := client.New(ts.URL, "alice", "password")
+
+		_, err := c.Info(ctx)
+		require.NoError(t, err)
+
+		ts.reject.Store(true)
+
+		_, err = c.Info(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), ts.logins.Load())
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestServer(t)
+
+		_, err := client.New(ts.URL, "alice", "password").Login(ctx)
+		require.NoError(t, err)
+
+		_, err = client.New(ts.URL, "alice", "wrong").Info(ctx)
+		assert.ErrorIs(t, err, client.ErrUnauthorized)
+	})
+}
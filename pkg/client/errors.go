@@ -0,0 +1,62 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The API reports errors with these sentinels, match them with errors.Is.
+var (
+	ErrBadRequest          = errors.New("bad request")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrForbidden           = errors.New("forbidden")
+	ErrInternalServerError = errors.New("internal server error")
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrNotFound            = errors.New("not found")
+	ErrConflict            = errors.New("conflict")
+	ErrLimitExceeded       = errors.New("limit_exceeded")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:   ErrBadRequest,
+	http.StatusUnauthorized: ErrUnauthorized,
+	http.StatusForbidden:    ErrForbidden,
+	http.StatusNotFound:     ErrNotFound,
+	http.StatusConflict:     ErrConflict,
+}
+
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int
+	// Message is the error reported by the server, e.g. "insufficient
+	// funds: need 300 coins, has 200".
+	Message string
+
+	err error
+}
+
+func newError(statusCode int, message string) *Error {
+	err, ok := statusErrors[statusCode]
+
+	switch {
+	case strings.HasPrefix(message, ErrInsufficientFunds.Error()):
+		err = ErrInsufficientFunds
+	case strings.HasPrefix(message, ErrLimitExceeded.Error()):
+		err = ErrLimitExceeded
+	case !ok:
+		err = ErrInternalServerError
+	}
+
+	return &Error{StatusCode: statusCode, Message: message, err: err}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("shop api: %d %s", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the sentinel error matching the response.
+func (e *Error) Unwrap() error {
+	return e.err
+}
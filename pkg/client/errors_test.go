@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/esklo/avito-backend-winter-2025/internal/http/render"
+	"github.com/esklo/avito-backend-winter-2025/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status  int
+		message string
+		want    error
+	}{
+		{http.StatusBadRequest, "bad request: body: amount: number must be at least 1", ErrBadRequest},
+		{http.StatusBadRequest, "insufficient funds: need 300 coins, has 200", ErrInsufficientFunds},
+		{http.StatusBadRequest, "limit_exceeded: at most 100 coins per transfer", ErrLimitExceeded},
+		{http.StatusUnauthorized, "unauthorized: invalid token", ErrUnauthorized},
+		{http.StatusForbidden, "forbidden", ErrForbidden},
+		{http.StatusNotFound, "not found", ErrNotFound},
+		{http.StatusConflict, "conflict: request with this idempotency key is in progress", ErrConflict},
+		{http.StatusInternalServerError, "internal server error", ErrInternalServerError},
+		{http.StatusBadGateway, "Bad Gateway", ErrInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			t.Parallel()
+
+			err := newError(tt.status, tt.message)
+			assert.ErrorIs(t, err, tt.want)
+			assert.Equal(t, tt.status, err.StatusCode)
+
+			for _, other := range statusErrors {
+				if !errors.Is(tt.want, other) {
+					assert.NotErrorIs(t, err, other)
+				}
+			}
+		})
+	}
+}
+
+// TestNewError_MirrorsModel checks that every model error rendered by the
+// server is matched by its client counterpart.
+func TestNewError_MirrorsModel(t *testing.T) {
+	t.Parallel()
+
+	mirrors := map[error]error{
+		model.ErrBadRequest:          ErrBadRequest,
+		model.ErrUnauthorized:        ErrUnauthorized,
+		model.ErrForbidden:           ErrForbidden,
+		model.ErrInternalServerError: ErrInternalServerError,
+		model.ErrInsufficientFunds:   ErrInsufficientFunds,
+		model.ErrNotFound:            ErrNotFound,
+		model.ErrConflict:            ErrConflict,
+		model.ErrLimitExceeded:       ErrLimitExceeded,
+	}
+
+	for modelErr, want := range mirrors {
+		assert.Equal(t, modelErr.Error(), want.Error())
+
+		err := fmt.Errorf("%w: details", modelErr)
+		assert.ErrorIs(t, newError(render.StatusCode(err), err.Error()), want, modelErr.Error())
+	}
+}
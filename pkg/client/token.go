@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// refreshBefore is how long before its expiry a token is replaced.
+const refreshBefore = time.Minute
+
+// currentToken returns the token, logging in if there is none yet or it is
+// about to expire.
+func (c *Client) currentToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && (c.expiresAt.IsZero() || time.Until(c.expiresAt) > refreshBefore) {
+		return c.token, nil
+	}
+
+	return c.login(ctx)
+}
+
+// refreshToken replaces the rejected token unless a concurrent call has
+// already done so.
+func (c *Client) refreshToken(ctx context.Context, rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != rejected {
+		return c.token, nil
+	}
+
+	return c.login(ctx)
+}
+
+// login must be called with mu held.
+func (c *Client) login(ctx context.Context) (string, error) {
+	body, err := json.Marshal(AuthRequest{Username: c.username, Password: c.password, ReferralCode: c.referral})
+	if err != nil {
+		return "", fmt.Errorf("encode request: %w", err)
+	}
+
+	var resp AuthResponse
+	if err := c.send(ctx, http.MethodPost, "/api/auth", "", body, &resp, requestOptions{}); err != nil {
+		return "", err
+	}
+
+	c.token = resp.Token
+	c.expiresAt = tokenExpiry(resp.Token)
+
+	return c.token, nil
+}
+
+// tokenExpiry reads the exp claim of a JWT without verifying it, the server
+// does that. It returns the zero time if the claim is missing.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(claims.ExpiresAt, 0)
+}
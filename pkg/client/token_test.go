@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newToken(t *testing.T, expiresAt time.Time) string {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]any{"sub": "alice", "exp": expiresAt.Unix()})
+	require.NoError(t, err)
+
+	return "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestTokenExpiry(t *testing.T) {
+	t.Parallel()
+
+	expiresAt := time.Unix(time.Now().Add(time.Hour).Unix(), 0)
+
+	assert.Equal(t, expiresAt, tokenExpiry(newToken(t, expiresAt)))
+	assert.True(t, tokenExpiry("opaque").IsZero())
+	assert.True(t, tokenExpiry("e30.!!!.sig").IsZero())
+}
+
+func TestClient_currentToken(t *testing.T) {
+	t.Parallel()
+
+	var logins atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := logins.Add(1)
+		// The first token is about to expire, the second one is not.
+		expiresAt := time.Now().Add(time.Duration(n-1) * time.Hour).Add(refreshBefore / 2)
+
+		_, _ = fmt.Fprintf(w, `{"token": %q}`, newToken(t, expiresAt))
+	}))
+	t.Cleanup(ts.Close)
+
+	c := New(ts.URL, "alice", "password")
+	ctx := context.Background()
+
+	first, err := c.currentToken(ctx)
+	require.NoError(t, err)
+
+	second, err := c.currentToken(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second, "token about to expire is replaced")
+
+	third, err := c.currentToken(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, second, third)
+	assert.Equal(t, int32(2), logins.Load())
+}
@@ -0,0 +1,214 @@
+package client
+
+import "time"
+
+// AuthRequest is the body of POST /api/auth. ReferralCode is only used when
+// the request registers the user.
+type AuthRequest struct {
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	ReferralCode string `json:"referralCode,omitempty"`
+}
+
+// AuthResponse is the response to POST /api/auth.
+type AuthResponse struct {
+	Token string `json:"token"`
+}
+
+// SendCoinRequest is the body of POST /api/sendCoin.
+type SendCoinRequest struct {
+	ToUser string `json:"toUser"`
+	Amount int    `json:"amount"`
+	// Memo is an optional message to the receiver.
+	Memo string `json:"memo,omitempty"`
+}
+
+// SendCoinBatchRequest is the body of POST /api/sendCoin/batch.
+type SendCoinBatchRequest struct {
+	Transfers []SendCoinRequest `json:"transfers"`
+}
+
+// SendCoinBatchResponse is the response to POST /api/sendCoin/batch.
+type SendCoinBatchResponse struct {
+	// Total is the number of coins sent by the batch.
+	Total   int              `json:"total"`
+	Results []SendCoinResult `json:"results"`
+}
+
+// SendCoinResult is the result of a transfer of a batch. Status is
+// "applied", "failed" or "skipped", a failed transfer has an Error.
+type SendCoinResult struct {
+	ToUser string `json:"toUser"`
+	Amount int    `json:"amount"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PendingTransfer is a transfer whose coins are held until the receiver
+// accepts it, the response to POST /api/pending-transfers.
+type PendingTransfer struct {
+	ID        int64     `json:"id"`
+	FromUser  string    `json:"fromUser"`
+	ToUser    string    `json:"toUser"`
+	Amount    int       `json:"amount"`
+	Memo      string    `json:"memo,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PendingTransfersResponse is the response to GET /api/pending-transfers.
+type PendingTransfersResponse struct {
+	Incoming []PendingTransfer `json:"incoming"`
+	Outgoing []PendingTransfer `json:"outgoing"`
+}
+
+// RequestPaymentRequest is the body of POST /api/payment-requests, asking
+// FromUser for Amount coins.
+type RequestPaymentRequest struct {
+	FromUser string `json:"fromUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+}
+
+// PaymentRequest asks the payer to send coins to the requester, the response
+// to POST /api/payment-requests.
+type PaymentRequest struct {
+	ID        int64     `json:"id"`
+	Requester string    `json:"requester"`
+	Payer     string    `json:"payer"`
+	Amount    int       `json:"amount"`
+	Memo      string    `json:"memo,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PaymentRequestsResponse is the response to GET /api/payment-requests.
+type PaymentRequestsResponse struct {
+	Incoming []PaymentRequest `json:"incoming"`
+	Outgoing []PaymentRequest `json:"outgoing"`
+}
+
+// ScheduleTransferRequest is the body of POST /api/scheduled-transfers. A
+// transfer without a Schedule, a cron expression in UTC, is made once at
+// RunAt. A recurring one starts at RunAt if it is set.
+type ScheduleTransferRequest struct {
+	ToUser   string     `json:"toUser"`
+	Amount   int        `json:"amount"`
+	Memo     string     `json:"memo,omitempty"`
+	RunAt    *time.Time `json:"runAt,omitempty"`
+	Schedule string     `json:"schedule,omitempty"`
+}
+
+// ScheduledTransfer is a transfer set up to be made later. Status is
+// "active", "completed", "failed" or "cancelled", only an active transfer
+// has a NextRunAt.
+type ScheduledTransfer struct {
+	ID        int64      `json:"id"`
+	ToUser    string     `json:"toUser"`
+	Amount    int        `json:"amount"`
+	Memo      string     `json:"memo,omitempty"`
+	Schedule  string     `json:"schedule,omitempty"`
+	Status    string     `json:"status"`
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// ScheduledTransferRun is a run of a scheduled transfer. Status is
+// "succeeded" or "failed", a failed run has an Error.
+type ScheduledTransferRun struct {
+	ScheduledAt time.Time `json:"scheduledAt"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// AddToCartRequest is the body of POST /api/cart/items. Quantity defaults to
+// one. Size and Color choose the variant of an item with variants.
+type AddToCartRequest struct {
+	Item     string `json:"item"`
+	Size     string `json:"size,omitempty"`
+	Color    string `json:"color,omitempty"`
+	Quantity int    `json:"quantity,omitempty"`
+}
+
+// CartResponse is the cart of the user at the current prices, the response to
+// the cart endpoints and, listing what was bought, to POST /api/checkout.
+type CartResponse struct {
+	Items []CartItem `json:"items"`
+	Total int        `json:"total"`
+}
+
+// CartItem is a line of the cart. Price is the price of one item, Total that
+// of the line. Stock is the number of items left, nil if the item never runs
+// out. Size and Color are set for a variant.
+type CartItem struct {
+	Item     string `json:"item"`
+	Size     string `json:"size,omitempty"`
+	Color    string `json:"color,omitempty"`
+	Quantity int    `json:"quantity"`
+	Price    int    `json:"price"`
+	Total    int    `json:"total"`
+	Stock    *int   `json:"stock,omitempty"`
+}
+
+// InfoResponse is the response to GET /api/info.
+type InfoResponse struct {
+	Coins       int         `json:"coins"`
+	Inventory   []Item      `json:"inventory"`
+	CoinHistory CoinHistory `json:"coinHistory"`
+}
+
+// Item sums the items of a type the user bought, each variant is listed on
+// its own.
+type Item struct {
+	Type     string `json:"type"`
+	Size     string `json:"size,omitempty"`
+	Color    string `json:"color,omitempty"`
+	Quantity int    `json:"quantity"`
+}
+
+// Variant is a version of an item that can be bought, listed by
+// GET /api/items/{name}/variants. Price includes the price of the item. Stock
+// is the number of items left, nil if the variant never runs out.
+type Variant struct {
+	Size  string `json:"size,omitempty"`
+	Color string `json:"color,omitempty"`
+	Price int    `json:"price"`
+	Stock *int   `json:"stock,omitempty"`
+}
+
+type CoinHistory struct {
+	Received []ReceivedCoins `json:"received"`
+	Sent     []SentCoins     `json:"sent"`
+}
+
+// ReceivedCoins sums the coins received from a user. A transfer with a memo
+// is listed on its own, and so is a reversal of a transfer the user sent.
+type ReceivedCoins struct {
+	FromUser string `json:"fromUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+	Reversal bool   `json:"reversal,omitempty"`
+}
+
+// SentCoins sums the coins sent to a user. A transfer with a memo is listed
+// on its own, and so is a reversal of a transfer the user received.
+type SentCoins struct {
+	ToUser   string `json:"toUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+	Reversal bool   `json:"reversal,omitempty"`
+}
+
+// ReferralResponse is the response to GET /api/referral.
+type ReferralResponse struct {
+	Code      string `json:"code"`
+	Bonus     int    `json:"bonus"`
+	Rewarded  int    `json:"rewarded"`
+	Remaining int    `json:"remaining"`
+}
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Errors string `json:"errors"`
+}
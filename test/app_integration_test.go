@@ -4,16 +4,24 @@ package test
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/esklo/avito-backend-winter-2025/internal/config"
+	"github.com/esklo/avito-backend-winter-2025/internal/migrate"
 	"github.com/esklo/avito-backend-winter-2025/internal/model"
 	"github.com/esklo/avito-backend-winter-2025/internal/repository"
+	"github.com/esklo/avito-backend-winter-2025/internal/repository/repositorytest"
 	"github.com/esklo/avito-backend-winter-2025/internal/service"
 	"github.com/esklo/avito-backend-winter-2025/internal/service/auth"
 	"github.com/esklo/avito-backend-winter-2025/internal/service/hasher"
 	"github.com/esklo/avito-backend-winter-2025/internal/service/shop"
 	"github.com/esklo/avito-backend-winter-2025/internal/service/user"
+	"github.com/esklo/avito-backend-winter-2025/migrations"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -28,6 +36,7 @@ type testSuite struct {
 	users  service.UserManager
 	hasher service.Hasher
 	repo   repository.Repository
+	db     *pgxpool.Pool
 
 	cleanup func()
 }
@@ -36,7 +45,6 @@ func setupTestDB(t *testing.T) (*pgxpool.Pool, func()) {
 	ctx := context.Background()
 	container, err := postgres.Run(ctx,
 		"postgres:13",
-		postgres.WithInitScripts("../migrations/init.sql"),
 		postgres.WithDatabase("test_db"),
 		postgres.WithUsername("test_user"),
 		postgres.WithPassword("test_password"),
@@ -58,6 +66,10 @@ func setupTestDB(t *testing.T) (*pgxpool.Pool, func()) {
 	db, err := pgxpool.New(ctx, conn)
 	require.NoError(t, err)
 
+	m, err := migrate.New(db, migrations.FS, slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, m.Up(ctx))
+
 	return db, cleanup
 }
 
@@ -67,18 +79,22 @@ func newTestSuite(t *testing.T) *testSuite {
 	ts := &testSuite{
 		hasher:  hasher.NewArgon2(),
 		repo:    repository.New(db),
+		db:      db,
 		cleanup: cleanup,
 	}
 
 	ts.shop = shop.NewService(ts.repo)
-	ts.users = user.NewService(ts.repo, ts.hasher)
-	ts.auth = auth.NewService(ts.repo, ts.users, ts.hasher, []byte("test-secret"))
+	cfg := config.Default()
+	ts.users = user.NewService(
+		ts.repo, ts.hasher, cfg.Signup, model.TransferLimits{}, cfg.PendingTransfers, cfg.PaymentRequests,
+	)
+	ts.auth = auth.NewService(ts.repo, ts.users, ts.hasher, []byte("test-secret"), nil)
 
 	return ts
 }
 
 func (ts *testSuite) createTestUser(t *testing.T, username string) *model.User {
-	_, err := ts.auth.Login(context.Background(), username, "test_password")
+	_, err := ts.auth.Login(context.Background(), username, "test_password", "")
 	require.NoError(t, err)
 
 	u, err := ts.repo.FindUser(context.Background(), nil, username)
@@ -97,17 +113,26 @@ func TestAuthenticatorIntegration(t *testing.T) {
 		t.Parallel()
 		ctx := context.Background()
 
-		_, err := suite.auth.Login(ctx, "user", "password")
+		_, err := suite.auth.Login(ctx, "user", "password", "")
 		assert.NoError(t, err)
 
-		_, err = suite.auth.Login(ctx, "user", "password1")
+		_, err = suite.auth.Login(ctx, "user", "password1", "")
 		assert.ErrorIs(t, err, model.ErrUnauthorized)
 
-		_, err = suite.auth.Login(ctx, "user", "password")
+		_, err = suite.auth.Login(ctx, "user", "password", "")
 		assert.NoError(t, err)
 	})
 }
 
+func TestRepositoryContractIntegration(t *testing.T) {
+	t.Parallel()
+
+	suite := newTestSuite(t)
+	t.Cleanup(suite.cleanup)
+
+	repositorytest.Run(t, suite.repo)
+}
+
 func TestShopIntegration(t *testing.T) {
 	t.Parallel()
 
@@ -123,7 +148,7 @@ func TestShopIntegration(t *testing.T) {
 		infoBefore, err := suite.users.Info(ctx, u.Username)
 		require.NoError(t, err)
 
-		err = suite.shop.BuyItem(ctx, "pink-hoody", u.Username)
+		err = suite.shop.BuyItem(ctx, "pink-hoody", model.VariantAttributes{}, u.Username)
 		require.NoError(t, err)
 
 		infoAfter, err := suite.users.Info(ctx, u.Username)
@@ -150,7 +175,7 @@ func TestTransactionIntegration(t *testing.T) {
 		user1 := suite.createTestUser(t, "sender")
 		user2 := suite.createTestUser(t, "receiver")
 
-		err := suite.users.Transfer(ctx, user1.Username, user2.Username, 100)
+		_, err := suite.users.Transfer(ctx, user1.Username, user2.Username, 100, "")
 		require.NoError(t, err)
 
 		info1, err := suite.users.Info(ctx, user1.Username)
@@ -171,3 +196,82 @@ func TestTransactionIntegration(t *testing.T) {
 		})
 	})
 }
+
+func TestConcurrencyIntegration(t *testing.T) {
+	t.Parallel()
+
+	suite := newTestSuite(t)
+	t.Cleanup(suite.cleanup)
+
+	t.Run("coin supply is conserved", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		const (
+			numUsers   = 5
+			numWorkers = 20
+			numOps     = 50
+		)
+
+		usernames := make([]string, numUsers)
+		for i := range usernames {
+			usernames[i] = fmt.Sprintf("stress-%d", i)
+			suite.createTestUser(t, usernames[i])
+		}
+
+		supplyBefore := suite.coinSupply(t)
+
+		var wg sync.WaitGroup
+
+		errs := make(chan error, numWorkers*numOps)
+
+		for range numWorkers {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for range numOps {
+					from := usernames[rand.IntN(numUsers)]
+					to := usernames[rand.IntN(numUsers)]
+
+					var err error
+					if from == to {
+						err = suite.shop.BuyItem(ctx, "cup", model.VariantAttributes{}, from)
+					} else {
+						_, err = suite.users.Transfer(ctx, from, to, 1+rand.IntN(300), "")
+					}
+
+					if err != nil {
+						errs <- err
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+		}
+
+		assert.Equal(t, supplyBefore, suite.coinSupply(t))
+	})
+}
+
+// coinSupply is the sum of all balances plus the coins spent on purchases.
+func (ts *testSuite) coinSupply(t *testing.T) int {
+	var supply int
+
+	err := ts.db.QueryRow(context.Background(), `
+		SELECT
+			(SELECT coalesce(sum(balance), 0) FROM users) +
+			(SELECT coalesce(sum(purchases.quantity * items.price), 0)
+			 FROM purchases
+			 JOIN items ON items.id = purchases.item_id);
+	`).Scan(&supply)
+	require.NoError(t, err)
+
+	return supply
+}